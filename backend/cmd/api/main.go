@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Analytics timezones must resolve even without system zoneinfo
 
 	"backend/internal/server"
 )
@@ -37,6 +39,16 @@ func gracefulShutdown(app *server.App, done chan bool) {
 	done <- true
 }
 
+// reloadOnHangup reloads the app's configuration files on every SIGHUP
+func reloadOnHangup(app *server.App) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		app.Reload()
+	}
+}
+
 func main() {
 	app := server.NewServer()
 
@@ -45,6 +57,7 @@ func main() {
 
 	// Run graceful shutdown in a separate goroutine
 	go gracefulShutdown(app, done)
+	go reloadOnHangup(app)
 
 	err := app.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
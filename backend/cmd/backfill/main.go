@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/shortener"
+)
+
+// backfill recomputes the analytics rollup tables for a range of days, e.g.
+// after first deploying rollups or after importing historical clicks.
+func main() {
+	fromFlag := flag.String("from", "", "first UTC day to backfill (YYYY-MM-DD), defaults to the earliest click")
+	toFlag := flag.String("to", "", "last UTC day to backfill (YYYY-MM-DD), defaults to today")
+	flag.Parse()
+
+	db := database.New()
+	defer db.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	from, err := parseDay(*fromFlag)
+	if err != nil {
+		log.Fatalf("[BACKFILL] FATAL: Invalid -from: %v", err)
+	}
+	if from.IsZero() {
+		if from, err = db.GetEarliestClickTime(ctx); err != nil {
+			log.Fatalf("[BACKFILL] FATAL: %v", err)
+		}
+		if from.IsZero() {
+			log.Printf("[BACKFILL] No clicks recorded, nothing to backfill")
+			return
+		}
+	}
+
+	to, err := parseDay(*toFlag)
+	if err != nil {
+		log.Fatalf("[BACKFILL] FATAL: Invalid -to: %v", err)
+	}
+	if to.IsZero() {
+		to = now
+	} else {
+		to = to.AddDate(0, 0, 1) // Include the whole last day
+	}
+
+	log.Printf("[BACKFILL] Backfilling rollups from %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if err := shortener.NewRollup(db).Backfill(ctx, from, to, now); err != nil {
+		log.Fatalf("[BACKFILL] FATAL: Backfill failed: %v", err)
+	}
+}
+
+// parseDay parses an optional YYYY-MM-DD flag as a UTC day
+func parseDay(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/models"
+)
+
+// AuditQuery selects audit entries, newest first. Pages are chained with
+// BeforeID, the ID of the last entry already read.
+type AuditQuery struct {
+	ShortCode string // Empty for all links
+	Action    string // Empty for all actions
+	Actor     string // Empty for all actors
+	From      time.Time
+	To        time.Time
+	BeforeID  int64 // 0 starts from the newest entry
+	Limit     int
+}
+
+// RecordAudit appends an entry to the audit log
+func (r *Repository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	var changes []byte
+	if len(entry.Changes) > 0 {
+		var err error
+		if changes, err = json.Marshal(entry.Changes); err != nil {
+			return fmt.Errorf("failed to encode audit changes: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO url_audit_log (url_id, short_code, action, actor, ip, user_agent, changes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, occurred_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		entry.URLID,
+		entry.ShortCode,
+		entry.Action,
+		entry.Actor,
+		entry.IP,
+		entry.UserAgent,
+		changes,
+	).Scan(&entry.ID, &entry.OccurredAt)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to record audit entry %s for %s: %v", entry.Action, entry.ShortCode, err)
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditLog returns audit entries matching q, newest first
+func (r *Repository) GetAuditLog(ctx context.Context, q *AuditQuery) ([]models.AuditEntry, error) {
+	query := `
+		SELECT id, url_id, short_code, action, actor, host(ip), user_agent, changes::text, occurred_at
+		FROM url_audit_log
+		WHERE ($1 = '' OR short_code = $1)
+		AND ($2 = '' OR action = $2)
+		AND ($3 = '' OR actor = $3)
+		AND occurred_at >= $4
+		AND occurred_at < $5
+		AND ($6 = 0 OR id < $6)
+		ORDER BY id DESC
+		LIMIT $7`
+
+	rows, err := r.db.QueryContext(ctx, query, q.ShortCode, q.Action, q.Actor, q.From, q.To, q.BeforeID, q.Limit)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to read audit log: %v", err)
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.AuditEntry, 0, q.Limit)
+	for rows.Next() {
+		var e models.AuditEntry
+		var changes *string
+		err := rows.Scan(&e.ID, &e.URLID, &e.ShortCode, &e.Action, &e.Actor, &e.IP, &e.UserAgent, &changes, &e.OccurredAt)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan audit entry: %v", err)
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if changes != nil {
+			if err := json.Unmarshal([]byte(*changes), &e.Changes); err != nil {
+				return nil, fmt.Errorf("failed to decode audit changes of entry %d: %w", e.ID, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return entries, nil
+}
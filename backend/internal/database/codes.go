@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var (
+	// ErrShortCodeExists is returned when inserting a link whose code is
+	// taken, reserved or retired
+	ErrShortCodeExists = errors.New("short code already exists")
+
+	// ErrReservedCodeExists is returned when reserving a code or pattern twice
+	ErrReservedCodeExists = errors.New("reserved code already exists")
+)
+
+// AllocateCodeIDs leases count unused ids from the short code sequence. The
+// ids are unique across instances but not necessarily contiguous.
+func (r *Repository) AllocateCodeIDs(ctx context.Context, count int) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT nextval('short_code_seq') FROM generate_series(1, $1)`, count)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to allocate %d code ids: %v", count, err)
+		return nil, fmt.Errorf("failed to allocate code ids: %w", err)
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, count)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan code id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to allocate code ids: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] Allocated %d code ids", len(ids))
+	return ids, nil
+}
+
+// CountCodesByLength returns how many codes of each length are in use: by
+// links, including deleted ones, and by tombstones of purged links
+func (r *Repository) CountCodesByLength(ctx context.Context) (map[int]int64, error) {
+	query := `
+		SELECT length(short_code), count(*)
+		FROM (
+			SELECT short_code FROM urls
+			UNION ALL
+			SELECT short_code FROM code_tombstones
+		) codes
+		GROUP BY 1`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to count codes by length: %v", err)
+		return nil, fmt.Errorf("failed to count codes: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64)
+	for rows.Next() {
+		var length int
+		var count int64
+		if err := rows.Scan(&length, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan code count: %w", err)
+		}
+		counts[length] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count codes: %w", err)
+	}
+	return counts, nil
+}
+
+// GetCodeLength returns the stored length of generated codes of an alphabet,
+// or 0 if none was stored
+func (r *Repository) GetCodeLength(ctx context.Context, alphabet string) (int, error) {
+	var length int
+	err := r.db.QueryRowContext(ctx, `SELECT length FROM code_lengths WHERE alphabet = $1`, alphabet).Scan(&length)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get code length: %w", err)
+	}
+	return length, nil
+}
+
+// SetCodeLength stores the length of generated codes of an alphabet if the
+// stored length is still from, 0 meaning none was stored. It reports false
+// if another instance changed the length first.
+func (r *Repository) SetCodeLength(ctx context.Context, alphabet string, from, length int) (bool, error) {
+	query := `
+		INSERT INTO code_lengths (alphabet, length, updated_at)
+		VALUES ($1, $3, now())
+		ON CONFLICT (alphabet) DO UPDATE SET length = EXCLUDED.length, updated_at = EXCLUDED.updated_at
+		WHERE code_lengths.length = $2`
+
+	result, err := r.db.ExecContext(ctx, query, alphabet, from, length)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to store %s code length %d: %v", alphabet, length, err)
+		return false, fmt.Errorf("failed to set code length: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		log.Printf("[REPOSITORY] WARNING: %s code length changed from %d concurrently, not storing %d", alphabet, from, length)
+		return false, nil
+	}
+	log.Printf("[REPOSITORY] SUCCESS: Stored %s code length %d", alphabet, length)
+	return true, nil
+}
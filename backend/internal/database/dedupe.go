@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/models"
+)
+
+// CreateOrGetURL returns the live link of url's owner to the same target with
+// the same expiry, or creates url if there is none. Anonymous links have no
+// owner to match and are never reused. It reports whether url
+// was created. The code of a new link comes from newCode, which is only
+// called when no link is reused. Concurrent calls for one target are
+// serialized by a transaction advisory lock, so at most one of them creates
+// a link.
+func (r *Repository) CreateOrGetURL(ctx context.Context, url *models.URL, newCode func(context.Context) (string, error)) (*models.URL, bool, error) {
+	log.Printf("[REPOSITORY] Creating or reusing URL: TargetURL=%s", url.TargetURL)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, hashtext($2))`, LockNamespaceDedupe, url.TargetURL); err != nil {
+		return nil, false, fmt.Errorf("failed to lock target: %w", err)
+	}
+
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE target_hash = sha256(convert_to($1, 'UTF8')) AND target_url = $1
+			AND owner_id = $2 AND expires_at IS NOT DISTINCT FROM $3
+			AND is_active AND deleted_at IS NULL AND quarantined_at IS NULL
+			AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY id
+		LIMIT 1`
+
+	existing, err := scanURL(tx.QueryRowContext(ctx, query, url.TargetURL, url.OwnerID, url.ExpiresAt))
+	if err == nil {
+		log.Printf("[REPOSITORY] SUCCESS: Reusing URL ID=%d, ShortCode=%s", existing.ID, existing.ShortCode)
+		return existing, false, tx.Commit()
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[REPOSITORY] ERROR: Failed to look up links to %s: %v", url.TargetURL, err)
+		return nil, false, fmt.Errorf("failed to look up existing URL: %w", err)
+	}
+
+	if url.ShortCode, err = newCode(ctx); err != nil {
+		return nil, false, err
+	}
+
+	err = tx.QueryRowContext(ctx, insertURLQuery,
+		url.ShortCode,
+		url.TargetURL,
+		url.IsActive,
+		time.Now(),
+		url.ExpiresAt,
+		url.OwnerID,
+	).Scan(&url.ID, &url.CreatedAt, &url.Revision)
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Printf("[REPOSITORY] ERROR: Short code collision for %s: %v", url.ShortCode, err)
+			return nil, false, fmt.Errorf("%w: %s", ErrShortCodeExists, url.ShortCode)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to create URL %s: %v", url.ShortCode, err)
+		return nil, false, fmt.Errorf("failed to create URL: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit URL: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Created URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
+	url.LogCreation()
+	return url, true, nil
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+)
+
+// ErasureQuery selects the click events of one data subject. At least one of
+// IPs and UserAgent must be set; when both are, events must match both.
+type ErasureQuery struct {
+	IPs       []string // Any of these addresses, as stored (raw or anonymized)
+	UserAgent string   // Exact user agent string
+}
+
+// SoftDeleteURL marks a URL as deleted, starting its grace period. Deleted
+// URLs keep their row, and therefore their short code, until purged.
+func (r *Repository) SoftDeleteURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	log.Printf("[REPOSITORY] Soft-deleting URL: %s", shortCode)
+
+	query := `
+		UPDATE urls
+		SET deleted_at = now()
+		WHERE short_code = $1 AND deleted_at IS NULL
+		RETURNING ` + urlColumns
+
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, shortCode))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("[REPOSITORY] No undeleted URL to delete: %s", shortCode)
+			return nil, fmt.Errorf("URL not found: %s", shortCode)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to delete URL %s: %v", shortCode, err)
+		return nil, fmt.Errorf("failed to delete URL: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Soft-deleted URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
+	return url, nil
+}
+
+// RestoreURL undoes a soft delete made at or after deletedAfter. Links
+// deleted earlier are past their grace period and are not restored.
+func (r *Repository) RestoreURL(ctx context.Context, shortCode string, deletedAfter time.Time) (*models.URL, error) {
+	log.Printf("[REPOSITORY] Restoring URL: %s", shortCode)
+
+	query := `
+		UPDATE urls
+		SET deleted_at = NULL
+		WHERE short_code = $1 AND deleted_at >= $2
+		RETURNING ` + urlColumns
+
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, shortCode, deletedAfter))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("[REPOSITORY] No restorable URL: %s", shortCode)
+			return nil, fmt.Errorf("URL not found: %s", shortCode)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to restore URL %s: %v", shortCode, err)
+		return nil, fmt.Errorf("failed to restore URL: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Restored URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
+	return url, nil
+}
+
+// PurgeDeletedURLs permanently deletes up to limit URLs soft-deleted before
+// cutoff, together with their clicks, counters and rollups, and tombstones
+// their codes in the same statement so they are never reissued. It returns
+// the purged codes.
+func (r *Repository) PurgeDeletedURLs(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	log.Printf("[REPOSITORY] Purging URLs deleted before %s (limit: %d)", cutoff.Format(time.RFC3339), limit)
+
+	query := `
+		WITH purged AS (
+			DELETE FROM urls
+			WHERE id IN (
+				SELECT id
+				FROM urls
+				WHERE deleted_at < $1
+				ORDER BY deleted_at
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING short_code
+		)
+		INSERT INTO code_tombstones (short_code)
+		SELECT short_code FROM purged
+		ON CONFLICT (short_code) DO UPDATE SET deleted_at = now()
+		RETURNING short_code`
+
+	rows, err := r.db.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to purge deleted URLs: %v", err)
+		return nil, fmt.Errorf("failed to purge deleted URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan purged code: %v", err)
+			return nil, fmt.Errorf("failed to scan purged code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Purged %d deleted URLs", len(codes))
+	return codes, nil
+}
+
+// IsCodeTombstoned reports whether a code belonged to a purged URL
+func (r *Repository) IsCodeTombstoned(ctx context.Context, code string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM code_tombstones WHERE short_code = $1)`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, code).Scan(&exists); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to check tombstone for %s: %v", code, err)
+		return false, fmt.Errorf("failed to check code tombstone: %w", err)
+	}
+	return exists, nil
+}
+
+// EraseClickEvents deletes every click event matching q across all links and
+// returns how many were deleted. Aggregates in the rollup tables and unique
+// visitor sketches hold no personal data and are kept.
+func (r *Repository) EraseClickEvents(ctx context.Context, q *ErasureQuery) (int64, error) {
+	var conditions []string
+	var args []interface{}
+
+	if len(q.IPs) > 0 {
+		args = append(args, q.IPs)
+		conditions = append(conditions, fmt.Sprintf("ip = ANY($%d::inet[])", len(args)))
+	}
+	if q.UserAgent != "" {
+		args = append(args, q.UserAgent)
+		conditions = append(conditions, fmt.Sprintf("ua = $%d", len(args)))
+	}
+	if len(conditions) == 0 {
+		return 0, fmt.Errorf("erasure query needs an IP or a user agent")
+	}
+
+	log.Printf("[REPOSITORY] Erasing click events (%d IPs, user agent: %v)", len(q.IPs), q.UserAgent != "")
+
+	result, err := r.db.ExecContext(ctx,
+		`DELETE FROM click_events WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to erase click events: %v", err)
+		return 0, fmt.Errorf("failed to erase click events: %w", err)
+	}
+
+	erased, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify erasure: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Erased %d click events", erased)
+	return erased, nil
+}
+
+// urlColumns lists the columns read by scanURL, in order
+const urlColumns = `id, short_code, target_url, is_active, created_at, expires_at, deleted_at, revision,
+	quarantined_at, quarantine_reason, probe_status, probe_status_code, final_url, probed_at, owner_id`
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURL scans a row of urlColumns
+func scanURL(row rowScanner) (*models.URL, error) {
+	url := &models.URL{}
+	err := row.Scan(
+		&url.ID,
+		&url.ShortCode,
+		&url.TargetURL,
+		&url.IsActive,
+		&url.CreatedAt,
+		&url.ExpiresAt,
+		&url.DeletedAt,
+		&url.Revision,
+		&url.QuarantinedAt,
+		&url.QuarantineReason,
+		&url.ProbeStatus,
+		&url.ProbeStatusCode,
+		&url.FinalURL,
+		&url.ProbedAt,
+		&url.OwnerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return url, nil
+}
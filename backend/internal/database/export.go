@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/models"
+)
+
+// ClickExportQuery selects one page of raw click events, ordered by
+// (occurred_at, id). Pages are chained with a keyset cursor: the next page
+// starts after the last row of the previous one.
+type ClickExportQuery struct {
+	URLID       int64     // Restrict to one link; 0 for all links
+	From        time.Time // Inclusive
+	To          time.Time // Exclusive
+	IncludeBots bool
+	AfterTime   time.Time // Cursor: occurred_at of the last row already read
+	AfterID     int64     // Cursor: id of the last row already read
+	Limit       int
+}
+
+// GetClickEventsPage returns the next page of click events for an export
+func (r *Repository) GetClickEventsPage(ctx context.Context, q *ClickExportQuery) ([]models.ClickEvent, error) {
+	query := `
+		SELECT id, url_id, occurred_at, host(ip), ua, referrer,
+			utm_source, utm_medium, utm_campaign, utm_term, utm_content, query_params::text,
+			country, region, city, browser, browser_version, os, device_type, is_bot, revision
+		FROM click_events
+		WHERE ($1 = 0 OR url_id = $1)
+		AND occurred_at >= $2
+		AND occurred_at < $3
+		AND ($4 OR NOT is_bot)
+		AND (occurred_at, id) > ($5, $6)
+		ORDER BY occurred_at, id
+		LIMIT $7
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, q.URLID, q.From, q.To, q.IncludeBots, q.AfterTime, q.AfterID, q.Limit)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to read click events page: %v", err)
+		return nil, fmt.Errorf("failed to read click events: %w", err)
+	}
+	defer rows.Close()
+
+	clicks := make([]models.ClickEvent, 0, q.Limit)
+	for rows.Next() {
+		var c models.ClickEvent
+		err := rows.Scan(
+			&c.ID, &c.URLID, &c.OccurredAt, &c.IP, &c.UserAgent, &c.Referrer,
+			&c.UTMSource, &c.UTMMedium, &c.UTMCampaign, &c.UTMTerm, &c.UTMContent, &c.QueryParams,
+			&c.Country, &c.Region, &c.City, &c.Browser, &c.BrowserVersion, &c.OS, &c.DeviceType, &c.IsBot, &c.Revision,
+		)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan click event: %v", err)
+			return nil, fmt.Errorf("failed to scan click event: %w", err)
+		}
+		clicks = append(clicks, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return clicks, nil
+}
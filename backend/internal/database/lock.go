@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Advisory lock keys for jobs that must only run on one instance at a time
+const (
+	LockExpirySweep          int64 = 0x75726c0001 // "url" + job number
+	LockDeletionPurge        int64 = 0x75726c0002
+	LockURLRescan            int64 = 0x75726c0003
+	LockLinkCheck            int64 = 0x75726c0004
+	LockRollup               int64 = 0x75726c0005
+	LockPartitionMaintenance int64 = 0x75726c0006
+	LockCodeLength           int64 = 0x75726c0007
+)
+
+// LockNamespaceDedupe is the first key of the transaction locks serializing
+// creation of links to one target; the second is a hash of the target. Locks
+// on a pair of keys never conflict with single-key job locks.
+const LockNamespaceDedupe int32 = 0x75726c
+
+// WithAdvisoryLock runs fn while holding a Postgres session advisory lock on
+// key. If another session holds the lock, fn is not run and false is returned.
+// The lock lives on a dedicated connection and is released when fn returns.
+func (r *Repository) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock connection: %w", err)
+	}
+	defer conn.Close()
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&acquired); err != nil {
+		return false, fmt.Errorf("failed to take advisory lock %d: %w", key, err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	defer func() {
+		// Unlock even if ctx was cancelled, or the pooled connection would keep the lock
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			log.Printf("[REPOSITORY] WARNING: Failed to release advisory lock %d: %v", key, err)
+		}
+	}()
+
+	return true, fn(ctx)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// ClickNotifyChannel is the Postgres NOTIFY channel that fans live clicks out
+// to every API instance
+const ClickNotifyChannel = "click_live"
+
+// NotifyClick publishes a live click payload to every listening instance.
+// Postgres limits payloads to 8000 bytes.
+func (r *Repository) NotifyClick(ctx context.Context, payload string) error {
+	if _, err := r.db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, ClickNotifyChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify click: %w", err)
+	}
+	return nil
+}
+
+// ListenClicks holds a dedicated connection listening on ClickNotifyChannel
+// and calls fn with each payload. It blocks until ctx ends or the connection
+// fails, and always returns a non-nil error.
+func (r *Repository) ListenClicks(ctx context.Context, fn func(payload string)) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+ClickNotifyChannel); err != nil {
+			return fmt.Errorf("failed to listen for clicks: %w", err)
+		}
+		log.Printf("[REPOSITORY] Listening for live clicks on %s", ClickNotifyChannel)
+
+		// Don't hand a connection that is still listening back to the pool
+		defer func() {
+			if pgxConn.IsClosed() {
+				return
+			}
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := pgxConn.Exec(unlistenCtx, "UNLISTEN "+ClickNotifyChannel); err != nil {
+				log.Printf("[REPOSITORY] WARNING: Failed to unlisten %s: %v", ClickNotifyChannel, err)
+			}
+		}()
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("stopped listening for clicks: %w", err)
+			}
+			fn(notification.Payload)
+		}
+	})
+}
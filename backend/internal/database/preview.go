@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/models"
+)
+
+// GetURLPreview returns the preview metadata of a link, empty if none was
+// fetched or set yet
+func (r *Repository) GetURLPreview(ctx context.Context, urlID int64) (*models.URLPreview, error) {
+	query := `
+		SELECT title, description, image_url, fetched_at, fetch_error,
+			title_override, description_override, image_url_override
+		FROM url_previews
+		WHERE url_id = $1`
+
+	preview := &models.URLPreview{}
+	err := r.db.QueryRowContext(ctx, query, urlID).Scan(
+		&preview.Fetched.Title,
+		&preview.Fetched.Description,
+		&preview.Fetched.ImageURL,
+		&preview.FetchedAt,
+		&preview.FetchError,
+		&preview.Override.Title,
+		&preview.Override.Description,
+		&preview.Override.ImageURL,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.URLPreview{}, nil
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to fetch preview of URL ID %d: %v", urlID, err)
+		return nil, fmt.Errorf("failed to fetch preview: %w", err)
+	}
+
+	return preview, nil
+}
+
+// SavePreviewFetch stores the outcome of fetching a link's preview. When
+// fetched is nil the fetch failed: fetchErr is recorded and the previously
+// fetched metadata is kept.
+func (r *Repository) SavePreviewFetch(ctx context.Context, urlID int64, fetched *models.LinkPreview, fetchedAt time.Time, fetchErr *string) error {
+	var query string
+	var args []any
+	if fetched != nil {
+		query = `
+			INSERT INTO url_previews (url_id, title, description, image_url, fetched_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (url_id) DO UPDATE
+			SET title = EXCLUDED.title, description = EXCLUDED.description, image_url = EXCLUDED.image_url,
+				fetched_at = EXCLUDED.fetched_at, fetch_error = NULL`
+		args = []any{urlID, fetched.Title, fetched.Description, fetched.ImageURL, fetchedAt}
+	} else {
+		query = `
+			INSERT INTO url_previews (url_id, fetched_at, fetch_error)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (url_id) DO UPDATE
+			SET fetched_at = EXCLUDED.fetched_at, fetch_error = EXCLUDED.fetch_error`
+		args = []any{urlID, fetchedAt, fetchErr}
+	}
+
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to save preview of URL ID %d: %v", urlID, err)
+		return fmt.Errorf("failed to save preview: %w", err)
+	}
+	return nil
+}
+
+// SetPreviewOverride replaces the preview overrides of a link; nil fields
+// fall back to the fetched metadata
+func (r *Repository) SetPreviewOverride(ctx context.Context, urlID int64, override *models.LinkPreview) error {
+	log.Printf("[REPOSITORY] Setting preview override of URL ID=%d", urlID)
+
+	query := `
+		INSERT INTO url_previews (url_id, title_override, description_override, image_url_override)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (url_id) DO UPDATE
+		SET title_override = EXCLUDED.title_override,
+			description_override = EXCLUDED.description_override,
+			image_url_override = EXCLUDED.image_url_override`
+
+	if _, err := r.db.ExecContext(ctx, query, urlID, override.Title, override.Description, override.ImageURL); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to set preview override of URL ID %d: %v", urlID, err)
+		return fmt.Errorf("failed to set preview override: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"backend/internal/models"
+)
+
+// RecordProbe stores the outcome of a destination probe on a link
+func (r *Repository) RecordProbe(ctx context.Context, id int64, result *models.ProbeResult) (*models.URL, error) {
+	log.Printf("[REPOSITORY] Recording probe of URL ID=%d: %s", id, result.Status)
+
+	var statusCode *int
+	if result.StatusCode != 0 {
+		statusCode = &result.StatusCode
+	}
+	var finalURL *string
+	if result.FinalURL != "" {
+		finalURL = &result.FinalURL
+	}
+
+	query := `
+		UPDATE urls
+		SET probe_status = $2, probe_status_code = $3, final_url = $4, probed_at = $5
+		WHERE id = $1
+		RETURNING ` + urlColumns
+
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, id, result.Status, statusCode, finalURL, result.ProbedAt))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("URL not found: %d", id)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to record probe of URL ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to record probe: %w", err)
+	}
+
+	return url, nil
+}
+
+// GetBrokenURLs returns up to limit undeleted links whose last probe did not
+// succeed, most recently probed first
+func (r *Repository) GetBrokenURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE probe_status <> 'ok'
+		AND deleted_at IS NULL
+		ORDER BY probed_at DESC
+		LIMIT $1`
+
+	return r.queryURLs(ctx, query, limit)
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"backend/internal/models"
+)
+
+// GetURLsToScan returns up to limit links that currently redirect (active,
+// not deleted and not quarantined) with an ID above afterID, in ID order
+func (r *Repository) GetURLsToScan(ctx context.Context, afterID int64, limit int) ([]*models.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE id > $1
+		AND is_active
+		AND deleted_at IS NULL
+		AND quarantined_at IS NULL
+		ORDER BY id
+		LIMIT $2`
+
+	return r.queryURLs(ctx, query, afterID, limit)
+}
+
+// QuarantineURL stops a link from redirecting because its target was
+// flagged. Deleted or already quarantined links are left alone.
+func (r *Repository) QuarantineURL(ctx context.Context, id int64, reason string) (*models.URL, error) {
+	log.Printf("[REPOSITORY] Quarantining URL ID=%d: %s", id, reason)
+
+	query := `
+		UPDATE urls
+		SET quarantined_at = now(), quarantine_reason = $2
+		WHERE id = $1 AND quarantined_at IS NULL AND deleted_at IS NULL
+		RETURNING ` + urlColumns
+
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, id, reason))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("URL not found or already quarantined: %d", id)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to quarantine URL ID %d: %v", id, err)
+		return nil, fmt.Errorf("failed to quarantine URL: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Quarantined URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
+	return url, nil
+}
+
+// ReleaseURL lifts the quarantine of a link
+func (r *Repository) ReleaseURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	log.Printf("[REPOSITORY] Releasing URL from quarantine: %s", shortCode)
+
+	query := `
+		UPDATE urls
+		SET quarantined_at = NULL, quarantine_reason = NULL
+		WHERE short_code = $1 AND quarantined_at IS NOT NULL
+		RETURNING ` + urlColumns
+
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, shortCode))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("URL not found or not quarantined: %s", shortCode)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to release URL %s: %v", shortCode, err)
+		return nil, fmt.Errorf("failed to release URL: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Released URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
+	return url, nil
+}
+
+// GetQuarantinedURLs returns up to limit quarantined links, most recently
+// quarantined first
+func (r *Repository) GetQuarantinedURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE quarantined_at IS NOT NULL
+		ORDER BY quarantined_at DESC
+		LIMIT $1`
+
+	return r.queryURLs(ctx, query, limit)
+}
+
+// queryURLs runs a query selecting urlColumns and scans every row
+func (r *Repository) queryURLs(ctx context.Context, query string, args ...any) ([]*models.URL, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query URLs: %v", err)
+		return nil, fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []*models.URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan URL row: %v", err)
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return urls, nil
+}
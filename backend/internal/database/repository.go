@@ -3,11 +3,16 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"backend/internal/hll"
 	"backend/internal/models"
 )
 
@@ -26,57 +31,126 @@ func NewRepository(db *sql.DB) *Repository {
 type URLRepository interface {
 	// Core URL operations
 	CreateURL(ctx context.Context, url *models.URL) error
+	CreateOrGetURL(ctx context.Context, url *models.URL, newCode func(context.Context) (string, error)) (*models.URL, bool, error)
+	AllocateCodeIDs(ctx context.Context, count int) ([]int64, error)
+	CountCodesByLength(ctx context.Context) (map[int]int64, error)
+	GetCodeLength(ctx context.Context, alphabet string) (int, error)
+	SetCodeLength(ctx context.Context, alphabet string, from, length int) (bool, error)
 	GetURLByShortCode(ctx context.Context, shortCode string) (*models.URL, error)
 	GetURLByID(ctx context.Context, id int64) (*models.URL, error)
 	UpdateURL(ctx context.Context, url *models.URL) error
+	GetURLRevisions(ctx context.Context, urlID int64) ([]models.URLRevision, error)
+	GetURLRevision(ctx context.Context, urlID int64, revision int) (*models.URLRevision, error)
 	DeactivateURL(ctx context.Context, shortCode string) error
 
+	// Deletion and erasure
+	SoftDeleteURL(ctx context.Context, shortCode string) (*models.URL, error)
+	RestoreURL(ctx context.Context, shortCode string, deletedAfter time.Time) (*models.URL, error)
+	PurgeDeletedURLs(ctx context.Context, cutoff time.Time, limit int) ([]string, error)
+	IsCodeTombstoned(ctx context.Context, code string) (bool, error)
+	EraseClickEvents(ctx context.Context, q *ErasureQuery) (int64, error)
+
+	// Destination scanning
+	GetURLsToScan(ctx context.Context, afterID int64, limit int) ([]*models.URL, error)
+	QuarantineURL(ctx context.Context, id int64, reason string) (*models.URL, error)
+	ReleaseURL(ctx context.Context, shortCode string) (*models.URL, error)
+	GetQuarantinedURLs(ctx context.Context, limit int) ([]*models.URL, error)
+	RecordProbe(ctx context.Context, id int64, result *models.ProbeResult) (*models.URL, error)
+	GetBrokenURLs(ctx context.Context, limit int) ([]*models.URL, error)
+
+	// Link previews
+	GetURLPreview(ctx context.Context, urlID int64) (*models.URLPreview, error)
+	SavePreviewFetch(ctx context.Context, urlID int64, fetched *models.LinkPreview, fetchedAt time.Time, fetchErr *string) error
+	SetPreviewOverride(ctx context.Context, urlID int64, override *models.LinkPreview) error
+
+	// Audit log
+	RecordAudit(ctx context.Context, entry *models.AuditEntry) error
+	GetAuditLog(ctx context.Context, q *AuditQuery) ([]models.AuditEntry, error)
+
 	// Reserved codes
 	IsReservedCode(ctx context.Context, code string) (bool, error)
-	AddReservedCode(ctx context.Context, code, reason, description string) error
+	AddReservedCode(ctx context.Context, reservation *models.ReservedCode) error
+	ListReservedCodes(ctx context.Context) ([]*models.ReservedCode, error)
+	RemoveReservedCode(ctx context.Context, code string) (bool, error)
 
 	// Analytics
 	RecordClick(ctx context.Context, click *models.ClickEvent) error
 	GetClickCount(ctx context.Context, urlID int64) (int64, error)
-	GetLastClicked(ctx context.Context, urlID int64) (*time.Time, error)
+	GetBotClickCount(ctx context.Context, urlID int64) (int64, error)
+	GetLastClicked(ctx context.Context, urlID int64, includeBots bool) (*time.Time, error)
 	UpdateCounterShards(ctx context.Context, urlID int64) error
+
+	// Unique visitors
+	MergeUniqueSketch(ctx context.Context, urlID int64, day time.Time, isBot bool, sketch *hll.Sketch) error
+	GetUniqueSketches(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (map[string]*hll.Sketch, error)
 	
 	// Detailed Analytics
-	GetClicksByDay(ctx context.Context, urlID int64, days int) ([]models.DayStat, error)
-	GetTopReferrers(ctx context.Context, urlID int64, days int, limit int) ([]models.ReferrerStat, error)
-	GetBrowserStats(ctx context.Context, urlID int64, days int, limit int) ([]models.BrowserStat, error)
-	GetAnalyticsBatch(ctx context.Context, urlID int64, days int, referrerLimit int, browserLimit int) (*AnalyticsBatch, error)
+	GetClickSeries(ctx context.Context, urlID int64, from, to time.Time, granularity, timezone string, includeBots bool) ([]models.TimeBucket, error)
+	GetClickCountInRange(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (int64, error)
+	GetTopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.ReferrerStat, error)
+	GetBrowserStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.BrowserStat, error)
+	GetOSStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.OSStat, error)
+	GetDeviceStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.DeviceStat, error)
+	GetTopCountries(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CountryStat, error)
+	GetTopCities(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CityStat, error)
+	GetRevisionStats(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) ([]models.RevisionStat, error)
+	GetAnalyticsBatch(ctx context.Context, urlID int64, from, to time.Time, referrerLimit int, browserLimit int, includeBots bool) (*AnalyticsBatch, error)
+
+	// Rollups
+	RollupHour(ctx context.Context, hour time.Time) (int64, error)
+	RollupDay(ctx context.Context, day time.Time) (int64, error)
+	GetRollupWatermark(ctx context.Context, rollup string) (time.Time, error)
+	SetRollupWatermark(ctx context.Context, rollup string, watermark time.Time) error
+	GetEarliestClickTime(ctx context.Context) (time.Time, error)
+	GetCampaignStats(ctx context.Context, q *CampaignQuery) ([]models.CampaignStat, error)
+	GetClickEventsPage(ctx context.Context, q *ClickExportQuery) ([]models.ClickEvent, error)
+
+	// Live click fan-out
+	NotifyClick(ctx context.Context, payload string) error
+	ListenClicks(ctx context.Context, fn func(payload string)) error
 
 	// Maintenance
-	CleanupExpiredURLs(ctx context.Context) (int64, error)
+	CleanupExpiredURLs(ctx context.Context, limit int) ([]*models.URL, error)
+	WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error)
+	EnsureClickPartition(ctx context.Context, month time.Time) (bool, error)
+	ListClickPartitions(ctx context.Context) ([]time.Time, error)
+	DropClickPartition(ctx context.Context, month time.Time) error
+	PurgeClickEventsBefore(ctx context.Context, cutoff time.Time) (int64, error)
 	GetURLsCreatedSince(ctx context.Context, since time.Time, limit int) ([]*models.URL, error)
 }
 
 // Ensure Repository implements URLRepository interface
 var _ URLRepository = (*Repository)(nil)
 
+// insertURLQuery inserts a link with its first revision
+const insertURLQuery = `
+	WITH created AS (
+		INSERT INTO urls (short_code, target_url, is_active, created_at, expires_at, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, target_url, is_active, created_at, expires_at, revision
+	)
+	INSERT INTO url_revisions (url_id, revision, target_url, is_active, expires_at, created_at)
+	SELECT id, revision, target_url, is_active, expires_at, created_at FROM created
+	RETURNING url_id, created_at, revision`
+
 // CreateURL inserts a new URL into the database
 func (r *Repository) CreateURL(ctx context.Context, url *models.URL) error {
 	log.Printf("[REPOSITORY] Creating URL: ShortCode=%s, TargetURL=%s", url.ShortCode, url.TargetURL)
 
-	query := `
-		INSERT INTO urls (short_code, target_url, is_active, created_at, expires_at)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at`
-
-	err := r.db.QueryRowContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, insertURLQuery,
 		url.ShortCode,
 		url.TargetURL,
 		url.IsActive,
 		time.Now(),
 		url.ExpiresAt,
-	).Scan(&url.ID, &url.CreatedAt)
+		url.OwnerID,
+	).Scan(&url.ID, &url.CreatedAt, &url.Revision)
 
 	if err != nil {
 		// Check for unique constraint violation
 		if isUniqueViolation(err) {
 			log.Printf("[REPOSITORY] ERROR: Short code collision for %s: %v", url.ShortCode, err)
-			return fmt.Errorf("short code already exists: %s", url.ShortCode)
+			return fmt.Errorf("%w: %s", ErrShortCodeExists, url.ShortCode)
 		}
 		log.Printf("[REPOSITORY] ERROR: Failed to create URL %s: %v", url.ShortCode, err)
 		return fmt.Errorf("failed to create URL: %w", err)
@@ -92,7 +166,8 @@ func (r *Repository) GetURLByShortCode(ctx context.Context, shortCode string) (*
 	log.Printf("[REPOSITORY] Fetching URL by short code: %s", shortCode)
 
 	query := `
-		SELECT id, short_code, target_url, is_active, created_at, expires_at
+		SELECT id, short_code, target_url, is_active, created_at, expires_at, deleted_at, revision,
+			quarantined_at, quarantine_reason, probe_status, probe_status_code, final_url, probed_at, owner_id
 		FROM urls
 		WHERE short_code = $1`
 
@@ -104,6 +179,15 @@ func (r *Repository) GetURLByShortCode(ctx context.Context, shortCode string) (*
 		&url.IsActive,
 		&url.CreatedAt,
 		&url.ExpiresAt,
+		&url.DeletedAt,
+		&url.Revision,
+		&url.QuarantinedAt,
+		&url.QuarantineReason,
+		&url.ProbeStatus,
+		&url.ProbeStatusCode,
+		&url.FinalURL,
+		&url.ProbedAt,
+		&url.OwnerID,
 	)
 
 	if err != nil {
@@ -125,7 +209,8 @@ func (r *Repository) GetURLByID(ctx context.Context, id int64) (*models.URL, err
 	log.Printf("[REPOSITORY] Fetching URL by ID: %d", id)
 
 	query := `
-		SELECT id, short_code, target_url, is_active, created_at, expires_at
+		SELECT id, short_code, target_url, is_active, created_at, expires_at, deleted_at, revision,
+			quarantined_at, quarantine_reason, probe_status, probe_status_code, final_url, probed_at, owner_id
 		FROM urls
 		WHERE id = $1`
 
@@ -137,6 +222,15 @@ func (r *Repository) GetURLByID(ctx context.Context, id int64) (*models.URL, err
 		&url.IsActive,
 		&url.CreatedAt,
 		&url.ExpiresAt,
+		&url.DeletedAt,
+		&url.Revision,
+		&url.QuarantinedAt,
+		&url.QuarantineReason,
+		&url.ProbeStatus,
+		&url.ProbeStatusCode,
+		&url.FinalURL,
+		&url.ProbedAt,
+		&url.OwnerID,
 	)
 
 	if err != nil {
@@ -152,39 +246,38 @@ func (r *Repository) GetURLByID(ctx context.Context, id int64) (*models.URL, err
 	return url, nil
 }
 
-// UpdateURL updates an existing URL
+// UpdateURL updates an existing URL, storing the result as a new revision
 func (r *Repository) UpdateURL(ctx context.Context, url *models.URL) error {
 	log.Printf("[REPOSITORY] Updating URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
 
 	query := `
-		UPDATE urls 
-		SET target_url = $2, is_active = $3, expires_at = $4
-		WHERE id = $1`
+		WITH updated AS (
+			UPDATE urls 
+			SET target_url = $2, is_active = $3, expires_at = $4, revision = revision + 1
+			WHERE id = $1
+			RETURNING id, revision, target_url, is_active, expires_at
+		)
+		INSERT INTO url_revisions (url_id, revision, target_url, is_active, expires_at)
+		SELECT id, revision, target_url, is_active, expires_at FROM updated
+		RETURNING revision`
 
-	result, err := r.db.ExecContext(ctx, query,
+	err := r.db.QueryRowContext(ctx, query,
 		url.ID,
 		url.TargetURL,
 		url.IsActive,
 		url.ExpiresAt,
-	)
+	).Scan(&url.Revision)
 
 	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("[REPOSITORY] ERROR: No rows updated for URL ID %d (not found)", url.ID)
+			return fmt.Errorf("URL not found: %d", url.ID)
+		}
 		log.Printf("[REPOSITORY] ERROR: Failed to update URL ID %d: %v", url.ID, err)
 		return fmt.Errorf("failed to update URL: %w", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("[REPOSITORY] ERROR: Failed to get rows affected for URL ID %d: %v", url.ID, err)
-		return fmt.Errorf("failed to verify update: %w", err)
-	}
-
-	if rowsAffected == 0 {
-		log.Printf("[REPOSITORY] ERROR: No rows updated for URL ID %d (not found)", url.ID)
-		return fmt.Errorf("URL not found: %d", url.ID)
-	}
-
-	log.Printf("[REPOSITORY] SUCCESS: Updated URL ID=%d", url.ID)
+	log.Printf("[REPOSITORY] SUCCESS: Updated URL ID=%d to revision %d", url.ID, url.Revision)
 	return nil
 }
 
@@ -215,11 +308,12 @@ func (r *Repository) DeactivateURL(ctx context.Context, shortCode string) error
 	return nil
 }
 
-// IsReservedCode checks if a code is in the reserved_codes table
+// IsReservedCode checks if a code is an exact reservation in the
+// reserved_codes table
 func (r *Repository) IsReservedCode(ctx context.Context, code string) (bool, error) {
 	log.Printf("[REPOSITORY] Checking if code is reserved: %s", code)
 
-	query := `SELECT EXISTS(SELECT 1 FROM reserved_codes WHERE code = $1)`
+	query := `SELECT EXISTS(SELECT 1 FROM reserved_codes WHERE code = $1 AND kind = 'exact')`
 
 	var exists bool
 	err := r.db.QueryRowContext(ctx, query, code).Scan(&exists)
@@ -237,28 +331,93 @@ func (r *Repository) IsReservedCode(ctx context.Context, code string) (bool, err
 	return exists, nil
 }
 
-// AddReservedCode adds a new reserved code
-func (r *Repository) AddReservedCode(ctx context.Context, code, reason, description string) error {
-	log.Printf("[REPOSITORY] Adding reserved code: %s (reason: %s)", code, reason)
+// AddReservedCode adds a new reserved code or pattern, an exact code if no
+// kind is set
+func (r *Repository) AddReservedCode(ctx context.Context, reservation *models.ReservedCode) error {
+	code := reservation.Code
+	log.Printf("[REPOSITORY] Adding reserved code: %s (reason: %s)", code, reservation.Reason)
+
+	if reservation.Kind == "" {
+		reservation.Kind = models.ReservationExact
+	}
 
 	query := `
-		INSERT INTO reserved_codes (code, reason, description)
-		VALUES ($1, $2, $3)`
+		INSERT INTO reserved_codes (code, kind, reason, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at`
 
-	_, err := r.db.ExecContext(ctx, query, code, reason, description)
+	var createdAt time.Time
+	err := r.db.QueryRowContext(ctx, query, code, reservation.Kind, reservation.Reason, reservation.Description).Scan(&createdAt)
 	if err != nil {
 		if isUniqueViolation(err) {
 			log.Printf("[REPOSITORY] ERROR: Reserved code already exists: %s", code)
-			return fmt.Errorf("reserved code already exists: %s", code)
+			return fmt.Errorf("%w: %s", ErrReservedCodeExists, code)
 		}
 		log.Printf("[REPOSITORY] ERROR: Failed to add reserved code %s: %v", code, err)
 		return fmt.Errorf("failed to add reserved code: %w", err)
 	}
 
+	reservation.Source = models.ReservationSourceAdmin
+	reservation.CreatedAt = &createdAt
 	log.Printf("[REPOSITORY] SUCCESS: Added reserved code: %s", code)
 	return nil
 }
 
+// ListReservedCodes returns every stored reservation, oldest first
+func (r *Repository) ListReservedCodes(ctx context.Context) ([]*models.ReservedCode, error) {
+	query := `
+		SELECT code, kind, reason, description, created_at
+		FROM reserved_codes
+		ORDER BY created_at, code`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to list reserved codes: %v", err)
+		return nil, fmt.Errorf("failed to list reserved codes: %w", err)
+	}
+	defer rows.Close()
+
+	var reservations []*models.ReservedCode
+	for rows.Next() {
+		reservation := &models.ReservedCode{Source: models.ReservationSourceAdmin}
+		var description sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&reservation.Code, &reservation.Kind, &reservation.Reason, &description, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reserved code: %w", err)
+		}
+		reservation.Description = description.String
+		reservation.CreatedAt = &createdAt
+		reservations = append(reservations, reservation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list reserved codes: %w", err)
+	}
+	return reservations, nil
+}
+
+// RemoveReservedCode deletes a stored reservation, reporting whether it existed
+func (r *Repository) RemoveReservedCode(ctx context.Context, code string) (bool, error) {
+	log.Printf("[REPOSITORY] Removing reserved code: %s", code)
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM reserved_codes WHERE code = $1`, code)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to remove reserved code %s: %v", code, err)
+		return false, fmt.Errorf("failed to remove reserved code: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		log.Printf("[REPOSITORY] Reserved code not found: %s", code)
+		return false, nil
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Removed reserved code: %s", code)
+	return true, nil
+}
+
 // RecordClick inserts a click event
 func (r *Repository) RecordClick(ctx context.Context, click *models.ClickEvent) error {
 	log.Printf("[REPOSITORY] Recording click for URL ID=%d, IP=%s",
@@ -267,8 +426,10 @@ func (r *Repository) RecordClick(ctx context.Context, click *models.ClickEvent)
 	query := `
 		INSERT INTO click_events (
 			url_id, occurred_at, ip, ua, referrer, utm_source, utm_medium,
-			utm_campaign, utm_term, utm_content, query_params
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			utm_campaign, utm_term, utm_content, query_params,
+			country, region, city,
+			browser, browser_version, os, device_type, is_bot, revision
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		RETURNING id`
 
 	err := r.db.QueryRowContext(ctx, query,
@@ -283,6 +444,15 @@ func (r *Repository) RecordClick(ctx context.Context, click *models.ClickEvent)
 		click.UTMTerm,
 		click.UTMContent,
 		click.QueryParams,
+		click.Country,
+		click.Region,
+		click.City,
+		click.Browser,
+		click.BrowserVersion,
+		click.OS,
+		click.DeviceType,
+		click.IsBot,
+		click.Revision,
 	).Scan(&click.ID)
 
 	if err != nil {
@@ -313,11 +483,12 @@ func (r *Repository) GetClickCount(ctx context.Context, urlID int64) (int64, err
 	return totalClicks, nil
 }
 
-// getClickCountFromEvents fallback method to count from events table
+// getClickCountFromEvents fallback method to count from events table. Like
+// the sharded counters, it counts human clicks only.
 func (r *Repository) getClickCountFromEvents(ctx context.Context, urlID int64) (int64, error) {
 	log.Printf("[REPOSITORY] Fallback: Counting clicks from events for URL ID=%d", urlID)
 
-	query := `SELECT COUNT(*) FROM click_events WHERE url_id = $1`
+	query := `SELECT COUNT(*) FROM click_events WHERE url_id = $1 AND NOT is_bot`
 	var count int64
 
 	err := r.db.QueryRowContext(ctx, query, urlID).Scan(&count)
@@ -330,19 +501,54 @@ func (r *Repository) getClickCountFromEvents(ctx context.Context, urlID int64) (
 	return count, nil
 }
 
-// GetLastClicked gets the most recent click timestamp for a URL
-func (r *Repository) GetLastClicked(ctx context.Context, urlID int64) (*time.Time, error) {
+// GetBotClickCount counts clicks flagged as bots for a URL.
+// Bot clicks are not added to the sharded counters, so compacted hours are
+// read from the hourly rollup, which outlives raw event retention.
+func (r *Repository) GetBotClickCount(ctx context.Context, urlID int64) (int64, error) {
+	log.Printf("[REPOSITORY] Getting bot click count for URL ID=%d", urlID)
+
+	watermark, err := r.GetRollupWatermark(ctx, RollupHourly)
+	if err != nil {
+		log.Printf("[REPOSITORY] WARNING: Counting bot clicks from raw events only: %v", err)
+		watermark = time.Time{}
+	}
+
+	query := `
+		SELECT
+			(SELECT COALESCE(SUM(clicks), 0)::bigint
+			 FROM click_rollups_hourly
+			 WHERE url_id = $1 AND is_bot AND hour < $2)
+			+
+			(SELECT COUNT(*)
+			 FROM click_events
+			 WHERE url_id = $1 AND is_bot AND occurred_at >= $2)
+	`
+	var count int64
+
+	err = r.db.QueryRowContext(ctx, query, urlID, watermark).Scan(&count)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to count bot clicks for URL ID %d: %v", urlID, err)
+		return 0, fmt.Errorf("failed to count bot clicks: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: URL ID=%d has %d bot clicks", urlID, count)
+	return count, nil
+}
+
+// GetLastClicked gets the most recent click timestamp for a URL, of a bot only when includeBots is set
+func (r *Repository) GetLastClicked(ctx context.Context, urlID int64, includeBots bool) (*time.Time, error) {
 	log.Printf("[REPOSITORY] Getting last clicked time for URL ID=%d", urlID)
 
 	query := `
 		SELECT occurred_at 
 		FROM click_events 
 		WHERE url_id = $1 
+		AND ($2 OR NOT is_bot)
 		ORDER BY occurred_at DESC 
 		LIMIT 1`
 
 	var lastClicked time.Time
-	err := r.db.QueryRowContext(ctx, query, urlID).Scan(&lastClicked)
+	err := r.db.QueryRowContext(ctx, query, urlID, includeBots).Scan(&lastClicked)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -380,41 +586,70 @@ func (r *Repository) UpdateCounterShards(ctx context.Context, urlID int64) error
 	return nil
 }
 
-// CleanupExpiredURLs marks expired URLs as inactive
-func (r *Repository) CleanupExpiredURLs(ctx context.Context) (int64, error) {
-	log.Printf("[REPOSITORY] Starting cleanup of expired URLs")
+// CleanupExpiredURLs marks up to limit expired URLs as inactive and returns
+// them. Rows locked by a concurrent sweep are skipped.
+func (r *Repository) CleanupExpiredURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	log.Printf("[REPOSITORY] Starting cleanup of expired URLs (limit: %d)", limit)
 
 	query := `
 		UPDATE urls 
 		SET is_active = false 
-		WHERE expires_at IS NOT NULL 
-		AND expires_at < $1 
-		AND is_active = true`
+		WHERE id IN (
+			SELECT id
+			FROM urls
+			WHERE expires_at IS NOT NULL 
+			AND expires_at < $1 
+			AND is_active = true
+			ORDER BY expires_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, short_code, target_url, is_active, created_at, expires_at, revision`
 
-	result, err := r.db.ExecContext(ctx, query, time.Now())
+	rows, err := r.db.QueryContext(ctx, query, time.Now(), limit)
 	if err != nil {
 		log.Printf("[REPOSITORY] ERROR: Failed to cleanup expired URLs: %v", err)
-		return 0, fmt.Errorf("failed to cleanup expired URLs: %w", err)
+		return nil, fmt.Errorf("failed to cleanup expired URLs: %w", err)
 	}
+	defer rows.Close()
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("[REPOSITORY] ERROR: Failed to get cleanup count: %v", err)
-		return 0, fmt.Errorf("failed to get cleanup count: %w", err)
+	var expired []*models.URL
+	for rows.Next() {
+		url := &models.URL{}
+		err := rows.Scan(
+			&url.ID,
+			&url.ShortCode,
+			&url.TargetURL,
+			&url.IsActive,
+			&url.CreatedAt,
+			&url.ExpiresAt,
+			&url.Revision,
+		)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan expired URL: %v", err)
+			return nil, fmt.Errorf("failed to scan expired URL: %w", err)
+		}
+		expired = append(expired, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
-	log.Printf("[REPOSITORY] SUCCESS: Cleaned up %d expired URLs", rowsAffected)
-	return rowsAffected, nil
+	log.Printf("[REPOSITORY] SUCCESS: Cleaned up %d expired URLs", len(expired))
+	return expired, nil
 }
 
-// GetURLsCreatedSince gets URLs created since a given time
+// GetURLsCreatedSince gets undeleted URLs created since a given time
 func (r *Repository) GetURLsCreatedSince(ctx context.Context, since time.Time, limit int) ([]*models.URL, error) {
 	log.Printf("[REPOSITORY] Fetching URLs created since %s (limit: %d)", since.Format(time.RFC3339), limit)
 
 	query := `
-		SELECT id, short_code, target_url, is_active, created_at, expires_at
+		SELECT id, short_code, target_url, is_active, created_at, expires_at, revision,
+			quarantined_at, quarantine_reason, probe_status, probe_status_code, final_url, probed_at, owner_id
 		FROM urls
-		WHERE created_at >= $1
+		WHERE created_at >= $1 AND deleted_at IS NULL
 		ORDER BY created_at DESC
 		LIMIT $2`
 
@@ -435,6 +670,14 @@ func (r *Repository) GetURLsCreatedSince(ctx context.Context, since time.Time, l
 			&url.IsActive,
 			&url.CreatedAt,
 			&url.ExpiresAt,
+			&url.Revision,
+			&url.QuarantinedAt,
+			&url.QuarantineReason,
+			&url.ProbeStatus,
+			&url.ProbeStatusCode,
+			&url.FinalURL,
+			&url.ProbedAt,
+			&url.OwnerID,
 		)
 		if err != nil {
 			log.Printf("[REPOSITORY] ERROR: Failed to scan URL row: %v", err)
@@ -456,9 +699,12 @@ func (r *Repository) GetURLsCreatedSince(ctx context.Context, since time.Time, l
 
 // isUniqueViolation checks if an error is a unique constraint violation
 func isUniqueViolation(err error) bool {
-	// PostgreSQL unique violation error code: 23505
-	// This is a simplified check - in production you might want to use
-	// a database driver specific method
+	// PostgreSQL unique violation error code: 23505, also raised by the
+	// reserved and retired code trigger
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
 	return err != nil && (fmt.Sprintf("%v", err) == "pq: duplicate key value violates unique constraint \"urls_short_code_uniq\"" ||
 		fmt.Sprintf("%v", err) == "ERROR: duplicate key value violates unique constraint \"urls_short_code_uniq\" (SQLSTATE 23505)" ||
 		// Generic check for constraint violations
@@ -477,55 +723,106 @@ func safeString(s *string) string {
 	return *s
 }
 
-// GetClicksByDay returns click statistics grouped by day
-func (r *Repository) GetClicksByDay(ctx context.Context, urlID int64, days int) ([]models.DayStat, error) {
-	log.Printf("[REPOSITORY] Getting clicks by day for URL ID %d (last %d days)", urlID, days)
-	
+// GetClickSeries returns click counts bucketed by granularity, with bucket
+// boundaries computed in the given IANA timezone. Empty buckets are omitted.
+func (r *Repository) GetClickSeries(ctx context.Context, urlID int64, from, to time.Time, granularity, timezone string, includeBots bool) ([]models.TimeBucket, error) {
+	log.Printf("[REPOSITORY] Getting %s click series for URL ID %d (%s to %s, %s)",
+		granularity, urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), timezone)
+
+	if !models.IsValidGranularity(granularity) {
+		return nil, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+
+	// Hourly rollups can only be regrouped when local buckets fall on UTC hour
+	// boundaries, so minute series and half-hour timezones read raw events only
+	rollStart, rollEnd := to, to
+	if granularity != models.GranularityMinute && hourAlignedTimezone(timezone, from, to) {
+		rollStart, rollEnd = r.rollupWindow(ctx, RollupHourly, from, to)
+	}
+
+	// Truncate in local time, then convert the bucket start back to an instant
 	query := `
-		SELECT DATE(occurred_at) as click_date, COUNT(*) as clicks
-		FROM click_events 
-		WHERE url_id = $1 
-		AND occurred_at >= NOW() - $2 * INTERVAL '1 day'
-		ORDER BY click_date DESC
+		SELECT date_trunc($4, t AT TIME ZONE $5) AT TIME ZONE $5 AS bucket, SUM(clicks)::bigint AS clicks
+		FROM (
+			SELECT hour AS t, clicks
+			FROM click_rollups_hourly
+			WHERE url_id = $1
+			AND hour >= $7
+			AND hour < $8
+			AND ($6 OR NOT is_bot)
+			UNION ALL
+			SELECT occurred_at AS t, 1
+			FROM click_events
+			WHERE url_id = $1
+			AND ((occurred_at >= $2 AND occurred_at < $7) OR (occurred_at >= $8 AND occurred_at < $3))
+			AND ($6 OR NOT is_bot)
+		) combined
+		GROUP BY bucket
+		ORDER BY bucket
 	`
-	
-	rows, err := r.db.QueryContext(ctx, query, urlID, days)
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, granularity, timezone, includeBots, rollStart, rollEnd)
 	if err != nil {
-		log.Printf("[REPOSITORY] ERROR: Failed to query clicks by day: %v", err)
-		return nil, fmt.Errorf("failed to get clicks by day: %w", err)
+		log.Printf("[REPOSITORY] ERROR: Failed to query click series: %v", err)
+		return nil, fmt.Errorf("failed to get click series: %w", err)
 	}
 	defer rows.Close()
-	
-	var stats []models.DayStat
+
+	var buckets []models.TimeBucket
 	for rows.Next() {
-		var stat models.DayStat
-		err := rows.Scan(&stat.Date, &stat.Clicks)
-		if err != nil {
-			log.Printf("[REPOSITORY] ERROR: Failed to scan day stat: %v", err)
+		var bucket models.TimeBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan series bucket: %v", err)
 			continue
 		}
-		stats = append(stats, stat)
+		buckets = append(buckets, bucket)
 	}
-	
-	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d day stats", len(stats))
-	return stats, nil
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d series buckets", len(buckets))
+	return buckets, nil
 }
 
-// GetTopReferrers returns top referrer statistics
-func (r *Repository) GetTopReferrers(ctx context.Context, urlID int64, days int, limit int) ([]models.ReferrerStat, error) {
-	log.Printf("[REPOSITORY] Getting top referrers for URL ID %d (last %d days, limit %d)", urlID, days, limit)
-	
+// GetClickCountInRange counts clicks between from (inclusive) and to (exclusive)
+func (r *Repository) GetClickCountInRange(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (int64, error) {
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupHourly, from, to)
+
 	query := `
-		SELECT COALESCE(referrer, 'Direct') as referrer, COUNT(*) as clicks
-		FROM click_events 
-		WHERE url_id = $1 
-		AND occurred_at >= NOW() - $2 * INTERVAL '1 day'
-		GROUP BY referrer
-		ORDER BY clicks DESC
-		LIMIT $3
+		SELECT
+			(SELECT COALESCE(SUM(clicks), 0)::bigint
+			 FROM click_rollups_hourly
+			 WHERE url_id = $1 AND hour >= $5 AND hour < $6 AND ($4 OR NOT is_bot))
+			+
+			(SELECT COUNT(*)
+			 FROM click_events
+			 WHERE url_id = $1
+			 AND ((occurred_at >= $2 AND occurred_at < $5) OR (occurred_at >= $6 AND occurred_at < $3))
+			 AND ($4 OR NOT is_bot))
 	`
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, query, urlID, from, to, includeBots, rollStart, rollEnd).Scan(&count)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to count clicks in range: %v", err)
+		return 0, fmt.Errorf("failed to count clicks in range: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetTopReferrers returns top referrer statistics
+func (r *Repository) GetTopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.ReferrerStat, error) {
+	log.Printf("[REPOSITORY] Getting top referrers for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
 	
-	rows, err := r.db.QueryContext(ctx, query, urlID, days, limit)
+	// Whole compacted days come from the daily rollup, the rest from raw events
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupDaily, from, to)
+	query := rollupDimensionQuery(DimensionReferrer, "COALESCE(referrer, 'Direct')")
+	
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots, rollStart, rollEnd)
 	if err != nil {
 		log.Printf("[REPOSITORY] ERROR: Failed to query top referrers: %v", err)
 		return nil, fmt.Errorf("failed to get top referrers: %w", err)
@@ -547,32 +844,15 @@ func (r *Repository) GetTopReferrers(ctx context.Context, urlID int64, days int,
 	return stats, nil
 }
 
-// GetBrowserStats returns browser statistics based on user agent parsing
-func (r *Repository) GetBrowserStats(ctx context.Context, urlID int64, days int, limit int) ([]models.BrowserStat, error) {
-	log.Printf("[REPOSITORY] Getting browser stats for URL ID %d (last %d days, limit %d)", urlID, days, limit)
+// GetBrowserStats returns browser statistics from the user agent parsed at ingest
+func (r *Repository) GetBrowserStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.BrowserStat, error) {
+	log.Printf("[REPOSITORY] Getting browser stats for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
 	
-	query := `
-		SELECT 
-			CASE 
-				WHEN ua ILIKE '%%chrome%%' THEN 'Chrome'
-				WHEN ua ILIKE '%%firefox%%' THEN 'Firefox'  
-				WHEN ua ILIKE '%%safari%%' AND ua NOT ILIKE '%%chrome%%' THEN 'Safari'
-				WHEN ua ILIKE '%%edge%%' THEN 'Edge'
-				WHEN ua ILIKE '%%opera%%' THEN 'Opera'
-				WHEN ua ILIKE '%%postman%%' THEN 'Postman'
-				ELSE 'Other'
-			END as browser,
-			COUNT(*) as clicks
-		FROM click_events 
-		WHERE url_id = $1 
-		AND occurred_at >= NOW() - $2 * INTERVAL '1 day'
-		AND ua IS NOT NULL
-		GROUP BY browser
-		ORDER BY clicks DESC
-		LIMIT $3
-	`
+	// Whole compacted days come from the daily rollup, the rest from raw events
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupDaily, from, to)
+	query := rollupDimensionQuery(DimensionBrowser, "browser")
 	
-	rows, err := r.db.QueryContext(ctx, query, urlID, days, limit)
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots, rollStart, rollEnd)
 	if err != nil {
 		log.Printf("[REPOSITORY] ERROR: Failed to query browser stats: %v", err)
 		return nil, fmt.Errorf("failed to get browser stats: %w", err)
@@ -594,6 +874,270 @@ func (r *Repository) GetBrowserStats(ctx context.Context, urlID int64, days int,
 	return stats, nil
 }
 
+// GetOSStats returns operating system statistics from the user agent parsed at ingest
+func (r *Repository) GetOSStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.OSStat, error) {
+	log.Printf("[REPOSITORY] Getting OS stats for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
+
+	// Whole compacted days come from the daily rollup, the rest from raw events
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupDaily, from, to)
+	query := rollupDimensionQuery(DimensionOS, "os")
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots, rollStart, rollEnd)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query OS stats: %v", err)
+		return nil, fmt.Errorf("failed to get OS stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.OSStat
+	for rows.Next() {
+		var stat models.OSStat
+		err := rows.Scan(&stat.OS, &stat.Clicks)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan OS stat: %v", err)
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d OS stats", len(stats))
+	return stats, nil
+}
+
+// GetDeviceStats returns device type statistics from the user agent parsed at ingest
+func (r *Repository) GetDeviceStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.DeviceStat, error) {
+	log.Printf("[REPOSITORY] Getting device stats for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
+
+	// Whole compacted days come from the daily rollup, the rest from raw events
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupDaily, from, to)
+	query := rollupDimensionQuery(DimensionDevice, "device_type")
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots, rollStart, rollEnd)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query device stats: %v", err)
+		return nil, fmt.Errorf("failed to get device stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.DeviceStat
+	for rows.Next() {
+		var stat models.DeviceStat
+		err := rows.Scan(&stat.Device, &stat.Clicks)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan device stat: %v", err)
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d device stats", len(stats))
+	return stats, nil
+}
+
+// GetTopCountries returns click statistics grouped by country
+func (r *Repository) GetTopCountries(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CountryStat, error) {
+	log.Printf("[REPOSITORY] Getting top countries for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
+
+	// Whole compacted days come from the daily rollup, the rest from raw events
+	rollStart, rollEnd := r.rollupWindow(ctx, RollupDaily, from, to)
+	query := rollupDimensionQuery(DimensionCountry, "country")
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots, rollStart, rollEnd)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query top countries: %v", err)
+		return nil, fmt.Errorf("failed to get top countries: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.CountryStat
+	for rows.Next() {
+		var stat models.CountryStat
+		err := rows.Scan(&stat.Country, &stat.Clicks)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan country stat: %v", err)
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d country stats", len(stats))
+	return stats, nil
+}
+
+// GetTopCities returns click statistics grouped by city. Cities are not
+// rolled up, so only clicks within the raw retention period are counted.
+func (r *Repository) GetTopCities(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CityStat, error) {
+	log.Printf("[REPOSITORY] Getting top cities for URL ID %d (%s to %s, limit %d)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339), limit)
+
+	query := `
+		SELECT city, COALESCE(region, '') as region, country, COUNT(*) as clicks
+		FROM click_events
+		WHERE url_id = $1
+		AND occurred_at >= $2
+		AND occurred_at < $3
+		AND city IS NOT NULL
+		AND country IS NOT NULL
+		AND ($5 OR NOT is_bot)
+		GROUP BY city, region, country
+		ORDER BY clicks DESC
+		LIMIT $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, limit, includeBots)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query top cities: %v", err)
+		return nil, fmt.Errorf("failed to get top cities: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.CityStat
+	for rows.Next() {
+		var stat models.CityStat
+		err := rows.Scan(&stat.City, &stat.Region, &stat.Country, &stat.Clicks)
+		if err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan city stat: %v", err)
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d city stats", len(stats))
+	return stats, nil
+}
+
+// MergeUniqueSketch folds a visitor sketch into the stored sketch for a URL and UTC day.
+// Bot and human visitors are kept in separate sketches.
+func (r *Repository) MergeUniqueSketch(ctx context.Context, urlID int64, day time.Time, isBot bool, sketch *hll.Sketch) error {
+	dayStr := day.UTC().Format("2006-01-02")
+	log.Printf("[REPOSITORY] Merging unique sketch for URL ID=%d, Day=%s, Bot=%v", urlID, dayStr, isBot)
+
+	encoded, err := sketch.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode sketch: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to begin sketch transaction: %v", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// First visitor of the day: the insert wins and there is nothing to merge
+	insertQuery := `
+		INSERT INTO click_uniques_daily (url_id, day, is_bot, sketch, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (url_id, day, is_bot) DO NOTHING`
+
+	result, err := tx.ExecContext(ctx, insertQuery, urlID, dayStr, isBot, encoded, time.Now())
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to insert unique sketch for URL ID %d: %v", urlID, err)
+		return fmt.Errorf("failed to insert unique sketch: %w", err)
+	}
+
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		// Row exists: lock it, merge in Go and write it back
+		var existing []byte
+		selectQuery := `
+			SELECT sketch FROM click_uniques_daily
+			WHERE url_id = $1 AND day = $2 AND is_bot = $3
+			FOR UPDATE`
+
+		if err := tx.QueryRowContext(ctx, selectQuery, urlID, dayStr, isBot).Scan(&existing); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to lock unique sketch for URL ID %d: %v", urlID, err)
+			return fmt.Errorf("failed to load unique sketch: %w", err)
+		}
+
+		merged, err := hll.FromBytes(existing)
+		if err != nil {
+			log.Printf("[REPOSITORY] WARNING: Discarding corrupt unique sketch for URL ID %d: %v", urlID, err)
+			merged = hll.NewDefault()
+		}
+		if err := merged.Merge(sketch); err != nil {
+			return fmt.Errorf("failed to merge unique sketch: %w", err)
+		}
+
+		if encoded, err = merged.MarshalBinary(); err != nil {
+			return fmt.Errorf("failed to encode sketch: %w", err)
+		}
+
+		updateQuery := `
+			UPDATE click_uniques_daily
+			SET sketch = $4, updated_at = $5
+			WHERE url_id = $1 AND day = $2 AND is_bot = $3`
+
+		if _, err := tx.ExecContext(ctx, updateQuery, urlID, dayStr, isBot, encoded, time.Now()); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to update unique sketch for URL ID %d: %v", urlID, err)
+			return fmt.Errorf("failed to update unique sketch: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to commit unique sketch for URL ID %d: %v", urlID, err)
+		return fmt.Errorf("failed to commit unique sketch: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Merged unique sketch for URL ID=%d, Day=%s", urlID, dayStr)
+	return nil
+}
+
+// GetUniqueSketches returns the daily visitor sketches for a URL in [from, to), widened to whole UTC days.
+// The day of to is only included when to is not midnight, so ranges split at midnight share no day.
+// When includeBots is set, bot sketches are merged into each day.
+func (r *Repository) GetUniqueSketches(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (map[string]*hll.Sketch, error) {
+	end := to.UTC().Truncate(24 * time.Hour)
+	if end.Before(to) {
+		end = end.AddDate(0, 0, 1)
+	}
+	fromDay := from.UTC().Format("2006-01-02")
+	endDay := end.Format("2006-01-02")
+	log.Printf("[REPOSITORY] Getting unique sketches for URL ID %d (%s until %s)", urlID, fromDay, endDay)
+
+	query := `
+		SELECT day::text, sketch
+		FROM click_uniques_daily
+		WHERE url_id = $1
+		AND day >= $2 AND day < $3
+		AND ($4 OR NOT is_bot)`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, fromDay, endDay, includeBots)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query unique sketches: %v", err)
+		return nil, fmt.Errorf("failed to get unique sketches: %w", err)
+	}
+	defer rows.Close()
+
+	sketches := make(map[string]*hll.Sketch)
+	for rows.Next() {
+		var day string
+		var data []byte
+		if err := rows.Scan(&day, &data); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan unique sketch: %v", err)
+			continue
+		}
+		sketch, err := hll.FromBytes(data)
+		if err != nil {
+			log.Printf("[REPOSITORY] WARNING: Skipping corrupt unique sketch for %s: %v", day, err)
+			continue
+		}
+		if existing, ok := sketches[day]; ok {
+			if err := existing.Merge(sketch); err != nil {
+				log.Printf("[REPOSITORY] WARNING: Failed to merge unique sketch for %s: %v", day, err)
+			}
+			continue
+		}
+		sketches[day] = sketch
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d unique sketches", len(sketches))
+	return sketches, nil
+}
+
 // Health check specific to repository
 func (r *Repository) Health(ctx context.Context) error {
 	log.Printf("[REPOSITORY] Performing health check")
@@ -617,23 +1161,30 @@ type AnalyticsBatch struct {
 	ClicksByDay  []models.DayStat
 	TopReferrers []models.ReferrerStat
 	BrowserStats []models.BrowserStat
+	OSStats      []models.OSStat
+	DeviceStats  []models.DeviceStat
 }
 
-// GetAnalyticsBatch retrieves all analytics data in a single query using CTEs
-func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, days int, referrerLimit int, browserLimit int) (*AnalyticsBatch, error) {
-	log.Printf("[REPOSITORY] Getting batched analytics for URL ID %d (last %d days)", urlID, days)
+// GetAnalyticsBatch retrieves all analytics data in a single query using CTEs.
+// browserLimit also caps the OS and device breakdowns.
+func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, from, to time.Time, referrerLimit int, browserLimit int, includeBots bool) (*AnalyticsBatch, error) {
+	log.Printf("[REPOSITORY] Getting batched analytics for URL ID %d (%s to %s)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339))
 
 	query := `
 		WITH params AS (
 			SELECT $1::bigint AS url_id,
-			       NOW() - $2 * INTERVAL '1 day' AS since
+			       $2::timestamptz AS since,
+			       $3::timestamptz AS until,
+			       $6::boolean AS include_bots
 		),
 		clicks_by_day AS (
-			SELECT DATE(occurred_at) AS click_date, COUNT(*) AS clicks
+			SELECT (occurred_at AT TIME ZONE 'UTC')::date AS click_date, COUNT(*) AS clicks
 			FROM click_events, params
 			WHERE url_id = params.url_id
 			  AND occurred_at >= params.since
-			GROUP BY DATE(occurred_at)
+			  AND occurred_at < params.until
+			  AND (params.include_bots OR NOT is_bot)
+			GROUP BY click_date
 			ORDER BY click_date DESC
 		),
 		top_referrers AS (
@@ -641,38 +1192,60 @@ func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, days in
 			FROM click_events, params
 			WHERE url_id = params.url_id
 			  AND occurred_at >= params.since
+			  AND occurred_at < params.until
+			  AND (params.include_bots OR NOT is_bot)
 			GROUP BY referrer
 			ORDER BY clicks DESC
-			LIMIT $3
+			LIMIT $4
 		),
 		browser_stats AS (
-			SELECT
-				CASE
-					WHEN ua ILIKE '%%chrome%%' THEN 'Chrome'
-					WHEN ua ILIKE '%%firefox%%' THEN 'Firefox'
-					WHEN ua ILIKE '%%safari%%' AND ua NOT ILIKE '%%chrome%%' THEN 'Safari'
-					WHEN ua ILIKE '%%edge%%' THEN 'Edge'
-					WHEN ua ILIKE '%%opera%%' THEN 'Opera'
-					WHEN ua ILIKE '%%postman%%' THEN 'Postman'
-					ELSE 'Other'
-				END AS browser,
-				COUNT(*) AS clicks
+			SELECT browser, COUNT(*) AS clicks
 			FROM click_events, params
 			WHERE url_id = params.url_id
 			  AND occurred_at >= params.since
-			  AND ua IS NOT NULL
+			  AND occurred_at < params.until
+			  AND (params.include_bots OR NOT is_bot)
+			  AND browser IS NOT NULL
 			GROUP BY browser
 			ORDER BY clicks DESC
-			LIMIT $4
+			LIMIT $5
+		),
+		os_stats AS (
+			SELECT os, COUNT(*) AS clicks
+			FROM click_events, params
+			WHERE url_id = params.url_id
+			  AND occurred_at >= params.since
+			  AND occurred_at < params.until
+			  AND (params.include_bots OR NOT is_bot)
+			  AND os IS NOT NULL
+			GROUP BY os
+			ORDER BY clicks DESC
+			LIMIT $5
+		),
+		device_stats AS (
+			SELECT device_type, COUNT(*) AS clicks
+			FROM click_events, params
+			WHERE url_id = params.url_id
+			  AND occurred_at >= params.since
+			  AND occurred_at < params.until
+			  AND (params.include_bots OR NOT is_bot)
+			  AND device_type IS NOT NULL
+			GROUP BY device_type
+			ORDER BY clicks DESC
+			LIMIT $5
 		)
 		SELECT 'day' AS result_type, click_date::text AS key, clicks FROM clicks_by_day
 		UNION ALL
 		SELECT 'referrer' AS result_type, referrer AS key, clicks FROM top_referrers
 		UNION ALL
 		SELECT 'browser' AS result_type, browser AS key, clicks FROM browser_stats
+		UNION ALL
+		SELECT 'os' AS result_type, os AS key, clicks FROM os_stats
+		UNION ALL
+		SELECT 'device' AS result_type, device_type AS key, clicks FROM device_stats
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, urlID, days, referrerLimit, browserLimit)
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, referrerLimit, browserLimit, includeBots)
 	if err != nil {
 		log.Printf("[REPOSITORY] ERROR: Failed to query batched analytics: %v", err)
 		return nil, fmt.Errorf("failed to get batched analytics: %w", err)
@@ -683,6 +1256,8 @@ func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, days in
 		ClicksByDay:  []models.DayStat{},
 		TopReferrers: []models.ReferrerStat{},
 		BrowserStats: []models.BrowserStat{},
+		OSStats:      []models.OSStat{},
+		DeviceStats:  []models.DeviceStat{},
 	}
 
 	for rows.Next() {
@@ -710,6 +1285,16 @@ func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, days in
 				Browser: key,
 				Clicks:  clicks,
 			})
+		case "os":
+			batch.OSStats = append(batch.OSStats, models.OSStat{
+				OS:     key,
+				Clicks: clicks,
+			})
+		case "device":
+			batch.DeviceStats = append(batch.DeviceStats, models.DeviceStat{
+				Device: key,
+				Clicks: clicks,
+			})
 		}
 	}
 
@@ -718,8 +1303,134 @@ func (r *Repository) GetAnalyticsBatch(ctx context.Context, urlID int64, days in
 		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
-	log.Printf("[REPOSITORY] SUCCESS: Retrieved batched analytics - Days: %d, Referrers: %d, Browsers: %d",
-		len(batch.ClicksByDay), len(batch.TopReferrers), len(batch.BrowserStats))
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved batched analytics - Days: %d, Referrers: %d, Browsers: %d, OS: %d, Devices: %d",
+		len(batch.ClicksByDay), len(batch.TopReferrers), len(batch.BrowserStats), len(batch.OSStats), len(batch.DeviceStats))
 
 	return batch, nil
 }
+
+// utmColumns maps UTM dimensions to their click_events columns
+var utmColumns = map[string]string{
+	models.UTMSource:   "utm_source",
+	models.UTMMedium:   "utm_medium",
+	models.UTMCampaign: "utm_campaign",
+	models.UTMTerm:     "utm_term",
+	models.UTMContent:  "utm_content",
+}
+
+// CampaignQuery describes a UTM campaign breakdown
+type CampaignQuery struct {
+	URLID       int64             // Restrict to one link; 0 for all links
+	From        time.Time         // Inclusive
+	To          time.Time         // Exclusive
+	GroupBy     []string          // UTM dimensions to group on, in output order
+	GroupByLink bool              // Also group per short code
+	Filters     map[string]string // Drill-down: dimension -> exact value (models.UTMNone matches unset)
+	IncludeBots bool
+	Limit       int
+}
+
+// GetCampaignStats returns clicks grouped by the requested UTM dimensions.
+// Only clicks carrying at least one UTM parameter and still within the raw
+// retention period are counted.
+func (r *Repository) GetCampaignStats(ctx context.Context, q *CampaignQuery) ([]models.CampaignStat, error) {
+	log.Printf("[REPOSITORY] Getting campaign stats (url ID %d, group by %v, filters %v)", q.URLID, q.GroupBy, q.Filters)
+
+	args := []interface{}{q.From, q.To, q.IncludeBots}
+	where := []string{
+		"ce.occurred_at >= $1",
+		"ce.occurred_at < $2",
+		"($3 OR NOT ce.is_bot)",
+		"COALESCE(ce.utm_source, ce.utm_medium, ce.utm_campaign, ce.utm_term, ce.utm_content) IS NOT NULL",
+	}
+
+	if q.URLID != 0 {
+		args = append(args, q.URLID)
+		where = append(where, fmt.Sprintf("ce.url_id = $%d", len(args)))
+	}
+
+	// Filters are applied in canonical order so the generated SQL is stable
+	for _, dim := range models.UTMDimensions {
+		value, ok := q.Filters[dim]
+		if !ok {
+			continue
+		}
+		if value == models.UTMNone {
+			where = append(where, fmt.Sprintf("ce.%s IS NULL", utmColumns[dim]))
+			continue
+		}
+		args = append(args, value)
+		where = append(where, fmt.Sprintf("ce.%s = $%d", utmColumns[dim], len(args)))
+	}
+
+	var selects, groups []string
+	from := "click_events ce"
+	if q.GroupByLink {
+		from += " JOIN urls u ON u.id = ce.url_id"
+		selects = append(selects, "u.short_code")
+		groups = append(groups, "u.short_code")
+	}
+	for _, dim := range q.GroupBy {
+		column, ok := utmColumns[dim]
+		if !ok {
+			return nil, fmt.Errorf("unknown UTM dimension: %s", dim)
+		}
+		selects = append(selects, fmt.Sprintf("COALESCE(ce.%s, '%s')", column, models.UTMNone))
+		groups = append(groups, fmt.Sprintf("ce.%s", column))
+	}
+
+	selects = append(selects, "COUNT(*) AS clicks", "MIN(ce.occurred_at)", "MAX(ce.occurred_at)")
+	args = append(args, q.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		%s
+		ORDER BY clicks DESC
+		LIMIT $%d
+	`, strings.Join(selects, ", "), from, strings.Join(where, " AND "), groupByClause(groups), len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query campaign stats: %v", err)
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []models.CampaignStat{}
+	for rows.Next() {
+		var stat models.CampaignStat
+
+		dest := make([]interface{}, 0, len(selects))
+		if q.GroupByLink {
+			dest = append(dest, &stat.ShortCode)
+		}
+		for _, dim := range q.GroupBy {
+			dest = append(dest, stat.DimensionField(dim))
+		}
+		dest = append(dest, &stat.Clicks, &stat.FirstClick, &stat.LastClick)
+
+		if err := rows.Scan(dest...); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan campaign stat: %v", err)
+			return nil, fmt.Errorf("failed to scan campaign stat: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Retrieved %d campaign stats", len(stats))
+	return stats, nil
+}
+
+// groupByClause renders a GROUP BY clause, or nothing for a grand total
+func groupByClause(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+	return "GROUP BY " + strings.Join(columns, ", ")
+}
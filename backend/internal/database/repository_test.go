@@ -1,10 +1,14 @@
 package database
 
 import (
+	"backend/internal/hll"
 	"backend/internal/models"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -44,6 +48,7 @@ func setupTestRepository(t *testing.T) (*Repository, func()) {
 		db.Exec("DELETE FROM url_counters_live WHERE url_id IN (SELECT id FROM urls WHERE short_code LIKE 'test%')")
 		db.Exec("DELETE FROM urls WHERE short_code LIKE 'test%'")
 		db.Exec("DELETE FROM reserved_codes WHERE code LIKE 'test%'")
+		db.Exec("DELETE FROM code_tombstones WHERE short_code LIKE 'test%'")
 		db.Close()
 	}
 
@@ -250,6 +255,27 @@ func TestRepository_UpdateURL(t *testing.T) {
 	if retrieved.IsActive != false {
 		t.Errorf("UpdateURL() IsActive = %v, expected %v", retrieved.IsActive, false)
 	}
+
+	// Both versions are kept as revisions
+	if testURL.Revision != 2 || retrieved.Revision != 2 {
+		t.Errorf("UpdateURL() Revision = %d (stored %d), expected 2", testURL.Revision, retrieved.Revision)
+	}
+
+	original, err := repo.GetURLRevision(ctx, testURL.ID, 1)
+	if err != nil {
+		t.Fatalf("GetURLRevision() unexpected error: %v", err)
+	}
+	if original.TargetURL != "https://example.com/original" || !original.IsActive {
+		t.Errorf("GetURLRevision(1) = %+v, expected the original target", original)
+	}
+
+	revisions, err := repo.GetURLRevisions(ctx, testURL.ID)
+	if err != nil {
+		t.Fatalf("GetURLRevisions() unexpected error: %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Revision != 2 {
+		t.Errorf("GetURLRevisions() = %+v, expected revisions 2 and 1", revisions)
+	}
 }
 
 func TestRepository_IsReservedCode(t *testing.T) {
@@ -259,7 +285,7 @@ func TestRepository_IsReservedCode(t *testing.T) {
 	ctx := context.Background()
 
 	// Add a reserved code
-	err := repo.AddReservedCode(ctx, "testreserved", "test", "Test reserved code")
+	err := repo.AddReservedCode(ctx, &models.ReservedCode{Code: "testreserved", Reason: "test", Description: "Test reserved code"})
 	if err != nil {
 		t.Fatalf("Failed to add reserved code: %v", err)
 	}
@@ -288,6 +314,49 @@ func TestRepository_IsReservedCode(t *testing.T) {
 	}
 }
 
+func TestRepository_ReservedCodePatterns(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	pattern := &models.ReservedCode{Code: "test-promo-*", Kind: models.ReservationGlob, Reason: "test"}
+	if err := repo.AddReservedCode(ctx, pattern); err != nil {
+		t.Fatalf("AddReservedCode() unexpected error: %v", err)
+	}
+	if pattern.CreatedAt == nil || pattern.Source != models.ReservationSourceAdmin {
+		t.Errorf("AddReservedCode() = %+v, expected a stored reservation", pattern)
+	}
+	if err := repo.AddReservedCode(ctx, &models.ReservedCode{Code: "test-promo-*", Reason: "test"}); !errors.Is(err, ErrReservedCodeExists) {
+		t.Errorf("AddReservedCode() twice error = %v, expected ErrReservedCodeExists", err)
+	}
+
+	// Patterns are matched by the service, not as exact codes
+	if reserved, err := repo.IsReservedCode(ctx, "test-promo-*"); err != nil || reserved {
+		t.Errorf("IsReservedCode() of a pattern = %v, %v, expected false", reserved, err)
+	}
+
+	reservations, err := repo.ListReservedCodes(ctx)
+	if err != nil {
+		t.Fatalf("ListReservedCodes() unexpected error: %v", err)
+	}
+	found := false
+	for _, reservation := range reservations {
+		if reservation.Code == "test-promo-*" {
+			found = reservation.Kind == models.ReservationGlob
+		}
+	}
+	if !found {
+		t.Errorf("ListReservedCodes() = %v, expected the glob reservation", reservations)
+	}
+
+	for _, expected := range []bool{true, false} {
+		if removed, err := repo.RemoveReservedCode(ctx, "test-promo-*"); err != nil || removed != expected {
+			t.Errorf("RemoveReservedCode() = %v, %v, expected %v", removed, err, expected)
+		}
+	}
+}
+
 func TestRepository_RecordClick(t *testing.T) {
 	repo, cleanup := setupTestRepository(t)
 	defer cleanup()
@@ -357,7 +426,7 @@ func TestRepository_GetLastClicked(t *testing.T) {
 	}
 
 	// No clicks yet
-	lastClicked, err := repo.GetLastClicked(ctx, testURL.ID)
+	lastClicked, err := repo.GetLastClicked(ctx, testURL.ID, false)
 	if err != nil {
 		t.Errorf("GetLastClicked() unexpected error: %v", err)
 		return
@@ -382,7 +451,7 @@ func TestRepository_GetLastClicked(t *testing.T) {
 	}
 
 	// Check last clicked
-	lastClicked, err = repo.GetLastClicked(ctx, testURL.ID)
+	lastClicked, err = repo.GetLastClicked(ctx, testURL.ID, false)
 	if err != nil {
 		t.Errorf("GetLastClicked() unexpected error: %v", err)
 		return
@@ -398,6 +467,99 @@ func TestRepository_GetLastClicked(t *testing.T) {
 	if timeDiff > time.Second || timeDiff < -time.Second {
 		t.Errorf("GetLastClicked() time difference too large: %v", timeDiff)
 	}
+
+	// A later bot click only counts when bots are included
+	botTime := clickTime.Add(time.Minute)
+	if err := repo.RecordClick(ctx, &models.ClickEvent{URLID: testURL.ID, OccurredAt: botTime, IsBot: true}); err != nil {
+		t.Fatalf("Failed to record bot click: %v", err)
+	}
+	for _, includeBots := range []bool{false, true} {
+		expected := clickTime
+		if includeBots {
+			expected = botTime
+		}
+		lastClicked, err = repo.GetLastClicked(ctx, testURL.ID, includeBots)
+		if err != nil || lastClicked == nil || lastClicked.Sub(expected).Abs() > time.Second {
+			t.Errorf("GetLastClicked(includeBots=%v) = %v, %v, expected %v", includeBots, lastClicked, err, expected)
+		}
+	}
+}
+
+func TestRepository_ClickCountFromEventsExcludesBots(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	testURL := &models.URL{
+		ShortCode: "testbotcount",
+		TargetURL: "https://example.com/botcount",
+		IsActive:  true,
+	}
+	if err := repo.CreateURL(ctx, testURL); err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	for _, isBot := range []bool{false, true} {
+		if err := repo.RecordClick(ctx, &models.ClickEvent{URLID: testURL.ID, OccurredAt: time.Now(), IsBot: isBot}); err != nil {
+			t.Fatalf("RecordClick() unexpected error: %v", err)
+		}
+	}
+
+	count, err := repo.getClickCountFromEvents(ctx, testURL.ID)
+	if err != nil {
+		t.Fatalf("getClickCountFromEvents() unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("getClickCountFromEvents() = %d, expected only the human click", count)
+	}
+}
+
+func TestRepository_GetUniqueSketches_AdjacentRanges(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	testURL := &models.URL{
+		ShortCode: "testuniquerange",
+		TargetURL: "https://example.com/uniquerange",
+		IsActive:  true,
+	}
+	if err := repo.CreateURL(ctx, testURL); err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	// One sketch on each of four days
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		sketch := hll.NewDefault()
+		sketch.Insert(uint64(i+1) * 0x9E3779B97F4A7C15) // Spread over the registers
+		if err := repo.MergeUniqueSketch(ctx, testURL.ID, start.AddDate(0, 0, i), false, sketch); err != nil {
+			t.Fatalf("MergeUniqueSketch() unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		days     int
+	}{
+		{"previous period", start, start.AddDate(0, 0, 2), 2},
+		{"current period", start.AddDate(0, 0, 2), start.AddDate(0, 0, 4), 2},
+		{"end within a day", start.AddDate(0, 0, 2), start.AddDate(0, 0, 2).Add(time.Hour), 1},
+	}
+
+	for _, tt := range tests {
+		sketches, err := repo.GetUniqueSketches(ctx, testURL.ID, tt.from, tt.to, false)
+		if err != nil {
+			t.Errorf("%s: GetUniqueSketches() unexpected error: %v", tt.name, err)
+			continue
+		}
+		if len(sketches) != tt.days {
+			t.Errorf("%s: GetUniqueSketches() returned %d days, expected %d", tt.name, len(sketches), tt.days)
+		}
+	}
 }
 
 func TestRepository_UpdateCounterShards(t *testing.T) {
@@ -473,14 +635,14 @@ func TestRepository_CleanupExpiredURLs(t *testing.T) {
 	}
 
 	// Cleanup expired URLs
-	cleaned, err := repo.CleanupExpiredURLs(ctx)
+	cleaned, err := repo.CleanupExpiredURLs(ctx, 100)
 	if err != nil {
 		t.Errorf("CleanupExpiredURLs() unexpected error: %v", err)
 		return
 	}
 
-	if cleaned != 1 {
-		t.Errorf("CleanupExpiredURLs() cleaned %d URLs, expected 1", cleaned)
+	if len(cleaned) != 1 || cleaned[0].ShortCode != "testexpired" {
+		t.Errorf("CleanupExpiredURLs() cleaned %d URLs, expected only testexpired", len(cleaned))
 	}
 
 	// Verify expired URL is now inactive
@@ -506,6 +668,393 @@ func TestRepository_CleanupExpiredURLs(t *testing.T) {
 	}
 }
 
+func TestRepository_DeleteRestorePurge(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	url := &models.URL{ShortCode: "testdeleted", TargetURL: "https://example.com/deleted", IsActive: true}
+	if err := repo.CreateURL(ctx, url); err != nil {
+		t.Fatalf("Failed to create URL: %v", err)
+	}
+
+	deleted, err := repo.SoftDeleteURL(ctx, "testdeleted")
+	if err != nil {
+		t.Fatalf("SoftDeleteURL() unexpected error: %v", err)
+	}
+	if deleted.DeletedAt == nil {
+		t.Fatal("SoftDeleteURL() did not set DeletedAt")
+	}
+
+	// Deleting twice finds nothing to delete
+	if _, err := repo.SoftDeleteURL(ctx, "testdeleted"); err == nil {
+		t.Error("Second SoftDeleteURL() expected error")
+	}
+
+	// A grace period that started after the deletion has passed
+	if _, err := repo.RestoreURL(ctx, "testdeleted", time.Now().Add(time.Hour)); err == nil {
+		t.Error("RestoreURL() past the grace period expected error")
+	}
+
+	restored, err := repo.RestoreURL(ctx, "testdeleted", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("RestoreURL() unexpected error: %v", err)
+	}
+	if restored.DeletedAt != nil {
+		t.Error("RestoreURL() left DeletedAt set")
+	}
+
+	if _, err := repo.SoftDeleteURL(ctx, "testdeleted"); err != nil {
+		t.Fatalf("SoftDeleteURL() unexpected error: %v", err)
+	}
+	purged, err := repo.PurgeDeletedURLs(ctx, time.Now().Add(time.Minute), 100)
+	if err != nil {
+		t.Fatalf("PurgeDeletedURLs() unexpected error: %v", err)
+	}
+	if len(purged) != 1 || purged[0] != "testdeleted" {
+		t.Errorf("PurgeDeletedURLs() = %v, expected [testdeleted]", purged)
+	}
+
+	if _, err := repo.GetURLByShortCode(ctx, "testdeleted"); err == nil {
+		t.Error("Purged URL still exists")
+	}
+	if tombstoned, err := repo.IsCodeTombstoned(ctx, "testdeleted"); err != nil || !tombstoned {
+		t.Errorf("IsCodeTombstoned() = %v, %v, expected true", tombstoned, err)
+	}
+}
+
+func TestRepository_QuarantineRelease(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	url := &models.URL{ShortCode: "testquarantine", TargetURL: "https://example.com/flagged", IsActive: true}
+	if err := repo.CreateURL(ctx, url); err != nil {
+		t.Fatalf("Failed to create URL: %v", err)
+	}
+
+	quarantined, err := repo.QuarantineURL(ctx, url.ID, "blocklist matched example.com")
+	if err != nil {
+		t.Fatalf("QuarantineURL() unexpected error: %v", err)
+	}
+	if !quarantined.IsQuarantined() || quarantined.QuarantineReason == nil || !quarantined.IsActive {
+		t.Errorf("QuarantineURL() = %+v, expected an active quarantined URL with a reason", quarantined)
+	}
+
+	// Quarantining twice finds nothing to quarantine
+	if _, err := repo.QuarantineURL(ctx, url.ID, "again"); err == nil {
+		t.Error("Second QuarantineURL() expected error")
+	}
+
+	// Quarantined links are not scanned
+	toScan, err := repo.GetURLsToScan(ctx, url.ID-1, 10)
+	if err != nil {
+		t.Fatalf("GetURLsToScan() unexpected error: %v", err)
+	}
+	for _, u := range toScan {
+		if u.ID == url.ID {
+			t.Error("GetURLsToScan() returned a quarantined URL")
+		}
+	}
+
+	released, err := repo.ReleaseURL(ctx, "testquarantine")
+	if err != nil {
+		t.Fatalf("ReleaseURL() unexpected error: %v", err)
+	}
+	if released.IsQuarantined() || released.QuarantineReason != nil {
+		t.Error("ReleaseURL() left the quarantine set")
+	}
+	if _, err := repo.ReleaseURL(ctx, "testquarantine"); err == nil {
+		t.Error("Second ReleaseURL() expected error")
+	}
+}
+
+func TestRepository_RecordProbe(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	url := &models.URL{ShortCode: "testprobe", TargetURL: "https://example.com/probed", IsActive: true}
+	if err := repo.CreateURL(ctx, url); err != nil {
+		t.Fatalf("Failed to create URL: %v", err)
+	}
+
+	result := &models.ProbeResult{
+		Status:     models.ProbeBroken,
+		StatusCode: 404,
+		FinalURL:   "https://example.com/gone",
+		ProbedAt:   time.Now(),
+	}
+	probed, err := repo.RecordProbe(ctx, url.ID, result)
+	if err != nil {
+		t.Fatalf("RecordProbe() unexpected error: %v", err)
+	}
+	if probed.ProbeStatus == nil || *probed.ProbeStatus != models.ProbeBroken ||
+		probed.ProbeStatusCode == nil || *probed.ProbeStatusCode != 404 ||
+		probed.FinalURL == nil || *probed.FinalURL != result.FinalURL || probed.ProbedAt == nil {
+		t.Errorf("RecordProbe() = %+v, expected the probe outcome", probed)
+	}
+
+	broken, err := repo.GetBrokenURLs(ctx, 100)
+	if err != nil {
+		t.Fatalf("GetBrokenURLs() unexpected error: %v", err)
+	}
+	found := false
+	for _, u := range broken {
+		found = found || u.ID == url.ID
+	}
+	if !found {
+		t.Error("GetBrokenURLs() did not return the broken URL")
+	}
+
+	// A passing probe takes the link off the report
+	if _, err := repo.RecordProbe(ctx, url.ID, &models.ProbeResult{Status: models.ProbeOK, StatusCode: 200, ProbedAt: time.Now()}); err != nil {
+		t.Fatalf("RecordProbe() unexpected error: %v", err)
+	}
+	broken, _ = repo.GetBrokenURLs(ctx, 100)
+	for _, u := range broken {
+		if u.ID == url.ID {
+			t.Error("GetBrokenURLs() returned a URL whose last probe passed")
+		}
+	}
+}
+
+func TestRepository_Preview(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	url := &models.URL{ShortCode: "testpreview", TargetURL: "https://example.com/previewed", IsActive: true}
+	if err := repo.CreateURL(ctx, url); err != nil {
+		t.Fatalf("Failed to create URL: %v", err)
+	}
+
+	title, override := "Fetched title", "Override title"
+	if err := repo.SavePreviewFetch(ctx, url.ID, &models.LinkPreview{Title: &title}, time.Now(), nil); err != nil {
+		t.Fatalf("SavePreviewFetch() unexpected error: %v", err)
+	}
+	if err := repo.SetPreviewOverride(ctx, url.ID, &models.LinkPreview{Title: &override}); err != nil {
+		t.Fatalf("SetPreviewOverride() unexpected error: %v", err)
+	}
+
+	// A failed fetch keeps the metadata of the last successful one
+	message := "unexpected status 503"
+	if err := repo.SavePreviewFetch(ctx, url.ID, nil, time.Now(), &message); err != nil {
+		t.Fatalf("SavePreviewFetch() unexpected error: %v", err)
+	}
+
+	preview, err := repo.GetURLPreview(ctx, url.ID)
+	if err != nil {
+		t.Fatalf("GetURLPreview() unexpected error: %v", err)
+	}
+	if preview.Fetched.Title == nil || *preview.Fetched.Title != title ||
+		preview.FetchError == nil || *preview.FetchError != message {
+		t.Errorf("GetURLPreview() = %+v, expected the earlier metadata and the fetch error", preview)
+	}
+	if effective := preview.Effective(); effective.Title == nil || *effective.Title != override {
+		t.Errorf("Effective() = %+v, expected the override title", effective)
+	}
+}
+
+func TestRepository_CreateOrGetURL(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	owner := int64(4242)
+	target := "https://example.com/dedupe"
+
+	// Concurrent creators of one target end up with a single link
+	const creators = 8
+	var wg sync.WaitGroup
+	var allocated atomic.Int32
+	codes := make(chan string, creators)
+	created := make(chan bool, creators)
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := &models.URL{TargetURL: target, IsActive: true, OwnerID: &owner}
+			got, isNew, err := repo.CreateOrGetURL(ctx, url, func(context.Context) (string, error) {
+				allocated.Add(1)
+				return fmt.Sprintf("testdedupe%d", i), nil
+			})
+			if err != nil {
+				t.Errorf("CreateOrGetURL() unexpected error: %v", err)
+				return
+			}
+			codes <- got.ShortCode
+			created <- isNew
+		}(i)
+	}
+	wg.Wait()
+	close(codes)
+	close(created)
+
+	distinct := make(map[string]bool)
+	for code := range codes {
+		distinct[code] = true
+	}
+	newLinks := 0
+	for isNew := range created {
+		if isNew {
+			newLinks++
+		}
+	}
+	if len(distinct) != 1 || newLinks != 1 || allocated.Load() != 1 {
+		t.Errorf("CreateOrGetURL() returned %d codes, created %d links and allocated %d codes, expected 1, 1 and 1",
+			len(distinct), newLinks, allocated.Load())
+	}
+
+	// Other owners get their own link
+	other := &models.URL{TargetURL: target, IsActive: true}
+	newCode := func(context.Context) (string, error) { return "testdedupeother", nil }
+	if _, isNew, err := repo.CreateOrGetURL(ctx, other, newCode); err != nil || !isNew {
+		t.Errorf("CreateOrGetURL() for another owner = %v, %v, expected a new link", isNew, err)
+	}
+}
+
+func TestRepository_AllocateCodeIDs(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	first, err := repo.AllocateCodeIDs(ctx, 5)
+	if err != nil {
+		t.Fatalf("AllocateCodeIDs() unexpected error: %v", err)
+	}
+	second, err := repo.AllocateCodeIDs(ctx, 5)
+	if err != nil {
+		t.Fatalf("AllocateCodeIDs() unexpected error: %v", err)
+	}
+
+	seen := make(map[int64]bool)
+	for _, id := range append(first, second...) {
+		if seen[id] {
+			t.Errorf("AllocateCodeIDs() leased id %d twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("AllocateCodeIDs() leased %d ids, expected 10", len(seen))
+	}
+
+	// Reserved codes are rejected as taken, so generated codes can be retried
+	if err := repo.AddReservedCode(ctx, &models.ReservedCode{Code: "testseqreserved", Reason: "test"}); err != nil {
+		t.Fatalf("AddReservedCode() unexpected error: %v", err)
+	}
+	defer repo.db.Exec("DELETE FROM reserved_codes WHERE code = 'testseqreserved'")
+	err = repo.CreateURL(ctx, &models.URL{ShortCode: "testseqreserved", TargetURL: "https://example.com", IsActive: true})
+	if !errors.Is(err, ErrShortCodeExists) {
+		t.Errorf("CreateURL() with a reserved code error = %v, expected ErrShortCodeExists", err)
+	}
+}
+
+func TestRepository_CodeLengths(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	defer repo.db.Exec("DELETE FROM code_lengths WHERE alphabet = 'testalphabet'")
+
+	before, err := repo.CountCodesByLength(ctx)
+	if err != nil {
+		t.Fatalf("CountCodesByLength() unexpected error: %v", err)
+	}
+	for _, code := range []string{"testlen01", "testlen02"} {
+		if err := repo.CreateURL(ctx, &models.URL{ShortCode: code, TargetURL: "https://example.com", IsActive: true}); err != nil {
+			t.Fatalf("Failed to create URL: %v", err)
+		}
+	}
+	after, err := repo.CountCodesByLength(ctx)
+	if err != nil {
+		t.Fatalf("CountCodesByLength() unexpected error: %v", err)
+	}
+	if after[9]-before[9] != 2 {
+		t.Errorf("CountCodesByLength() counted %d new 9-character codes, expected 2", after[9]-before[9])
+	}
+
+	if length, err := repo.GetCodeLength(ctx, "testalphabet"); err != nil || length != 0 {
+		t.Errorf("GetCodeLength() before storing = %d, %v, expected 0", length, err)
+	}
+	tests := []struct {
+		from, length int
+		swapped      bool
+		expected     int
+	}{
+		{0, 8, true, 8},
+		{0, 9, false, 8}, // Stored by another instance meanwhile
+		{8, 7, true, 7},
+		{8, 9, false, 7},
+	}
+	for _, tt := range tests {
+		swapped, err := repo.SetCodeLength(ctx, "testalphabet", tt.from, tt.length)
+		if err != nil || swapped != tt.swapped {
+			t.Fatalf("SetCodeLength(%d, %d) = %v, %v, expected %v", tt.from, tt.length, swapped, err, tt.swapped)
+		}
+		if length, err := repo.GetCodeLength(ctx, "testalphabet"); err != nil || length != tt.expected {
+			t.Errorf("GetCodeLength() = %d, %v, expected %d", length, err, tt.expected)
+		}
+	}
+}
+
+func TestRepository_EraseClickEvents(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	url := &models.URL{ShortCode: "testerasure", TargetURL: "https://example.com/erasure", IsActive: true}
+	if err := repo.CreateURL(ctx, url); err != nil {
+		t.Fatalf("Failed to create URL: %v", err)
+	}
+
+	ip, otherIP, ua := "192.0.2.0", "198.51.100.0", "Mozilla/5.0 (erasure test)"
+	for _, click := range []*models.ClickEvent{
+		{URLID: url.ID, OccurredAt: time.Now(), IP: &ip, UserAgent: &ua},
+		{URLID: url.ID, OccurredAt: time.Now(), IP: &otherIP, UserAgent: &ua},
+	} {
+		if err := repo.RecordClick(ctx, click); err != nil {
+			t.Fatalf("Failed to record click: %v", err)
+		}
+	}
+
+	// Both IP and user agent must match
+	erased, err := repo.EraseClickEvents(ctx, &ErasureQuery{IPs: []string{ip}, UserAgent: ua})
+	if err != nil {
+		t.Fatalf("EraseClickEvents() unexpected error: %v", err)
+	}
+	if erased != 1 {
+		t.Errorf("EraseClickEvents() = %d, expected 1", erased)
+	}
+
+	if _, err := repo.EraseClickEvents(ctx, &ErasureQuery{}); err == nil {
+		t.Error("EraseClickEvents() with an empty query expected error")
+	}
+}
+
+func TestRepository_GetCampaignStats_EmptyTotal(t *testing.T) {
+	repo, cleanup := setupTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// A grand total over a range with no clicks is one row of zero clicks
+	to := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC)
+	stats, err := repo.GetCampaignStats(ctx, &CampaignQuery{From: to.AddDate(0, 0, -1), To: to, Limit: 1})
+	if err != nil {
+		t.Fatalf("GetCampaignStats() unexpected error: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Clicks != 0 || stats[0].FirstClick != nil || stats[0].LastClick != nil {
+		t.Errorf("GetCampaignStats() = %+v, expected one empty total", stats)
+	}
+}
+
 func TestRepository_Health(t *testing.T) {
 	repo, cleanup := setupTestRepository(t)
 	defer cleanup()
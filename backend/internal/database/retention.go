@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// RetentionWatermark is the rollup_watermarks key recording the instant before
+// which raw click events have been purged. Rollups cannot be recomputed there.
+const RetentionWatermark = "retention"
+
+// clickPartitionPrefix names the monthly partitions of click_events, e.g.
+// click_events_p202509 holds September 2025 (UTC)
+const clickPartitionPrefix = "click_events_p"
+
+// clickPartitionName returns the partition table holding the UTC month of t
+func clickPartitionName(t time.Time) string {
+	return clickPartitionPrefix + t.UTC().Format("200601")
+}
+
+// parseClickPartitionName returns the first instant of the month held by a
+// partition table, or false if name is not a monthly partition
+func parseClickPartitionName(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, clickPartitionPrefix)
+	if !ok || len(suffix) != 6 {
+		return time.Time{}, false
+	}
+
+	month, err := time.Parse("200601", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// EnsureClickPartition creates the click_events partition for the UTC month
+// containing month, moving any rows for that month out of the default
+// partition. It reports whether a partition was created.
+func (r *Repository) EnsureClickPartition(ctx context.Context, month time.Time) (bool, error) {
+	var created bool
+	err := r.db.QueryRowContext(ctx, `SELECT ensure_click_events_partition($1::date)`,
+		month.UTC().Format("2006-01-02")).Scan(&created)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to create partition %s: %v", clickPartitionName(month), err)
+		return false, fmt.Errorf("failed to create click partition: %w", err)
+	}
+
+	if created {
+		log.Printf("[REPOSITORY] SUCCESS: Created partition %s", clickPartitionName(month))
+	}
+	return created, nil
+}
+
+// ListClickPartitions returns the first instant of each month that has a
+// click_events partition, oldest first
+func (r *Repository) ListClickPartitions(ctx context.Context) ([]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'click_events'::regclass
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list click partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var months []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan click partition: %w", err)
+		}
+		if month, ok := parseClickPartitionName(name); ok {
+			months = append(months, month)
+		}
+	}
+
+	return months, rows.Err()
+}
+
+// DropClickPartition drops the click_events partition for the UTC month
+// containing month. Rollup tables do not reference click_events, so
+// aggregates already compacted from the partition are kept.
+func (r *Repository) DropClickPartition(ctx context.Context, month time.Time) error {
+	name := clickPartitionName(month)
+
+	// The name is built from a formatted date, so it is safe to interpolate
+	if _, err := r.db.ExecContext(ctx, `DROP TABLE IF EXISTS `+name); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to drop partition %s: %v", name, err)
+		return fmt.Errorf("failed to drop click partition: %w", err)
+	}
+
+	log.Printf("[REPOSITORY] SUCCESS: Dropped partition %s", name)
+	return nil
+}
+
+// PurgeClickEventsBefore deletes raw click events that occurred before cutoff
+// and are not in a partition that can be dropped whole
+func (r *Repository) PurgeClickEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM click_events WHERE occurred_at < $1`, cutoff)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to purge click events before %s: %v", cutoff.Format(time.RFC3339), err)
+		return 0, fmt.Errorf("failed to purge click events: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClickPartitionName(t *testing.T) {
+	month := time.Date(2025, 9, 30, 23, 0, 0, 0, time.FixedZone("UTC-5", -5*3600))
+
+	// 23:00 at UTC-5 is already October in UTC
+	if got := clickPartitionName(month); got != "click_events_p202510" {
+		t.Errorf("clickPartitionName() = %s, expected click_events_p202510", got)
+	}
+}
+
+func TestParseClickPartitionName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected time.Time
+		ok       bool
+	}{
+		{"click_events_p202509", time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), true},
+		{"click_events_default", time.Time{}, false},
+		{"click_events_p2025", time.Time{}, false},
+		{"click_events_p202513", time.Time{}, false},
+		{"urls", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		month, ok := parseClickPartitionName(tt.name)
+		if ok != tt.ok || !month.Equal(tt.expected) {
+			t.Errorf("parseClickPartitionName(%s) = %v, %v, expected %v, %v", tt.name, month, ok, tt.expected, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/models"
+)
+
+// GetURLRevisions returns every revision of a URL, newest first
+func (r *Repository) GetURLRevisions(ctx context.Context, urlID int64) ([]models.URLRevision, error) {
+	query := `
+		SELECT revision, target_url, is_active, expires_at, created_at
+		FROM url_revisions
+		WHERE url_id = $1
+		ORDER BY revision DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to read revisions of URL ID %d: %v", urlID, err)
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []models.URLRevision
+	for rows.Next() {
+		var rev models.URLRevision
+		if err := rows.Scan(&rev.Revision, &rev.TargetURL, &rev.IsActive, &rev.ExpiresAt, &rev.CreatedAt); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan revision: %v", err)
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetURLRevision returns one revision of a URL
+func (r *Repository) GetURLRevision(ctx context.Context, urlID int64, revision int) (*models.URLRevision, error) {
+	query := `
+		SELECT revision, target_url, is_active, expires_at, created_at
+		FROM url_revisions
+		WHERE url_id = $1 AND revision = $2`
+
+	rev := &models.URLRevision{}
+	err := r.db.QueryRowContext(ctx, query, urlID, revision).Scan(
+		&rev.Revision, &rev.TargetURL, &rev.IsActive, &rev.ExpiresAt, &rev.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("revision %d of URL ID %d not found", revision, urlID)
+		}
+		log.Printf("[REPOSITORY] ERROR: Failed to read revision %d of URL ID %d: %v", revision, urlID, err)
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+
+	return rev, nil
+}
+
+// GetRevisionStats returns clicks grouped by the revision that was live at
+// click time. Revisions are not rolled up, so only clicks within the raw
+// retention period are counted, and clicks recorded before revisions were
+// tracked are left out.
+func (r *Repository) GetRevisionStats(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) ([]models.RevisionStat, error) {
+	log.Printf("[REPOSITORY] Getting revision stats for URL ID %d (%s to %s)", urlID, from.Format(time.RFC3339), to.Format(time.RFC3339))
+
+	query := `
+		SELECT revision, COUNT(*) as clicks
+		FROM click_events
+		WHERE url_id = $1
+		AND occurred_at >= $2
+		AND occurred_at < $3
+		AND revision IS NOT NULL
+		AND ($4 OR NOT is_bot)
+		GROUP BY revision
+		ORDER BY revision DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID, from, to, includeBots)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to query revision stats: %v", err)
+		return nil, fmt.Errorf("failed to get revision stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []models.RevisionStat
+	for rows.Next() {
+		var stat models.RevisionStat
+		if err := rows.Scan(&stat.Revision, &stat.Clicks); err != nil {
+			log.Printf("[REPOSITORY] ERROR: Failed to scan revision stat: %v", err)
+			return nil, fmt.Errorf("failed to scan revision stat: %w", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Row iteration error: %v", err)
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Rollup names, used as keys in rollup_watermarks
+const (
+	RollupHourly = "hourly"
+	RollupDaily  = "daily"
+)
+
+// Dimensions compacted into click_rollups_daily
+const (
+	DimensionReferrer = "referrer"
+	DimensionBrowser  = "browser"
+	DimensionOS       = "os"
+	DimensionDevice   = "device"
+	DimensionCountry  = "country"
+)
+
+// RollupUnit returns the bucket size of a rollup. Days are UTC days.
+func RollupUnit(rollup string) time.Duration {
+	if rollup == RollupDaily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// RollupHour recomputes the hourly totals for the UTC hour starting at hour.
+// It is idempotent, so hours can be re-rolled after late or backfilled clicks,
+// and a concurrent rollup of the same hour overwrites rather than conflicts.
+func (r *Repository) RollupHour(ctx context.Context, hour time.Time) (int64, error) {
+	hour = hour.UTC().Truncate(time.Hour)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM click_rollups_hourly WHERE hour = $1`, hour); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to clear hourly rollup %s: %v", hour.Format(time.RFC3339), err)
+		return 0, fmt.Errorf("failed to clear hourly rollup: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO click_rollups_hourly (url_id, hour, is_bot, clicks)
+		SELECT url_id, $1, is_bot, COUNT(*)
+		FROM click_events
+		WHERE occurred_at >= $1
+		AND occurred_at < $2
+		GROUP BY url_id, is_bot
+		ON CONFLICT (url_id, hour, is_bot) DO UPDATE SET clicks = EXCLUDED.clicks
+	`, hour, hour.Add(time.Hour))
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to roll up hour %s: %v", hour.Format(time.RFC3339), err)
+		return 0, fmt.Errorf("failed to roll up hour: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit hourly rollup: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+// RollupDay recomputes the per-dimension counts for the UTC day starting at day.
+// Like RollupHour it replaces whatever was stored for that day.
+func (r *Repository) RollupDay(ctx context.Context, day time.Time) (int64, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM click_rollups_daily WHERE day = $1::date`, day.Format("2006-01-02")); err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to clear daily rollup %s: %v", day.Format("2006-01-02"), err)
+		return 0, fmt.Errorf("failed to clear daily rollup: %w", err)
+	}
+
+	// One scan of the day fans out into a row per (dimension, value)
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO click_rollups_daily (url_id, day, is_bot, dimension, value, clicks)
+		SELECT ce.url_id, $1::date, ce.is_bot, d.dimension, d.value, COUNT(*)
+		FROM click_events ce
+		CROSS JOIN LATERAL (VALUES
+			('referrer', COALESCE(ce.referrer, 'Direct')),
+			('browser', ce.browser),
+			('os', ce.os),
+			('device', ce.device_type),
+			('country', ce.country)
+		) AS d(dimension, value)
+		WHERE ce.occurred_at >= $2
+		AND ce.occurred_at < $3
+		AND d.value IS NOT NULL
+		GROUP BY ce.url_id, ce.is_bot, d.dimension, d.value
+		ON CONFLICT (url_id, dimension, day, is_bot, value) DO UPDATE SET clicks = EXCLUDED.clicks
+	`, day.Format("2006-01-02"), day, day.Add(24*time.Hour))
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to roll up day %s: %v", day.Format("2006-01-02"), err)
+		return 0, fmt.Errorf("failed to roll up day: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit daily rollup: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows, nil
+}
+
+// GetRollupWatermark returns the instant before which a rollup is complete,
+// or the zero time if the rollup has never run
+func (r *Repository) GetRollupWatermark(ctx context.Context, rollup string) (time.Time, error) {
+	var watermark time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT watermark FROM rollup_watermarks WHERE rollup = $1`, rollup).Scan(&watermark)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get %s rollup watermark: %w", rollup, err)
+	}
+	return watermark, nil
+}
+
+// SetRollupWatermark records that a rollup is complete up to watermark
+func (r *Repository) SetRollupWatermark(ctx context.Context, rollup string, watermark time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO rollup_watermarks (rollup, watermark, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (rollup) DO UPDATE SET watermark = EXCLUDED.watermark, updated_at = NOW()
+	`, rollup, watermark)
+	if err != nil {
+		log.Printf("[REPOSITORY] ERROR: Failed to set %s rollup watermark: %v", rollup, err)
+		return fmt.Errorf("failed to set rollup watermark: %w", err)
+	}
+	return nil
+}
+
+// GetEarliestClickTime returns when the oldest stored click happened, or the
+// zero time if there are no clicks
+func (r *Repository) GetEarliestClickTime(ctx context.Context) (time.Time, error) {
+	var earliest sql.NullTime
+	if err := r.db.QueryRowContext(ctx, `SELECT MIN(occurred_at) FROM click_events`).Scan(&earliest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to get earliest click: %w", err)
+	}
+	return earliest.Time, nil
+}
+
+// rollupWindow returns the part of [from, to) that can be read from a rollup:
+// the whole rollup units inside the range that are below the watermark. The
+// rest of the range is read from click_events. An empty window is returned as
+// start == end == to, which leaves [from, to) entirely to the raw query.
+func (r *Repository) rollupWindow(ctx context.Context, rollup string, from, to time.Time) (time.Time, time.Time) {
+	watermark, err := r.GetRollupWatermark(ctx, rollup)
+	if err != nil {
+		log.Printf("[REPOSITORY] WARNING: Reading raw events only: %v", err)
+		return to, to
+	}
+	return splitRollupRange(from, to, watermark, RollupUnit(rollup))
+}
+
+// splitRollupRange aligns [from, to) to whole units below the watermark
+func splitRollupRange(from, to, watermark time.Time, unit time.Duration) (time.Time, time.Time) {
+	start := from.UTC().Truncate(unit)
+	if start.Before(from) {
+		start = start.Add(unit)
+	}
+
+	end := to.UTC().Truncate(unit)
+	if watermark.Before(end) {
+		end = watermark.UTC().Truncate(unit)
+	}
+
+	if !start.Before(end) {
+		return to, to
+	}
+	return start, end
+}
+
+// rollupDimensionQuery builds a breakdown query that reads whole compacted
+// days from click_rollups_daily and the uncompacted head and tail of the
+// range from click_events. Parameters: $1 url_id, $2 from, $3 to, $4 limit,
+// $5 include_bots, $6 and $7 the rollup window.
+func rollupDimensionQuery(dimension, rawExpr string) string {
+	return fmt.Sprintf(`
+		SELECT value, SUM(clicks)::bigint AS clicks
+		FROM (
+			SELECT value, clicks
+			FROM click_rollups_daily
+			WHERE url_id = $1
+			AND dimension = '%s'
+			AND day >= ($6::timestamptz AT TIME ZONE 'UTC')::date
+			AND day < ($7::timestamptz AT TIME ZONE 'UTC')::date
+			AND ($5 OR NOT is_bot)
+			UNION ALL
+			SELECT %s, COUNT(*)
+			FROM click_events
+			WHERE url_id = $1
+			AND ((occurred_at >= $2 AND occurred_at < $6) OR (occurred_at >= $7 AND occurred_at < $3))
+			AND %s IS NOT NULL
+			AND ($5 OR NOT is_bot)
+			GROUP BY 1
+		) combined
+		GROUP BY value
+		ORDER BY clicks DESC
+		LIMIT $4
+	`, dimension, rawExpr, rawExpr)
+}
+
+// hourAlignedTimezone reports whether local hours in timezone start on UTC
+// hour boundaries at both ends of [from, to)
+func hourAlignedTimezone(timezone string, from, to time.Time) bool {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return false
+	}
+	for _, t := range []time.Time{from, to} {
+		if _, offset := t.In(loc).Zone(); offset%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
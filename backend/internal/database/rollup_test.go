@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitRollupRange(t *testing.T) {
+	day := 24 * time.Hour
+	at := func(d, h int) time.Time { return time.Date(2025, 9, d, h, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name          string
+		from, to      time.Time
+		watermark     time.Time
+		unit          time.Duration
+		expectedStart time.Time
+		expectedEnd   time.Time
+	}{
+		{"aligned range fully compacted", at(1, 0), at(5, 0), at(10, 0), day, at(1, 0), at(5, 0)},
+		{"partial head and tail days", at(1, 6), at(5, 18), at(10, 0), day, at(2, 0), at(5, 0)},
+		{"watermark cuts the range", at(1, 0), at(5, 0), at(3, 7), day, at(1, 0), at(3, 0)},
+		{"hourly", at(1, 6), at(1, 12), at(1, 10), time.Hour, at(1, 6), at(1, 10)},
+		{"nothing compacted", at(1, 0), at(5, 0), time.Time{}, day, at(5, 0), at(5, 0)},
+		{"range within one day", at(1, 3), at(1, 20), at(10, 0), day, at(1, 20), at(1, 20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := splitRollupRange(tt.from, tt.to, tt.watermark, tt.unit)
+			if !start.Equal(tt.expectedStart) || !end.Equal(tt.expectedEnd) {
+				t.Errorf("splitRollupRange() = [%v, %v), expected [%v, %v)", start, end, tt.expectedStart, tt.expectedEnd)
+			}
+		})
+	}
+}
+
+func TestHourAlignedTimezone(t *testing.T) {
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	tests := []struct {
+		timezone string
+		expected bool
+	}{
+		{"UTC", true},
+		{"America/New_York", true},
+		{"Asia/Kolkata", false},
+		{"Australia/Adelaide", false},
+		{"Not/A_Zone", false},
+	}
+
+	for _, tt := range tests {
+		if got := hourAlignedTimezone(tt.timezone, from, to); got != tt.expected {
+			t.Errorf("hourAlignedTimezone(%s) = %v, expected %v", tt.timezone, got, tt.expected)
+		}
+	}
+}
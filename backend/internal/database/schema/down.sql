@@ -2,7 +2,19 @@
 
 ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_not_reserved_check;
 
+DROP TABLE IF EXISTS rollup_watermarks;
+DROP TABLE IF EXISTS click_rollups_daily;
+DROP TABLE IF EXISTS click_rollups_hourly;
+DROP TABLE IF EXISTS click_uniques_daily;
 DROP TABLE IF EXISTS click_events;
+DROP FUNCTION IF EXISTS ensure_click_events_partition(date);
 DROP TABLE IF EXISTS url_counters_live;
+DROP TABLE IF EXISTS url_revisions;
+DROP TABLE IF EXISTS url_previews;
 DROP TABLE IF EXISTS reserved_codes;
+DROP TABLE IF EXISTS url_audit_log;
+DROP FUNCTION IF EXISTS url_audit_log_append_only();
+DROP TABLE IF EXISTS code_lengths;
+DROP SEQUENCE IF EXISTS short_code_seq;
+DROP TABLE IF EXISTS code_tombstones;
 DROP TABLE IF EXISTS urls;
@@ -4,13 +4,67 @@ CREATE TABLE urls (
   target_url text NOT NULL,
   is_active boolean NOT NULL DEFAULT true,
   created_at timestamptz NOT NULL DEFAULT now(),
-  expires_at timestamptz
+  expires_at timestamptz,
+  deleted_at timestamptz, -- Soft-deleted, restorable until purged
+  revision integer NOT NULL DEFAULT 1, -- Current row of url_revisions
+  quarantined_at timestamptz, -- Flagged by a destination scan, not redirecting
+  quarantine_reason text,
+  probe_status text, -- Outcome of the last destination probe
+  probe_status_code integer,
+  final_url text, -- Where the redirect chain ended
+  probed_at timestamptz,
+  owner_id bigint, -- Creator, scoping deduplication of targets
+  target_hash bytea GENERATED ALWAYS AS (sha256(convert_to(target_url, 'UTF8'))) STORED
 );
 
 CREATE UNIQUE INDEX urls_short_code_uniq ON urls (short_code);
 CREATE INDEX urls_created_at_idx ON urls (created_at DESC);
 CREATE INDEX urls_active_idx ON urls (is_active);
 CREATE INDEX urls_expiry_idx ON urls (expires_at);
+CREATE INDEX urls_deleted_idx ON urls (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX urls_quarantined_idx ON urls (quarantined_at) WHERE quarantined_at IS NOT NULL;
+CREATE INDEX urls_probe_failed_idx ON urls (probed_at DESC) WHERE probe_status <> 'ok';
+CREATE INDEX urls_target_hash_idx ON urls (target_hash, owner_id) WHERE deleted_at IS NULL;
+
+-- Codes of permanently deleted URLs, never handed out again
+CREATE TABLE code_tombstones (
+  short_code text PRIMARY KEY,
+  deleted_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Ids of sequence-strategy short codes, leased in blocks by each instance
+CREATE SEQUENCE short_code_seq;
+
+-- Length of random codes per alphabet, grown as the shorter keyspace fills
+CREATE TABLE code_lengths (
+  alphabet text PRIMARY KEY,
+  length integer NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Numbered snapshots of a link's target and options, one per edit
+CREATE TABLE url_revisions (
+  url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+  revision integer NOT NULL,
+  target_url text NOT NULL,
+  is_active boolean NOT NULL,
+  expires_at timestamptz,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (url_id, revision)
+);
+
+-- Link preview metadata fetched from the destination, and per-link overrides
+CREATE TABLE url_previews (
+  url_id bigint PRIMARY KEY REFERENCES urls(id) ON DELETE CASCADE,
+  title text,
+  description text,
+  image_url text,
+  fetched_at timestamptz,
+  fetch_error text, -- Of the last fetch; earlier metadata is kept
+  title_override text,
+  description_override text,
+  image_url_override text
+);
 
 CREATE TABLE url_counters_live (
   url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
@@ -20,8 +74,11 @@ CREATE TABLE url_counters_live (
   PRIMARY KEY (url_id, shard_id)
 );
 
+-- Raw clicks, range-partitioned by UTC month so expired months can be dropped
+-- whole. Partitions are created ahead of time by the maintenance worker; the
+-- default partition only catches clicks outside every existing month.
 CREATE TABLE click_events (
-  id bigserial PRIMARY KEY,
+  id bigserial,
   url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
   occurred_at timestamptz NOT NULL DEFAULT now(),
   ip inet,
@@ -32,16 +89,142 @@ CREATE TABLE click_events (
   utm_campaign text,
   utm_term text,
   utm_content text,
-  query_params jsonb
-);
+  query_params jsonb,
+  country text,
+  region text,
+  city text,
+  browser text,
+  browser_version text,
+  os text,
+  device_type text,
+  is_bot boolean NOT NULL DEFAULT false,
+  revision integer, -- Revision of the link live at click time
+  PRIMARY KEY (id, occurred_at)
+) PARTITION BY RANGE (occurred_at);
+
+CREATE TABLE click_events_default PARTITION OF click_events DEFAULT;
+
+-- Create the partition for the UTC month containing month_start. Rows for that
+-- month already in the default partition are moved into the new partition,
+-- since Postgres refuses to attach a range the default partition overlaps.
+CREATE OR REPLACE FUNCTION ensure_click_events_partition(month_start date)
+RETURNS boolean AS $$
+DECLARE
+  range_start timestamptz := date_trunc('month', month_start::timestamp) AT TIME ZONE 'UTC';
+  range_end timestamptz := (date_trunc('month', month_start::timestamp) + INTERVAL '1 month') AT TIME ZONE 'UTC';
+  partition_name text := 'click_events_p' || to_char(month_start, 'YYYYMM');
+BEGIN
+  -- Serialize creations, so the check below and CREATE TABLE are atomic
+  PERFORM pg_advisory_xact_lock(hashtext('ensure_click_events_partition'));
+
+  IF to_regclass(partition_name) IS NOT NULL THEN
+    RETURN false;
+  END IF;
+
+  CREATE TEMP TABLE click_events_moving (LIKE click_events);
+
+  WITH moved AS (
+    DELETE FROM click_events_default
+    WHERE occurred_at >= range_start AND occurred_at < range_end
+    RETURNING *
+  )
+  INSERT INTO click_events_moving SELECT * FROM moved;
+
+  EXECUTE format('CREATE TABLE %I PARTITION OF click_events FOR VALUES FROM (%L) TO (%L)',
+    partition_name, range_start, range_end);
+
+  INSERT INTO click_events SELECT * FROM click_events_moving;
+  DROP TABLE click_events_moving;
+
+  RETURN true;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT ensure_click_events_partition((now() AT TIME ZONE 'UTC')::date);
+SELECT ensure_click_events_partition((now() AT TIME ZONE 'UTC' + INTERVAL '1 month')::date);
 
 CREATE INDEX click_events_time_brin ON click_events USING brin (occurred_at);
 CREATE INDEX click_events_url_time_idx ON click_events (url_id, occurred_at DESC);
 CREATE INDEX click_events_utm_idx ON click_events (utm_source, utm_medium, utm_campaign, occurred_at DESC);
+CREATE INDEX click_events_ip_idx ON click_events (ip);
+
+-- Per-day HyperLogLog sketches of salted visitor hashes (anonymized IP + UA),
+-- merged at query time to estimate unique visitors over any date range
+CREATE TABLE click_uniques_daily (
+  url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+  day date NOT NULL,
+  is_bot boolean NOT NULL DEFAULT false,
+  sketch bytea NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (url_id, day, is_bot)
+);
+
+-- Analytics rollups compacted from click_events by the rollup worker. Queries
+-- read whole compacted units from here and the tail past the watermark from
+-- click_events. Daily uniques are already pre-aggregated in click_uniques_daily.
+CREATE TABLE click_rollups_hourly (
+  url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+  hour timestamptz NOT NULL,
+  is_bot boolean NOT NULL,
+  clicks bigint NOT NULL,
+  PRIMARY KEY (url_id, hour, is_bot)
+);
+
+CREATE INDEX click_rollups_hourly_hour_idx ON click_rollups_hourly (hour);
 
+-- Per-day click counts by dimension (referrer, browser, os, device, country)
+CREATE TABLE click_rollups_daily (
+  url_id bigint NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+  day date NOT NULL,
+  is_bot boolean NOT NULL,
+  dimension text NOT NULL,
+  value text NOT NULL,
+  clicks bigint NOT NULL,
+  PRIMARY KEY (url_id, dimension, day, is_bot, value)
+);
+
+CREATE INDEX click_rollups_daily_day_idx ON click_rollups_daily (day);
+
+-- Rollup progress: everything before the watermark has been compacted
+CREATE TABLE rollup_watermarks (
+  rollup text PRIMARY KEY,
+  watermark timestamptz NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
 
+-- Append-only trail of link and reserved code mutations. There is no foreign
+-- key to urls so the history of purged links is kept.
+CREATE TABLE url_audit_log (
+  id bigserial PRIMARY KEY,
+  url_id bigint,
+  short_code text NOT NULL,
+  action text NOT NULL,
+  actor text NOT NULL,
+  ip inet,
+  user_agent text,
+  changes jsonb, -- {"field": {"before": ..., "after": ...}}
+  occurred_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX url_audit_log_code_idx ON url_audit_log (short_code, id DESC);
+CREATE INDEX url_audit_log_time_idx ON url_audit_log (occurred_at);
+
+CREATE OR REPLACE FUNCTION url_audit_log_append_only()
+RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'url_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER url_audit_log_append_only
+  BEFORE UPDATE OR DELETE ON url_audit_log
+  FOR EACH ROW EXECUTE FUNCTION url_audit_log_append_only();
+
+-- Codes links may not use. Glob and regex reservations are patterns matched
+-- by the service; the trigger below enforces exact codes.
 CREATE TABLE reserved_codes (
   code text PRIMARY KEY,
+  kind text NOT NULL DEFAULT 'exact' CHECK (kind IN ('exact', 'glob', 'regex')),
   reason text NOT NULL,
   description text,
   created_at timestamptz NOT NULL DEFAULT now()
@@ -53,12 +236,19 @@ CREATE INDEX reserved_codes_reason_idx ON reserved_codes (reason);
 -- Enforce that no new URLs may use a reserved code via trigger instead of a CHECK constraint,
 -- since PostgreSQL does not support subqueries in CHECK constraints.
 
--- Create a trigger function to check reserved codes on insert/update
+-- Create a trigger function to check reserved codes on insert/update, and retired codes on insert
 CREATE OR REPLACE FUNCTION prevent_reserved_short_code()
 RETURNS trigger AS $$
 BEGIN
-  IF EXISTS (SELECT 1 FROM reserved_codes WHERE code = NEW.short_code) THEN
-    RAISE EXCEPTION 'short_code "%" is reserved and cannot be used', NEW.short_code;
+  -- Raised as unique violations: the code is taken, and generated codes are
+  -- retried with another
+  IF EXISTS (SELECT 1 FROM reserved_codes WHERE code = NEW.short_code AND kind = 'exact') THEN
+    RAISE EXCEPTION 'short_code "%" is reserved and cannot be used', NEW.short_code
+      USING ERRCODE = 'unique_violation';
+  END IF;
+  IF TG_OP = 'INSERT' AND EXISTS (SELECT 1 FROM code_tombstones WHERE short_code = NEW.short_code) THEN
+    RAISE EXCEPTION 'short_code "%" is retired and cannot be reused', NEW.short_code
+      USING ERRCODE = 'unique_violation';
   END IF;
   RETURN NEW;
 END;
@@ -71,10 +261,12 @@ FOR EACH ROW
 EXECUTE FUNCTION prevent_reserved_short_code();
 
 -- Performance indexes for analytics queries
-CREATE INDEX IF NOT EXISTS click_events_url_date_idx
-    ON click_events(url_id, DATE(occurred_at));
 CREATE INDEX IF NOT EXISTS click_events_referrer_idx
     ON click_events(url_id, referrer) WHERE referrer IS NOT NULL;
-CREATE INDEX IF NOT EXISTS click_events_ua_idx
-    ON click_events(url_id, ua) WHERE ua IS NOT NULL;
+CREATE INDEX IF NOT EXISTS click_events_agent_idx
+    ON click_events(url_id, browser, os, device_type);
+CREATE INDEX IF NOT EXISTS click_events_bot_idx
+    ON click_events(url_id) WHERE is_bot;
+CREATE INDEX IF NOT EXISTS click_events_geo_idx
+    ON click_events(url_id, country, city) WHERE country IS NOT NULL;
 
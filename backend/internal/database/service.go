@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"backend/internal/hll"
 	"backend/internal/models"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -337,6 +338,26 @@ func (s *service) CreateURL(ctx context.Context, url *models.URL) error {
 	return s.repository.CreateURL(ctx, url)
 }
 
+func (s *service) CreateOrGetURL(ctx context.Context, url *models.URL, newCode func(context.Context) (string, error)) (*models.URL, bool, error) {
+	return s.repository.CreateOrGetURL(ctx, url, newCode)
+}
+
+func (s *service) AllocateCodeIDs(ctx context.Context, count int) ([]int64, error) {
+	return s.repository.AllocateCodeIDs(ctx, count)
+}
+
+func (s *service) CountCodesByLength(ctx context.Context) (map[int]int64, error) {
+	return s.repository.CountCodesByLength(ctx)
+}
+
+func (s *service) GetCodeLength(ctx context.Context, alphabet string) (int, error) {
+	return s.repository.GetCodeLength(ctx, alphabet)
+}
+
+func (s *service) SetCodeLength(ctx context.Context, alphabet string, from, length int) (bool, error) {
+	return s.repository.SetCodeLength(ctx, alphabet, from, length)
+}
+
 func (s *service) GetURLByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
 	return s.repository.GetURLByShortCode(ctx, shortCode)
 }
@@ -357,8 +378,92 @@ func (s *service) IsReservedCode(ctx context.Context, code string) (bool, error)
 	return s.repository.IsReservedCode(ctx, code)
 }
 
-func (s *service) AddReservedCode(ctx context.Context, code, reason, description string) error {
-	return s.repository.AddReservedCode(ctx, code, reason, description)
+func (s *service) AddReservedCode(ctx context.Context, reservation *models.ReservedCode) error {
+	return s.repository.AddReservedCode(ctx, reservation)
+}
+
+func (s *service) ListReservedCodes(ctx context.Context) ([]*models.ReservedCode, error) {
+	return s.repository.ListReservedCodes(ctx)
+}
+
+func (s *service) RemoveReservedCode(ctx context.Context, code string) (bool, error) {
+	return s.repository.RemoveReservedCode(ctx, code)
+}
+
+func (s *service) SoftDeleteURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	return s.repository.SoftDeleteURL(ctx, shortCode)
+}
+
+func (s *service) RestoreURL(ctx context.Context, shortCode string, deletedAfter time.Time) (*models.URL, error) {
+	return s.repository.RestoreURL(ctx, shortCode, deletedAfter)
+}
+
+func (s *service) PurgeDeletedURLs(ctx context.Context, cutoff time.Time, limit int) ([]string, error) {
+	return s.repository.PurgeDeletedURLs(ctx, cutoff, limit)
+}
+
+func (s *service) IsCodeTombstoned(ctx context.Context, code string) (bool, error) {
+	return s.repository.IsCodeTombstoned(ctx, code)
+}
+
+func (s *service) EraseClickEvents(ctx context.Context, q *ErasureQuery) (int64, error) {
+	return s.repository.EraseClickEvents(ctx, q)
+}
+
+func (s *service) GetURLRevisions(ctx context.Context, urlID int64) ([]models.URLRevision, error) {
+	return s.repository.GetURLRevisions(ctx, urlID)
+}
+
+func (s *service) GetURLRevision(ctx context.Context, urlID int64, revision int) (*models.URLRevision, error) {
+	return s.repository.GetURLRevision(ctx, urlID, revision)
+}
+
+func (s *service) GetRevisionStats(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) ([]models.RevisionStat, error) {
+	return s.repository.GetRevisionStats(ctx, urlID, from, to, includeBots)
+}
+
+func (s *service) GetURLsToScan(ctx context.Context, afterID int64, limit int) ([]*models.URL, error) {
+	return s.repository.GetURLsToScan(ctx, afterID, limit)
+}
+
+func (s *service) QuarantineURL(ctx context.Context, id int64, reason string) (*models.URL, error) {
+	return s.repository.QuarantineURL(ctx, id, reason)
+}
+
+func (s *service) ReleaseURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	return s.repository.ReleaseURL(ctx, shortCode)
+}
+
+func (s *service) GetQuarantinedURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	return s.repository.GetQuarantinedURLs(ctx, limit)
+}
+
+func (s *service) RecordProbe(ctx context.Context, id int64, result *models.ProbeResult) (*models.URL, error) {
+	return s.repository.RecordProbe(ctx, id, result)
+}
+
+func (s *service) GetBrokenURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	return s.repository.GetBrokenURLs(ctx, limit)
+}
+
+func (s *service) GetURLPreview(ctx context.Context, urlID int64) (*models.URLPreview, error) {
+	return s.repository.GetURLPreview(ctx, urlID)
+}
+
+func (s *service) SavePreviewFetch(ctx context.Context, urlID int64, fetched *models.LinkPreview, fetchedAt time.Time, fetchErr *string) error {
+	return s.repository.SavePreviewFetch(ctx, urlID, fetched, fetchedAt, fetchErr)
+}
+
+func (s *service) SetPreviewOverride(ctx context.Context, urlID int64, override *models.LinkPreview) error {
+	return s.repository.SetPreviewOverride(ctx, urlID, override)
+}
+
+func (s *service) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	return s.repository.RecordAudit(ctx, entry)
+}
+
+func (s *service) GetAuditLog(ctx context.Context, q *AuditQuery) ([]models.AuditEntry, error) {
+	return s.repository.GetAuditLog(ctx, q)
 }
 
 func (s *service) RecordClick(ctx context.Context, click *models.ClickEvent) error {
@@ -369,16 +474,32 @@ func (s *service) GetClickCount(ctx context.Context, urlID int64) (int64, error)
 	return s.repository.GetClickCount(ctx, urlID)
 }
 
-func (s *service) GetLastClicked(ctx context.Context, urlID int64) (*time.Time, error) {
-	return s.repository.GetLastClicked(ctx, urlID)
+func (s *service) GetBotClickCount(ctx context.Context, urlID int64) (int64, error) {
+	return s.repository.GetBotClickCount(ctx, urlID)
+}
+
+func (s *service) GetLastClicked(ctx context.Context, urlID int64, includeBots bool) (*time.Time, error) {
+	return s.repository.GetLastClicked(ctx, urlID, includeBots)
 }
 
 func (s *service) UpdateCounterShards(ctx context.Context, urlID int64) error {
 	return s.repository.UpdateCounterShards(ctx, urlID)
 }
 
-func (s *service) CleanupExpiredURLs(ctx context.Context) (int64, error) {
-	return s.repository.CleanupExpiredURLs(ctx)
+func (s *service) MergeUniqueSketch(ctx context.Context, urlID int64, day time.Time, isBot bool, sketch *hll.Sketch) error {
+	return s.repository.MergeUniqueSketch(ctx, urlID, day, isBot, sketch)
+}
+
+func (s *service) GetUniqueSketches(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (map[string]*hll.Sketch, error) {
+	return s.repository.GetUniqueSketches(ctx, urlID, from, to, includeBots)
+}
+
+func (s *service) CleanupExpiredURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	return s.repository.CleanupExpiredURLs(ctx, limit)
+}
+
+func (s *service) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	return s.repository.WithAdvisoryLock(ctx, key, fn)
 }
 
 func (s *service) GetURLsCreatedSince(ctx context.Context, since time.Time, limit int) ([]*models.URL, error) {
@@ -386,20 +507,92 @@ func (s *service) GetURLsCreatedSince(ctx context.Context, since time.Time, limi
 }
 
 // New analytics method delegations
-func (s *service) GetClicksByDay(ctx context.Context, urlID int64, days int) ([]models.DayStat, error) {
-	return s.repository.GetClicksByDay(ctx, urlID, days)
+func (s *service) GetClickSeries(ctx context.Context, urlID int64, from, to time.Time, granularity, timezone string, includeBots bool) ([]models.TimeBucket, error) {
+	return s.repository.GetClickSeries(ctx, urlID, from, to, granularity, timezone, includeBots)
+}
+
+func (s *service) GetClickCountInRange(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (int64, error) {
+	return s.repository.GetClickCountInRange(ctx, urlID, from, to, includeBots)
+}
+
+func (s *service) GetTopReferrers(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.ReferrerStat, error) {
+	return s.repository.GetTopReferrers(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetBrowserStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.BrowserStat, error) {
+	return s.repository.GetBrowserStats(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetOSStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.OSStat, error) {
+	return s.repository.GetOSStats(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetDeviceStats(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.DeviceStat, error) {
+	return s.repository.GetDeviceStats(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetTopCountries(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CountryStat, error) {
+	return s.repository.GetTopCountries(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetTopCities(ctx context.Context, urlID int64, from, to time.Time, limit int, includeBots bool) ([]models.CityStat, error) {
+	return s.repository.GetTopCities(ctx, urlID, from, to, limit, includeBots)
+}
+
+func (s *service) GetAnalyticsBatch(ctx context.Context, urlID int64, from, to time.Time, referrerLimit int, browserLimit int, includeBots bool) (*AnalyticsBatch, error) {
+	return s.repository.GetAnalyticsBatch(ctx, urlID, from, to, referrerLimit, browserLimit, includeBots)
+}
+
+func (s *service) RollupHour(ctx context.Context, hour time.Time) (int64, error) {
+	return s.repository.RollupHour(ctx, hour)
+}
+
+func (s *service) RollupDay(ctx context.Context, day time.Time) (int64, error) {
+	return s.repository.RollupDay(ctx, day)
+}
+
+func (s *service) GetRollupWatermark(ctx context.Context, rollup string) (time.Time, error) {
+	return s.repository.GetRollupWatermark(ctx, rollup)
+}
+
+func (s *service) SetRollupWatermark(ctx context.Context, rollup string, watermark time.Time) error {
+	return s.repository.SetRollupWatermark(ctx, rollup, watermark)
+}
+
+func (s *service) GetEarliestClickTime(ctx context.Context) (time.Time, error) {
+	return s.repository.GetEarliestClickTime(ctx)
+}
+
+func (s *service) GetCampaignStats(ctx context.Context, q *CampaignQuery) ([]models.CampaignStat, error) {
+	return s.repository.GetCampaignStats(ctx, q)
+}
+
+func (s *service) GetClickEventsPage(ctx context.Context, q *ClickExportQuery) ([]models.ClickEvent, error) {
+	return s.repository.GetClickEventsPage(ctx, q)
+}
+
+func (s *service) NotifyClick(ctx context.Context, payload string) error {
+	return s.repository.NotifyClick(ctx, payload)
+}
+
+func (s *service) ListenClicks(ctx context.Context, fn func(payload string)) error {
+	return s.repository.ListenClicks(ctx, fn)
+}
+
+func (s *service) EnsureClickPartition(ctx context.Context, month time.Time) (bool, error) {
+	return s.repository.EnsureClickPartition(ctx, month)
 }
 
-func (s *service) GetTopReferrers(ctx context.Context, urlID int64, days int, limit int) ([]models.ReferrerStat, error) {
-	return s.repository.GetTopReferrers(ctx, urlID, days, limit)
+func (s *service) ListClickPartitions(ctx context.Context) ([]time.Time, error) {
+	return s.repository.ListClickPartitions(ctx)
 }
 
-func (s *service) GetBrowserStats(ctx context.Context, urlID int64, days int, limit int) ([]models.BrowserStat, error) {
-	return s.repository.GetBrowserStats(ctx, urlID, days, limit)
+func (s *service) DropClickPartition(ctx context.Context, month time.Time) error {
+	return s.repository.DropClickPartition(ctx, month)
 }
 
-func (s *service) GetAnalyticsBatch(ctx context.Context, urlID int64, days int, referrerLimit int, browserLimit int) (*AnalyticsBatch, error) {
-	return s.repository.GetAnalyticsBatch(ctx, urlID, days, referrerLimit, browserLimit)
+func (s *service) PurgeClickEventsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	return s.repository.PurgeClickEventsBefore(ctx, cutoff)
 }
 
 // GetDB returns the underlying database connection (for advanced use cases)
@@ -0,0 +1,112 @@
+package geoip
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+var (
+	ErrInvalidIP = errors.New("invalid IP address")
+)
+
+// Location holds the geographic information resolved for an IP address
+type Location struct {
+	CountryCode string `json:"country_code"` // ISO 3166-1 alpha-2
+	Country     string `json:"country"`
+	RegionCode  string `json:"region_code"`
+	Region      string `json:"region"`
+	City        string `json:"city"`
+}
+
+// Resolver resolves IP addresses to locations
+type Resolver interface {
+	// Lookup returns the location for ip, or nil if the database has no record
+	Lookup(ip string) (*Location, error)
+	Close() error
+}
+
+// cityRecord mirrors the subset of the GeoIP2/GeoLite2 City schema we use
+type cityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// MMDBResolver resolves locations from a local MaxMind-format (.mmdb) database
+type MMDBResolver struct {
+	reader *maxminddb.Reader
+	lang   string
+}
+
+// Ensure MMDBResolver implements Resolver interface
+var _ Resolver = (*MMDBResolver)(nil)
+
+// Open opens a City-style .mmdb database from disk
+func Open(path string) (*MMDBResolver, error) {
+	log.Printf("[GEOIP] Opening database: %s", path)
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		log.Printf("[GEOIP] ERROR: Failed to open database %s: %v", path, err)
+		return nil, fmt.Errorf("failed to open GeoIP database: %w", err)
+	}
+
+	log.Printf("[GEOIP] SUCCESS: Loaded %s database (built %s)",
+		reader.Metadata.DatabaseType, reader.Metadata.BuildTime().Format("2006-01-02"))
+	return &MMDBResolver{reader: reader, lang: "en"}, nil
+}
+
+// Lookup resolves an IP address to a location
+func (r *MMDBResolver) Lookup(ip string) (*Location, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIP, ip)
+	}
+
+	result := r.reader.Lookup(addr.Unmap())
+	if !result.Found() {
+		return nil, result.Err()
+	}
+
+	var record cityRecord
+	if err := result.Decode(&record); err != nil {
+		return nil, fmt.Errorf("failed to decode GeoIP record: %w", err)
+	}
+
+	loc := &Location{
+		CountryCode: record.Country.ISOCode,
+		Country:     r.name(record.Country.Names),
+		City:        r.name(record.City.Names),
+	}
+
+	// The first subdivision is the largest (e.g. state or province)
+	if len(record.Subdivisions) > 0 {
+		loc.RegionCode = record.Subdivisions[0].ISOCode
+		loc.Region = r.name(record.Subdivisions[0].Names)
+	}
+
+	return loc, nil
+}
+
+// name picks the configured language from a names map
+func (r *MMDBResolver) name(names map[string]string) string {
+	return names[r.lang]
+}
+
+// Close releases the underlying database
+func (r *MMDBResolver) Close() error {
+	return r.reader.Close()
+}
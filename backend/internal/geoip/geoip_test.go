@@ -0,0 +1,122 @@
+package geoip
+
+import (
+	"errors"
+	"testing"
+)
+
+// testdata/city-test.mmdb is a small City-schema fixture containing:
+//   81.2.69.0/24    GB / England (ENG) / London
+//   89.160.20.0/24  SE / Östergötland County (E) / Linköping
+//   216.160.83.0/24 US / Washington (WA) / Milton
+//   67.43.156.0/24  BT (country only)
+//   2001:480::/32   US (country only)
+const fixturePath = "testdata/city-test.mmdb"
+
+func openFixture(t *testing.T) *MMDBResolver {
+	t.Helper()
+
+	r, err := Open(fixturePath)
+	if err != nil {
+		t.Fatalf("Open(%s) unexpected error: %v", fixturePath, err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestOpen_MissingFile(t *testing.T) {
+	if _, err := Open("testdata/does-not-exist.mmdb"); err == nil {
+		t.Errorf("Open() expected error for missing file, got none")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	r := openFixture(t)
+
+	tests := []struct {
+		name     string
+		ip       string
+		expected *Location
+	}{
+		{
+			name: "city record",
+			ip:   "81.2.69.142",
+			expected: &Location{
+				CountryCode: "GB", Country: "United Kingdom",
+				RegionCode: "ENG", Region: "England", City: "London",
+			},
+		},
+		{
+			name: "non-ascii names",
+			ip:   "89.160.20.112",
+			expected: &Location{
+				CountryCode: "SE", Country: "Sweden",
+				RegionCode: "E", Region: "Östergötland County", City: "Linköping",
+			},
+		},
+		{
+			name: "us state",
+			ip:   "216.160.83.56",
+			expected: &Location{
+				CountryCode: "US", Country: "United States",
+				RegionCode: "WA", Region: "Washington", City: "Milton",
+			},
+		},
+		{
+			name:     "country only",
+			ip:       "67.43.156.1",
+			expected: &Location{CountryCode: "BT", Country: "Bhutan"},
+		},
+		{
+			name:     "ipv6",
+			ip:       "2001:480::1",
+			expected: &Location{CountryCode: "US", Country: "United States"},
+		},
+		{
+			name: "ipv4-mapped ipv6",
+			ip:   "::ffff:81.2.69.142",
+			expected: &Location{
+				CountryCode: "GB", Country: "United Kingdom",
+				RegionCode: "ENG", Region: "England", City: "London",
+			},
+		},
+		{
+			name:     "not in database",
+			ip:       "8.8.8.8",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc, err := r.Lookup(tt.ip)
+			if err != nil {
+				t.Fatalf("Lookup(%s) unexpected error: %v", tt.ip, err)
+			}
+
+			if tt.expected == nil {
+				if loc != nil {
+					t.Errorf("Lookup(%s) = %+v, expected nil", tt.ip, loc)
+				}
+				return
+			}
+
+			if loc == nil {
+				t.Fatalf("Lookup(%s) = nil, expected %+v", tt.ip, tt.expected)
+			}
+			if *loc != *tt.expected {
+				t.Errorf("Lookup(%s) = %+v, expected %+v", tt.ip, loc, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLookup_InvalidIP(t *testing.T) {
+	r := openFixture(t)
+
+	for _, ip := range []string{"", "not-an-ip", "300.1.1.1"} {
+		if _, err := r.Lookup(ip); !errors.Is(err, ErrInvalidIP) {
+			t.Errorf("Lookup(%q) error = %v, expected %v", ip, err, ErrInvalidIP)
+		}
+	}
+}
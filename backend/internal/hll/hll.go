@@ -0,0 +1,267 @@
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	// Precision bounds (number of index bits). 2^p registers are used.
+	MinPrecision = 4
+	MaxPrecision = 16
+
+	// DefaultPrecision gives 16384 registers, a standard error of ~0.81%
+	DefaultPrecision = 14
+
+	// Encoding format
+	encodingVersion = 1
+	formatSparse    = 0
+	formatDense     = 1
+)
+
+var (
+	ErrInvalidPrecision  = errors.New("hll: precision must be between 4 and 16")
+	ErrPrecisionMismatch = errors.New("hll: cannot merge sketches with different precision")
+	ErrInvalidEncoding   = errors.New("hll: invalid sketch encoding")
+)
+
+// Sketch is a HyperLogLog cardinality estimator.
+// Small sketches are kept in a sparse representation and switch to a dense
+// register array once that becomes the smaller encoding.
+// A Sketch is not safe for concurrent use.
+type Sketch struct {
+	p      uint8
+	m      uint32
+	dense  []uint8          // nil while the sketch is sparse
+	sparse map[uint16]uint8 // register index -> rank, used while dense is nil
+}
+
+// New creates an empty sketch with the given precision
+func New(precision uint8) (*Sketch, error) {
+	if precision < MinPrecision || precision > MaxPrecision {
+		return nil, ErrInvalidPrecision
+	}
+	return &Sketch{
+		p:      precision,
+		m:      1 << precision,
+		sparse: make(map[uint16]uint8),
+	}, nil
+}
+
+// NewDefault creates an empty sketch with DefaultPrecision
+func NewDefault() *Sketch {
+	s, _ := New(DefaultPrecision)
+	return s
+}
+
+// Precision returns the number of index bits used by the sketch
+func (s *Sketch) Precision() uint8 {
+	return s.p
+}
+
+// Insert adds a 64-bit hash to the sketch.
+// The caller is responsible for supplying well-distributed hashes.
+func (s *Sketch) Insert(hash uint64) {
+	idx := uint16(hash >> (64 - s.p))
+	// Set a guard bit so the rank never exceeds 64-p+1
+	w := hash<<s.p | 1<<(s.p-1)
+	rank := uint8(bits.LeadingZeros64(w) + 1)
+	s.setRegister(idx, rank)
+}
+
+// setRegister stores rank at idx if it is larger than the current value
+func (s *Sketch) setRegister(idx uint16, rank uint8) {
+	if s.dense != nil {
+		if rank > s.dense[idx] {
+			s.dense[idx] = rank
+		}
+		return
+	}
+
+	if rank > s.sparse[idx] {
+		s.sparse[idx] = rank
+		s.maybeDensify()
+	}
+}
+
+// maybeDensify converts a sparse sketch to dense once the sparse encoding
+// (roughly 3 bytes per entry) would outgrow the dense register array
+func (s *Sketch) maybeDensify() {
+	if s.dense != nil || uint32(len(s.sparse))*3 < s.m {
+		return
+	}
+
+	s.dense = make([]uint8, s.m)
+	for idx, rank := range s.sparse {
+		s.dense[idx] = rank
+	}
+	s.sparse = nil
+}
+
+// Merge folds other into s. Both sketches must share the same precision.
+func (s *Sketch) Merge(other *Sketch) error {
+	if other == nil {
+		return nil
+	}
+	if s.p != other.p {
+		return ErrPrecisionMismatch
+	}
+
+	if other.dense != nil {
+		if s.dense == nil {
+			s.dense = make([]uint8, s.m)
+			for idx, rank := range s.sparse {
+				s.dense[idx] = rank
+			}
+			s.sparse = nil
+		}
+		for i, rank := range other.dense {
+			if rank > s.dense[i] {
+				s.dense[i] = rank
+			}
+		}
+		return nil
+	}
+
+	for idx, rank := range other.sparse {
+		s.setRegister(idx, rank)
+	}
+	return nil
+}
+
+// Estimate returns the estimated number of distinct hashes inserted
+func (s *Sketch) Estimate() uint64 {
+	m := float64(s.m)
+
+	var sum float64
+	var zeros uint32
+	if s.dense != nil {
+		for _, rank := range s.dense {
+			sum += math.Ldexp(1, -int(rank))
+			if rank == 0 {
+				zeros++
+			}
+		}
+	} else {
+		for _, rank := range s.sparse {
+			sum += math.Ldexp(1, -int(rank))
+		}
+		zeros = s.m - uint32(len(s.sparse))
+		sum += float64(zeros) // 2^-0 for every empty register
+	}
+
+	estimate := alpha(s.m) * m * m / sum
+
+	// Small-range correction: linear counting is more accurate while
+	// there are still empty registers
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// alpha returns the bias-correction constant for m registers
+func alpha(m uint32) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
+
+// MarshalBinary encodes the sketch as
+// [version][precision][format][payload]
+// where the sparse payload is a uvarint entry count followed by
+// (uvarint index delta, rank) pairs and the dense payload is the raw registers
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	if s.dense != nil {
+		buf := make([]byte, 0, 3+len(s.dense))
+		buf = append(buf, encodingVersion, s.p, formatDense)
+		return append(buf, s.dense...), nil
+	}
+
+	indexes := make([]int, 0, len(s.sparse))
+	for idx := range s.sparse {
+		indexes = append(indexes, int(idx))
+	}
+	sort.Ints(indexes)
+
+	buf := make([]byte, 0, 3+binary.MaxVarintLen32+len(indexes)*3)
+	buf = append(buf, encodingVersion, s.p, formatSparse)
+	buf = binary.AppendUvarint(buf, uint64(len(indexes)))
+	prev := 0
+	for _, idx := range indexes {
+		buf = binary.AppendUvarint(buf, uint64(idx-prev))
+		buf = append(buf, s.sparse[uint16(idx)])
+		prev = idx
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a sketch produced by MarshalBinary
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) < 3 || data[0] != encodingVersion {
+		return ErrInvalidEncoding
+	}
+
+	decoded, err := New(data[1])
+	if err != nil {
+		return err
+	}
+
+	payload := data[3:]
+	switch data[2] {
+	case formatDense:
+		if uint32(len(payload)) != decoded.m {
+			return ErrInvalidEncoding
+		}
+		decoded.dense = append([]uint8(nil), payload...)
+		decoded.sparse = nil
+
+	case formatSparse:
+		count, n := binary.Uvarint(payload)
+		if n <= 0 || count > uint64(decoded.m) {
+			return ErrInvalidEncoding
+		}
+		payload = payload[n:]
+
+		idx := uint64(0)
+		for i := uint64(0); i < count; i++ {
+			delta, n := binary.Uvarint(payload)
+			if n <= 0 || len(payload) < n+1 {
+				return ErrInvalidEncoding
+			}
+			idx += delta
+			if idx >= uint64(decoded.m) {
+				return ErrInvalidEncoding
+			}
+			decoded.sparse[uint16(idx)] = payload[n]
+			payload = payload[n+1:]
+		}
+		decoded.maybeDensify()
+
+	default:
+		return ErrInvalidEncoding
+	}
+
+	*s = *decoded
+	return nil
+}
+
+// FromBytes decodes a sketch produced by MarshalBinary
+func FromBytes(data []byte) (*Sketch, error) {
+	s := &Sketch{}
+	if err := s.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,233 @@
+package hll
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// testHash returns a well-distributed 64-bit hash for test keys
+func testHash(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision uint8
+		wantError bool
+	}{
+		{"minimum precision", MinPrecision, false},
+		{"default precision", DefaultPrecision, false},
+		{"maximum precision", MaxPrecision, false},
+		{"too small", MinPrecision - 1, true},
+		{"too large", MaxPrecision + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.precision)
+			if tt.wantError {
+				if err == nil {
+					t.Errorf("New(%d) expected error, got none", tt.precision)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New(%d) unexpected error: %v", tt.precision, err)
+			}
+			if s.Estimate() != 0 {
+				t.Errorf("Empty sketch Estimate() = %d, expected 0", s.Estimate())
+			}
+		})
+	}
+}
+
+func TestEstimateErrorBound(t *testing.T) {
+	// Standard error for HyperLogLog is 1.04/sqrt(m); allow 3 standard errors
+	stdErr := 1.04 / math.Sqrt(float64(uint32(1)<<DefaultPrecision))
+	tolerance := 3 * stdErr
+
+	cardinalities := []int{10, 100, 1000, 10000, 50000, 100000, 500000}
+	if testing.Short() {
+		cardinalities = cardinalities[:5]
+	}
+
+	for _, n := range cardinalities {
+		t.Run(fmt.Sprintf("n=%d", n), func(t *testing.T) {
+			s := NewDefault()
+			for i := 0; i < n; i++ {
+				s.Insert(testHash(fmt.Sprintf("visitor-%d", i)))
+			}
+
+			estimate := float64(s.Estimate())
+			relErr := math.Abs(estimate-float64(n)) / float64(n)
+
+			// Very small cardinalities are effectively exact via linear counting
+			if n <= 100 && math.Abs(estimate-float64(n)) > 1 {
+				t.Errorf("Estimate() = %.0f, expected %d (+/-1)", estimate, n)
+			}
+			if relErr > tolerance {
+				t.Errorf("Estimate() = %.0f for n=%d, relative error %.4f exceeds %.4f",
+					estimate, n, relErr, tolerance)
+			}
+			t.Logf("n=%d estimate=%.0f relErr=%.4f", n, estimate, relErr)
+		})
+	}
+}
+
+func TestInsertDuplicates(t *testing.T) {
+	s := NewDefault()
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 500; j++ {
+			s.Insert(testHash(fmt.Sprintf("visitor-%d", j)))
+		}
+	}
+
+	estimate := s.Estimate()
+	if math.Abs(float64(estimate)-500) > 500*0.03 {
+		t.Errorf("Estimate() = %d after repeated inserts, expected ~500", estimate)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := NewDefault()
+	b := NewDefault()
+
+	// Overlapping ranges: a has [0, 30000), b has [20000, 50000)
+	for i := 0; i < 30000; i++ {
+		a.Insert(testHash(fmt.Sprintf("visitor-%d", i)))
+	}
+	for i := 20000; i < 50000; i++ {
+		b.Insert(testHash(fmt.Sprintf("visitor-%d", i)))
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() unexpected error: %v", err)
+	}
+
+	estimate := float64(a.Estimate())
+	relErr := math.Abs(estimate-50000) / 50000
+	if relErr > 0.03 {
+		t.Errorf("Merged Estimate() = %.0f, expected ~50000 (relative error %.4f)", estimate, relErr)
+	}
+}
+
+func TestMergeSparseIntoSparse(t *testing.T) {
+	a := NewDefault()
+	b := NewDefault()
+	for i := 0; i < 50; i++ {
+		a.Insert(testHash(fmt.Sprintf("a-%d", i)))
+		b.Insert(testHash(fmt.Sprintf("b-%d", i)))
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() unexpected error: %v", err)
+	}
+	if a.dense != nil {
+		t.Errorf("Merge() of small sketches should stay sparse")
+	}
+	if got := a.Estimate(); got < 99 || got > 101 {
+		t.Errorf("Merged Estimate() = %d, expected ~100", got)
+	}
+}
+
+func TestMergePrecisionMismatch(t *testing.T) {
+	a, _ := New(10)
+	b, _ := New(12)
+
+	if err := a.Merge(b); err != ErrPrecisionMismatch {
+		t.Errorf("Merge() error = %v, expected %v", err, ErrPrecisionMismatch)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		wantDense bool
+	}{
+		{"empty", 0, false},
+		{"sparse", 200, false},
+		{"dense", 20000, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewDefault()
+			for i := 0; i < tt.count; i++ {
+				s.Insert(testHash(fmt.Sprintf("visitor-%d", i)))
+			}
+
+			data, err := s.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() unexpected error: %v", err)
+			}
+
+			decoded, err := FromBytes(data)
+			if err != nil {
+				t.Fatalf("FromBytes() unexpected error: %v", err)
+			}
+
+			if (decoded.dense != nil) != tt.wantDense {
+				t.Errorf("Decoded dense = %v, expected %v", decoded.dense != nil, tt.wantDense)
+			}
+			if decoded.Estimate() != s.Estimate() {
+				t.Errorf("Decoded Estimate() = %d, expected %d", decoded.Estimate(), s.Estimate())
+			}
+			if decoded.Precision() != s.Precision() {
+				t.Errorf("Decoded Precision() = %d, expected %d", decoded.Precision(), s.Precision())
+			}
+		})
+	}
+}
+
+func TestSparseEncodingIsCompact(t *testing.T) {
+	s := NewDefault()
+	for i := 0; i < 10; i++ {
+		s.Insert(testHash(fmt.Sprintf("visitor-%d", i)))
+	}
+
+	data, _ := s.MarshalBinary()
+	if len(data) > 64 {
+		t.Errorf("Sparse encoding of 10 entries is %d bytes, expected <= 64", len(data))
+	}
+}
+
+func TestUnmarshalInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"wrong version", []byte{9, DefaultPrecision, formatSparse, 0}},
+		{"bad precision", []byte{encodingVersion, 30, formatSparse, 0}},
+		{"unknown format", []byte{encodingVersion, DefaultPrecision, 7}},
+		{"short dense", []byte{encodingVersion, DefaultPrecision, formatDense, 1, 2, 3}},
+		{"truncated sparse", []byte{encodingVersion, DefaultPrecision, formatSparse, 5, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromBytes(tt.data); err == nil {
+				t.Errorf("FromBytes() expected error, got none")
+			}
+		})
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	s := NewDefault()
+	hashes := make([]uint64, 1024)
+	for i := range hashes {
+		hashes[i] = testHash(fmt.Sprintf("visitor-%d", i))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.Insert(hashes[i%len(hashes)])
+	}
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// hostProfile maps host names as in UTS #46 for lookup: compatibility-
+// normalized and lowercased, ideographic full stops separating labels,
+// punycode labels validated, and DNS length limits enforced. Underscores
+// are allowed, as some service host names use them.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+	idna.StrictDomainName(false),
+)
+
+// hostToASCII converts a host name to its ASCII (punycode) form for DNS and
+// checks its Unicode labels for homographs, including labels already in
+// punycode
+func hostToASCII(host string) (string, error) {
+	host = strings.TrimSuffix(strings.NewReplacer("。", ".", "．", ".", "｡", ".").Replace(host), ".")
+	if host == "" {
+		return "", fmt.Errorf("%w: empty host", ErrInvalidURL)
+	}
+
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid host %q: %v", ErrInvalidURL, host, err)
+	}
+	display, err := hostProfile.ToUnicode(ascii)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid host %q: %v", ErrInvalidURL, host, err)
+	}
+
+	// Characters mapping to a dot, such as "⒈", would hide a label
+	given := strings.Split(strings.ToLower(host), ".")
+	labels := strings.Split(display, ".")
+	if len(labels) != len(given) {
+		return "", fmt.Errorf("%w: invalid host %q", ErrInvalidURL, host)
+	}
+	tld := labels[len(labels)-1]
+	for i, label := range labels {
+		if isASCII(label) {
+			// Punycode of an ASCII label hides nothing legitimate
+			if !validASCIILabel(label) || strings.HasPrefix(given[i], "xn--") {
+				return "", fmt.Errorf("%w: invalid host label %q", ErrInvalidURL, given[i])
+			}
+			continue
+		}
+		if !validUnicodeLabel(label) {
+			return "", fmt.Errorf("%w: invalid characters in host label %q", ErrInvalidURL, label)
+		}
+		if IsHomograph(label, tld) {
+			return "", fmt.Errorf("%w: %s", ErrHomographDomain, label)
+		}
+	}
+	return ascii, nil
+}
+
+// Scripts that may be mixed within a label, as in the "highly restrictive"
+// level of UTS #39: each CJK writing system with Latin
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// Cyrillic letters indistinguishable from Latin ones in most fonts
+const latinLookalikes = "асԁеһіјӏорԛѕԝхуьѵѡпг"
+
+// IsHomograph reports whether a Unicode domain label could impersonate
+// another: it mixes scripts, or it is written only in Cyrillic letters that
+// look Latin under a non-Cyrillic top-level domain (such as "аррӏе.com").
+func IsHomograph(label, tld string) bool {
+	scripts := make(map[string]bool)
+	lookalikes := true
+	for _, r := range label {
+		script := scriptOf(r)
+		if script == "" {
+			continue
+		}
+		scripts[script] = true
+		if !strings.ContainsRune(latinLookalikes, r) {
+			lookalikes = false
+		}
+	}
+
+	if len(scripts) > 1 {
+		for _, allowed := range allowedScriptSets {
+			if subsetOf(scripts, allowed) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return scripts["Cyrillic"] && lookalikes && !hasScript(tld, "Cyrillic")
+}
+
+// scriptOf returns the script of a letter, or "" for characters shared by
+// scripts such as digits, hyphens and combining marks
+func scriptOf(r rune) string {
+	if r < utf8.RuneSelf {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+			return "Latin"
+		}
+		return ""
+	}
+	for name, table := range unicode.Scripts {
+		if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+func hasScript(label, script string) bool {
+	for _, r := range label {
+		if scriptOf(r) == script {
+			return true
+		}
+	}
+	return false
+}
+
+func subsetOf(scripts map[string]bool, allowed []string) bool {
+	for script := range scripts {
+		found := false
+		for _, a := range allowed {
+			found = found || a == script
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// validASCIILabel reports whether a label holds only letters, digits,
+// hyphens and underscores, which some service host names use
+func validASCIILabel(label string) bool {
+	for i := 0; i < len(label); i++ {
+		if c := label[i]; !isUnreserved(c) || c == '.' || c == '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// validUnicodeLabel reports whether a label holds only letters, marks,
+// digits and hyphens, the characters IDNA permits in labels
+func validUnicodeLabel(label string) bool {
+	for _, r := range label {
+		if !unicode.In(r, unicode.L, unicode.M, unicode.Nd) && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHostToASCII(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"ＢÜＣＨＥＲ。example", "xn--bcher-kva.example"},
+		{"пример.рф", "xn--e1afmkfd.xn--p1ai"},
+		{"テスト.jp", "xn--zckzah.jp"},
+		{"xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"my_service.example.", "my_service.example"},
+	}
+
+	for _, tt := range tests {
+		if got, err := hostToASCII(tt.host); err != nil || got != tt.expected {
+			t.Errorf("hostToASCII(%q) = %q, %v, expected %q", tt.host, got, err, tt.expected)
+		}
+	}
+}
+
+func TestIsHomograph(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string
+		tld       string
+		homograph bool
+	}{
+		{"latin", "bücher", "de", false},
+		{"cyrillic", "пример", "рф", false},
+		{"japanese", "日本語テストabc", "jp", false},
+		{"korean with digits", "한국어123", "kr", false},
+		{"cyrillic a in latin", "pаypal", "com", true},
+		{"greek omicron in latin", "gοogle", "com", true},
+		{"latin lookalike cyrillic", "аррӏе", "com", true},
+		{"lookalike cyrillic under cyrillic tld", "аррӏе", "рф", false},
+		{"cyrillic beyond lookalikes", "яндекс", "com", false},
+		{"hangul with kana", "한국テ", "com", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsHomograph(tt.label, tt.tld); got != tt.homograph {
+			t.Errorf("%s: IsHomograph(%q, %q) = %v, expected %v", tt.name, tt.label, tt.tld, got, tt.homograph)
+		}
+	}
+}
+
+func TestHostToASCIIErrors(t *testing.T) {
+	tests := []struct {
+		host        string
+		expectedErr error
+	}{
+		{"xn--pypal-4ve.com", ErrHomographDomain}, // pаypal with a Cyrillic а
+		{"xn--abc-", ErrInvalidURL},               // ASCII in disguise
+		{"xn--BCHER-KVA.example", nil},            // Lowercased first
+		{"exa mple.com", ErrInvalidURL},
+		{"☃.com", ErrInvalidURL},
+		{"a..example", ErrInvalidURL},
+		{"a⒈example", ErrInvalidURL},
+		{strings.Repeat("a", 64) + ".example", ErrInvalidURL},
+	}
+
+	for _, tt := range tests {
+		_, err := hostToASCII(tt.host)
+		if !errors.Is(err, tt.expectedErr) || (tt.expectedErr == nil) != (err == nil) {
+			t.Errorf("hostToASCII(%q) error = %v, expected %v", tt.host, err, tt.expectedErr)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// NormalizeOptions enables normalizations that change which resource some
+// sites serve, and so are left to configuration
+type NormalizeOptions struct {
+	StripTrackingParams bool // Drop utm_* and click ID query parameters
+	SortQueryParams     bool // Order query parameters by name
+	StripFragments      bool // Drop the fragment, which single-page apps may route by
+}
+
+// Query parameters that only attribute a visit to a campaign or click
+var (
+	trackingParams = map[string]bool{
+		"fbclid": true, "gclid": true, "dclid": true, "gbraid": true, "wbraid": true,
+		"msclkid": true, "yclid": true, "twclid": true, "ttclid": true, "igshid": true,
+		"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true, "mkt_tok": true,
+	}
+	trackingParamPrefixes = []string{"utm_"}
+)
+
+// NormalizeURL normalizes a URL for consistent storage and comparison
+func NormalizeURL(rawURL string) (string, error) {
+	return NormalizeURLWith(rawURL, NormalizeOptions{})
+}
+
+// NormalizeURLWith normalizes a URL for consistent storage and comparison:
+// the scheme and host are lowercased, internationalized hosts are converted
+// to punycode, default ports, dot segments, a root path and empty query or
+// fragment are removed, and percent-encoding is made uniform (RFC 3986
+// section 6.2.2). URLs with credentials and homograph hosts are rejected.
+// Fragments are kept unless opts strips them: they never reach the server,
+// but single-page apps route by them, so they can change the page shown.
+func NormalizeURLWith(rawURL string, opts NormalizeOptions) (string, error) {
+	log.Printf("[NORMALIZE] Normalizing URL: %s", rawURL)
+
+	// Add scheme if missing
+	lower := strings.ToLower(rawURL)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		rawURL = "https://" + rawURL
+		log.Printf("[NORMALIZE] Added default HTTPS scheme: %s", rawURL)
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		log.Printf("[NORMALIZE] ERROR: Failed to parse URL: %v", err)
+		return "", err
+	}
+
+	// Credentials would be published with the link, and "trusted.com@evil.com"
+	// disguises the real host
+	if parsedURL.User != nil {
+		log.Printf("[NORMALIZE] ERROR: URL contains credentials")
+		return "", ErrURLCredentials
+	}
+
+	host, err := normalizeHost(parsedURL)
+	if err != nil {
+		log.Printf("[NORMALIZE] ERROR: Invalid host: %v", err)
+		return "", err
+	}
+	parsedURL.Host = host
+
+	path := removeDotSegments(normalizeEscapes(parsedURL.EscapedPath(), "/:@"))
+	if path == "/" {
+		path = ""
+	}
+	parsedURL.RawPath = path
+	if parsedURL.Path, err = url.PathUnescape(path); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	parsedURL.RawQuery = normalizeQuery(parsedURL.RawQuery, opts)
+	parsedURL.ForceQuery = false
+
+	if opts.StripFragments {
+		parsedURL.Fragment = ""
+	}
+	fragment := normalizeEscapes(parsedURL.EscapedFragment(), "/?:@")
+	if parsedURL.Fragment, err = url.PathUnescape(fragment); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	parsedURL.RawFragment = fragment
+
+	normalized := parsedURL.String()
+	log.Printf("[NORMALIZE] SUCCESS: Normalized URL: %s", normalized)
+	return normalized, nil
+}
+
+// normalizeHost returns the lowercase ASCII host of u without a default port
+func normalizeHost(u *url.URL) (string, error) {
+	hostname, port := u.Hostname(), u.Port()
+
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		log.Printf("[NORMALIZE] Removed default port from host: %s", hostname)
+		port = ""
+	}
+
+	if strings.Contains(hostname, ":") {
+		hostname = "[" + strings.ToLower(hostname) + "]"
+	} else {
+		ascii, err := hostToASCII(hostname)
+		if err != nil {
+			return "", err
+		}
+		hostname = ascii
+	}
+
+	if port != "" {
+		return hostname + ":" + port, nil
+	}
+	return hostname, nil
+}
+
+// normalizeQuery normalizes the encoding of each query parameter, dropping
+// empty ones, and applies the tracking and ordering options
+func normalizeQuery(rawQuery string, opts NormalizeOptions) string {
+	type param struct{ key, pair string }
+
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, hasValue := strings.Cut(pair, "=")
+		key = normalizeEscapes(key, "/?:@")
+		if opts.StripTrackingParams && isTrackingParam(key) {
+			continue
+		}
+		pair = key
+		if hasValue {
+			pair += "=" + normalizeEscapes(value, "/?:@=")
+		}
+		params = append(params, param{key, pair})
+	}
+
+	if opts.SortQueryParams {
+		// Stable, so repeated parameters keep their order
+		sort.SliceStable(params, func(i, j int) bool { return params[i].key < params[j].key })
+	}
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+func isTrackingParam(key string) bool {
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		key = unescaped
+	}
+	key = strings.ToLower(key)
+	if trackingParams[key] {
+		return true
+	}
+	for _, prefix := range trackingParamPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes decodes percent-encoded unreserved characters, uppercases
+// the hex digits of the remaining escapes and encodes every byte that is not
+// unreserved, a sub-delimiter or in allowed
+func normalizeEscapes(s, allowed string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hexDigits[decoded>>4])
+				b.WriteByte(hexDigits[decoded&15])
+			}
+			i += 2
+			continue
+		}
+		if c != '%' && (isUnreserved(c) || strings.IndexByte("!$&'()*+,;="+allowed, c) >= 0) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." segments of a path (RFC 3986
+// section 5.2.4)
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// Never above the root
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
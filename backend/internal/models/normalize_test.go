@@ -0,0 +1,85 @@
+package models
+
+import "testing"
+
+func TestNormalizeURLWith(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     NormalizeOptions
+		expected string
+	}{
+		{
+			name:     "strip tracking params",
+			input:    "https://example.com/a?utm_source=news&id=7&UTM_Medium=mail&fbclid=abc&%67clid=x",
+			opts:     NormalizeOptions{StripTrackingParams: true},
+			expected: "https://example.com/a?id=7",
+		},
+		{
+			name:     "strip only tracking leaves no query",
+			input:    "https://example.com/a?utm_campaign=launch",
+			opts:     NormalizeOptions{StripTrackingParams: true},
+			expected: "https://example.com/a",
+		},
+		{
+			name:     "sort params keeps repeated order",
+			input:    "https://example.com/?b=2&a=2&c&a=1",
+			opts:     NormalizeOptions{SortQueryParams: true},
+			expected: "https://example.com?a=2&a=1&b=2&c",
+		},
+		{
+			name:     "both",
+			input:    "https://example.com/p?z=1&utm_source=x&y=%7e",
+			opts:     NormalizeOptions{StripTrackingParams: true, SortQueryParams: true},
+			expected: "https://example.com/p?y=~&z=1",
+		},
+		{
+			name:     "strip fragment",
+			input:    "https://example.com/a?b=1#section-2",
+			opts:     NormalizeOptions{StripFragments: true},
+			expected: "https://example.com/a?b=1",
+		},
+		{
+			name:     "fragment kept by default",
+			input:    "https://example.com/a#section-2",
+			expected: "https://example.com/a#section-2",
+		},
+		{
+			name:     "values keep equals and plus",
+			input:    "https://example.com/?q=a+b=c&r=é",
+			opts:     NormalizeOptions{SortQueryParams: true},
+			expected: "https://example.com?q=a+b=c&r=%C3%A9",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeURLWith(tt.input, tt.opts)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: NormalizeURLWith(%s) = %s, expected %s", tt.name, tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/a/b/c/./../../g", "/a/g"},
+		{"/mid/content=5/../6", "/mid/6"},
+		{"/a/b/.", "/a/b/"},
+		{"/a/b/..", "/a/"},
+		{"/../../x", "/x"},
+		{"/a.b/c..d/.e", "/a.b/c..d/.e"},
+	}
+
+	for _, tt := range tests {
+		if got := removeDotSegments(tt.path); got != tt.expected {
+			t.Errorf("removeDotSegments(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+}
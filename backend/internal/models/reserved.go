@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Kinds of reservations
+const (
+	ReservationExact = "exact" // One code, matched in any case
+	ReservationGlob  = "glob"  // Shell pattern: * any run, ? one character, [a-z] a class
+	ReservationRegex = "regex" // Regular expression matching the whole code in any case
+)
+
+// Sources of reservations
+const (
+	ReservationSourceAdmin   = "admin"   // Stored in reserved_codes
+	ReservationSourceBuiltin = "builtin" // DefaultReservedCodes
+	ReservationSourceRoute   = "route"   // First path segment of a registered route
+)
+
+// DefaultReservedCodes are reserved in every deployment
+var DefaultReservedCodes = []string{"api", "www", "admin", "root", "null", "undefined"}
+
+// ReservedCode is a code, or a pattern of codes, that links may not use
+type ReservedCode struct {
+	Code        string     `json:"code"` // The code, or the pattern of glob and regex reservations
+	Kind        string     `json:"kind"`
+	Reason      string     `json:"reason"`
+	Description string     `json:"description,omitempty"`
+	Source      string     `json:"source"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"` // Only of stored reservations
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net"
 	"net/url"
 	"regexp"
 	"strings"
@@ -19,6 +18,41 @@ type URL struct {
 	IsActive  bool       `json:"is_active" db:"is_active"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // Set while awaiting permanent deletion
+	Revision  int        `json:"revision" db:"revision"`               // Number of the current revision
+
+	// Set when a scan flagged the target; the link stops redirecting until released
+	QuarantinedAt    *time.Time `json:"quarantined_at,omitempty" db:"quarantined_at"`
+	QuarantineReason *string    `json:"quarantine_reason,omitempty" db:"quarantine_reason"`
+
+	// Outcome of the last destination probe, unset until probed
+	ProbeStatus     *string    `json:"probe_status,omitempty" db:"probe_status"`
+	ProbeStatusCode *int       `json:"probe_status_code,omitempty" db:"probe_status_code"`
+	FinalURL        *string    `json:"final_url,omitempty" db:"final_url"`
+	ProbedAt        *time.Time `json:"probed_at,omitempty" db:"probed_at"`
+
+	OwnerID *int64 `json:"owner_id,omitempty" db:"owner_id"` // Creator, when known
+}
+
+// Destination probe outcomes, worst first
+const (
+	ProbeBlocked          = "blocked"            // A hop pointed at an internal address
+	ProbeRedirectLoop     = "redirect_loop"      // A hop redirected to an earlier one
+	ProbeTooManyRedirects = "too_many_redirects" // The chain exceeded the redirect limit
+	ProbeUnreachable      = "unreachable"        // A hop failed to respond
+	ProbeBroken           = "broken"             // The final destination answered with an error
+	ProbeNestedShortener  = "nested_shortener"   // The chain passes through a URL shortener
+	ProbeOK               = "ok"
+)
+
+// ProbeResult is the outcome of following a link's redirect chain
+type ProbeResult struct {
+	Status     string    `json:"status"`
+	StatusCode int       `json:"status_code,omitempty"` // Of the last response received
+	FinalURL   string    `json:"final_url"`
+	Hops       []string  `json:"hops"` // Every URL requested, starting with the target
+	Error      string    `json:"error,omitempty"`
+	ProbedAt   time.Time `json:"probed_at"`
 }
 
 // CreateURLRequest represents the request to create a new short URL
@@ -40,29 +74,49 @@ type CreateURLResponse struct {
 
 // URLInfoResponse represents the response for URL metadata
 type URLInfoResponse struct {
-	ShortCode   string     `json:"short_code"`
-	TargetURL   string     `json:"target_url"`
-	IsActive    bool       `json:"is_active"`
-	CreatedAt   time.Time  `json:"created_at"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
-	ClickCount  int64      `json:"click_count"`
-	LastClicked *time.Time `json:"last_clicked,omitempty"`
+	ShortCode        string     `json:"short_code"`
+	TargetURL        string     `json:"target_url"`
+	IsActive         bool       `json:"is_active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	QuarantinedAt    *time.Time `json:"quarantined_at,omitempty"`
+	QuarantineReason *string    `json:"quarantine_reason,omitempty"`
+	ProbeStatus      *string    `json:"probe_status,omitempty"`
+	FinalURL         *string    `json:"final_url,omitempty"`
+	ProbedAt         *time.Time `json:"probed_at,omitempty"`
+	ClickCount       int64      `json:"click_count"`
+	LastClicked      *time.Time `json:"last_clicked,omitempty"`
 }
 
 // ClickEvent represents a click tracking event
 type ClickEvent struct {
-	ID          int64     `json:"id" db:"id"`
-	URLID       int64     `json:"url_id" db:"url_id"`
-	OccurredAt  time.Time `json:"occurred_at" db:"occurred_at"`
-	IP          *string   `json:"ip,omitempty" db:"ip"`
-	UserAgent   *string   `json:"user_agent,omitempty" db:"ua"`
-	Referrer    *string   `json:"referrer,omitempty" db:"referrer"`
-	UTMSource   *string   `json:"utm_source,omitempty" db:"utm_source"`
-	UTMMedium   *string   `json:"utm_medium,omitempty" db:"utm_medium"`
-	UTMCampaign *string   `json:"utm_campaign,omitempty" db:"utm_campaign"`
-	UTMTerm     *string   `json:"utm_term,omitempty" db:"utm_term"`
-	UTMContent  *string   `json:"utm_content,omitempty" db:"utm_content"`
-	QueryParams *string   `json:"query_params,omitempty" db:"query_params"` // JSON string
+	ID          int64     `json:"id" db:"id" parquet:"id"`
+	URLID       int64     `json:"url_id" db:"url_id" parquet:"url_id"`
+	OccurredAt  time.Time `json:"occurred_at" db:"occurred_at" parquet:"occurred_at,timestamp(microsecond)"`
+	IP          *string   `json:"ip,omitempty" db:"ip" parquet:"ip"`
+	UserAgent   *string   `json:"user_agent,omitempty" db:"ua" parquet:"user_agent"`
+	Referrer    *string   `json:"referrer,omitempty" db:"referrer" parquet:"referrer"`
+	UTMSource   *string   `json:"utm_source,omitempty" db:"utm_source" parquet:"utm_source"`
+	UTMMedium   *string   `json:"utm_medium,omitempty" db:"utm_medium" parquet:"utm_medium"`
+	UTMCampaign *string   `json:"utm_campaign,omitempty" db:"utm_campaign" parquet:"utm_campaign"`
+	UTMTerm     *string   `json:"utm_term,omitempty" db:"utm_term" parquet:"utm_term"`
+	UTMContent  *string   `json:"utm_content,omitempty" db:"utm_content" parquet:"utm_content"`
+	QueryParams *string   `json:"query_params,omitempty" db:"query_params" parquet:"query_params"` // JSON string
+	Country     *string   `json:"country,omitempty" db:"country" parquet:"country"`                // ISO 3166-1 alpha-2
+	Region      *string   `json:"region,omitempty" db:"region" parquet:"region"`
+	City        *string   `json:"city,omitempty" db:"city" parquet:"city"`
+
+	// Parsed from the user agent at ingest time
+	Browser        *string `json:"browser,omitempty" db:"browser" parquet:"browser"`
+	BrowserVersion *string `json:"browser_version,omitempty" db:"browser_version" parquet:"browser_version"`
+	OS             *string `json:"os,omitempty" db:"os" parquet:"os"`
+	DeviceType     *string `json:"device_type,omitempty" db:"device_type" parquet:"device_type"`
+	IsBot          bool    `json:"is_bot" db:"is_bot" parquet:"is_bot"`
+
+	// Revision of the link that was live at click time; unset for clicks
+	// recorded before revisions were tracked
+	Revision *int `json:"revision,omitempty" db:"revision" parquet:"revision,optional"`
 }
 
 // Validation constants
@@ -81,8 +135,10 @@ var (
 	ErrCustomCodeTooShort = errors.New("custom code is too short")
 	ErrCustomCodeTooLong  = errors.New("custom code is too long")
 	ErrReservedCode       = errors.New("code is reserved")
-	ErrMaliciousURL       = errors.New("potentially malicious URL detected")
+	ErrMaliciousURL       = errors.New("potentially malicious URL detected") // Returned by URL scanners
 	ErrSSRFDetected       = errors.New("URL points to internal/private network")
+	ErrURLCredentials     = errors.New("URL must not contain credentials")
+	ErrHomographDomain    = errors.New("domain mixes lookalike scripts")
 )
 
 // Regular expressions for validation
@@ -90,15 +146,6 @@ var (
 	// Custom code can contain: letters, numbers, hyphens, underscores
 	// No spaces, no special characters except - and _
 	customCodeRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-
-	// Malicious URL patterns (basic detection)
-	maliciousPatterns = []*regexp.Regexp{
-		regexp.MustCompile(`(?i)(javascript|data|vbscript):`), // Script injection
-		regexp.MustCompile(`(?i)\.exe($|\?|#)`),               // Executable files
-		regexp.MustCompile(`(?i)\.bat($|\?|#)`),               // Batch files
-		regexp.MustCompile(`(?i)\.scr($|\?|#)`),               // Screen savers
-		regexp.MustCompile(`(?i)\.zip($|\?|#)`),               // Zip files (can be suspicious)
-	}
 )
 
 // ValidateURL validates a target URL for shortening
@@ -139,18 +186,6 @@ func ValidateURL(targetURL string) error {
 		return ErrInvalidURL
 	}
 
-	// Basic malicious URL detection
-	if err := checkMaliciousURL(targetURL); err != nil {
-		log.Printf("[VALIDATION] ERROR: Malicious URL detected: %v", err)
-		return err
-	}
-
-	// SSRF protection: check if host resolves to private/internal IP
-	if err := checkSSRF(parsedURL.Hostname()); err != nil {
-		log.Printf("[VALIDATION] ERROR: SSRF detected: %v", err)
-		return err
-	}
-
 	log.Printf("[VALIDATION] SUCCESS: URL validation passed for %s", parsedURL.Host)
 	return nil
 }
@@ -178,175 +213,13 @@ func ValidateCustomCode(code string) error {
 		return ErrInvalidCustomCode
 	}
 
-	// Check for reserved patterns (case-insensitive)
-	lowerCode := strings.ToLower(code)
-	reservedPatterns := []string{"api", "www", "admin", "root", "null", "undefined"}
-	for _, reserved := range reservedPatterns {
-		if lowerCode == reserved {
-			log.Printf("[VALIDATION] ERROR: Code '%s' matches reserved pattern '%s'", code, reserved)
-			return ErrReservedCode
-		}
-	}
+	// Reservations, including DefaultReservedCodes, are checked by the
+	// shortener service
 
 	log.Printf("[VALIDATION] SUCCESS: Custom code validation passed for: %s", code)
 	return nil
 }
 
-// checkMaliciousURL performs basic malicious URL detection
-func checkMaliciousURL(targetURL string) error {
-	for i, pattern := range maliciousPatterns {
-		if pattern.MatchString(targetURL) {
-			return fmt.Errorf("%w: matched pattern %d", ErrMaliciousURL, i+1)
-		}
-	}
-	return nil
-}
-
-// checkSSRF checks if a hostname resolves to a private/internal IP address
-func checkSSRF(hostname string) error {
-	// Check for obviously internal hostnames
-	lowerHost := strings.ToLower(hostname)
-	internalHosts := []string{
-		"localhost",
-		"127.0.0.1",
-		"0.0.0.0",
-		"::1",
-		"[::1]",
-	}
-	for _, internal := range internalHosts {
-		if lowerHost == internal {
-			return fmt.Errorf("%w: %s is a local address", ErrSSRFDetected, hostname)
-		}
-	}
-
-	// Check for internal domain patterns
-	if strings.HasSuffix(lowerHost, ".local") ||
-		strings.HasSuffix(lowerHost, ".internal") ||
-		strings.HasSuffix(lowerHost, ".localhost") ||
-		strings.HasSuffix(lowerHost, ".localdomain") {
-		return fmt.Errorf("%w: %s is an internal domain", ErrSSRFDetected, hostname)
-	}
-
-	// Resolve hostname to IP addresses
-	ips, err := net.LookupIP(hostname)
-	if err != nil {
-		// If DNS lookup fails, we allow it (might be a valid external host with DNS issues)
-		// In a stricter environment, you might want to reject these
-		log.Printf("[SSRF] DNS lookup failed for %s: %v (allowing)", hostname, err)
-		return nil
-	}
-
-	// Check if any resolved IP is private/internal
-	for _, ip := range ips {
-		if isPrivateIP(ip) {
-			return fmt.Errorf("%w: %s resolves to private IP %s", ErrSSRFDetected, hostname, ip.String())
-		}
-	}
-
-	return nil
-}
-
-// isPrivateIP checks if an IP address is in a private/reserved range
-func isPrivateIP(ip net.IP) bool {
-	// Check for loopback
-	if ip.IsLoopback() {
-		return true
-	}
-
-	// Check for link-local
-	if ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-		return true
-	}
-
-	// Check for private ranges
-	if ip.IsPrivate() {
-		return true
-	}
-
-	// Check for unspecified (0.0.0.0 or ::)
-	if ip.IsUnspecified() {
-		return true
-	}
-
-	// Additional checks for IPv4
-	if ip4 := ip.To4(); ip4 != nil {
-		// 169.254.0.0/16 (link-local, already covered but explicit)
-		if ip4[0] == 169 && ip4[1] == 254 {
-			return true
-		}
-		// 100.64.0.0/10 (Carrier-grade NAT)
-		if ip4[0] == 100 && ip4[1] >= 64 && ip4[1] <= 127 {
-			return true
-		}
-		// 192.0.0.0/24 (IETF Protocol Assignments)
-		if ip4[0] == 192 && ip4[1] == 0 && ip4[2] == 0 {
-			return true
-		}
-		// 192.0.2.0/24 (TEST-NET-1)
-		if ip4[0] == 192 && ip4[1] == 0 && ip4[2] == 2 {
-			return true
-		}
-		// 198.51.100.0/24 (TEST-NET-2)
-		if ip4[0] == 198 && ip4[1] == 51 && ip4[2] == 100 {
-			return true
-		}
-		// 203.0.113.0/24 (TEST-NET-3)
-		if ip4[0] == 203 && ip4[1] == 0 && ip4[2] == 113 {
-			return true
-		}
-		// 224.0.0.0/4 (Multicast)
-		if ip4[0] >= 224 && ip4[0] <= 239 {
-			return true
-		}
-		// 240.0.0.0/4 (Reserved for future use)
-		if ip4[0] >= 240 {
-			return true
-		}
-	}
-
-	// Additional checks for IPv6
-	// fc00::/7 (Unique local addresses) - IsPrivate() should catch this
-	// fe80::/10 (Link-local) - IsLinkLocalUnicast() should catch this
-
-	return false
-}
-
-// NormalizeURL normalizes a URL for consistent storage and comparison
-func NormalizeURL(rawURL string) (string, error) {
-	log.Printf("[NORMALIZE] Normalizing URL: %s", rawURL)
-
-	// Add scheme if missing
-	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
-		rawURL = "https://" + rawURL
-		log.Printf("[NORMALIZE] Added default HTTPS scheme: %s", rawURL)
-	}
-
-	parsedURL, err := url.Parse(rawURL)
-	if err != nil {
-		log.Printf("[NORMALIZE] ERROR: Failed to parse URL: %v", err)
-		return "", err
-	}
-
-	// Normalize host to lowercase
-	parsedURL.Host = strings.ToLower(parsedURL.Host)
-
-	// Remove trailing slash from path if it's just "/"
-	if parsedURL.Path == "/" {
-		parsedURL.Path = ""
-	}
-
-	// Remove default ports
-	if (parsedURL.Scheme == "http" && strings.HasSuffix(parsedURL.Host, ":80")) ||
-		(parsedURL.Scheme == "https" && strings.HasSuffix(parsedURL.Host, ":443")) {
-		parsedURL.Host = strings.Split(parsedURL.Host, ":")[0]
-		log.Printf("[NORMALIZE] Removed default port from host: %s", parsedURL.Host)
-	}
-
-	normalized := parsedURL.String()
-	log.Printf("[NORMALIZE] SUCCESS: Normalized URL: %s", normalized)
-	return normalized, nil
-}
-
 // IsExpired checks if a URL has expired
 func (u *URL) IsExpired() bool {
 	if u.ExpiresAt == nil {
@@ -359,12 +232,23 @@ func (u *URL) IsExpired() bool {
 	return expired
 }
 
-// IsAccessible checks if a URL can be accessed (active and not expired)
+// IsDeleted checks if a URL has been deleted and awaits permanent deletion
+func (u *URL) IsDeleted() bool {
+	return u.DeletedAt != nil
+}
+
+// IsQuarantined checks if a URL's target was flagged by a scan
+func (u *URL) IsQuarantined() bool {
+	return u.QuarantinedAt != nil
+}
+
+// IsAccessible checks if a URL can be accessed (active, not expired, not
+// deleted and not quarantined)
 func (u *URL) IsAccessible() bool {
-	accessible := u.IsActive && !u.IsExpired()
+	accessible := u.IsActive && !u.IsExpired() && !u.IsDeleted() && !u.IsQuarantined()
 	if !accessible {
-		log.Printf("[ACCESS] URL %s (ID: %d) is not accessible - Active: %v, Expired: %v",
-			u.ShortCode, u.ID, u.IsActive, u.IsExpired())
+		log.Printf("[ACCESS] URL %s (ID: %d) is not accessible - Active: %v, Expired: %v, Deleted: %v, Quarantined: %v",
+			u.ShortCode, u.ID, u.IsActive, u.IsExpired(), u.IsDeleted(), u.IsQuarantined())
 	}
 	return accessible
 }
@@ -385,13 +269,19 @@ func (u *URL) ToResponse(baseURL string) *CreateURLResponse {
 // ToInfoResponse converts URL model to info response format
 func (u *URL) ToInfoResponse(clickCount int64, lastClicked *time.Time) *URLInfoResponse {
 	return &URLInfoResponse{
-		ShortCode:   u.ShortCode,
-		TargetURL:   u.TargetURL,
-		IsActive:    u.IsActive,
-		CreatedAt:   u.CreatedAt,
-		ExpiresAt:   u.ExpiresAt,
-		ClickCount:  clickCount,
-		LastClicked: lastClicked,
+		ShortCode:        u.ShortCode,
+		TargetURL:        u.TargetURL,
+		IsActive:         u.IsActive,
+		CreatedAt:        u.CreatedAt,
+		ExpiresAt:        u.ExpiresAt,
+		DeletedAt:        u.DeletedAt,
+		QuarantinedAt:    u.QuarantinedAt,
+		QuarantineReason: u.QuarantineReason,
+		ProbeStatus:      u.ProbeStatus,
+		FinalURL:         u.FinalURL,
+		ProbedAt:         u.ProbedAt,
+		ClickCount:       clickCount,
+		LastClicked:      lastClicked,
 	}
 }
 
@@ -414,8 +304,34 @@ func (u *URL) LogAccess(ip string, userAgent string) {
 
 // Analytics statistics types
 type DayStat struct {
-	Date   string `json:"date"`
-	Clicks int64  `json:"clicks"`
+	Date         string `json:"date"`
+	Clicks       int64  `json:"clicks"`
+	UniqueClicks int64  `json:"unique_clicks"` // HyperLogLog estimate
+}
+
+// TimeBucket is one point of a click time series
+type TimeBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// Time series granularities, named after the PostgreSQL date_trunc fields
+const (
+	GranularityMinute = "minute"
+	GranularityHour   = "hour"
+	GranularityDay    = "day"
+	GranularityWeek   = "week"
+	GranularityMonth  = "month"
+)
+
+// IsValidGranularity reports whether g is a supported time series granularity
+func IsValidGranularity(g string) bool {
+	switch g {
+	case GranularityMinute, GranularityHour, GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	default:
+		return false
+	}
 }
 
 type ReferrerStat struct {
@@ -428,7 +344,179 @@ type CountryStat struct {
 	Clicks  int64  `json:"clicks"`
 }
 
+type CityStat struct {
+	City    string `json:"city"`
+	Region  string `json:"region,omitempty"`
+	Country string `json:"country"`
+	Clicks  int64  `json:"clicks"`
+}
+
+// RevisionStat holds clicks received while one revision of a link was live
+type RevisionStat struct {
+	Revision int   `json:"revision"`
+	Clicks   int64 `json:"clicks"`
+}
+
 type BrowserStat struct {
 	Browser string `json:"browser"`
 	Clicks  int64  `json:"clicks"`
 }
+
+type OSStat struct {
+	OS     string `json:"os"`
+	Clicks int64  `json:"clicks"`
+}
+
+type DeviceStat struct {
+	Device string `json:"device"`
+	Clicks int64  `json:"clicks"`
+}
+
+// UTM dimensions that campaign statistics can be grouped and filtered by
+const (
+	UTMSource   = "source"
+	UTMMedium   = "medium"
+	UTMCampaign = "campaign"
+	UTMTerm     = "term"
+	UTMContent  = "content"
+)
+
+// UTMDimensions lists the UTM dimensions in their canonical order
+var UTMDimensions = []string{UTMSource, UTMMedium, UTMCampaign, UTMTerm, UTMContent}
+
+// UTMNone stands in for a UTM parameter that was not set on the click
+const UTMNone = "(none)"
+
+// CampaignStat holds clicks for one combination of UTM values. Dimensions
+// that were not grouped on are left empty.
+type CampaignStat struct {
+	ShortCode  string    `json:"short_code,omitempty"`
+	Source     string    `json:"utm_source,omitempty"`
+	Medium     string    `json:"utm_medium,omitempty"`
+	Campaign   string    `json:"utm_campaign,omitempty"`
+	Term       string    `json:"utm_term,omitempty"`
+	Content    string    `json:"utm_content,omitempty"`
+	Clicks     int64      `json:"clicks"`
+	FirstClick *time.Time `json:"first_click,omitempty"` // Nil for a grand total with no clicks
+	LastClick  *time.Time `json:"last_click,omitempty"`
+}
+
+// Dimension returns the value of a UTM dimension
+func (c *CampaignStat) Dimension(name string) string {
+	switch name {
+	case UTMSource:
+		return c.Source
+	case UTMMedium:
+		return c.Medium
+	case UTMCampaign:
+		return c.Campaign
+	case UTMTerm:
+		return c.Term
+	case UTMContent:
+		return c.Content
+	default:
+		return ""
+	}
+}
+
+// DimensionField returns a pointer to the field backing a UTM dimension
+func (c *CampaignStat) DimensionField(name string) *string {
+	switch name {
+	case UTMSource:
+		return &c.Source
+	case UTMMedium:
+		return &c.Medium
+	case UTMCampaign:
+		return &c.Campaign
+	case UTMTerm:
+		return &c.Term
+	case UTMContent:
+		return &c.Content
+	default:
+		return nil
+	}
+}
+
+// URLRevision is a numbered snapshot of a link's target and options. Every
+// edit creates a new revision; rolling back copies an old one forward.
+type URLRevision struct {
+	Revision  int        `json:"revision"`
+	TargetURL string     `json:"target_url"`
+	IsActive  bool       `json:"is_active"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LinkPreview is what a link unfurls to in posts and chats
+type LinkPreview struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"image_url"`
+}
+
+// IsEmpty reports whether no field is set
+func (p LinkPreview) IsEmpty() bool {
+	return p.Title == nil && p.Description == nil && p.ImageURL == nil
+}
+
+// URLPreview holds the preview metadata fetched from a link's destination
+// and any per-link overrides
+type URLPreview struct {
+	Fetched    LinkPreview `json:"fetched"`
+	Override   LinkPreview `json:"override"`
+	FetchedAt  *time.Time  `json:"fetched_at,omitempty"`
+	FetchError *string     `json:"fetch_error,omitempty"` // Of the last fetch; earlier metadata is kept
+}
+
+// Effective returns the preview to show: each overridden field replaces the
+// fetched one
+func (p *URLPreview) Effective() LinkPreview {
+	effective := p.Fetched
+	if p.Override.Title != nil {
+		effective.Title = p.Override.Title
+	}
+	if p.Override.Description != nil {
+		effective.Description = p.Override.Description
+	}
+	if p.Override.ImageURL != nil {
+		effective.ImageURL = p.Override.ImageURL
+	}
+	return effective
+}
+
+// Audit actions recorded for link mutations
+const (
+	AuditURLCreate          = "url.create"
+	AuditURLUpdate          = "url.update"
+	AuditURLDeactivate      = "url.deactivate"
+	AuditURLDelete          = "url.delete"
+	AuditURLRestore         = "url.restore"
+	AuditURLRollback        = "url.rollback"
+	AuditURLExpire          = "url.expire"
+	AuditURLQuarantine      = "url.quarantine"
+	AuditURLRelease         = "url.release"
+	AuditURLPreview         = "url.preview"
+	AuditURLPurge           = "url.purge"
+	AuditReservedCodeAdd    = "reserved_code.add"
+	AuditReservedCodeRemove = "reserved_code.remove"
+)
+
+// FieldChange is the before and after value of one changed field
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry is one append-only record of a mutation. Entries outlive the
+// link they describe, so they are keyed by short code.
+type AuditEntry struct {
+	ID         int64                  `json:"id"`
+	URLID      *int64                 `json:"-"`
+	ShortCode  string                 `json:"short_code"`
+	Action     string                 `json:"action"`
+	Actor      string                 `json:"actor"`
+	IP         *string                `json:"ip,omitempty"`
+	UserAgent  *string                `json:"user_agent,omitempty"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
@@ -0,0 +1,195 @@
+// Package preview fetches the title, description and image a link's
+// destination advertises through its HTML <title> and Open Graph or Twitter
+// card meta tags. Requests go through an SSRF-guarded transport.
+package preview
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultMaxBytes  = 512 << 10
+	defaultUserAgent = "url-shortener-preview/1.0"
+	maxRedirects     = 10
+
+	// Longer values are cut, as unfurlers do
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// Config configures a Fetcher. Zero values use the defaults.
+type Config struct {
+	Timeout   time.Duration
+	MaxBytes  int64 // Of the document read; metadata lives in its head
+	UserAgent string
+
+	// Transport replaces the guarded transport, for tests against local servers
+	Transport http.RoundTripper
+}
+
+// Fetcher fetches link previews
+type Fetcher struct {
+	client    *http.Client
+	maxBytes  int64
+	userAgent string
+}
+
+// New creates a fetcher whose connections are checked by guard
+func New(guard *ssrf.Guard, cfg Config) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultMaxBytes
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		// No proxy: it would connect on our behalf without the guard
+		transport = &http.Transport{
+			DialContext:           guard.DialContext,
+			TLSHandshakeTimeout:   cfg.Timeout,
+			ResponseHeaderTimeout: cfg.Timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		}
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return nil
+			},
+		},
+		maxBytes:  cfg.MaxBytes,
+		userAgent: cfg.UserAgent,
+	}
+}
+
+// Fetch requests target and extracts its preview. An image destination is
+// its own preview image; other non-HTML destinations have an empty preview.
+func (f *Fetcher) Fetch(ctx context.Context, target string) (*models.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		final := resp.Request.URL.String()
+		return &models.LinkPreview{ImageURL: &final}, nil
+	case mediaType != "text/html" && mediaType != "application/xhtml+xml":
+		return &models.LinkPreview{}, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	return Parse(body, resp.Request.URL), nil
+}
+
+// Parse extracts the preview from an HTML document. Open Graph tags win
+// over Twitter card tags, which win over <title> and the description meta
+// tag. Relative image URLs are resolved against base.
+func Parse(doc []byte, base *url.URL) *models.LinkPreview {
+	var title, description, image ranked
+
+	scanHead(string(doc), func(tag string, attrs map[string]string, text string) {
+		if tag == "title" {
+			title.offer(3, text)
+			return
+		}
+
+		key := strings.ToLower(attrs["property"])
+		if key == "" {
+			key = strings.ToLower(attrs["name"])
+		}
+		content := attrs["content"]
+		switch key {
+		case "og:title":
+			title.offer(1, content)
+		case "twitter:title":
+			title.offer(2, content)
+		case "og:description":
+			description.offer(1, content)
+		case "twitter:description":
+			description.offer(2, content)
+		case "description":
+			description.offer(3, content)
+		case "og:image", "og:image:url", "og:image:secure_url":
+			image.offer(1, content)
+		case "twitter:image", "twitter:image:src":
+			image.offer(2, content)
+		}
+	})
+
+	preview := &models.LinkPreview{
+		Title:       truncated(title.value, maxTitleLength),
+		Description: truncated(description.value, maxDescriptionLength),
+	}
+	if image.value != "" {
+		if ref, err := base.Parse(image.value); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") &&
+			len(ref.String()) <= models.MaxURLLength {
+			imageURL := ref.String()
+			preview.ImageURL = &imageURL
+		}
+	}
+	return preview
+}
+
+// ranked keeps the non-empty value offered with the best (lowest) rank
+type ranked struct {
+	rank  int
+	value string
+}
+
+func (r *ranked) offer(rank int, value string) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value != "" && (r.value == "" || rank < r.rank) {
+		r.rank, r.value = rank, value
+	}
+}
+
+// truncated returns value cut to max runes, or nil if empty
+func truncated(value string, max int) *string {
+	if value == "" {
+		return nil
+	}
+	if runes := []rune(value); len(runes) > max {
+		value = strings.TrimSpace(string(runes[:max-1])) + "…"
+	}
+	return &value
+}
@@ -0,0 +1,158 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+
+	tests := []struct {
+		name        string
+		doc         string
+		title       string
+		description string
+		image       string
+	}{
+		{
+			name: "open graph wins",
+			doc: `<!DOCTYPE html><html><head>
+				<title>Page title</title>
+				<meta name="description" content="Plain description">
+				<meta name="twitter:title" content="Twitter title">
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:image" content="/img/cover.png">
+				</head><body></body></html>`,
+			title:       "OG title",
+			description: "OG description",
+			image:       "https://example.com/img/cover.png",
+		},
+		{
+			name: "fallbacks",
+			doc: `<html><head><TITLE>  Tom &amp; Jerry
+				</TITLE><META NAME=Description CONTENT='Cats &lt;3 mice'>
+				<meta name="twitter:image:src" content="//cdn.example.com/a.jpg"/></head>`,
+			title:       "Tom & Jerry",
+			description: "Cats <3 mice",
+			image:       "https://cdn.example.com/a.jpg",
+		},
+		{
+			name:  "twitter before title",
+			doc:   `<head><title>Title</title><meta name="twitter:title" content="Card"></head>`,
+			title: "Card",
+		},
+		{
+			name: "comments and scripts are skipped",
+			doc: `<head><!-- <meta property="og:title" content="Commented"> -->
+				<script>var s = '<meta property="og:title" content="Scripted">';</script>
+				<meta property="og:title" content="Real"></head>`,
+			title: "Real",
+		},
+		{
+			name:  "body is not scanned",
+			doc:   `<head><title>Head</title></head><body><meta property="og:title" content="Body"></body>`,
+			title: "Head",
+		},
+		{
+			name:  "non-http image ignored",
+			doc:   `<meta property="og:image" content="javascript:alert(1)"><meta property="og:title" content="X">`,
+			title: "X",
+		},
+		{
+			name:  "empty content falls back",
+			doc:   `<title>Fallback</title><meta property="og:title" content="   ">`,
+			title: "Fallback",
+		},
+		{
+			name:  "unterminated tag",
+			doc:   `<title>Cut</title><meta property="og:description" content="never closed`,
+			title: "Cut",
+		},
+	}
+
+	for _, tt := range tests {
+		preview := Parse([]byte(tt.doc), base)
+
+		if got := deref(preview.Title); got != tt.title {
+			t.Errorf("%s: title = %q, expected %q", tt.name, got, tt.title)
+		}
+		if got := deref(preview.Description); got != tt.description {
+			t.Errorf("%s: description = %q, expected %q", tt.name, got, tt.description)
+		}
+		if got := deref(preview.ImageURL); got != tt.image {
+			t.Errorf("%s: image = %q, expected %q", tt.name, got, tt.image)
+		}
+	}
+}
+
+func TestParseTruncates(t *testing.T) {
+	doc := `<title>` + strings.Repeat("é", 500) + `</title>`
+	preview := Parse([]byte(doc), &url.URL{})
+
+	if runes := []rune(deref(preview.Title)); len(runes) != maxTitleLength || runes[len(runes)-1] != '…' {
+		t.Errorf("Title has %d runes, expected %d ending in an ellipsis", len(runes), maxTitleLength)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<head><meta property="og:title" content="Fetched"><meta property="og:image" content="cover.png"></head>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/photo.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := New(nil, Config{Transport: server.Client().Transport})
+	ctx := context.Background()
+
+	// Relative images resolve against the final URL after redirects
+	preview, err := fetcher.Fetch(ctx, server.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Fetch() unexpected error: %v", err)
+	}
+	if deref(preview.Title) != "Fetched" || deref(preview.ImageURL) != server.URL+"/cover.png" {
+		t.Errorf("Fetch() = %q, %q, expected the page's tags", deref(preview.Title), deref(preview.ImageURL))
+	}
+
+	preview, err = fetcher.Fetch(ctx, server.URL+"/photo.jpg")
+	if err != nil || deref(preview.ImageURL) != server.URL+"/photo.jpg" {
+		t.Errorf("Fetch() of an image = %v, %v, expected the image itself", preview, err)
+	}
+
+	if _, err := fetcher.Fetch(ctx, server.URL+"/gone"); err == nil {
+		t.Error("Fetch() of a 410 page expected an error")
+	}
+
+	// The guarded transport refuses the loopback test server
+	guarded := New(ssrf.NewGuard(nil, false), Config{})
+	if _, err := guarded.Fetch(ctx, server.URL+"/page"); !errors.Is(err, models.ErrSSRFDetected) {
+		t.Errorf("Guarded Fetch() error = %v, expected ErrSSRFDetected", err)
+	}
+}
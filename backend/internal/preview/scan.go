@@ -0,0 +1,156 @@
+package preview
+
+import (
+	"html"
+	"strings"
+)
+
+// Elements whose content is not markup and is skipped whole
+var rawTextElements = map[string]bool{"script": true, "style": true, "template": true, "textarea": true}
+
+// scanHead calls fn for the <title> and every <meta> element of an HTML
+// document's head, stopping at </head> or <body>. It is a tolerant scanner
+// for metadata, not a full HTML parser: it skips comments and raw text
+// elements and unescapes entities in attribute values and the title.
+func scanHead(doc string, fn func(tag string, attrs map[string]string, text string)) {
+	lower := asciiLower(doc)
+
+	i := 0
+	for {
+		j := strings.IndexByte(doc[i:], '<')
+		if j < 0 {
+			return
+		}
+		i += j
+
+		if strings.HasPrefix(lower[i:], "<!--") {
+			end := strings.Index(lower[i+4:], "-->")
+			if end < 0 {
+				return
+			}
+			i += 4 + end + 3
+			continue
+		}
+
+		k := i + 1
+		closing := k < len(doc) && doc[k] == '/'
+		if closing {
+			k++
+		}
+		start := k
+		for k < len(doc) && isNameByte(doc[k]) {
+			k++
+		}
+		name := lower[start:k]
+		if name == "" {
+			// Not a tag, such as "<" in text or a doctype
+			i++
+			continue
+		}
+
+		attrs, end := parseAttrs(doc, k)
+		switch {
+		case closing && name == "head":
+			return
+		case closing:
+		case name == "body":
+			return
+		case name == "title":
+			close := strings.Index(lower[end:], "</title")
+			if close < 0 {
+				return
+			}
+			fn("title", nil, html.UnescapeString(doc[end:end+close]))
+			end += close
+		case name == "meta":
+			fn("meta", attrs, "")
+		case rawTextElements[name]:
+			close := strings.Index(lower[end:], "</"+name)
+			if close < 0 {
+				return
+			}
+			end += close
+		}
+		i = end
+	}
+}
+
+// parseAttrs parses the attributes of a tag starting at k, just after its
+// name. It returns the attributes, names lowercased, and the index after
+// the closing '>'.
+func parseAttrs(doc string, k int) (map[string]string, int) {
+	attrs := make(map[string]string)
+	for k < len(doc) {
+		before := k
+
+		for k < len(doc) && (isSpace(doc[k]) || doc[k] == '/') {
+			k++
+		}
+		if k >= len(doc) {
+			break
+		}
+		if doc[k] == '>' {
+			return attrs, k + 1
+		}
+
+		start := k
+		for k < len(doc) && !isSpace(doc[k]) && doc[k] != '=' && doc[k] != '>' && doc[k] != '/' {
+			k++
+		}
+		name := asciiLower(doc[start:k])
+
+		for k < len(doc) && isSpace(doc[k]) {
+			k++
+		}
+		value := ""
+		if k < len(doc) && doc[k] == '=' {
+			k++
+			for k < len(doc) && isSpace(doc[k]) {
+				k++
+			}
+			if k < len(doc) && (doc[k] == '"' || doc[k] == '\'') {
+				quote := doc[k]
+				end := strings.IndexByte(doc[k+1:], quote)
+				if end < 0 {
+					return attrs, len(doc)
+				}
+				value = doc[k+1 : k+1+end]
+				k += end + 2
+			} else {
+				start := k
+				for k < len(doc) && !isSpace(doc[k]) && doc[k] != '>' {
+					k++
+				}
+				value = doc[start:k]
+			}
+		}
+
+		// The first of duplicate attributes wins, as in browsers
+		if _, seen := attrs[name]; name != "" && !seen {
+			attrs[name] = html.UnescapeString(value)
+		}
+		if k == before {
+			k++
+		}
+	}
+	return attrs, len(doc)
+}
+
+// asciiLower lowercases ASCII letters only, keeping byte offsets intact
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func isNameByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,214 @@
+// Package probe follows the redirect chain of a link's destination to find
+// where it ends and whether it still works. Requests go through an
+// SSRF-guarded transport, so no hop can reach an internal address.
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+// DefaultShorteners are hosts of well-known URL shorteners. Links through
+// them hide their destination and break when the other service does.
+var DefaultShorteners = []string{
+	"bit.ly", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "ow.ly", "rb.gy",
+	"rebrand.ly", "shorturl.at", "t.co", "t.ly", "tiny.cc", "tinyurl.com",
+}
+
+const (
+	defaultTimeout      = 10 * time.Second
+	defaultMaxRedirects = 10
+	defaultUserAgent    = "url-shortener-link-checker/1.0"
+)
+
+// Config configures a Prober. Zero values use the defaults.
+type Config struct {
+	Timeout      time.Duration // Per request
+	MaxRedirects int
+	UserAgent    string
+	Shorteners   []string // Hosts, subdomains included, counted as URL shorteners
+
+	// Transport replaces the guarded transport, for tests against local servers
+	Transport http.RoundTripper
+}
+
+// Prober follows redirect chains
+type Prober struct {
+	client       *http.Client
+	maxRedirects int
+	userAgent    string
+	shorteners   map[string]bool
+}
+
+// New creates a prober whose connections are checked by guard
+func New(guard *ssrf.Guard, cfg Config) *Prober {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = defaultMaxRedirects
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = defaultUserAgent
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		// No proxy: it would connect on our behalf without the guard
+		transport = &http.Transport{
+			DialContext:           guard.DialContext,
+			TLSHandshakeTimeout:   cfg.Timeout,
+			ResponseHeaderTimeout: cfg.Timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		}
+	}
+
+	shorteners := make(map[string]bool, len(cfg.Shorteners))
+	for _, host := range cfg.Shorteners {
+		shorteners[strings.ToLower(host)] = true
+	}
+
+	return &Prober{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			// Redirects are followed by hand to record and check every hop
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxRedirects: cfg.MaxRedirects,
+		userAgent:    cfg.UserAgent,
+		shorteners:   shorteners,
+	}
+}
+
+// Probe follows the redirect chain starting at target. Failures of the
+// destination are reported in the result's Status, not as an error.
+func (p *Prober) Probe(ctx context.Context, target string) *models.ProbeResult {
+	result := &models.ProbeResult{Status: models.ProbeOK, FinalURL: target, ProbedAt: time.Now().UTC()}
+	visited := make(map[string]bool)
+	nested := false
+
+	current, err := url.Parse(target)
+	if err != nil {
+		return p.fail(result, models.ProbeUnreachable, err)
+	}
+
+	for {
+		result.Hops = append(result.Hops, current.String())
+		result.FinalURL = current.String()
+		visited[current.String()] = true
+		if p.isShortener(current.Hostname()) {
+			nested = true
+		}
+
+		resp, err := p.request(ctx, current)
+		if err != nil {
+			if errors.Is(err, models.ErrSSRFDetected) {
+				return p.fail(result, models.ProbeBlocked, err)
+			}
+			return p.fail(result, models.ProbeUnreachable, err)
+		}
+		result.StatusCode = resp.StatusCode
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			break
+		}
+
+		next, err := current.Parse(location)
+		if err != nil {
+			return p.fail(result, models.ProbeBroken, fmt.Errorf("invalid redirect location %q: %w", location, err))
+		}
+		next.Fragment = ""
+		if next.Scheme != "http" && next.Scheme != "https" {
+			// Nothing to follow, such as a redirect into an app
+			result.FinalURL = next.String()
+			break
+		}
+		if visited[next.String()] {
+			result.FinalURL = next.String()
+			return p.fail(result, models.ProbeRedirectLoop, fmt.Errorf("redirect loop back to %s", next))
+		}
+		if len(result.Hops) > p.maxRedirects {
+			return p.fail(result, models.ProbeTooManyRedirects, fmt.Errorf("more than %d redirects", p.maxRedirects))
+		}
+		current = next
+	}
+
+	switch {
+	case result.StatusCode >= 400:
+		result.Status = models.ProbeBroken
+	case nested:
+		result.Status = models.ProbeNestedShortener
+	}
+	return result
+}
+
+// request sends a HEAD request, falling back to GET for servers that do
+// not support HEAD. The body is discarded.
+func (p *Prober) request(ctx context.Context, target *url.URL) (*http.Response, error) {
+	resp, err := p.do(ctx, http.MethodHead, target)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = p.do(ctx, http.MethodGet, target)
+	}
+	return resp, err
+}
+
+func (p *Prober) do(ctx context.Context, method string, target *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Lets the connection be reused
+	resp.Body.Close()
+	return resp, nil
+}
+
+// isShortener reports whether host or one of its parent domains is a shortener
+func (p *Prober) isShortener(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for host != "" {
+		if p.shorteners[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return false
+		}
+		host = parent
+	}
+	return false
+}
+
+func (p *Prober) fail(result *models.ProbeResult, status string, err error) *models.ProbeResult {
+	result.Status = status
+	result.Error = err.Error()
+	return result
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
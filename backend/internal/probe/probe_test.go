@@ -0,0 +1,112 @@
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+// newTestServer serves a small site with redirect chains
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/chain/2", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/chain/2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/loop/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop/b", http.StatusFound)
+	})
+	mux.HandleFunc("/loop/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop/a", http.StatusFound)
+	})
+	mux.HandleFunc("/endless/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, r.URL.Path+"x", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/app", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "myapp://open", http.StatusFound)
+	})
+	mux.HandleFunc("/to-shortener", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://go.bit.ly/x", http.StatusFound)
+	})
+
+	var server *httptest.Server
+	mux.HandleFunc("go.bit.ly/x", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/ok", http.StatusFound)
+	})
+
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbe(t *testing.T) {
+	server := newTestServer(t)
+
+	// Send every request to the test server, which also answers for go.bit.ly
+	transport := &http.Transport{Proxy: func(r *http.Request) (*url.URL, error) { return url.Parse(server.URL) }}
+	prober := New(nil, Config{Transport: transport, MaxRedirects: 5, Shorteners: DefaultShorteners})
+
+	tests := []struct {
+		path       string
+		status     string
+		statusCode int
+		finalPath  string
+		hops       int
+	}{
+		{"/ok", models.ProbeOK, 200, "/ok", 1},
+		{"/gone", models.ProbeBroken, 404, "/gone", 1},
+		{"/get-only", models.ProbeOK, 200, "/get-only", 1},
+		{"/chain", models.ProbeOK, 200, "/ok", 3},
+		{"/loop/a", models.ProbeRedirectLoop, 302, "/loop/a", 2},
+		{"/endless/", models.ProbeTooManyRedirects, 307, "/endless/xxxxx", 6},
+		{"/app", models.ProbeOK, 302, "myapp://open", 1},
+		{"/to-shortener", models.ProbeNestedShortener, 200, "/ok", 3},
+	}
+
+	for _, tt := range tests {
+		result := prober.Probe(context.Background(), server.URL+tt.path)
+
+		if result.Status != tt.status {
+			t.Errorf("%s: status = %s, expected %s (%s)", tt.path, result.Status, tt.status, result.Error)
+		}
+		if result.StatusCode != tt.statusCode {
+			t.Errorf("%s: status code = %d, expected %d", tt.path, result.StatusCode, tt.statusCode)
+		}
+		if !strings.HasSuffix(result.FinalURL, tt.finalPath) {
+			t.Errorf("%s: final URL = %s, expected it to end in %s", tt.path, result.FinalURL, tt.finalPath)
+		}
+		if len(result.Hops) != tt.hops {
+			t.Errorf("%s: hops = %v, expected %d", tt.path, result.Hops, tt.hops)
+		}
+	}
+}
+
+func TestProbeGuarded(t *testing.T) {
+	server := newTestServer(t)
+	prober := New(ssrf.NewGuard(nil, false), Config{})
+
+	// The guarded transport refuses the loopback test server
+	result := prober.Probe(context.Background(), server.URL+"/ok")
+	if result.Status != models.ProbeBlocked {
+		t.Errorf("Probe() status = %s, expected %s (%s)", result.Status, models.ProbeBlocked, result.Error)
+	}
+}
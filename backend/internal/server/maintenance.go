@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// advisoryLocker runs a function under a cluster-wide lock, reporting false
+// when another instance holds it. database.Service implements it.
+type advisoryLocker interface {
+	WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error)
+}
+
+// maintenanceJob is a periodic task that must only run on one instance at a time
+type maintenanceJob struct {
+	name     string
+	interval time.Duration
+	lockKey  int64
+	run      func(ctx context.Context) error
+}
+
+// maintenanceRunner schedules maintenance jobs for the lifetime of the App.
+// Each job runs once at start, then every interval and whenever triggered,
+// skipping runs while another instance holds its lock.
+type maintenanceRunner struct {
+	locker advisoryLocker
+	jobs   []maintenanceJob
+	wake   map[string]chan struct{} // Per job, buffered so triggers coalesce
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newMaintenanceRunner creates a runner with no jobs
+func newMaintenanceRunner(locker advisoryLocker) *maintenanceRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &maintenanceRunner{locker: locker, wake: make(map[string]chan struct{}), ctx: ctx, cancel: cancel}
+}
+
+// add registers a job; it must be called before Start
+func (m *maintenanceRunner) add(job maintenanceJob) {
+	m.jobs = append(m.jobs, job)
+	m.wake[job.name] = make(chan struct{}, 1)
+}
+
+// trigger runs a job as soon as it is idle, without waiting for its next
+// tick. Unknown jobs, such as disabled ones, are ignored.
+func (m *maintenanceRunner) trigger(name string) {
+	wake, ok := m.wake[name]
+	if !ok {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+		log.Printf("[MAINTENANCE] Job %s triggered", name)
+	default:
+		// A run is already pending
+	}
+}
+
+// Start launches one goroutine per job
+func (m *maintenanceRunner) Start() {
+	for _, job := range m.jobs {
+		m.wg.Add(1)
+		go m.loop(job)
+	}
+	log.Printf("[MAINTENANCE] Started %d maintenance jobs", len(m.jobs))
+}
+
+// Stop cancels running jobs and waits for them to return, or for ctx to end
+func (m *maintenanceRunner) Stop(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("[MAINTENANCE] All maintenance jobs stopped")
+		return nil
+	case <-ctx.Done():
+		log.Printf("[MAINTENANCE] WARNING: Timed out waiting for maintenance jobs")
+		return ctx.Err()
+	}
+}
+
+// loop runs a job until the runner is stopped
+func (m *maintenanceRunner) loop(job maintenanceJob) {
+	defer m.wg.Done()
+	log.Printf("[MAINTENANCE] Job %s scheduled every %s", job.name, job.interval)
+
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		m.runOnce(job)
+
+		select {
+		case <-m.ctx.Done():
+			log.Printf("[MAINTENANCE] Job %s stopped", job.name)
+			return
+		case <-ticker.C:
+		case <-m.wake[job.name]:
+		}
+	}
+}
+
+// runOnce runs a job under its advisory lock, bounded by its interval
+func (m *maintenanceRunner) runOnce(job maintenanceJob) {
+	ctx, cancel := context.WithTimeout(m.ctx, job.interval)
+	defer cancel()
+
+	acquired, err := m.locker.WithAdvisoryLock(ctx, job.lockKey, job.run)
+	switch {
+	case err != nil:
+		log.Printf("[MAINTENANCE] WARNING: Job %s failed: %v", job.name, err)
+	case !acquired:
+		log.Printf("[MAINTENANCE] Job %s skipped, another instance holds its lock", job.name)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLocker simulates the advisory lock being free or held by another instance
+type fakeLocker struct {
+	held bool
+}
+
+func (f *fakeLocker) WithAdvisoryLock(ctx context.Context, key int64, fn func(ctx context.Context) error) (bool, error) {
+	if f.held {
+		return false, nil
+	}
+	return true, fn(ctx)
+}
+
+func TestMaintenanceRunner(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     bool
+		jobErr   error
+		wantRuns bool
+	}{
+		{name: "lock free", wantRuns: true},
+		{name: "lock held elsewhere", held: true, wantRuns: false},
+		{name: "failing job keeps running", jobErr: errors.New("boom"), wantRuns: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs atomic.Int64
+			runner := newMaintenanceRunner(&fakeLocker{held: tt.held})
+			runner.add(maintenanceJob{
+				name:     "test",
+				interval: 10 * time.Millisecond,
+				run: func(ctx context.Context) error {
+					runs.Add(1)
+					return tt.jobErr
+				},
+			})
+
+			runner.Start()
+			time.Sleep(50 * time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := runner.Stop(ctx); err != nil {
+				t.Fatalf("Stop() unexpected error: %v", err)
+			}
+
+			got := runs.Load()
+			if tt.wantRuns && got < 2 {
+				t.Errorf("Job ran %d times, expected repeated runs", got)
+			}
+			if !tt.wantRuns && got != 0 {
+				t.Errorf("Job ran %d times while the lock was held", got)
+			}
+
+			// Nothing runs after Stop
+			time.Sleep(30 * time.Millisecond)
+			if after := runs.Load(); after != got {
+				t.Errorf("Job ran %d more times after Stop()", after-got)
+			}
+		})
+	}
+}
+
+func TestMaintenanceRunnerTrigger(t *testing.T) {
+	runs := make(chan struct{}, 10)
+	runner := newMaintenanceRunner(&fakeLocker{})
+	runner.add(maintenanceJob{
+		name:     "test",
+		interval: time.Hour,
+		run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			return nil
+		},
+	})
+
+	runner.Start()
+	defer runner.Stop(context.Background())
+
+	// The initial run, then one per trigger instead of waiting an hour
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("Job run %d did not happen", i+1)
+		}
+		runner.trigger("test")
+	}
+
+	runner.trigger("unknown") // Ignored
+}
@@ -38,6 +38,11 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Register shortener routes
 	s.shortenerHandler.RegisterRoutes(r)
 
+	// Codes matching a route would never be reachable
+	if err := s.shortenerHandler.ReserveRouteCodes(r); err != nil {
+		log.Printf("[SERVER] WARNING: Route codes not reserved: %v", err)
+	}
+
 	return r
 }
 
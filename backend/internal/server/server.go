@@ -7,14 +7,19 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 
 	"backend/internal/database"
 	"backend/internal/shortener"
+	"backend/internal/urlscan"
 )
 
+// Maintenance job re-scanning link destinations, also run after a reload
+const rescanJob = "url-rescan"
+
 type Server struct {
 	port int
 
@@ -28,6 +33,7 @@ type App struct {
 	HTTPServer   *http.Server
 	shortenerSvc shortener.Service
 	db           database.Service
+	maintenance  *maintenanceRunner
 }
 
 // Shutdown gracefully shuts down the application
@@ -39,6 +45,11 @@ func (a *App) Shutdown(ctx context.Context) error {
 		log.Printf("[APP] HTTP server shutdown error: %v", err)
 	}
 
+	// Stop scheduled maintenance before the services it uses
+	if err := a.maintenance.Stop(ctx); err != nil {
+		log.Printf("[APP] Maintenance shutdown error: %v", err)
+	}
+
 	// Shutdown shortener service (drains pending clicks)
 	if err := a.shortenerSvc.Shutdown(ctx); err != nil {
 		log.Printf("[APP] Shortener service shutdown error: %v", err)
@@ -53,6 +64,22 @@ func (a *App) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Reload re-reads configuration files that can change at runtime, such as
+// the URL blocklists. It is triggered by SIGHUP.
+func (a *App) Reload() {
+	log.Println("[APP] Reloading configuration files...")
+
+	if err := a.shortenerSvc.ReloadURLScanners(); err != nil {
+		log.Printf("[APP] URL scanner reload error: %v", err)
+		return
+	}
+
+	// Check existing links against the updated lists
+	a.maintenance.trigger(rescanJob)
+
+	log.Println("[APP] Reload complete")
+}
+
 // ListenAndServe starts the HTTP server
 func (a *App) ListenAndServe() error {
 	return a.HTTPServer.ListenAndServe()
@@ -74,6 +101,209 @@ func NewServer() *App {
 	// Initialize database service
 	db := database.New()
 
+	// Rollup compaction interval (default 1m, 0 disables the worker)
+	rollupInterval := time.Minute
+	if intervalStr := os.Getenv("ROLLUP_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			rollupInterval = interval
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid ROLLUP_INTERVAL value '%s', using default %s: %v", intervalStr, rollupInterval, err)
+		}
+	}
+
+	// Raw click retention in days (default 90, 0 keeps clicks forever)
+	retentionDays := 90
+	if daysStr := os.Getenv("CLICK_RETENTION_DAYS"); daysStr != "" {
+		if days, err := strconv.Atoi(daysStr); err == nil && days >= 0 {
+			retentionDays = days
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid CLICK_RETENTION_DAYS value '%s', using default %d", daysStr, retentionDays)
+		}
+	}
+
+	// Days a deleted link stays restorable before it is purged (default 30)
+	deletionGraceDays := 30
+	if daysStr := os.Getenv("DELETION_GRACE_DAYS"); daysStr != "" {
+		if days, err := strconv.Atoi(daysStr); err == nil && days >= 0 {
+			deletionGraceDays = days
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid DELETION_GRACE_DAYS value '%s', using default %d", daysStr, deletionGraceDays)
+		}
+	}
+
+	// Partition, retention and deleted link purge interval (default 1h, 0 disables them)
+	maintenanceInterval := time.Hour
+	if intervalStr := os.Getenv("MAINTENANCE_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			maintenanceInterval = interval
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid MAINTENANCE_INTERVAL value '%s', using default %s: %v", intervalStr, maintenanceInterval, err)
+		}
+	}
+
+	// Expiry sweep interval (default 1m, 0 disables the sweep)
+	expirySweepInterval := time.Minute
+	if intervalStr := os.Getenv("EXPIRY_SWEEP_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			expirySweepInterval = interval
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid EXPIRY_SWEEP_INTERVAL value '%s', using default %s: %v", intervalStr, expirySweepInterval, err)
+		}
+	}
+
+	// Destination re-scan interval (default 24h, 0 disables scheduled re-scans)
+	rescanInterval := 24 * time.Hour
+	if intervalStr := os.Getenv("RESCAN_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			rescanInterval = interval
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid RESCAN_INTERVAL value '%s', using default %s: %v", intervalStr, rescanInterval, err)
+		}
+	}
+
+	// Broken-link check interval (default 0: destinations are only probed on request)
+	var linkCheckInterval time.Duration
+	if intervalStr := os.Getenv("LINK_CHECK_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err == nil {
+			linkCheckInterval = interval
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid LINK_CHECK_INTERVAL value '%s', link checks disabled: %v", intervalStr, err)
+		}
+	}
+
+	// Live click fan-out across instances via Postgres NOTIFY (default on)
+	liveFanout := true
+	if fanoutStr := os.Getenv("LIVE_FANOUT"); fanoutStr != "" {
+		if fanout, err := strconv.ParseBool(fanoutStr); err == nil {
+			liveFanout = fanout
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid LIVE_FANOUT value '%s', using default %t: %v", fanoutStr, liveFanout, err)
+		}
+	}
+
+	// File extensions of blocked target URLs (comma-separated, empty blocks none)
+	blockedExtensions := urlscan.DefaultBlockedExtensions
+	if extStr, ok := os.LookupEnv("BLOCKED_EXTENSIONS"); ok {
+		blockedExtensions = splitList(extStr)
+	}
+
+	// Reject target hosts that do not resolve (default on)
+	ssrfStrict := true
+	if strictStr := os.Getenv("SSRF_STRICT"); strictStr != "" {
+		if strict, err := strconv.ParseBool(strictStr); err == nil {
+			ssrfStrict = strict
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid SSRF_STRICT value '%s', using default %t: %v", strictStr, ssrfStrict, err)
+		}
+	}
+
+	// Fetch link previews of new targets in the background (default on)
+	fetchPreviews := true
+	if previewsStr := os.Getenv("FETCH_PREVIEWS"); previewsStr != "" {
+		if enabled, err := strconv.ParseBool(previewsStr); err == nil {
+			fetchPreviews = enabled
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid FETCH_PREVIEWS value '%s', using default %t: %v", previewsStr, fetchPreviews, err)
+		}
+	}
+
+	// Optional target query and fragment normalizations (default off)
+	stripTracking := false
+	if stripStr := os.Getenv("STRIP_TRACKING_PARAMS"); stripStr != "" {
+		if strip, err := strconv.ParseBool(stripStr); err == nil {
+			stripTracking = strip
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid STRIP_TRACKING_PARAMS value '%s', using default %t: %v", stripStr, stripTracking, err)
+		}
+	}
+	sortQuery := false
+	if sortStr := os.Getenv("SORT_QUERY_PARAMS"); sortStr != "" {
+		if sorted, err := strconv.ParseBool(sortStr); err == nil {
+			sortQuery = sorted
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid SORT_QUERY_PARAMS value '%s', using default %t: %v", sortStr, sortQuery, err)
+		}
+	}
+	stripFragments := false
+	if fragmentsStr := os.Getenv("STRIP_FRAGMENTS"); fragmentsStr != "" {
+		if strip, err := strconv.ParseBool(fragmentsStr); err == nil {
+			stripFragments = strip
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid STRIP_FRAGMENTS value '%s', using default %t: %v", fragmentsStr, stripFragments, err)
+		}
+	}
+
+	// Reuse an owner's existing link to the same target (default off), with
+	// per-owner defaults as comma-separated owner:bool pairs
+	dedupeTargets := false
+	if dedupeStr := os.Getenv("DEDUPLICATE_TARGETS"); dedupeStr != "" {
+		if dedupe, err := strconv.ParseBool(dedupeStr); err == nil {
+			dedupeTargets = dedupe
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid DEDUPLICATE_TARGETS value '%s', using default %t: %v", dedupeStr, dedupeTargets, err)
+		}
+	}
+	ownerDedupe := make(map[int64]bool)
+	for _, entry := range splitList(os.Getenv("OWNER_DEDUPLICATION")) {
+		ownerStr, dedupeStr, _ := strings.Cut(entry, ":")
+		owner, ownerErr := strconv.ParseInt(ownerStr, 10, 64)
+		dedupe, dedupeErr := strconv.ParseBool(dedupeStr)
+		if ownerErr != nil || dedupeErr != nil {
+			log.Printf("[SERVER] WARNING: Invalid OWNER_DEDUPLICATION entry '%s', ignoring it", entry)
+			continue
+		}
+		ownerDedupe[owner] = dedupe
+	}
+
+	// How generated codes are chosen: random (default) or sequence, which
+	// leases ids in blocks (default 100) and permutes them with CODE_SECRET
+	codeStrategy := shortener.CodeStrategyRandom
+	if strategyStr := os.Getenv("CODE_STRATEGY"); strategyStr != "" {
+		if strategyStr == shortener.CodeStrategyRandom || strategyStr == shortener.CodeStrategySequence {
+			codeStrategy = strategyStr
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid CODE_STRATEGY value '%s', using default %s", strategyStr, codeStrategy)
+		}
+	}
+	codeBlockSize := 100
+	if sizeStr := os.Getenv("CODE_BLOCK_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			codeBlockSize = size
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid CODE_BLOCK_SIZE value '%s', using default %d", sizeStr, codeBlockSize)
+		}
+	}
+
+	// Characters of generated codes: base62 (default), readable (no look-alike
+	// characters), crockford (case-insensitive base32) or words (brave-otter-42)
+	codeAlphabet := shortener.CodeAlphabetBase62
+	if alphabetStr := os.Getenv("CODE_ALPHABET"); alphabetStr != "" {
+		if _, err := shortener.AlphabetByName(alphabetStr); err == nil {
+			codeAlphabet = strings.ToLower(alphabetStr)
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid CODE_ALPHABET value '%s', using default %s", alphabetStr, codeAlphabet)
+		}
+	}
+	ignoreCodeCase := false
+	if ignoreStr := os.Getenv("IGNORE_CODE_CASE"); ignoreStr != "" {
+		if ignore, err := strconv.ParseBool(ignoreStr); err == nil {
+			ignoreCodeCase = ignore
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid IGNORE_CODE_CASE value '%s', using default %t", ignoreStr, ignoreCodeCase)
+		}
+	}
+
+	// Share of a code length's keyspace in use before random codes grow one
+	// character longer
+	maxCodeOccupancy := 0.1
+	if occupancyStr := os.Getenv("MAX_CODE_OCCUPANCY"); occupancyStr != "" {
+		if occupancy, err := strconv.ParseFloat(occupancyStr, 64); err == nil && occupancy > 0 && occupancy < 1 {
+			maxCodeOccupancy = occupancy
+		} else {
+			log.Printf("[SERVER] WARNING: Invalid MAX_CODE_OCCUPANCY value '%s', using default %g", occupancyStr, maxCodeOccupancy)
+		}
+	}
+
 	// Initialize shortener service with configuration
 	config := &shortener.Config{
 		MaxRetries:          5,
@@ -85,6 +315,30 @@ func NewServer() *App {
 		EnableAnalytics:     true,
 		AnonymizeIPs:        true,
 		RespectDNT:          false,
+		VisitorHashSalt:     os.Getenv("VISITOR_HASH_SALT"),
+		GeoIPDatabasePath:   os.Getenv("GEOIP_DATABASE_PATH"),
+		RollupInterval:      rollupInterval,
+		ClickRetention:      time.Duration(retentionDays) * 24 * time.Hour,
+		MaintenanceInterval: maintenanceInterval,
+		LiveFanout:          liveFanout,
+		DeletionGracePeriod: time.Duration(deletionGraceDays) * 24 * time.Hour,
+		URLBlocklists:       splitList(os.Getenv("URL_BLOCKLISTS")),
+		URLHashPrefixLists:  splitList(os.Getenv("URL_HASH_PREFIX_LISTS")),
+		URLAllowlists:       splitList(os.Getenv("URL_ALLOWLISTS")),
+		BlockedExtensions:   blockedExtensions,
+		SSRFStrict:          ssrfStrict,
+		FetchPreviews:       fetchPreviews,
+		StripTrackingParams: stripTracking,
+		SortQueryParams:     sortQuery,
+		StripFragments:      stripFragments,
+		DeduplicateTargets:  dedupeTargets,
+		OwnerDeduplication:  ownerDedupe,
+		CodeStrategy:        codeStrategy,
+		CodeSecret:          os.Getenv("CODE_SECRET"),
+		CodeBlockSize:       codeBlockSize,
+		CodeAlphabet:        codeAlphabet,
+		IgnoreCodeCase:      ignoreCodeCase,
+		MaxCodeOccupancy:    maxCodeOccupancy,
 	}
 
 	shortenerSvc := shortener.NewService(db, config)
@@ -106,9 +360,85 @@ func NewServer() *App {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	// End live click streams on shutdown, otherwise their connections
+	// never become idle and Shutdown waits for its deadline
+	httpServer.RegisterOnShutdown(shortenerSvc.CloseLiveStreams)
+
+	// Scheduled maintenance, owned by the App
+	maintenance := newMaintenanceRunner(db)
+	if expirySweepInterval > 0 {
+		maintenance.add(maintenanceJob{
+			name:     "expiry-sweep",
+			interval: expirySweepInterval,
+			lockKey:  database.LockExpirySweep,
+			run: func(ctx context.Context) error {
+				expired, err := shortenerSvc.ExpireURLs(ctx)
+				if expired > 0 {
+					log.Printf("[MAINTENANCE] Expired %d URLs", expired)
+				}
+				return err
+			},
+		})
+	}
+	if maintenanceInterval > 0 {
+		maintenance.add(maintenanceJob{
+			name:     "deletion-purge",
+			interval: maintenanceInterval,
+			lockKey:  database.LockDeletionPurge,
+			run: func(ctx context.Context) error {
+				purged, err := shortenerSvc.PurgeDeletedURLs(ctx)
+				if purged > 0 {
+					log.Printf("[MAINTENANCE] Purged %d deleted URLs", purged)
+				}
+				return err
+			},
+		})
+	}
+	if rescanInterval > 0 {
+		maintenance.add(maintenanceJob{
+			name:     rescanJob,
+			interval: rescanInterval,
+			lockKey:  database.LockURLRescan,
+			run: func(ctx context.Context) error {
+				result, err := shortenerSvc.RescanURLs(ctx)
+				if result != nil && result.Quarantined > 0 {
+					log.Printf("[MAINTENANCE] Quarantined %d of %d re-scanned URLs", result.Quarantined, result.Scanned)
+				}
+				return err
+			},
+		})
+	}
+	if linkCheckInterval > 0 {
+		maintenance.add(maintenanceJob{
+			name:     "link-check",
+			interval: linkCheckInterval,
+			lockKey:  database.LockLinkCheck,
+			run: func(ctx context.Context) error {
+				result, err := shortenerSvc.CheckLinks(ctx)
+				if result != nil && result.Broken > 0 {
+					log.Printf("[MAINTENANCE] %d of %d checked links are broken", result.Broken, result.Checked)
+				}
+				return err
+			},
+		})
+	}
+	maintenance.Start()
+
 	return &App{
 		HTTPServer:   httpServer,
 		shortenerSvc: shortenerSvc,
 		db:           db,
+		maintenance:  maintenance,
+	}
+}
+
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -0,0 +1,336 @@
+package shortener
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/database"
+)
+
+const (
+	// Share of a length's keyspace in use at which random codes grow longer,
+	// so about one in ten generated codes is taken
+	defaultMaxCodeOccupancy = 0.1
+
+	codeLengthRefreshTimeout = 10 * time.Second
+)
+
+// AdaptiveStrategy generates random codes whose length follows how full the
+// keyspace is. Codes grow longer when the current length's keyspace fills up
+// or collisions pile up, and shrink back to the minimum once a shorter length
+// has room again. The length is stored so instances and restarts share it.
+type AdaptiveStrategy struct {
+	repo         database.URLRepository
+	alphabet     *Alphabet
+	minLength    int
+	maxOccupancy float64
+
+	// Swapped whole on length changes, so NextCode never takes the lock
+	generator atomic.Pointer[Generator]
+
+	mu          sync.Mutex // Serializes length changes and guards counts
+	counts      map[int]int64
+	stored      int // Last stored length seen, 0 if none
+	refreshedAt time.Time
+
+	generated  atomic.Int64
+	collisions atomic.Int64
+	grows      atomic.Int64
+	shrinks    atomic.Int64
+}
+
+// CodeStats describes generated codes and the occupancy of their keyspace
+type CodeStats struct {
+	Strategy    string            `json:"strategy"`
+	Alphabet    string            `json:"alphabet"`
+	Length      int               `json:"length"` // Of newly generated codes
+	MinLength   int               `json:"min_length"`
+	Lengths     []CodeLengthStats `json:"lengths,omitempty"`
+	Generated   int64             `json:"generated"`
+	Collisions  int64             `json:"collisions"`
+	Grows       int64             `json:"grows"`
+	Shrinks     int64             `json:"shrinks"`
+	RefreshedAt *time.Time        `json:"refreshed_at,omitempty"` // Last count from the database
+}
+
+// CodeLengthStats is the occupancy of the keyspace of one code length
+type CodeLengthStats struct {
+	Length    int     `json:"length"`
+	Codes     int64   `json:"codes"`
+	Keyspace  float64 `json:"keyspace"`
+	Occupancy float64 `json:"occupancy"`
+}
+
+// NewAdaptiveStrategy creates an adaptive strategy starting at minLength.
+// Refresh loads the stored length and code counts.
+func NewAdaptiveStrategy(repo database.URLRepository, alphabet *Alphabet, minLength int, maxOccupancy float64) (*AdaptiveStrategy, error) {
+	generator, err := NewGeneratorWithAlphabet(alphabet, minLength)
+	if err != nil {
+		return nil, err
+	}
+	if maxOccupancy <= 0 || maxOccupancy >= 1 {
+		maxOccupancy = defaultMaxCodeOccupancy
+	}
+
+	a := &AdaptiveStrategy{
+		repo:         repo,
+		alphabet:     alphabet,
+		minLength:    minLength,
+		maxOccupancy: maxOccupancy,
+		counts:       make(map[int]int64),
+	}
+	a.generator.Store(generator)
+	return a, nil
+}
+
+// NextCode returns a random code of the current length, implementing
+// CodeStrategy
+func (a *AdaptiveStrategy) NextCode(ctx context.Context) (string, error) {
+	a.generated.Add(1)
+	return a.generator.Load().Generate()
+}
+
+// Unique reports false: random codes can collide and are looked up before use
+func (a *AdaptiveStrategy) Unique() bool {
+	return false
+}
+
+// Length returns the length of newly generated codes
+func (a *AdaptiveStrategy) Length() int {
+	return a.generator.Load().GetCodeLength()
+}
+
+// Collided records a generated code that was taken
+func (a *AdaptiveStrategy) Collided() {
+	a.collisions.Add(1)
+}
+
+// Grow lengthens codes after repeated collisions at length from. Requests
+// that collided at the same length grow it once between them.
+func (a *AdaptiveStrategy) Grow(ctx context.Context, from int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.Length() != from {
+		return
+	}
+	a.setLength(ctx, from+1)
+}
+
+// Created records a new code and grows codes once their keyspace is too full
+func (a *AdaptiveStrategy) Created(ctx context.Context, code string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.counts[len(code)]++
+	if length := a.Length(); a.occupancy(length) >= a.maxOccupancy {
+		a.setLength(ctx, length+1)
+	}
+}
+
+// Load counts codes in the database and adopts the stored length, growing it
+// if its keyspace is too full
+func (a *AdaptiveStrategy) Load(ctx context.Context) error {
+	return a.refresh(ctx, false)
+}
+
+// Refresh recounts codes in the database and adopts the stored length, then
+// moves it towards the shortest length at or above the minimum with room
+func (a *AdaptiveStrategy) Refresh(ctx context.Context) error {
+	return a.refresh(ctx, true)
+}
+
+func (a *AdaptiveStrategy) refresh(ctx context.Context, shrink bool) error {
+	counts, err := a.repo.CountCodesByLength(ctx)
+	if err != nil {
+		return err
+	}
+	stored, err := a.repo.GetCodeLength(ctx, a.alphabet.Name)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.counts = counts
+	a.stored = stored
+	a.refreshedAt = time.Now()
+
+	length := max(stored, a.minLength)
+	for length < maxCodeLength && a.occupancy(length) >= a.maxOccupancy {
+		length++
+	}
+	// Shrink one length per refresh, once the shorter keyspace is under half
+	// the limit, so lengths do not flap
+	if shrink && length > a.minLength && a.occupancy(length-1) < a.maxOccupancy/2 {
+		length--
+	}
+
+	if length != a.Length() || length != stored {
+		a.setLength(ctx, length)
+	}
+	return nil
+}
+
+// Stats returns the current length, keyspace occupancy and counters
+func (a *AdaptiveStrategy) Stats() *CodeStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := &CodeStats{
+		Strategy:   CodeStrategyRandom,
+		Alphabet:   a.alphabet.Name,
+		Length:     a.Length(),
+		MinLength:  a.minLength,
+		Generated:  a.generated.Load(),
+		Collisions: a.collisions.Load(),
+		Grows:      a.grows.Load(),
+		Shrinks:    a.shrinks.Load(),
+	}
+	if !a.refreshedAt.IsZero() {
+		refreshedAt := a.refreshedAt
+		stats.RefreshedAt = &refreshedAt
+	}
+
+	for length := range a.counts {
+		if length < minCodeLength || length > maxCodeLength {
+			continue
+		}
+		stats.Lengths = append(stats.Lengths, CodeLengthStats{
+			Length:    length,
+			Codes:     a.counts[length],
+			Keyspace:  a.keyspace(length),
+			Occupancy: a.occupancy(length),
+		})
+	}
+	sort.Slice(stats.Lengths, func(i, j int) bool {
+		return stats.Lengths[i].Length < stats.Lengths[j].Length
+	})
+	return stats
+}
+
+// setLength stores a new length and swaps in a generator of it. The store
+// only succeeds if no other instance changed the length since it was last
+// seen; otherwise the length that instance stored is adopted. The caller
+// holds a.mu.
+func (a *AdaptiveStrategy) setLength(ctx context.Context, length int) {
+	// Request contexts may end before the length is stored
+	ctx = context.WithoutCancel(ctx)
+
+	swapped, err := a.repo.SetCodeLength(ctx, a.alphabet.Name, a.stored, length)
+	switch {
+	case err != nil:
+		log.Printf("[SHORTENER] WARNING: Failed to store code length %d: %v", length, err)
+	case !swapped:
+		stored, err := a.repo.GetCodeLength(ctx, a.alphabet.Name)
+		if err != nil {
+			log.Printf("[SHORTENER] WARNING: Failed to get code length: %v", err)
+			return
+		}
+		log.Printf("[SHORTENER] Code length was changed to %d by another instance", stored)
+		a.stored = stored
+		length = max(stored, a.minLength)
+	default:
+		a.stored = length
+	}
+
+	a.swapGenerator(length)
+}
+
+// swapGenerator swaps in a generator of the given length. The caller holds
+// a.mu.
+func (a *AdaptiveStrategy) swapGenerator(length int) {
+	current := a.Length()
+	if length == current {
+		return
+	}
+
+	generator, err := NewGeneratorWithAlphabet(a.alphabet, length)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Cannot change code length to %d: %v", length, err)
+		return
+	}
+	a.generator.Store(generator)
+
+	if length > current {
+		a.grows.Add(1)
+		log.Printf("[SHORTENER] WARNING: Growing codes to %d characters, %.2f%% of the %d-character keyspace is taken",
+			length, 100*a.occupancy(current), current)
+	} else {
+		a.shrinks.Add(1)
+		log.Printf("[SHORTENER] Shrinking codes to %d characters, %.2f%% of their keyspace is taken",
+			length, 100*a.occupancy(length))
+	}
+}
+
+func (a *AdaptiveStrategy) occupancy(length int) float64 {
+	return float64(a.counts[length]) / a.keyspace(length)
+}
+
+func (a *AdaptiveStrategy) keyspace(length int) float64 {
+	return math.Pow(float64(a.alphabet.base()), float64(length))
+}
+
+// GetCodeStats returns the state of code generation
+func (s *service) GetCodeStats() *CodeStats {
+	if adaptive, ok := s.codes.(*AdaptiveStrategy); ok {
+		return adaptive.Stats()
+	}
+
+	strategy := s.config.CodeStrategy
+	if strategy == "" {
+		strategy = CodeStrategyRandom
+	}
+	return &CodeStats{
+		Strategy:  strategy,
+		Alphabet:  s.alphabet.Name,
+		Length:    s.config.DefaultCodeLength,
+		MinLength: s.config.DefaultCodeLength,
+	}
+}
+
+// refreshCodeLength recounts the keyspace of adaptive codes. Only periodic
+// refreshes shrink codes, so restarts keep the stored length.
+func (s *service) refreshCodeLength(ctx context.Context, shrink bool) {
+	adaptive, ok := s.codes.(*AdaptiveStrategy)
+	if !ok {
+		return
+	}
+	refresh := adaptive.Load
+	if shrink {
+		refresh = adaptive.Refresh
+	}
+	if err := refresh(ctx); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to refresh code length: %v", err)
+		return
+	}
+	log.Printf("[SHORTENER] Code length is %d", adaptive.Length())
+}
+
+// codeLengthWorker refreshes the code length periodically until shutdown
+func (s *service) codeLengthWorker(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			log.Printf("[SHORTENER] Code length worker shutting down")
+			return
+		case <-ticker.C:
+			// One instance at a time, so shrinks do not race grows elsewhere
+			s.runExclusive("Code length", database.LockCodeLength, codeLengthRefreshTimeout, func(ctx context.Context) error {
+				s.refreshCodeLength(ctx, true)
+				return nil
+			})
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+// lockedRepository serializes the repository calls of link creation, so
+// concurrent creations can share a MockRepository
+type lockedRepository struct {
+	*MockRepository
+	mu sync.Mutex
+}
+
+func (r *lockedRepository) CreateURL(ctx context.Context, url *models.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.CreateURL(ctx, url)
+}
+
+func (r *lockedRepository) GetURLByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.GetURLByShortCode(ctx, shortCode)
+}
+
+func (r *lockedRepository) IsCodeTombstoned(ctx context.Context, code string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.IsCodeTombstoned(ctx, code)
+}
+
+func (r *lockedRepository) RecordAudit(ctx context.Context, entry *models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.RecordAudit(ctx, entry)
+}
+
+func (r *lockedRepository) CountCodesByLength(ctx context.Context) (map[int]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.CountCodesByLength(ctx)
+}
+
+func (r *lockedRepository) GetCodeLength(ctx context.Context, alphabet string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.GetCodeLength(ctx, alphabet)
+}
+
+func (r *lockedRepository) SetCodeLength(ctx context.Context, alphabet string, from, length int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.MockRepository.SetCodeLength(ctx, alphabet, from, length)
+}
+
+func TestAdaptiveStrategy(t *testing.T) {
+	repo := NewMockRepository()
+	ctx := context.Background()
+
+	// 62^4 * 1e-6 allows 14 four-character codes
+	adaptive, err := NewAdaptiveStrategy(repo, AlphabetBase62, 4, 1e-6)
+	if err != nil {
+		t.Fatalf("NewAdaptiveStrategy() unexpected error: %v", err)
+	}
+
+	for i := 0; i < 15; i++ {
+		code, _ := adaptive.NextCode(ctx)
+		if len(code) != 4 {
+			t.Fatalf("Code %d is %q, expected 4 characters", i, code)
+		}
+		adaptive.Created(ctx, code)
+	}
+	if adaptive.Length() != 5 || repo.codeLengths[CodeAlphabetBase62] != 5 {
+		t.Errorf("Length after filling the keyspace = %d (stored %d), expected 5", adaptive.Length(), repo.codeLengths[CodeAlphabetBase62])
+	}
+
+	// Collisions grow the length they were seen at, once
+	adaptive.Grow(ctx, 4)
+	adaptive.Grow(ctx, 5)
+	adaptive.Grow(ctx, 5)
+	if adaptive.Length() != 6 {
+		t.Errorf("Length after collisions = %d, expected 6", adaptive.Length())
+	}
+
+	// Restarts keep the stored length; periodic refreshes shrink an empty
+	// keyspace one length at a time
+	if err := adaptive.Load(ctx); err != nil || adaptive.Length() != 6 {
+		t.Errorf("Load() = %v, length %d, expected the stored length 6", err, adaptive.Length())
+	}
+	for _, expected := range []int{5, 4, 4} {
+		if err := adaptive.Refresh(ctx); err != nil {
+			t.Fatalf("Refresh() unexpected error: %v", err)
+		}
+		if adaptive.Length() != expected || repo.codeLengths[CodeAlphabetBase62] != expected {
+			t.Errorf("Length after Refresh() = %d (stored %d), expected %d", adaptive.Length(), repo.codeLengths[CodeAlphabetBase62], expected)
+		}
+	}
+
+	stats := adaptive.Stats()
+	if stats.Grows != 2 || stats.Shrinks != 2 || stats.Generated != 15 || stats.RefreshedAt == nil {
+		t.Errorf("Stats() = %+v, expected 2 grows, 2 shrinks and 15 generated codes", stats)
+	}
+}
+
+func TestAdaptiveStrategy_ConcurrentInstances(t *testing.T) {
+	repo := NewMockRepository()
+	ctx := context.Background()
+
+	adaptive, err := NewAdaptiveStrategy(repo, AlphabetBase62, 4, defaultMaxCodeOccupancy)
+	if err != nil {
+		t.Fatalf("NewAdaptiveStrategy() unexpected error: %v", err)
+	}
+	if err := adaptive.Load(ctx); err != nil || repo.codeLengths[CodeAlphabetBase62] != 4 {
+		t.Fatalf("Load() = %v, stored length %d, expected 4", err, repo.codeLengths[CodeAlphabetBase62])
+	}
+
+	// Another instance grew codes to 6; this one's grow and shrink must not
+	// overwrite that, and adopt it instead
+	repo.codeLengths[CodeAlphabetBase62] = 6
+	adaptive.Grow(ctx, 4)
+	if adaptive.Length() != 6 || repo.codeLengths[CodeAlphabetBase62] != 6 {
+		t.Errorf("Length after a concurrent grow = %d (stored %d), expected 6", adaptive.Length(), repo.codeLengths[CodeAlphabetBase62])
+	}
+
+	repo.codeLengths[CodeAlphabetBase62] = 7
+	adaptive.Grow(ctx, 6)
+	if adaptive.Length() != 7 || repo.codeLengths[CodeAlphabetBase62] != 7 {
+		t.Errorf("Length after a second concurrent grow = %d (stored %d), expected 7", adaptive.Length(), repo.codeLengths[CodeAlphabetBase62])
+	}
+}
+
+func TestCreateShortURL_AdaptiveCountsGeneratedCodes(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	config.DefaultCodeLength = 4
+	config.MaxCodeOccupancy = 1e-6 // 14 four-character codes
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		req := &CreateURLRequest{URL: fmt.Sprintf("https://example.com/%d", i), CustomCode: fmt.Sprintf("cu%02d", i)}
+		if i >= 10 {
+			req = &CreateURLRequest{URL: fmt.Sprintf("https://example.com/%d", i), CodeAlphabet: CodeAlphabetCrockford}
+		}
+		if _, err := svc.CreateShortURL(ctx, req); err != nil {
+			t.Fatalf("CreateShortURL() unexpected error: %v", err)
+		}
+	}
+
+	// Custom codes and codes of other alphabets leave the keyspace alone
+	if stats := svc.GetCodeStats(); stats.Length != 4 || stats.Grows != 0 {
+		t.Errorf("GetCodeStats() length %d after %d grows, expected 4 and none", stats.Length, stats.Grows)
+	}
+}
+
+func TestAdaptiveStrategy_StoredLength(t *testing.T) {
+	repo := NewMockRepository()
+	repo.codeLengths[CodeAlphabetBase62] = 9
+	svc := NewService(repo, DefaultConfig())
+
+	stats := svc.GetCodeStats()
+	if stats.Length != 9 || stats.MinLength != 7 {
+		t.Errorf("GetCodeStats() length %d, min %d, expected the stored length 9 and min 7", stats.Length, stats.MinLength)
+	}
+}
+
+func TestCreateShortURL_ConcurrentAdaptive(t *testing.T) {
+	repo := &lockedRepository{MockRepository: NewMockRepository()}
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	config.DefaultCodeLength = 4
+	config.MaxCodeOccupancy = 1e-5 // 147 four-character codes
+	svc := NewService(repo, config)
+	ctx := context.Background()
+
+	const workers, perWorker = 32, 10
+	codes := make(chan string, workers*perWorker)
+	errs := make(chan error, workers*perWorker)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: fmt.Sprintf("https://example.com/%d/%d", w, i)})
+				if err != nil {
+					errs <- err
+					continue
+				}
+				codes <- url.ShortCode
+				svc.GetCodeStats()
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(codes)
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("CreateShortURL() unexpected error: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for code := range codes {
+		if seen[code] {
+			t.Errorf("Code %s was issued twice", code)
+		}
+		seen[code] = true
+	}
+
+	stats := svc.GetCodeStats()
+	if stats.Length != 5 || stats.Grows != 1 {
+		t.Errorf("GetCodeStats() length %d after %d grows, expected one grow to 5", stats.Length, stats.Grows)
+	}
+	if stored := repo.codeLengths[CodeAlphabetBase62]; stored != 5 {
+		t.Errorf("Stored code length = %d, expected 5", stored)
+	}
+}
@@ -0,0 +1,187 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"backend/internal/models"
+)
+
+const (
+	// Code alphabets of Config.CodeAlphabet and CreateURLRequest.CodeAlphabet
+	CodeAlphabetBase62    = "base62"    // a-z, A-Z, 0-9
+	CodeAlphabetReadable  = "readable"  // Base62 without 0, 1, i, l, o, I and O
+	CodeAlphabetCrockford = "crockford" // Crockford's base32, case-insensitive
+	CodeAlphabetWords     = "words"     // Word codes like brave-otter-42
+)
+
+// Alphabet is the set of codes a strategy generates. Case-insensitive
+// alphabets resolve codes typed in any case, and fold look-alike characters
+// onto the one they stand for.
+type Alphabet struct {
+	Name            string
+	Chars           string // Characters of generated codes; empty for word codes
+	CaseInsensitive bool
+
+	folds map[rune]rune // Applied after lowercasing
+	words bool
+}
+
+var (
+	AlphabetBase62 = &Alphabet{
+		Name:  CodeAlphabetBase62,
+		Chars: base62Chars,
+	}
+	AlphabetReadable = &Alphabet{
+		Name:  CodeAlphabetReadable,
+		Chars: "23456789abcdefghjkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ",
+	}
+	AlphabetCrockford = &Alphabet{
+		Name:            CodeAlphabetCrockford,
+		Chars:           "0123456789abcdefghjkmnpqrstvwxyz",
+		CaseInsensitive: true,
+		folds:           map[rune]rune{'i': '1', 'l': '1', 'o': '0'},
+	}
+	AlphabetWords = &Alphabet{
+		Name:            CodeAlphabetWords,
+		CaseInsensitive: true,
+		words:           true,
+	}
+)
+
+// AlphabetByName returns the alphabet called name; empty means base62
+func AlphabetByName(name string) (*Alphabet, error) {
+	switch strings.ToLower(name) {
+	case "", CodeAlphabetBase62:
+		return AlphabetBase62, nil
+	case CodeAlphabetReadable:
+		return AlphabetReadable, nil
+	case CodeAlphabetCrockford:
+		return AlphabetCrockford, nil
+	case CodeAlphabetWords:
+		return AlphabetWords, nil
+	}
+	return nil, fmt.Errorf("unknown code alphabet %q", name)
+}
+
+// IsValidCode checks if a string is a code of this alphabet. Codes of
+// case-insensitive alphabets are checked in canonical form.
+func (a *Alphabet) IsValidCode(code string) bool {
+	code = a.Canonical(code)
+	if a.words {
+		return isWordCode(code)
+	}
+
+	if len(code) < minCodeLength || len(code) > maxCodeLength {
+		return false
+	}
+	for _, char := range code {
+		if !strings.ContainsRune(a.Chars, char) {
+			return false
+		}
+	}
+	return true
+}
+
+// Canonical returns the stored form of a code typed by a user: unchanged for
+// case-sensitive alphabets, otherwise lowercased with look-alikes folded
+func (a *Alphabet) Canonical(code string) string {
+	if !a.CaseInsensitive {
+		return code
+	}
+
+	code = strings.ToLower(code)
+	if len(a.folds) == 0 {
+		return code
+	}
+	return strings.Map(func(r rune) rune {
+		if folded, ok := a.folds[r]; ok {
+			return folded
+		}
+		return r
+	}, code)
+}
+
+func (a *Alphabet) base() int64 {
+	return int64(len(a.Chars))
+}
+
+// newRandomStrategy creates the strategy of random codes of an alphabet
+func newRandomStrategy(alphabet *Alphabet, length int) (CodeStrategy, error) {
+	if alphabet.words {
+		return NewWordStrategy(), nil
+	}
+	generator, err := NewGeneratorWithAlphabet(alphabet, length)
+	if err != nil {
+		return nil, err
+	}
+	return generator, nil
+}
+
+// codeStrategy returns the strategy of a request's code alphabet: the
+// configured one, or random codes of another alphabet
+func (s *service) codeStrategy(name string) (CodeStrategy, error) {
+	if name == "" {
+		return s.codes, nil
+	}
+
+	alphabet, err := AlphabetByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	if alphabet == s.alphabet {
+		return s.codes, nil
+	}
+	if s.config.IgnoreCodeCase && !alphabet.CaseInsensitive {
+		return nil, fmt.Errorf("%w: %s codes are case-sensitive", ErrInvalidRequest, alphabet.Name)
+	}
+	return newRandomStrategy(alphabet, s.config.DefaultCodeLength)
+}
+
+// customCode returns the stored form of a custom code, which is lowercase
+// when codes are case-insensitive
+func (s *service) customCode(code string) string {
+	if s.config.IgnoreCodeCase {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+// lookupRedirectCode finds the link of a code as typed. With case-insensitive
+// codes, a code missing as typed is tried lowercased, then in the canonical
+// form of the configured alphabet. Links are cached under their stored code
+// so invalidating it covers every spelling.
+func (s *service) lookupRedirectCode(ctx context.Context, code string) (*models.URL, error) {
+	candidates := []string{code}
+	if s.config.IgnoreCodeCase {
+		if lower := strings.ToLower(code); lower != code {
+			candidates = append(candidates, lower)
+		}
+		if canonical := s.alphabet.Canonical(code); s.alphabet.IsValidCode(canonical) && !slices.Contains(candidates, canonical) {
+			candidates = append(candidates, canonical)
+		}
+	}
+
+	// Check cache first
+	for _, candidate := range candidates {
+		if url, found := s.urlCache.Get(candidate); found {
+			log.Printf("[SHORTENER] Cache hit for %s", candidate)
+			return url, nil
+		}
+	}
+
+	// Cache miss - get from database
+	var err error
+	for _, candidate := range candidates {
+		var url *models.URL
+		if url, err = s.repo.GetURLByShortCode(ctx, candidate); err == nil {
+			s.urlCache.Set(url.ShortCode, url)
+			log.Printf("[SHORTENER] Cache miss for %s, fetched from DB", candidate)
+			return url, nil
+		}
+	}
+	return nil, err
+}
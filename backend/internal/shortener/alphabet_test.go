@@ -0,0 +1,216 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"backend/internal/ssrf"
+)
+
+func TestAlphabetIsValidCode(t *testing.T) {
+	tests := []struct {
+		alphabet *Alphabet
+		code     string
+		valid    bool
+	}{
+		{AlphabetBase62, "aB3dE7x", true},
+		{AlphabetBase62, "abc-def", false},
+		{AlphabetReadable, "aB3dE7x", true},
+		{AlphabetReadable, "aB0dE7x", false},
+		{AlphabetReadable, "albcdef", false},
+		{AlphabetReadable, "aBcdeOx", false},
+		{AlphabetCrockford, "7k2m9q4", true},
+		{AlphabetCrockford, "7K2M9Q4", true},
+		{AlphabetCrockford, "7kIm0q4", true}, // I folds to 1
+		{AlphabetCrockford, "7k2u9q4", false},
+		{AlphabetCrockford, "7k2", false},
+		{AlphabetWords, "brave-otter-42", true},
+		{AlphabetWords, "Brave-Otter-42", true},
+		{AlphabetWords, "brave-otter-07", false},
+		{AlphabetWords, "brave-otter", false},
+		{AlphabetWords, "brave--42", false},
+		{AlphabetWords, "brave-ott3r-42", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alphabet.Name+"/"+tt.code, func(t *testing.T) {
+			if valid := tt.alphabet.IsValidCode(tt.code); valid != tt.valid {
+				t.Errorf("IsValidCode(%q) = %v, expected %v", tt.code, valid, tt.valid)
+			}
+		})
+	}
+}
+
+func TestAlphabetCanonical(t *testing.T) {
+	tests := []struct {
+		alphabet *Alphabet
+		code     string
+		expected string
+	}{
+		{AlphabetBase62, "AbC123", "AbC123"},
+		{AlphabetReadable, "AbC234", "AbC234"},
+		{AlphabetCrockford, "AbC123", "abc123"},
+		{AlphabetCrockford, "OIL0il", "011011"},
+		{AlphabetWords, "Jolly-Lion-10", "jolly-lion-10"},
+	}
+
+	for _, tt := range tests {
+		if canonical := tt.alphabet.Canonical(tt.code); canonical != tt.expected {
+			t.Errorf("%s Canonical(%q) = %q, expected %q", tt.alphabet.Name, tt.code, canonical, tt.expected)
+		}
+	}
+
+	if _, err := AlphabetByName("klingon"); err == nil {
+		t.Error("AlphabetByName(klingon) expected an error")
+	}
+}
+
+func TestGeneratorWithAlphabet(t *testing.T) {
+	for _, alphabet := range []*Alphabet{AlphabetReadable, AlphabetCrockford} {
+		gen, err := NewGeneratorWithAlphabet(alphabet, 7)
+		if err != nil {
+			t.Fatalf("NewGeneratorWithAlphabet(%s) unexpected error: %v", alphabet.Name, err)
+		}
+		for i := 0; i < 500; i++ {
+			code, err := gen.Generate()
+			if err != nil {
+				t.Fatalf("Generate() unexpected error: %v", err)
+			}
+			if len(code) != 7 || !alphabet.IsValidCode(code) || alphabet.Canonical(code) != code {
+				t.Fatalf("%s Generate() = %q, expected a canonical 7-character code", alphabet.Name, code)
+			}
+		}
+	}
+
+	if _, err := NewGeneratorWithAlphabet(AlphabetWords, 7); err == nil {
+		t.Error("NewGeneratorWithAlphabet(words) expected an error")
+	}
+}
+
+func TestIsProfane(t *testing.T) {
+	tests := []struct {
+		code    string
+		profane bool
+	}{
+		{"xShitq", true},
+		{"aSH1Tz", true},
+		{"p0rn42", true},
+		{"sku11", false},
+		{"brave-otter-42", false},
+		{"aB3dE7x", false},
+	}
+
+	for _, tt := range tests {
+		if profane := IsProfane(tt.code); profane != tt.profane {
+			t.Errorf("IsProfane(%q) = %v, expected %v", tt.code, profane, tt.profane)
+		}
+	}
+}
+
+func TestWordStrategy(t *testing.T) {
+	for _, list := range [][]string{adjectives, nouns} {
+		if len(list) < 100 {
+			t.Errorf("Word list has %d words, expected at least 100", len(list))
+		}
+		for _, word := range list {
+			if IsProfane(word) || !isWordCode(word+"-word-42") {
+				t.Errorf("Word %q is not fit for codes", word)
+			}
+		}
+	}
+
+	words := NewWordStrategy()
+	for i := 0; i < 200; i++ {
+		code, err := words.NextCode(context.Background())
+		if err != nil {
+			t.Fatalf("NextCode() unexpected error: %v", err)
+		}
+		if !AlphabetWords.IsValidCode(code) || IsProfane(code) {
+			t.Fatalf("NextCode() = %q, expected a clean word code", code)
+		}
+	}
+}
+
+func TestCreateShortURL_CodeAlphabet(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	config.CodeAlphabet = CodeAlphabetReadable
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	tests := []struct {
+		alphabet string
+		expected *Alphabet
+	}{
+		{"", AlphabetReadable},
+		{CodeAlphabetWords, AlphabetWords},
+		{CodeAlphabetCrockford, AlphabetCrockford},
+		{CodeAlphabetBase62, AlphabetBase62},
+	}
+
+	for _, tt := range tests {
+		url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/" + tt.alphabet, CodeAlphabet: tt.alphabet})
+		if err != nil {
+			t.Fatalf("CreateShortURL(%q) unexpected error: %v", tt.alphabet, err)
+		}
+		if !tt.expected.IsValidCode(url.ShortCode) {
+			t.Errorf("CreateShortURL(%q) code = %q, expected a %s code", tt.alphabet, url.ShortCode, tt.expected.Name)
+		}
+	}
+
+	_, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CodeAlphabet: "emoji"})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("CreateShortURL(emoji) error = %v, expected ErrInvalidRequest", err)
+	}
+}
+
+func TestGetURLForRedirect_IgnoreCodeCase(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	config.CodeAlphabet = CodeAlphabetCrockford
+	config.IgnoreCodeCase = true
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	generated, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/generated"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+	custom, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/custom", CustomCode: "Spring-Sale"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+	if custom.ShortCode != "spring-sale" {
+		t.Errorf("Custom code stored as %q, expected spring-sale", custom.ShortCode)
+	}
+
+	// Typed with look-alikes of the digits 0 and 1
+	typed := strings.NewReplacer("0", "O", "1", "l").Replace(strings.ToUpper(generated.ShortCode))
+
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{generated.ShortCode, generated.ShortCode},
+		{typed, generated.ShortCode},
+		{"SPRING-SALE", "spring-sale"},
+		{"spring-sale", "spring-sale"},
+	}
+
+	for _, tt := range tests {
+		url, err := svc.GetURLForRedirect(ctx, tt.code, nil)
+		if err != nil {
+			t.Errorf("GetURLForRedirect(%q) unexpected error: %v", tt.code, err)
+			continue
+		}
+		if url.ShortCode != tt.expected {
+			t.Errorf("GetURLForRedirect(%q) = %q, expected %q", tt.code, url.ShortCode, tt.expected)
+		}
+	}
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CodeAlphabet: CodeAlphabetBase62}); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("CreateShortURL(base62) error = %v, expected ErrInvalidRequest", err)
+	}
+}
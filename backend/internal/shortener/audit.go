@@ -0,0 +1,165 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/models"
+)
+
+const (
+	// Header naming the caller, set by the authenticating proxy in front of the API
+	ActorHeader = "X-Actor"
+
+	// Actor recorded when no caller is known, and for background jobs
+	anonymousActor = "anonymous"
+	systemActor    = "system"
+
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// Actor identifies who made a change
+type Actor struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context attributing changes made with it to actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// actorFromContext returns the actor of ctx, or the system actor for
+// changes made outside a request
+func actorFromContext(ctx context.Context) Actor {
+	if actor, ok := ctx.Value(actorKey{}).(Actor); ok {
+		return actor
+	}
+	return Actor{Name: systemActor}
+}
+
+// ActorMiddleware attributes the changes made by a request to its caller
+func ActorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSpace(r.Header.Get(ActorHeader))
+		if name == "" {
+			name = anonymousActor
+		}
+		actor := Actor{Name: name, IP: extractIPAddress(r), UserAgent: r.UserAgent()}
+		next.ServeHTTP(w, r.WithContext(WithActor(r.Context(), actor)))
+	})
+}
+
+// audit appends an entry for a mutation that already happened. A failure is
+// logged rather than returned, since the change can no longer be undone.
+func (s *service) audit(ctx context.Context, action string, url *models.URL, changes map[string]models.FieldChange) {
+	entry := &models.AuditEntry{ShortCode: url.ShortCode, Action: action, Changes: changes}
+	if url.ID != 0 {
+		entry.URLID = &url.ID
+	}
+	s.recordAudit(ctx, entry)
+}
+
+// recordAudit fills in the actor of ctx and stores the entry
+func (s *service) recordAudit(ctx context.Context, entry *models.AuditEntry) {
+	actor := actorFromContext(ctx)
+	entry.Actor = actor.Name
+	entry.IP = optionalString(actor.IP)
+	entry.UserAgent = optionalString(actor.UserAgent)
+
+	// Record even if the request was cancelled after the change was made
+	if err := s.repo.RecordAudit(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("[SHORTENER] ERROR: Failed to audit %s of %s by %s: %v", entry.Action, entry.ShortCode, entry.Actor, err)
+	}
+}
+
+// diffURL returns the audited fields that differ between two versions of a URL
+func diffURL(before, after *models.URL) map[string]models.FieldChange {
+	changes := make(map[string]models.FieldChange)
+	if before.TargetURL != after.TargetURL {
+		changes["target_url"] = models.FieldChange{Before: before.TargetURL, After: after.TargetURL}
+	}
+	if before.IsActive != after.IsActive {
+		changes["is_active"] = models.FieldChange{Before: before.IsActive, After: after.IsActive}
+	}
+	if !equalTimes(before.ExpiresAt, after.ExpiresAt) {
+		changes["expires_at"] = models.FieldChange{Before: before.ExpiresAt, After: after.ExpiresAt}
+	}
+	if !equalTimes(before.DeletedAt, after.DeletedAt) {
+		changes["deleted_at"] = models.FieldChange{Before: before.DeletedAt, After: after.DeletedAt}
+	}
+	if !equalTimes(before.QuarantinedAt, after.QuarantinedAt) {
+		changes["quarantined_at"] = models.FieldChange{Before: before.QuarantinedAt, After: after.QuarantinedAt}
+	}
+	return changes
+}
+
+// equalTimes compares optional times
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// GetAuditLog returns audit entries, newest first, for one link or for all
+// of them. The history of a purged link is still available by its code.
+func (s *service) GetAuditLog(ctx context.Context, req *AuditRequest) (*AuditResponse, error) {
+	if req == nil {
+		req = &AuditRequest{}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !req.From.IsZero() && !req.From.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+
+	log.Printf("[SHORTENER] Getting audit log (code: %q, action: %q, actor: %q, limit: %d)",
+		req.ShortCode, req.Action, req.Actor, limit)
+
+	entries, err := s.repo.GetAuditLog(ctx, &database.AuditQuery{
+		ShortCode: req.ShortCode,
+		Action:    req.Action,
+		Actor:     req.Actor,
+		From:      req.From,
+		To:        to,
+		BeforeID:  req.Before,
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	// Unknown links have no history at all
+	if req.ShortCode != "" && len(entries) == 0 && req.Before == 0 {
+		if _, err := s.repo.GetURLByShortCode(ctx, req.ShortCode); err != nil {
+			return nil, ErrURLNotFound
+		}
+	}
+
+	response := &AuditResponse{Entries: entries}
+	if len(entries) == limit {
+		response.NextBefore = &entries[len(entries)-1].ID
+	}
+	return response, nil
+}
@@ -0,0 +1,161 @@
+package shortener
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func TestDiffURL(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	base := models.URL{ShortCode: "abc", TargetURL: "https://example.com", IsActive: true, ExpiresAt: &now}
+
+	tests := []struct {
+		name     string
+		change   func(u *models.URL)
+		expected []string
+	}{
+		{"no change", func(u *models.URL) {}, nil},
+		{"same expiry, other pointer", func(u *models.URL) { same := now; u.ExpiresAt = &same }, nil},
+		{"target", func(u *models.URL) { u.TargetURL = "https://example.org" }, []string{"target_url"}},
+		{"expiry and activity", func(u *models.URL) { u.ExpiresAt = &later; u.IsActive = false }, []string{"expires_at", "is_active"}},
+		{"expiry removed", func(u *models.URL) { u.ExpiresAt = nil }, []string{"expires_at"}},
+		{"deleted", func(u *models.URL) { u.DeletedAt = &now }, []string{"deleted_at"}},
+	}
+
+	for _, tt := range tests {
+		after := base
+		tt.change(&after)
+
+		changes := diffURL(&base, &after)
+		if len(changes) != len(tt.expected) {
+			t.Errorf("%s: diffURL() = %v, expected changes to %v", tt.name, changes, tt.expected)
+			continue
+		}
+		for _, field := range tt.expected {
+			if _, ok := changes[field]; !ok {
+				t.Errorf("%s: diffURL() is missing %s", tt.name, field)
+			}
+		}
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewService(repo, DefaultConfig())
+	ctx := WithActor(context.Background(), Actor{Name: "alice", IP: "203.0.113.9", UserAgent: "curl/8.0"})
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CustomCode: "audited"}); err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+	if _, err := svc.UpdateURL(ctx, "audited", &UpdateURLRequest{TargetURL: "https://example.org"}); err != nil {
+		t.Fatalf("UpdateURL() unexpected error: %v", err)
+	}
+	if err := svc.DeactivateURL(ctx, "audited"); err != nil {
+		t.Fatalf("DeactivateURL() unexpected error: %v", err)
+	}
+	if _, err := svc.ReserveCode(ctx, &ReserveCodeRequest{Code: "brand", Reason: "system", Description: "Brand area"}); err != nil {
+		t.Fatalf("ReserveCode() unexpected error: %v", err)
+	}
+
+	// Changes without a request are made by the system
+	if err := svc.DeactivateURL(context.Background(), "audited"); err != nil {
+		t.Fatalf("DeactivateURL() unexpected error: %v", err)
+	}
+
+	history, err := svc.GetAuditLog(ctx, &AuditRequest{ShortCode: "audited"})
+	if err != nil {
+		t.Fatalf("GetAuditLog() unexpected error: %v", err)
+	}
+
+	var actions []string
+	for _, e := range history.Entries {
+		actions = append(actions, e.Action+"/"+e.Actor)
+	}
+	expected := "url.deactivate/system,url.deactivate/alice,url.update/alice,url.create/alice"
+	if strings.Join(actions, ",") != expected {
+		t.Fatalf("History = %v, expected %s", actions, expected)
+	}
+
+	update := history.Entries[2]
+	change, ok := update.Changes["target_url"]
+	if !ok || change.Before != "https://example.com" || change.After != "https://example.org" || len(update.Changes) != 1 {
+		t.Errorf("Update changes = %+v, expected only target_url", update.Changes)
+	}
+	if update.IP == nil || *update.IP != "203.0.113.9" || update.UserAgent == nil || *update.UserAgent != "curl/8.0" {
+		t.Errorf("Update attributed to IP %v, UA %v", update.IP, update.UserAgent)
+	}
+
+	// Deactivating an inactive link changes nothing
+	if len(history.Entries[0].Changes) != 0 {
+		t.Errorf("Repeated deactivation changes = %+v, expected none", history.Entries[0].Changes)
+	}
+
+	reserved, _ := svc.GetAuditLog(ctx, &AuditRequest{Action: models.AuditReservedCodeAdd})
+	if len(reserved.Entries) != 1 || reserved.Entries[0].ShortCode != "brand" {
+		t.Errorf("Reserved code entries = %+v", reserved.Entries)
+	}
+
+	// Paging with the cursor walks the rest of the log
+	page, _ := svc.GetAuditLog(ctx, &AuditRequest{Limit: 2})
+	if len(page.Entries) != 2 || page.NextBefore == nil {
+		t.Fatalf("First page = %+v, expected 2 entries and a cursor", page)
+	}
+	rest, _ := svc.GetAuditLog(ctx, &AuditRequest{Limit: 10, Before: *page.NextBefore})
+	if len(rest.Entries) != 3 || rest.NextBefore != nil {
+		t.Errorf("Second page has %d entries (cursor %v), expected the remaining 3", len(rest.Entries), rest.NextBefore)
+	}
+
+	if _, err := svc.GetAuditLog(ctx, &AuditRequest{ShortCode: "nonexistent"}); err != ErrURLNotFound {
+		t.Errorf("GetAuditLog() of unknown link error = %v, expected %v", err, ErrURLNotFound)
+	}
+}
+
+func TestAuditHandlers(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	req := httptest.NewRequest("PUT", "/api/urls/testcampaign", strings.NewReader(`{"target_url": "https://example.org"}`))
+	req.Header.Set(ActorHeader, "bob")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"history", "/api/urls/testcampaign/history", http.StatusOK},
+		{"unknown link", "/api/urls/nonexistent/history", http.StatusNotFound},
+		{"global", "/api/audit?action=url.update&actor=bob&from=2025-01-01", http.StatusOK},
+		{"bad cursor", "/api/audit?before=abc", http.StatusBadRequest},
+		{"bad range", "/api/audit?from=2025-02-01&to=2025-01-01", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d: %s", tt.name, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/audit?actor=bob", nil))
+
+	var resp struct {
+		Data AuditResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Data.Entries) != 1 || resp.Data.Entries[0].Action != models.AuditURLUpdate {
+		t.Errorf("Entries by bob = %+v, expected one update", resp.Data.Entries)
+	}
+}
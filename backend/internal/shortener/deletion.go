@@ -0,0 +1,149 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/models"
+)
+
+// Deleted URLs purged per query
+const purgeBatchSize = 100
+
+// DeleteURL soft-deletes a URL. It stops redirecting at once and can be
+// restored until the grace period ends, after which it is purged with all of
+// its clicks and its code is retired for good. Deleting twice is a no-op.
+func (s *service) DeleteURL(ctx context.Context, shortCode string) (*DeletionResponse, error) {
+	log.Printf("[SHORTENER] Deleting URL: %s", shortCode)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+
+	if !url.IsDeleted() {
+		before := *url
+		url, err = s.repo.SoftDeleteURL(ctx, shortCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete URL: %w", err)
+		}
+		s.urlCache.Delete(shortCode)
+		s.audit(ctx, models.AuditURLDelete, url, diffURL(&before, url))
+		s.events.emit(Event{Type: EventURLDeleted, URLID: url.ID, ShortCode: shortCode})
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Deleted URL %s, purge after %s", shortCode,
+		s.purgeAfter(*url.DeletedAt).Format(time.RFC3339))
+	return &DeletionResponse{
+		ShortCode:  shortCode,
+		DeletedAt:  *url.DeletedAt,
+		PurgeAfter: s.purgeAfter(*url.DeletedAt),
+	}, nil
+}
+
+// RestoreURL undoes a deletion within the grace period
+func (s *service) RestoreURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	log.Printf("[SHORTENER] Restoring URL: %s", shortCode)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if !url.IsDeleted() {
+		return nil, ErrURLNotDeleted
+	}
+	if !time.Now().Before(s.purgeAfter(*url.DeletedAt)) {
+		return nil, ErrRestoreExpired
+	}
+	before := *url
+
+	restored, err := s.repo.RestoreURL(ctx, shortCode, time.Now().Add(-s.config.DeletionGracePeriod))
+	if err != nil {
+		// Lost a race with the purge or another restore
+		return nil, ErrRestoreExpired
+	}
+
+	s.urlCache.Delete(shortCode)
+	s.audit(ctx, models.AuditURLRestore, restored, diffURL(&before, restored))
+	s.events.emit(Event{Type: EventURLRestored, URLID: restored.ID, ShortCode: shortCode})
+
+	log.Printf("[SHORTENER] SUCCESS: Restored URL: %s", shortCode)
+	return restored, nil
+}
+
+// PurgeDeletedURLs permanently deletes every URL whose grace period has
+// ended, emitting EventURLPurged for each. It returns how many were purged.
+func (s *service) PurgeDeletedURLs(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.config.DeletionGracePeriod)
+
+	var total int
+	for {
+		codes, err := s.repo.PurgeDeletedURLs(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			log.Printf("[SHORTENER] ERROR: Purge failed after %d URLs: %v", total, err)
+			return total, err
+		}
+
+		for _, code := range codes {
+			s.urlCache.Delete(code)
+			s.recordAudit(ctx, &models.AuditEntry{ShortCode: code, Action: models.AuditURLPurge})
+			s.events.emit(Event{Type: EventURLPurged, ShortCode: code})
+		}
+		total += len(codes)
+
+		if len(codes) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// EraseClicks deletes the raw click events of one data subject across all
+// links. With IP anonymization on, clicks were stored with the anonymized
+// address, so the IP matches its whole anonymization prefix, erasing other
+// visitors' clicks too. An IP alone is then only accepted with MatchPrefix;
+// add the user agent to narrow the match.
+func (s *service) EraseClicks(ctx context.Context, req *ErasureRequest) (*ErasureResponse, error) {
+	ip := strings.TrimSpace(req.IP)
+	userAgent := strings.TrimSpace(req.UserAgent)
+	if ip == "" && userAgent == "" {
+		return nil, fmt.Errorf("%w: an IP or a user agent is required", ErrInvalidRequest)
+	}
+
+	query := &database.ErasureQuery{UserAgent: userAgent}
+	response := &ErasureResponse{}
+	if ip != "" {
+		if net.ParseIP(ip) == nil {
+			return nil, fmt.Errorf("%w: invalid IP address %q", ErrInvalidRequest, ip)
+		}
+		query.IPs = []string{ip}
+		if anonymized := s.anonymizeIP(ip); s.config.AnonymizeIPs && anonymized != ip {
+			if userAgent == "" && !req.MatchPrefix {
+				return nil, fmt.Errorf("%w: IPs are stored anonymized as %s, shared by other visitors; add a user agent or set match_prefix",
+					ErrInvalidRequest, anonymized)
+			}
+			query.IPs = append(query.IPs, anonymized)
+			response.MatchedPrefix = anonymized
+		}
+	}
+
+	log.Printf("[SHORTENER] Erasing clicks for a data subject (IP: %v, user agent: %v)", ip != "", userAgent != "")
+
+	erased, err := s.repo.EraseClickEvents(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase clicks: %w", err)
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Erased %d clicks", erased)
+	response.ClicksErased = erased
+	return response, nil
+}
+
+// purgeAfter returns when a URL deleted at deletedAt becomes eligible for purging
+func (s *service) purgeAfter(deletedAt time.Time) time.Time {
+	return deletedAt.Add(s.config.DeletionGracePeriod)
+}
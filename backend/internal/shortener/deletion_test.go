@@ -0,0 +1,182 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func TestDeleteRestoreAndPurge(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewService(repo, DefaultConfig()).(*service)
+	ctx := context.Background()
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CustomCode: "doomed"}); err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	var events []string
+	svc.OnEvent(func(e Event) { events = append(events, e.Type) })
+
+	// Warm the cache so deletion has to evict it
+	if _, err := svc.GetURLForRedirect(ctx, "doomed", nil); err != nil {
+		t.Fatalf("GetURLForRedirect() unexpected error: %v", err)
+	}
+
+	deletion, err := svc.DeleteURL(ctx, "doomed")
+	if err != nil {
+		t.Fatalf("DeleteURL() unexpected error: %v", err)
+	}
+	if !deletion.PurgeAfter.Equal(deletion.DeletedAt.Add(svc.config.DeletionGracePeriod)) {
+		t.Errorf("PurgeAfter = %s, expected DeletedAt + grace period", deletion.PurgeAfter)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "doomed", nil); err != ErrURLDeleted {
+		t.Errorf("GetURLForRedirect() after delete error = %v, expected %v", err, ErrURLDeleted)
+	}
+	if _, err := svc.UpdateURL(ctx, "doomed", &UpdateURLRequest{TargetURL: "https://example.org"}); err != ErrURLDeleted {
+		t.Errorf("UpdateURL() after delete error = %v, expected %v", err, ErrURLDeleted)
+	}
+
+	// Deleting again keeps the original grace period
+	again, err := svc.DeleteURL(ctx, "doomed")
+	if err != nil || !again.DeletedAt.Equal(deletion.DeletedAt) {
+		t.Errorf("Second DeleteURL() = %+v, %v, expected the first deletion", again, err)
+	}
+
+	if _, err := svc.RestoreURL(ctx, "doomed"); err != nil {
+		t.Fatalf("RestoreURL() unexpected error: %v", err)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "doomed", nil); err != nil {
+		t.Errorf("GetURLForRedirect() after restore error = %v", err)
+	}
+	if _, err := svc.RestoreURL(ctx, "doomed"); err != ErrURLNotDeleted {
+		t.Errorf("RestoreURL() of a live URL error = %v, expected %v", err, ErrURLNotDeleted)
+	}
+
+	// Nothing is purged within the grace period
+	if _, err := svc.DeleteURL(ctx, "doomed"); err != nil {
+		t.Fatalf("DeleteURL() unexpected error: %v", err)
+	}
+	if purged, _ := svc.PurgeDeletedURLs(ctx); purged != 0 {
+		t.Errorf("PurgeDeletedURLs() within grace period = %d, expected 0", purged)
+	}
+
+	// Once the grace period has passed the URL can't be restored and is purged
+	past := time.Now().Add(-svc.config.DeletionGracePeriod - time.Minute)
+	repo.urls["doomed"].DeletedAt = &past
+	if _, err := svc.RestoreURL(ctx, "doomed"); err != ErrRestoreExpired {
+		t.Errorf("RestoreURL() past grace period error = %v, expected %v", err, ErrRestoreExpired)
+	}
+	if purged, err := svc.PurgeDeletedURLs(ctx); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeletedURLs() = %d, %v, expected 1", purged, err)
+	}
+
+	// The code is never handed out again
+	if err := svc.ValidateCustomCode(ctx, "doomed"); err != ErrCustomCodeRetired {
+		t.Errorf("ValidateCustomCode() of purged code error = %v, expected %v", err, ErrCustomCodeRetired)
+	}
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.net", CustomCode: "doomed"}); !errors.Is(err, ErrCustomCodeRetired) {
+		t.Errorf("CreateShortURL() with purged code error = %v, expected %v", err, ErrCustomCodeRetired)
+	}
+
+	expected := []string{EventURLDeleted, EventURLRestored, EventURLDeleted, EventURLPurged}
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("Events = %v, expected %v", events, expected)
+	}
+}
+
+func TestEraseClicks(t *testing.T) {
+	ip, anonymized, other := "192.0.2.77", "192.0.2.0", "198.51.100.0"
+	ua, otherUA := "Mozilla/5.0 (subject)", "Mozilla/5.0 (someone else)"
+
+	tests := []struct {
+		name        string
+		anonymize   bool
+		req         ErasureRequest
+		expectedErr error
+		expectedIPs []string
+		erased      int64
+		prefix      string
+	}{
+		{name: "IP alone needs a prefix match", anonymize: true, req: ErasureRequest{IP: ip}, expectedErr: ErrInvalidRequest},
+		{name: "IP matches its anonymized prefix", anonymize: true, req: ErasureRequest{IP: ip, MatchPrefix: true}, expectedIPs: []string{ip, anonymized}, erased: 2, prefix: anonymized},
+		{name: "IP and user agent", anonymize: true, req: ErasureRequest{IP: ip, UserAgent: ua}, expectedIPs: []string{ip, anonymized}, erased: 1, prefix: anonymized},
+		{name: "raw IP only without anonymization", req: ErasureRequest{IP: ip}, expectedIPs: []string{ip}, erased: 0},
+		{name: "user agent only", anonymize: true, req: ErasureRequest{UserAgent: ua}, erased: 2},
+		{name: "empty request", req: ErasureRequest{IP: "  "}, expectedErr: ErrInvalidRequest},
+		{name: "invalid IP", req: ErasureRequest{IP: "not-an-ip"}, expectedErr: ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRepository()
+			repo.clickEvents = []models.ClickEvent{
+				{ID: 1, IP: &anonymized, UserAgent: &ua},
+				{ID: 2, IP: &anonymized, UserAgent: &otherUA},
+				{ID: 3, IP: &other, UserAgent: &ua},
+			}
+			config := DefaultConfig()
+			config.AnonymizeIPs = tt.anonymize
+			svc := NewService(repo, config)
+
+			result, err := svc.EraseClicks(context.Background(), &tt.req)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("EraseClicks() error = %v, expected %v", err, tt.expectedErr)
+				}
+				if len(repo.erasureQueries) != 0 {
+					t.Error("Invalid request reached the repository")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("EraseClicks() unexpected error: %v", err)
+			}
+
+			if result.ClicksErased != tt.erased || result.MatchedPrefix != tt.prefix {
+				t.Errorf("EraseClicks() = %+v, expected %d clicks erased and prefix %q", result, tt.erased, tt.prefix)
+			}
+			if got := repo.erasureQueries[0].IPs; strings.Join(got, ",") != strings.Join(tt.expectedIPs, ",") {
+				t.Errorf("Erased IPs = %v, expected %v", got, tt.expectedIPs)
+			}
+		})
+	}
+}
+
+func TestDeletionHandlers(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"restore live link", "POST", "/api/urls/testcampaign/restore", "", http.StatusConflict},
+		{"invalid permanent flag", "DELETE", "/api/urls/testcampaign?permanent=maybe", "", http.StatusBadRequest},
+		{"permanent delete", "DELETE", "/api/urls/testcampaign?permanent=true", "", http.StatusOK},
+		{"redirect deleted link", "GET", "/testcampaign", "", http.StatusGone},
+		{"restore deleted link", "POST", "/api/urls/testcampaign/restore", "", http.StatusOK},
+		{"redirect restored link", "GET", "/testcampaign", "", http.StatusFound},
+		{"restore unknown link", "POST", "/api/urls/nonexistent/restore", "", http.StatusNotFound},
+		{"erasure", "POST", "/api/privacy/erasure", `{"ip": "192.0.2.1", "match_prefix": true}`, http.StatusOK},
+		{"erasure of an anonymized IP alone", "POST", "/api/privacy/erasure", `{"ip": "192.0.2.1"}`, http.StatusBadRequest},
+		{"empty erasure", "POST", "/api/privacy/erasure", `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d: %s", tt.name, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package shortener
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types emitted by the service
+const (
+	EventURLExpired     = "url.expired"
+	EventURLDeleted     = "url.deleted"
+	EventURLRestored    = "url.restored"
+	EventURLPurged      = "url.purged"
+	EventURLQuarantined = "url.quarantined"
+	EventURLReleased    = "url.released"
+)
+
+// Event describes a change to a link made by the service
+type Event struct {
+	Type      string    `json:"type"`
+	URLID     int64     `json:"-"`
+	ShortCode string    `json:"short_code"`
+	At        time.Time `json:"at"`
+}
+
+// EventHandler receives service events. Handlers run synchronously on the
+// emitting goroutine, so they must not block.
+type EventHandler func(Event)
+
+// eventBus fans events out to registered handlers
+type eventBus struct {
+	mu       sync.RWMutex
+	handlers []EventHandler
+}
+
+// subscribe registers a handler for every future event
+func (b *eventBus) subscribe(handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// emit logs an event and passes it to every handler
+func (b *eventBus) emit(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	log.Printf("[SHORTENER] EVENT: %s %s", event.Type, event.ShortCode)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers {
+		handler(event)
+	}
+}
+
+// OnEvent registers a handler for service events
+func (s *service) OnEvent(handler EventHandler) {
+	s.events.subscribe(handler)
+}
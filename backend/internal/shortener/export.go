@@ -0,0 +1,286 @@
+package shortener
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Click export formats
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatParquet = "parquet"
+)
+
+const (
+	// Rows read from the database per keyset page
+	exportPageSize = 1000
+
+	// Parquet rows buffered before a row group is written out
+	parquetRowGroupSize = 64 * exportPageSize
+)
+
+// clickCSVHeader lists the CSV columns, named like the JSON fields
+var clickCSVHeader = []string{
+	"id", "url_id", "occurred_at", "ip", "user_agent", "referrer",
+	"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "query_params",
+	"country", "region", "city", "browser", "browser_version", "os", "device_type", "is_bot",
+	"revision",
+}
+
+// ClickExport streams the raw click events selected by an ExportRequest
+type ClickExport struct {
+	Format   string
+	Filename string
+
+	repo      database.URLRepository
+	query     database.ClickExportQuery
+	anonymize func(ip string) string // Applied to every exported IP when set
+}
+
+// ExportClicks validates an export request and prepares a stream of the
+// matching click events, for one link or all links
+func (s *service) ExportClicks(ctx context.Context, req *ExportRequest) (*ClickExport, error) {
+	if req == nil {
+		req = &ExportRequest{}
+	}
+
+	format := strings.ToLower(req.Format)
+	switch format {
+	case "":
+		format = ExportFormatCSV
+	case ExportFormatCSV, ExportFormatNDJSON, ExportFormatParquet:
+	default:
+		return nil, fmt.Errorf("%w: unsupported export format %q", ErrInvalidRequest, req.Format)
+	}
+
+	to := req.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !req.From.IsZero() && !req.From.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+
+	export := &ClickExport{
+		Format:   format,
+		Filename: "clicks." + format,
+		repo:     s.repo,
+		query: database.ClickExportQuery{
+			From:        req.From,
+			To:          to,
+			IncludeBots: req.IncludeBots,
+			Limit:       exportPageSize,
+		},
+	}
+
+	if req.ShortCode != "" {
+		url, err := s.repo.GetURLByShortCode(ctx, req.ShortCode)
+		if err != nil {
+			return nil, ErrURLNotFound
+		}
+		export.query.URLID = url.ID
+		export.Filename = req.ShortCode + "-clicks." + format
+	}
+
+	// IPs are anonymized at ingest; re-applying it covers clicks recorded
+	// before anonymization was enabled
+	if s.config.AnonymizeIPs {
+		export.anonymize = s.anonymizeIP
+	}
+
+	log.Printf("[SHORTENER] Exporting clicks as %s (link: %q, %s to %s)",
+		format, req.ShortCode, req.From.Format(time.RFC3339), to.Format(time.RFC3339))
+	return export, nil
+}
+
+// ContentType returns the MIME type of the export format
+func (e *ClickExport) ContentType() string {
+	switch e.Format {
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	case ExportFormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Stream writes every selected click event to w, one keyset page at a time,
+// flushing w after each page when it supports it. It returns the number of
+// rows written.
+func (e *ClickExport) Stream(ctx context.Context, w io.Writer) (int64, error) {
+	enc, err := newClickEncoder(e.Format, w)
+	if err != nil {
+		return 0, err
+	}
+	flusher, _ := w.(http.Flusher)
+
+	query := e.query
+	var written int64
+	for {
+		clicks, err := e.repo.GetClickEventsPage(ctx, &query)
+		if err != nil {
+			return written, err
+		}
+
+		if e.anonymize != nil {
+			for i := range clicks {
+				if clicks[i].IP != nil {
+					ip := e.anonymize(*clicks[i].IP)
+					clicks[i].IP = &ip
+				}
+			}
+		}
+
+		if err := enc.Encode(clicks); err != nil {
+			return written, fmt.Errorf("failed to encode clicks: %w", err)
+		}
+		written += int64(len(clicks))
+
+		if len(clicks) < query.Limit {
+			break
+		}
+
+		last := clicks[len(clicks)-1]
+		query.AfterTime, query.AfterID = last.OccurredAt, last.ID
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	if err := enc.Close(); err != nil {
+		return written, fmt.Errorf("failed to finish export: %w", err)
+	}
+	return written, nil
+}
+
+// clickEncoder writes click events in one export format
+type clickEncoder interface {
+	// Encode writes a page of clicks, pushing complete rows to the
+	// underlying writer where the format allows it
+	Encode(clicks []models.ClickEvent) error
+	// Close writes any buffered rows and trailing metadata
+	Close() error
+}
+
+// newClickEncoder creates an encoder for format writing to w
+func newClickEncoder(format string, w io.Writer) (clickEncoder, error) {
+	switch format {
+	case ExportFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(clickCSVHeader); err != nil {
+			return nil, err
+		}
+		return &csvClickEncoder{writer: writer}, nil
+	case ExportFormatNDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonClickEncoder{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case ExportFormatParquet:
+		writer := parquet.NewGenericWriter[models.ClickEvent](w,
+			parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+			parquet.Compression(&parquet.Snappy),
+		)
+		return &parquetClickEncoder{writer: writer}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported export format %q", ErrInvalidRequest, format)
+	}
+}
+
+// csvClickEncoder writes one CSV record per click
+type csvClickEncoder struct {
+	writer *csv.Writer
+}
+
+func (e *csvClickEncoder) Encode(clicks []models.ClickEvent) error {
+	for i := range clicks {
+		c := &clicks[i]
+		record := []string{
+			strconv.FormatInt(c.ID, 10),
+			strconv.FormatInt(c.URLID, 10),
+			c.OccurredAt.UTC().Format(time.RFC3339Nano),
+			stringValue(c.IP), stringValue(c.UserAgent), stringValue(c.Referrer),
+			stringValue(c.UTMSource), stringValue(c.UTMMedium), stringValue(c.UTMCampaign),
+			stringValue(c.UTMTerm), stringValue(c.UTMContent), stringValue(c.QueryParams),
+			stringValue(c.Country), stringValue(c.Region), stringValue(c.City),
+			stringValue(c.Browser), stringValue(c.BrowserVersion), stringValue(c.OS), stringValue(c.DeviceType),
+			strconv.FormatBool(c.IsBot),
+			intValue(c.Revision),
+		}
+		if err := e.writer.Write(record); err != nil {
+			return err
+		}
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvClickEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// ndjsonClickEncoder writes one JSON object per line
+type ndjsonClickEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonClickEncoder) Encode(clicks []models.ClickEvent) error {
+	for i := range clicks {
+		if err := e.enc.Encode(&clicks[i]); err != nil {
+			return err
+		}
+	}
+	return e.buf.Flush()
+}
+
+func (e *ndjsonClickEncoder) Close() error {
+	return e.buf.Flush()
+}
+
+// parquetClickEncoder buffers clicks into row groups; the file footer is
+// written on Close, so a Parquet export is only readable once complete
+type parquetClickEncoder struct {
+	writer *parquet.GenericWriter[models.ClickEvent]
+}
+
+func (e *parquetClickEncoder) Encode(clicks []models.ClickEvent) error {
+	_, err := e.writer.Write(clicks)
+	return err
+}
+
+func (e *parquetClickEncoder) Close() error {
+	return e.writer.Close()
+}
+
+// stringValue dereferences an optional string, mapping nil to ""
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// intValue formats an optional int, mapping nil to ""
+func intValue(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
@@ -0,0 +1,179 @@
+package shortener
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// setupExportService creates a service with one link and n clicks on it, one
+// second apart, every tenth of them from a bot
+func setupExportService(t *testing.T, n int) (Service, *MockRepository) {
+	t.Helper()
+
+	repo := NewMockRepository()
+	config := DefaultConfig()
+	config.MaintenanceInterval = 0
+	svc := NewService(repo, config)
+
+	url, err := svc.CreateShortURL(context.Background(), &CreateURLRequest{URL: "https://example.com", CustomCode: "export"})
+	if err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		ip := fmt.Sprintf("203.0.113.%d", i%250+1)
+		browser := "Firefox"
+		repo.clickEvents = append(repo.clickEvents, models.ClickEvent{
+			ID:         int64(i + 1),
+			URLID:      url.ID,
+			OccurredAt: start.Add(time.Duration(i) * time.Second),
+			IP:         &ip,
+			Browser:    &browser,
+			IsBot:      i%10 == 0,
+		})
+	}
+
+	return svc, repo
+}
+
+func TestExportClicks_CSV(t *testing.T) {
+	svc, _ := setupExportService(t, 2500)
+
+	export, err := svc.ExportClicks(context.Background(), &ExportRequest{ShortCode: "export", IncludeBots: true})
+	if err != nil {
+		t.Fatalf("ExportClicks() unexpected error: %v", err)
+	}
+	if export.Filename != "export-clicks.csv" {
+		t.Errorf("Filename = %s, expected export-clicks.csv", export.Filename)
+	}
+
+	var buf bytes.Buffer
+	rows, err := export.Stream(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("Stream() unexpected error: %v", err)
+	}
+	if rows != 2500 {
+		t.Errorf("Stream() wrote %d rows, expected 2500", rows)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Exported CSV is invalid: %v", err)
+	}
+	if len(records) != 2501 {
+		t.Fatalf("CSV has %d records, expected header + 2500", len(records))
+	}
+	if records[0][0] != "id" || records[0][len(records[0])-1] != "revision" {
+		t.Errorf("CSV header = %v", records[0])
+	}
+
+	// Pages must follow each other without gaps or repeats
+	for i, record := range records[1:] {
+		if record[0] != fmt.Sprint(i+1) {
+			t.Fatalf("Row %d has id %s, expected %d", i, record[0], i+1)
+		}
+	}
+
+	// IPs are anonymized on export
+	if ip := records[2][3]; ip != "203.0.113.0" {
+		t.Errorf("Exported IP = %s, expected 203.0.113.0", ip)
+	}
+}
+
+func TestExportClicks_NDJSON(t *testing.T) {
+	svc, _ := setupExportService(t, 1500)
+
+	export, err := svc.ExportClicks(context.Background(), &ExportRequest{Format: "ndjson"})
+	if err != nil {
+		t.Fatalf("ExportClicks() unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := export.Stream(context.Background(), &buf); err != nil {
+		t.Fatalf("Stream() unexpected error: %v", err)
+	}
+
+	var lines int
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var click models.ClickEvent
+		if err := json.Unmarshal(scanner.Bytes(), &click); err != nil {
+			t.Fatalf("Line %d is not a click event: %v", lines+1, err)
+		}
+		if click.IsBot {
+			t.Errorf("Line %d is a bot click, expected bots to be excluded", lines+1)
+		}
+		lines++
+	}
+	if lines != 1350 {
+		t.Errorf("NDJSON has %d lines, expected 1350 human clicks", lines)
+	}
+}
+
+func TestExportClicks_Parquet(t *testing.T) {
+	svc, _ := setupExportService(t, 1200)
+
+	export, err := svc.ExportClicks(context.Background(), &ExportRequest{Format: "parquet", IncludeBots: true})
+	if err != nil {
+		t.Fatalf("ExportClicks() unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := export.Stream(context.Background(), &buf); err != nil {
+		t.Fatalf("Stream() unexpected error: %v", err)
+	}
+
+	clicks, err := parquet.Read[models.ClickEvent](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Exported Parquet is unreadable: %v", err)
+	}
+	if len(clicks) != 1200 {
+		t.Fatalf("Parquet has %d rows, expected 1200", len(clicks))
+	}
+
+	last := clicks[len(clicks)-1]
+	expected := time.Date(2025, 9, 1, 0, 19, 59, 0, time.UTC)
+	if last.ID != 1200 || !last.OccurredAt.Equal(expected) {
+		t.Errorf("Last row = id %d at %v, expected id 1200 at %v", last.ID, last.OccurredAt, expected)
+	}
+	if last.Browser == nil || *last.Browser != "Firefox" || last.Country != nil {
+		t.Errorf("Last row optional fields = browser %v, country %v", last.Browser, last.Country)
+	}
+}
+
+func TestExportClicks_Errors(t *testing.T) {
+	svc, _ := setupExportService(t, 0)
+
+	tests := []struct {
+		name     string
+		req      *ExportRequest
+		expected error
+	}{
+		{"unknown link", &ExportRequest{ShortCode: "missing"}, ErrURLNotFound},
+		{"unknown format", &ExportRequest{Format: "xlsx"}, ErrInvalidRequest},
+		{"inverted range", &ExportRequest{
+			From: time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+		}, ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ExportClicks(context.Background(), tt.req); !errors.Is(err, tt.expected) {
+				t.Errorf("ExportClicks() error = %v, expected %v", err, tt.expected)
+			}
+		})
+	}
+}
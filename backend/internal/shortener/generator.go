@@ -1,15 +1,16 @@
 package shortener
 
 import (
+	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"math/big"
 )
 
 const (
 	// Base62 character set: a-z, A-Z, 0-9
 	base62Chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	base62Base  = int64(len(base62Chars))
 
 	// Default short code length (7 chars = ~41 bits entropy with base62)
 	// 62^7 = 3,521,614,606,208 possible combinations
@@ -25,15 +26,28 @@ var (
 	ErrRandomGeneration  = errors.New("failed to generate cryptographically secure random number")
 )
 
-// Generator handles short code generation using CSPRNG and Base62 encoding
+// CodeStrategy chooses the short codes of new links
+type CodeStrategy interface {
+	// NextCode returns a code for a new link
+	NextCode(ctx context.Context) (string, error)
+
+	// Unique reports whether codes never repeat, so they need no availability
+	// lookup. Inserts still reject codes taken by custom or reserved codes.
+	Unique() bool
+}
+
+// Generator handles short code generation using CSPRNG and Base62 encoding,
+// or the encoding of another alphabet
 type Generator struct {
 	codeLength int
+	alphabet   *Alphabet
 }
 
 // NewGenerator creates a new generator with default settings
 func NewGenerator() *Generator {
 	return &Generator{
 		codeLength: defaultCodeLength,
+		alphabet:   AlphabetBase62,
 	}
 }
 
@@ -44,25 +58,54 @@ func NewGeneratorWithLength(length int) (*Generator, error) {
 	}
 	return &Generator{
 		codeLength: length,
+		alphabet:   AlphabetBase62,
 	}, nil
 }
 
+// NewGeneratorWithAlphabet creates a new generator of codes of the given
+// alphabet and length
+func NewGeneratorWithAlphabet(alphabet *Alphabet, length int) (*Generator, error) {
+	if alphabet.words {
+		return nil, fmt.Errorf("%s codes are not generated from characters", alphabet.Name)
+	}
+	generator, err := NewGeneratorWithLength(length)
+	if err != nil {
+		return nil, err
+	}
+	generator.alphabet = alphabet
+	return generator, nil
+}
+
 // Generate creates a cryptographically secure random short code
 // Uses crypto/rand for entropy and Base62 encoding for URL-safe output
 func (g *Generator) Generate() (string, error) {
 	// Calculate the maximum value for our code length
 	// This ensures uniform distribution across the keyspace
-	maxValue := new(big.Int)
-	maxValue.Exp(big.NewInt(base62Base), big.NewInt(int64(g.codeLength)), nil)
+	maxValue := g.GetKeyspaceSize()
 
-	// Generate cryptographically secure random number
-	randomValue, err := rand.Int(rand.Reader, maxValue)
-	if err != nil {
-		return "", ErrRandomGeneration
+	for {
+		// Generate cryptographically secure random number
+		randomValue, err := rand.Int(rand.Reader, maxValue)
+		if err != nil {
+			return "", ErrRandomGeneration
+		}
+
+		// Convert to Base62, drawing again for codes spelling offensive words
+		code := g.toBase62(randomValue)
+		if !IsProfane(code) {
+			return code, nil
+		}
 	}
+}
+
+// NextCode returns a random code, implementing CodeStrategy
+func (g *Generator) NextCode(ctx context.Context) (string, error) {
+	return g.Generate()
+}
 
-	// Convert to Base62
-	return g.toBase62(randomValue), nil
+// Unique reports false: random codes can collide and are looked up before use
+func (g *Generator) Unique() bool {
+	return false
 }
 
 // GenerateBatch creates multiple unique short codes in one call
@@ -91,20 +134,23 @@ func (g *Generator) GenerateBatch(count int) ([]string, error) {
 	return codes, nil
 }
 
-// toBase62 converts a big integer to Base62 string representation
+// toBase62 converts a big integer to Base62 string representation, or to the
+// digits of the generator's alphabet
 func (g *Generator) toBase62(value *big.Int) string {
+	chars := g.alphabet.Chars
+
 	if value.Sign() == 0 {
 		// Handle zero case - pad to required length
-		result := string(base62Chars[0])
+		result := string(chars[0])
 		for len(result) < g.codeLength {
-			result = string(base62Chars[0]) + result
+			result = string(chars[0]) + result
 		}
 		return result
 	}
 
 	// Convert to base62
 	result := ""
-	base := big.NewInt(base62Base)
+	base := big.NewInt(g.alphabet.base())
 	zero := big.NewInt(0)
 	remainder := &big.Int{}
 
@@ -113,18 +159,19 @@ func (g *Generator) toBase62(value *big.Int) string {
 
 	for num.Cmp(zero) > 0 {
 		num.DivMod(num, base, remainder)
-		result = string(base62Chars[remainder.Int64()]) + result
+		result = string(chars[remainder.Int64()]) + result
 	}
 
 	// Pad with leading characters if necessary to maintain consistent length
 	for len(result) < g.codeLength {
-		result = string(base62Chars[0]) + result
+		result = string(chars[0]) + result
 	}
 
 	return result
 }
 
-// IsValidCode checks if a string is a valid Base62 code
+// IsValidCode checks if a string is a valid Base62 code; see
+// Alphabet.IsValidCode for other alphabets
 func IsValidCode(code string) bool {
 	if len(code) < minCodeLength || len(code) > maxCodeLength {
 		return false
@@ -153,5 +200,5 @@ func (g *Generator) GetCodeLength() int {
 // GetKeyspaceSize returns the total number of possible codes for current length
 func (g *Generator) GetKeyspaceSize() *big.Int {
 	result := new(big.Int)
-	return result.Exp(big.NewInt(base62Base), big.NewInt(int64(g.codeLength)), nil)
+	return result.Exp(big.NewInt(g.alphabet.base()), big.NewInt(int64(g.codeLength)), nil)
 }
\ No newline at end of file
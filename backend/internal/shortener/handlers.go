@@ -1,13 +1,21 @@
 package shortener
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"backend/internal/models"
+	"backend/internal/useragent"
+
 	"github.com/go-chi/chi/v5"
 )
 
@@ -95,6 +103,8 @@ func (h *Handler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case strings.Contains(err.Error(), "invalid URL"):
 			statusCode = http.StatusBadRequest
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
 		case strings.Contains(err.Error(), "custom code"):
 			statusCode = http.StatusConflict
 		case strings.Contains(err.Error(), "reserved"):
@@ -127,9 +137,9 @@ func (h *Handler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		statusCode := http.StatusNotFound
 		
 		switch err {
-		case ErrURLExpired:
+		case ErrURLExpired, ErrURLDeleted:
 			statusCode = http.StatusGone
-		case ErrURLInactive:
+		case ErrURLInactive, ErrURLQuarantined:
 			statusCode = http.StatusForbidden
 		}
 		
@@ -137,6 +147,16 @@ func (h *Handler) RedirectURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// Unfurlers get a page with the link's own preview when it has overrides
+	if useragent.IsUnfurler(r.UserAgent()) {
+		preview, err := h.service.GetURLPreview(r.Context(), url.ShortCode)
+		if err == nil && !preview.Override.IsEmpty() {
+			log.Printf("[HANDLER] SUCCESS: Serving preview of %s to %s", shortCode, r.UserAgent())
+			writePreviewPage(w, url, preview.Effective)
+			return
+		}
+	}
+	
 	log.Printf("[HANDLER] SUCCESS: Redirecting %s -> %s", shortCode, url.TargetURL)
 	
 	// Perform redirect
@@ -190,6 +210,8 @@ func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
 		
 		if err == ErrURLNotFound {
 			statusCode = http.StatusNotFound
+		} else if err == ErrURLDeleted {
+			statusCode = http.StatusConflict
 		} else if strings.Contains(err.Error(), "invalid") {
 			statusCode = http.StatusBadRequest
 		}
@@ -202,7 +224,9 @@ func (h *Handler) UpdateURL(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, url, "URL updated successfully")
 }
 
-// DeleteURL handles DELETE /api/urls/{shortCode}
+// DeleteURL handles DELETE /api/urls/{shortCode}. By default the link is
+// only deactivated; with ?permanent=true it is deleted, restorable until its
+// grace period ends.
 func (h *Handler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	shortCode := chi.URLParam(r, "shortCode")
 	log.Printf("[HANDLER] DeleteURL request for: %s", shortCode)
@@ -212,6 +236,18 @@ func (h *Handler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	if permanentParam := r.URL.Query().Get("permanent"); permanentParam != "" {
+		permanent, err := strconv.ParseBool(permanentParam)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Invalid permanent parameter")
+			return
+		}
+		if permanent {
+			h.deleteURLPermanently(w, r, shortCode)
+			return
+		}
+	}
+	
 	err := h.service.DeactivateURL(r.Context(), shortCode)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
@@ -227,6 +263,411 @@ func (h *Handler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	writeSuccess(w, nil, "URL deleted successfully")
 }
 
+// deleteURLPermanently schedules a link for permanent deletion
+func (h *Handler) deleteURLPermanently(w http.ResponseWriter, r *http.Request, shortCode string) {
+	deletion, err := h.service.DeleteURL(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err == ErrURLNotFound {
+			statusCode = http.StatusNotFound
+		}
+		
+		writeError(w, statusCode, err, "Failed to delete URL")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Scheduled %s for permanent deletion", shortCode)
+	writeSuccess(w, deletion, "URL deleted, restorable until purge_after")
+}
+
+// RestoreURL handles POST /api/urls/{shortCode}/restore
+func (h *Handler) RestoreURL(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] RestoreURL request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	url, err := h.service.RestoreURL(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err {
+		case ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case ErrURLNotDeleted:
+			statusCode = http.StatusConflict
+		case ErrRestoreExpired:
+			statusCode = http.StatusGone
+		}
+		
+		writeError(w, statusCode, err, "Failed to restore URL")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Restored URL %s", shortCode)
+	writeSuccess(w, url, "URL restored successfully")
+}
+
+// GetURLRevisions handles GET /api/urls/{shortCode}/revisions
+func (h *Handler) GetURLRevisions(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] GetURLRevisions request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	revisions, err := h.service.GetURLRevisions(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err == ErrURLNotFound {
+			statusCode = http.StatusNotFound
+		}
+		
+		writeError(w, statusCode, err, "Failed to get revisions")
+		return
+	}
+	
+	writeSuccess(w, revisions, "Revisions retrieved successfully")
+}
+
+// RestoreRevision handles POST /api/urls/{shortCode}/revisions/{revision}/restore
+func (h *Handler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] RestoreRevision request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+	if err != nil || revision < 1 {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Revision must be a positive integer")
+		return
+	}
+	
+	url, err := h.service.RestoreRevision(r.Context(), shortCode, revision)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err == ErrURLNotFound, err == ErrRevisionNotFound:
+			statusCode = http.StatusNotFound
+		case err == ErrURLDeleted:
+			statusCode = http.StatusConflict
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
+		}
+		
+		writeError(w, statusCode, err, "Failed to restore revision")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Restored %s to revision %d as revision %d", shortCode, revision, url.Revision)
+	writeSuccess(w, url, "Revision restored successfully")
+}
+
+// GetQuarantinedURLs handles GET /api/admin/quarantine
+func (h *Handler) GetQuarantinedURLs(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetQuarantinedURLs request")
+	
+	limit := 0
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit < 0 {
+			writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = parsedLimit
+	}
+	
+	urls, err := h.service.GetQuarantinedURLs(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err, "Failed to retrieve quarantined URLs")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Retrieved %d quarantined URLs", len(urls))
+	writeSuccess(w, urls, "Quarantined URLs retrieved successfully")
+}
+
+// ReleaseURL handles POST /api/admin/quarantine/{shortCode}/release
+func (h *Handler) ReleaseURL(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] ReleaseURL request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	url, err := h.service.ReleaseURL(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err {
+		case ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case ErrURLNotQuarantined:
+			statusCode = http.StatusConflict
+		}
+		
+		writeError(w, statusCode, err, "Failed to release URL")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Released URL %s from quarantine", shortCode)
+	writeSuccess(w, url, "URL released from quarantine")
+}
+
+// ProbeURL handles POST /api/urls/{shortCode}/probe, following the redirect
+// chain of the link's target now
+func (h *Handler) ProbeURL(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] ProbeURL request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	result, err := h.service.ProbeURL(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err {
+		case ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case ErrURLDeleted:
+			statusCode = http.StatusConflict
+		}
+		
+		writeError(w, statusCode, err, "Failed to probe URL")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Probed URL %s: %s", shortCode, result.Status)
+	writeSuccess(w, result, "URL probed successfully")
+}
+
+// GetBrokenLinks handles GET /api/admin/broken-links
+func (h *Handler) GetBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetBrokenLinks request")
+	
+	limit := 0
+	if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit < 0 {
+			writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = parsedLimit
+	}
+	
+	urls, err := h.service.GetBrokenLinks(r.Context(), limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err, "Failed to retrieve broken links")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Retrieved %d broken links", len(urls))
+	writeSuccess(w, urls, "Broken links retrieved successfully")
+}
+
+// GetCodeStats handles GET /api/admin/codes
+func (h *Handler) GetCodeStats(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetCodeStats request")
+	
+	stats := h.service.GetCodeStats()
+	
+	log.Printf("[HANDLER] SUCCESS: Codes are %d characters", stats.Length)
+	writeSuccess(w, stats, "Code statistics retrieved successfully")
+}
+
+// GetReservedCodes handles GET /api/admin/reserved-codes
+func (h *Handler) GetReservedCodes(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetReservedCodes request")
+	
+	reserved, err := h.service.GetReservedCodes(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err, "Failed to retrieve reserved codes")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Retrieved %d reserved codes", len(reserved))
+	writeSuccess(w, reserved, "Reserved codes retrieved successfully")
+}
+
+// ReserveCode handles POST /api/admin/reserved-codes
+func (h *Handler) ReserveCode(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] ReserveCode request")
+	
+	var req ReserveCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid JSON payload")
+		return
+	}
+	
+	reserved, err := h.service.ReserveCode(r.Context(), &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
+		case errors.Is(err, ErrAlreadyReserved), errors.Is(err, ErrCustomCodeTaken):
+			statusCode = http.StatusConflict
+		}
+		
+		writeError(w, statusCode, err, "Failed to reserve code")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Reserved %s code %s", reserved.Kind, reserved.Code)
+	writeSuccess(w, reserved, "Code reserved successfully")
+}
+
+// UnreserveCode handles DELETE /api/admin/reserved-codes/{code}
+func (h *Handler) UnreserveCode(w http.ResponseWriter, r *http.Request) {
+	// Patterns may contain characters that are escaped in paths
+	code, err := neturl.PathUnescape(chi.URLParam(r, "code"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Invalid code")
+		return
+	}
+	log.Printf("[HANDLER] UnreserveCode request for: %s", code)
+	
+	if err := h.service.UnreserveCode(r.Context(), code); err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, ErrNotReserved):
+			statusCode = http.StatusNotFound
+		case errors.Is(err, ErrBuiltinReserved):
+			statusCode = http.StatusConflict
+		}
+		
+		writeError(w, statusCode, err, "Failed to remove reserved code")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Removed reserved code %s", code)
+	writeSuccess(w, nil, "Reserved code removed successfully")
+}
+
+// ReserveRouteCodes reserves the first path segment of every route, so no
+// link is shadowed by a route or shadows one
+func (h *Handler) ReserveRouteCodes(routes chi.Routes) error {
+	seen := make(map[string]bool)
+	var codes []string
+	
+	err := chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+		if segment == "" || segment == "*" || strings.HasPrefix(segment, "{") || seen[segment] {
+			return nil
+		}
+		seen[segment] = true
+		codes = append(codes, segment)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk routes: %w", err)
+	}
+	
+	h.service.ReserveRoutes(codes)
+	return nil
+}
+
+// GetURLPreview handles GET /api/urls/{shortCode}/preview
+func (h *Handler) GetURLPreview(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] GetURLPreview request for: %s", shortCode)
+	
+	preview, err := h.service.GetURLPreview(r.Context(), shortCode)
+	if err != nil {
+		writePreviewError(w, err, "Failed to retrieve preview")
+		return
+	}
+	
+	writeSuccess(w, preview, "Preview retrieved successfully")
+}
+
+// SetPreviewOverride handles PUT /api/urls/{shortCode}/preview
+func (h *Handler) SetPreviewOverride(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] SetPreviewOverride request for: %s", shortCode)
+	
+	var req PreviewOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid JSON payload")
+		return
+	}
+	
+	preview, err := h.service.SetPreviewOverride(r.Context(), shortCode, &req)
+	if err != nil {
+		writePreviewError(w, err, "Failed to set preview")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Set preview override of %s", shortCode)
+	writeSuccess(w, preview, "Preview updated successfully")
+}
+
+// RefreshPreview handles POST /api/urls/{shortCode}/preview/refresh
+func (h *Handler) RefreshPreview(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] RefreshPreview request for: %s", shortCode)
+	
+	preview, err := h.service.RefreshPreview(r.Context(), shortCode)
+	if err != nil {
+		writePreviewError(w, err, "Failed to refresh preview")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Refreshed preview of %s", shortCode)
+	writeSuccess(w, preview, "Preview refreshed successfully")
+}
+
+// writePreviewError maps preview errors to status codes
+func writePreviewError(w http.ResponseWriter, err error, message string) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case err == ErrURLNotFound:
+		statusCode = http.StatusNotFound
+	case err == ErrURLDeleted:
+		statusCode = http.StatusConflict
+	case errors.Is(err, ErrInvalidRequest):
+		statusCode = http.StatusBadRequest
+	}
+	
+	writeError(w, statusCode, err, message)
+}
+
+// EraseClicks handles POST /api/privacy/erasure, deleting the clicks of one
+// data subject across all links
+func (h *Handler) EraseClicks(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] EraseClicks request from %s", r.RemoteAddr)
+	
+	var req ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid JSON payload")
+		return
+	}
+	
+	result, err := h.service.EraseClicks(r.Context(), &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidRequest) {
+			statusCode = http.StatusBadRequest
+		}
+		
+		writeError(w, statusCode, err, "Failed to erase clicks")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Erased %d clicks", result.ClicksErased)
+	writeSuccess(w, result, "Clicks erased successfully")
+}
+
 // GetAnalytics handles GET /api/urls/{shortCode}/analytics
 func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	shortCode := chi.URLParam(r, "shortCode")
@@ -245,21 +686,494 @@ func (h *Handler) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	
-	analytics, err := h.service.GetAnalytics(r.Context(), shortCode, days)
+	// Bots are excluded unless explicitly requested
+	includeBots := false
+	if botsParam := r.URL.Query().Get("include_bots"); botsParam != "" {
+		if parsed, err := strconv.ParseBool(botsParam); err == nil {
+			includeBots = parsed
+		}
+	}
+	
+	req := &AnalyticsRequest{
+		Days:        days,
+		Granularity: r.URL.Query().Get("granularity"),
+		Timezone:    r.URL.Query().Get("tz"),
+		IncludeBots: includeBots,
+	}
+	
+	// Bare dates in from/to are midnights in the requested timezone
+	loc := time.UTC
+	if req.Timezone != "" {
+		parsedLoc, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err, "Invalid timezone")
+			return
+		}
+		loc = parsedLoc
+	}
+	
+	var err error
+	if req.From, err = parseTimeParam(r.URL.Query().Get("from"), false, loc); err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid from parameter")
+		return
+	}
+	if req.To, err = parseTimeParam(r.URL.Query().Get("to"), true, loc); err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid to parameter")
+		return
+	}
+	
+	analytics, err := h.service.GetAnalytics(r.Context(), shortCode, req)
 	if err != nil {
 		statusCode := http.StatusInternalServerError
-		if err == ErrURLNotFound {
+		switch {
+		case err == ErrURLNotFound:
 			statusCode = http.StatusNotFound
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
 		}
 		
 		writeError(w, statusCode, err, "Failed to retrieve analytics")
 		return
 	}
 	
-	log.Printf("[HANDLER] SUCCESS: Retrieved analytics for %s (%d days)", shortCode, days)
+	log.Printf("[HANDLER] SUCCESS: Retrieved analytics for %s (%s to %s)", shortCode,
+		analytics.PeriodStart.Format(time.RFC3339), analytics.PeriodEnd.Format(time.RFC3339))
 	writeSuccess(w, analytics, "Analytics retrieved successfully")
 }
 
+// GetCampaigns handles GET /api/analytics/campaigns
+func (h *Handler) GetCampaigns(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetCampaigns request")
+	h.serveCampaigns(w, r, "")
+}
+
+// GetURLCampaigns handles GET /api/urls/{shortCode}/campaigns
+func (h *Handler) GetURLCampaigns(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] GetURLCampaigns request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	h.serveCampaigns(w, r, shortCode)
+}
+
+// serveCampaigns parses a campaign query and writes it as JSON or CSV
+func (h *Handler) serveCampaigns(w http.ResponseWriter, r *http.Request, shortCode string) {
+	req, err := parseCampaignRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid campaign query")
+		return
+	}
+	req.ShortCode = shortCode
+	
+	campaigns, err := h.service.GetCampaignStats(r.Context(), req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err == ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
+		}
+		
+		writeError(w, statusCode, err, "Failed to retrieve campaign analytics")
+		return
+	}
+	
+	if r.URL.Query().Get("format") == "csv" {
+		writeCampaignCSV(w, campaigns)
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Retrieved %d campaign rows", len(campaigns.Campaigns))
+	writeSuccess(w, campaigns, "Campaign analytics retrieved successfully")
+}
+
+// parseCampaignRequest reads a campaign query from URL parameters:
+// from, to, group_by (comma separated, "link" groups per short code),
+// utm_* drill-down filters, include_bots and limit
+func parseCampaignRequest(r *http.Request) (*CampaignRequest, error) {
+	params := r.URL.Query()
+	req := &CampaignRequest{Filters: make(map[string]string)}
+	
+	var err error
+	if req.From, err = parseTimeParam(params.Get("from"), false, time.UTC); err != nil {
+		return nil, fmt.Errorf("%w: from: %v", ErrInvalidRequest, err)
+	}
+	if req.To, err = parseTimeParam(params.Get("to"), true, time.UTC); err != nil {
+		return nil, fmt.Errorf("%w: to: %v", ErrInvalidRequest, err)
+	}
+	
+	if groupBy := params.Get("group_by"); groupBy != "" {
+		for _, dim := range strings.Split(groupBy, ",") {
+			dim = strings.TrimSpace(dim)
+			if dim == "link" {
+				req.GroupByLink = true
+				continue
+			}
+			req.GroupBy = append(req.GroupBy, dim)
+		}
+	}
+	
+	for _, dim := range models.UTMDimensions {
+		if value := params.Get("utm_" + dim); value != "" {
+			req.Filters[dim] = value
+		}
+	}
+	
+	if botsParam := params.Get("include_bots"); botsParam != "" {
+		if parsed, err := strconv.ParseBool(botsParam); err == nil {
+			req.IncludeBots = parsed
+		}
+	}
+	
+	if limitParam := params.Get("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			req.Limit = parsedLimit
+		}
+	}
+	
+	return req, nil
+}
+
+// ExportClicks handles GET /api/clicks/export
+func (h *Handler) ExportClicks(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] ExportClicks request")
+	h.serveClickExport(w, r, "")
+}
+
+// ExportURLClicks handles GET /api/urls/{shortCode}/clicks/export
+func (h *Handler) ExportURLClicks(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] ExportURLClicks request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	h.serveClickExport(w, r, shortCode)
+}
+
+// serveClickExport streams raw click events as a download. Errors after the
+// first byte can only be logged, since the status line is already sent.
+func (h *Handler) serveClickExport(w http.ResponseWriter, r *http.Request, shortCode string) {
+	req, err := parseExportRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid export query")
+		return
+	}
+	req.ShortCode = shortCode
+	
+	export, err := h.service.ExportClicks(r.Context(), req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err == ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
+		}
+		
+		writeError(w, statusCode, err, "Failed to export clicks")
+		return
+	}
+	
+	// Large exports outlive the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[HANDLER] WARNING: Could not lift write deadline for export: %v", err)
+	}
+	
+	w.Header().Set("Content-Type", export.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.Filename))
+	w.WriteHeader(http.StatusOK)
+	
+	rows, err := export.Stream(r.Context(), w)
+	if err != nil {
+		log.Printf("[HANDLER] ERROR: Click export aborted after %d rows: %v", rows, err)
+		return
+	}
+	log.Printf("[HANDLER] SUCCESS: Exported %d clicks as %s", rows, export.Format)
+}
+
+// LiveClicks handles GET /api/urls/{shortCode}/live, streaming clicks as
+// Server-Sent Events until the client disconnects or the service shuts down
+func (h *Handler) LiveClicks(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] LiveClicks request for: %s from %s", shortCode, r.RemoteAddr)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, ErrInvalidRequest, "Streaming is not supported")
+		return
+	}
+	
+	sub, err := h.service.SubscribeLive(r.Context(), shortCode)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err {
+		case ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case ErrShuttingDown:
+			statusCode = http.StatusServiceUnavailable
+		}
+		
+		writeError(w, statusCode, err, "Failed to subscribe to live clicks")
+		return
+	}
+	defer sub.Close()
+	
+	// The stream is open-ended, so the server's write timeout can't apply
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("[HANDLER] WARNING: Could not lift write deadline for live stream: %v", err)
+	}
+	
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+	
+	fmt.Fprintf(w, "retry: %d\n\n", liveListenRetry.Milliseconds())
+	flusher.Flush()
+	
+	heartbeat := time.NewTicker(liveHeartbeatInterval)
+	defer heartbeat.Stop()
+	
+	var sent, reportedDrops int64
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("[HANDLER] Live stream for %s closed by client after %d clicks", shortCode, sent)
+			return
+		case click, ok := <-sub.Events():
+			if !ok {
+				log.Printf("[HANDLER] Live stream for %s ended by shutdown after %d clicks", shortCode, sent)
+				return
+			}
+			
+			// Tell the client how many clicks it missed while it was too slow
+			if dropped := sub.Dropped(); dropped > reportedDrops {
+				if err := writeSSE(w, "dropped", "", map[string]int64{"dropped": dropped - reportedDrops}); err != nil {
+					return
+				}
+				reportedDrops = dropped
+			}
+			
+			sent++
+			if err := writeSSE(w, "click", strconv.FormatInt(sent, 10), click); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes one Server-Sent Event with a JSON data payload
+func writeSSE(w io.Writer, event, id string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// parseExportRequest reads an export query from URL parameters: from, to,
+// format and include_bots. Raw exports include bot clicks unless asked not to,
+// since every row carries is_bot.
+func parseExportRequest(r *http.Request) (*ExportRequest, error) {
+	params := r.URL.Query()
+	req := &ExportRequest{Format: params.Get("format"), IncludeBots: true}
+	
+	var err error
+	if req.From, err = parseTimeParam(params.Get("from"), false, time.UTC); err != nil {
+		return nil, fmt.Errorf("%w: from: %v", ErrInvalidRequest, err)
+	}
+	if req.To, err = parseTimeParam(params.Get("to"), true, time.UTC); err != nil {
+		return nil, fmt.Errorf("%w: to: %v", ErrInvalidRequest, err)
+	}
+	
+	if botsParam := params.Get("include_bots"); botsParam != "" {
+		if parsed, err := strconv.ParseBool(botsParam); err == nil {
+			req.IncludeBots = parsed
+		}
+	}
+	
+	return req, nil
+}
+
+// parseTimeParam parses an RFC 3339 timestamp or a YYYY-MM-DD date in loc.
+// A bare date used as an upper bound covers the whole day.
+func parseTimeParam(value string, endOfRange bool, loc *time.Location) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 timestamp or YYYY-MM-DD date, got %q", value)
+	}
+	if endOfRange {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// writeCampaignCSV writes campaign rows as a CSV download
+func writeCampaignCSV(w http.ResponseWriter, campaigns *CampaignResponse) {
+	filename := "campaigns.csv"
+	if campaigns.ShortCode != "" {
+		filename = campaigns.ShortCode + "-campaigns.csv"
+	}
+	
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	
+	var header []string
+	if campaigns.GroupByLink {
+		header = append(header, "short_code")
+	}
+	for _, dim := range campaigns.GroupBy {
+		header = append(header, "utm_"+dim)
+	}
+	header = append(header, "clicks", "first_click", "last_click")
+	
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	for i := range campaigns.Campaigns {
+		stat := &campaigns.Campaigns[i]
+		
+		var record []string
+		if campaigns.GroupByLink {
+			record = append(record, stat.ShortCode)
+		}
+		for _, dim := range campaigns.GroupBy {
+			record = append(record, stat.Dimension(dim))
+		}
+		record = append(record,
+			strconv.FormatInt(stat.Clicks, 10),
+			formatCSVTime(stat.FirstClick),
+			formatCSVTime(stat.LastClick),
+		)
+		writer.Write(record)
+	}
+	writer.Flush()
+	
+	if err := writer.Error(); err != nil {
+		log.Printf("[HANDLER] ERROR: Failed to write campaign CSV: %v", err)
+		return
+	}
+	log.Printf("[HANDLER] SUCCESS: Exported %d campaign rows as CSV", len(campaigns.Campaigns))
+}
+
+// formatCSVTime formats an optional time for CSV, empty if unset
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// GetAuditLog handles GET /api/audit
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HANDLER] GetAuditLog request")
+	h.serveAuditLog(w, r, "")
+}
+
+// GetURLHistory handles GET /api/urls/{shortCode}/history
+func (h *Handler) GetURLHistory(w http.ResponseWriter, r *http.Request) {
+	shortCode := chi.URLParam(r, "shortCode")
+	log.Printf("[HANDLER] GetURLHistory request for: %s", shortCode)
+	
+	if shortCode == "" {
+		writeError(w, http.StatusBadRequest, ErrInvalidRequest, "Short code is required")
+		return
+	}
+	
+	h.serveAuditLog(w, r, shortCode)
+}
+
+// serveAuditLog parses an audit log query and writes one page of entries
+func (h *Handler) serveAuditLog(w http.ResponseWriter, r *http.Request, shortCode string) {
+	req, err := parseAuditRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err, "Invalid audit query")
+		return
+	}
+	req.ShortCode = shortCode
+	
+	audit, err := h.service.GetAuditLog(r.Context(), req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case err == ErrURLNotFound:
+			statusCode = http.StatusNotFound
+		case errors.Is(err, ErrInvalidRequest):
+			statusCode = http.StatusBadRequest
+		}
+		
+		writeError(w, statusCode, err, "Failed to retrieve audit log")
+		return
+	}
+	
+	log.Printf("[HANDLER] SUCCESS: Retrieved %d audit entries", len(audit.Entries))
+	writeSuccess(w, audit, "Audit log retrieved successfully")
+}
+
+// parseAuditRequest reads the action, actor, from, to, before and limit
+// query parameters
+func parseAuditRequest(r *http.Request) (*AuditRequest, error) {
+	query := r.URL.Query()
+	req := &AuditRequest{
+		Action: query.Get("action"),
+		Actor:  query.Get("actor"),
+	}
+	
+	var err error
+	if req.From, err = parseTimeParam(query.Get("from"), false, time.UTC); err != nil {
+		return nil, fmt.Errorf("invalid from parameter: %w", err)
+	}
+	if req.To, err = parseTimeParam(query.Get("to"), true, time.UTC); err != nil {
+		return nil, fmt.Errorf("invalid to parameter: %w", err)
+	}
+	
+	if beforeParam := query.Get("before"); beforeParam != "" {
+		if req.Before, err = strconv.ParseInt(beforeParam, 10, 64); err != nil || req.Before < 0 {
+			return nil, fmt.Errorf("invalid before parameter: %s", beforeParam)
+		}
+	}
+	if limitParam := query.Get("limit"); limitParam != "" {
+		if req.Limit, err = strconv.Atoi(limitParam); err != nil || req.Limit < 0 {
+			return nil, fmt.Errorf("invalid limit parameter: %s", limitParam)
+		}
+	}
+	
+	return req, nil
+}
+
 // ValidateCustomCode handles GET /api/validate/{code}
 func (h *Handler) ValidateCustomCode(w http.ResponseWriter, r *http.Request) {
 	code := chi.URLParam(r, "code")
@@ -332,6 +1246,9 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 	
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		// Attribute changes to the caller in the audit log
+		r.Use(ActorMiddleware)
+		
 		// Core functionality
 		r.Post("/shorten", h.CreateShortURL)
 		r.Get("/health", h.HealthCheck)
@@ -342,7 +1259,51 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 			r.Get("/{shortCode}", h.GetURLInfo)
 			r.Put("/{shortCode}", h.UpdateURL)
 			r.Delete("/{shortCode}", h.DeleteURL)
+			r.Post("/{shortCode}/restore", h.RestoreURL)
+			r.Get("/{shortCode}/history", h.GetURLHistory)
+			r.Get("/{shortCode}/revisions", h.GetURLRevisions)
+			r.Post("/{shortCode}/revisions/{revision}/restore", h.RestoreRevision)
+			r.Post("/{shortCode}/probe", h.ProbeURL)
+			r.Get("/{shortCode}/preview", h.GetURLPreview)
+			r.Put("/{shortCode}/preview", h.SetPreviewOverride)
+			r.Post("/{shortCode}/preview/refresh", h.RefreshPreview)
 			r.Get("/{shortCode}/analytics", h.GetAnalytics)
+			r.Get("/{shortCode}/campaigns", h.GetURLCampaigns)
+			r.Get("/{shortCode}/clicks/export", h.ExportURLClicks)
+			r.Get("/{shortCode}/live", h.LiveClicks)
+		})
+		
+		// Cross-link analytics
+		r.Route("/analytics", func(r chi.Router) {
+			r.Get("/campaigns", h.GetCampaigns)
+		})
+		
+		// Raw click export across all links
+		r.Get("/clicks/export", h.ExportClicks)
+		
+		// Audit trail across all links
+		r.Get("/audit", h.GetAuditLog)
+		
+		// Data-subject erasure
+		r.Post("/privacy/erasure", h.EraseClicks)
+		
+		// Links flagged by destination re-scans
+		r.Route("/admin/quarantine", func(r chi.Router) {
+			r.Get("/", h.GetQuarantinedURLs)
+			r.Post("/{shortCode}/release", h.ReleaseURL)
+		})
+		
+		// Links whose destination failed its last probe
+		r.Get("/admin/broken-links", h.GetBrokenLinks)
+		
+		// Code length and keyspace occupancy
+		r.Get("/admin/codes", h.GetCodeStats)
+		
+		// Codes and patterns links may not use
+		r.Route("/admin/reserved-codes", func(r chi.Router) {
+			r.Get("/", h.GetReservedCodes)
+			r.Post("/", h.ReserveCode)
+			r.Delete("/{code}", h.UnreserveCode)
 		})
 		
 		// Validation
@@ -350,7 +1311,9 @@ func (h *Handler) RegisterRoutes(r chi.Router) {
 	})
 	
 	// Redirect route (must be last to avoid conflicts)
+	// HEAD is served too so unfurlers get the Location header (and are flagged as bots)
 	r.Get("/{shortCode}", h.RedirectURL)
+	r.Head("/{shortCode}", h.RedirectURL)
 	
 	log.Printf("[HANDLER] Shortener routes registered successfully")
 }
\ No newline at end of file
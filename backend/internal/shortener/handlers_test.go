@@ -0,0 +1,194 @@
+package shortener
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func setupTestRouter(t *testing.T) (chi.Router, Service) {
+	t.Helper()
+
+	svc := setupTestService()
+	if _, err := svc.CreateShortURL(context.Background(), &CreateURLRequest{
+		URL:        "https://example.com",
+		CustomCode: "testcampaign",
+	}); err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	r := chi.NewRouter()
+	NewHandler(svc).RegisterRoutes(r)
+	return r, svc
+}
+
+func TestGetCampaigns(t *testing.T) {
+	router, _ := setupTestRouter(t)
+	day := func(days int) string { return time.Now().AddDate(0, 0, days).Format("2006-01-02") }
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"all links", "/api/analytics/campaigns", http.StatusOK},
+		{"per link", "/api/urls/testcampaign/campaigns?group_by=source,campaign", http.StatusOK},
+		{"drill-down", "/api/analytics/campaigns?utm_campaign=spring&group_by=term,content", http.StatusOK},
+		{"date range", "/api/analytics/campaigns?from=" + day(-30) + "&to=" + day(-1), http.StatusOK},
+		{"before click retention", "/api/analytics/campaigns?from=" + day(-120) + "&to=" + day(-100), http.StatusBadRequest},
+		{"unknown link", "/api/urls/nonexistent/campaigns", http.StatusNotFound},
+		{"unknown dimension", "/api/analytics/campaigns?group_by=gclid", http.StatusBadRequest},
+		{"bad date", "/api/analytics/campaigns?from=yesterday", http.StatusBadRequest},
+		{"inverted range", "/api/analytics/campaigns?from=2025-09-30&to=2025-09-01", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("GET %s status = %d, expected %d: %s", tt.path, rec.Code, tt.expectedStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetAnalyticsHandler(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"defaults", "/api/urls/testcampaign/analytics", http.StatusOK},
+		{"hourly in timezone", "/api/urls/testcampaign/analytics?from=2025-09-01&to=2025-09-02&granularity=hour&tz=America/New_York", http.StatusOK},
+		{"unknown timezone", "/api/urls/testcampaign/analytics?tz=Mars/Olympus_Mons", http.StatusBadRequest},
+		{"unknown granularity", "/api/urls/testcampaign/analytics?granularity=fortnight", http.StatusBadRequest},
+		{"too many buckets", "/api/urls/testcampaign/analytics?days=365&granularity=minute", http.StatusBadRequest},
+		{"bad date", "/api/urls/testcampaign/analytics?to=tomorrow", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("GET %s status = %d, expected %d: %s", tt.path, rec.Code, tt.expectedStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetCampaigns_CSV(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/urls/testcampaign/campaigns?format=csv&group_by=link,source,medium", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, expected %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q, expected text/csv", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "testcampaign-campaigns.csv") {
+		t.Errorf("Content-Disposition = %q, expected per-link filename", cd)
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"short_code", "utm_source", "utm_medium", "clicks", "first_click", "last_click"},
+		{"testcampaign", "newsletter", "email", "8", "2025-09-01T10:00:00Z", "2025-09-02T10:00:00Z"},
+		{"testcampaign", "twitter", "(none)", "4", "2025-09-01T12:00:00Z", "2025-09-01T12:00:00Z"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("CSV has %d rows, expected %d: %v", len(records), len(expected), records)
+	}
+	for i := range expected {
+		if strings.Join(records[i], ",") != strings.Join(expected[i], ",") {
+			t.Errorf("CSV row %d = %v, expected %v", i, records[i], expected[i])
+		}
+	}
+}
+
+func TestParseTimeParam(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		value      string
+		endOfRange bool
+		loc        *time.Location
+		expected   time.Time
+		wantErr    bool
+	}{
+		{"", false, time.UTC, time.Time{}, false},
+		{"2025-09-01", false, time.UTC, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2025-09-01", true, time.UTC, time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC), false},
+		{"2025-09-01", false, tokyo, time.Date(2025, 8, 31, 15, 0, 0, 0, time.UTC), false},
+		{"2025-09-01T12:30:00Z", true, tokyo, time.Date(2025, 9, 1, 12, 30, 0, 0, time.UTC), false},
+		{"09/01/2025", false, time.UTC, time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeParam(tt.value, tt.endOfRange, tt.loc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimeParam(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("parseTimeParam(%q, %v) = %v, expected %v", tt.value, tt.endOfRange, got, tt.expected)
+		}
+	}
+}
+
+func TestExportClicksHandler(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name                string
+		path                string
+		expectedStatus      int
+		expectedContentType string
+		expectedFilename    string
+	}{
+		{"per link csv", "/api/urls/testcampaign/clicks/export", http.StatusOK, "text/csv", "testcampaign-clicks.csv"},
+		{"all links ndjson", "/api/clicks/export?format=ndjson&from=2025-09-01", http.StatusOK, "application/x-ndjson", "clicks.ndjson"},
+		{"parquet", "/api/clicks/export?format=parquet&include_bots=false", http.StatusOK, "application/vnd.apache.parquet", "clicks.parquet"},
+		{"unknown link", "/api/urls/nonexistent/clicks/export", http.StatusNotFound, "application/json", ""},
+		{"unknown format", "/api/clicks/export?format=xml", http.StatusBadRequest, "application/json", ""},
+		{"bad date", "/api/clicks/export?to=tomorrow", http.StatusBadRequest, "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Status = %d, expected %d: %s", rec.Code, tt.expectedStatus, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.expectedContentType) {
+				t.Errorf("Content-Type = %q, expected %s", ct, tt.expectedContentType)
+			}
+			if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, tt.expectedFilename) {
+				t.Errorf("Content-Disposition = %q, expected %s", cd, tt.expectedFilename)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package shortener
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/models"
+)
+
+const (
+	// Clicks buffered per live subscriber; a slow subscriber loses the
+	// newest clicks instead of slowing down the click workers
+	liveBufferSize = 64
+
+	// SSE comment sent on idle streams so proxies keep the connection open
+	liveHeartbeatInterval = 15 * time.Second
+
+	// How long to wait before re-establishing a failed LISTEN connection
+	liveListenRetry = 5 * time.Second
+
+	// Time allowed to NOTIFY other instances of a click
+	liveNotifyTimeout = 2 * time.Second
+)
+
+// LiveClick is a click as delivered on the live stream. It deliberately
+// leaves out the IP address and user agent.
+type LiveClick struct {
+	ShortCode  string    `json:"short_code"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Referrer   *string   `json:"referrer,omitempty"`
+	Country    *string   `json:"country,omitempty"`
+	City       *string   `json:"city,omitempty"`
+	Browser    *string   `json:"browser,omitempty"`
+	OS         *string   `json:"os,omitempty"`
+	DeviceType *string   `json:"device_type,omitempty"`
+	IsBot      bool      `json:"is_bot"`
+}
+
+// newLiveClick projects a recorded click onto the live stream format
+func newLiveClick(shortCode string, click *models.ClickEvent) LiveClick {
+	return LiveClick{
+		ShortCode:  shortCode,
+		OccurredAt: click.OccurredAt,
+		Referrer:   click.Referrer,
+		Country:    click.Country,
+		City:       click.City,
+		Browser:    click.Browser,
+		OS:         click.OS,
+		DeviceType: click.DeviceType,
+		IsBot:      click.IsBot,
+	}
+}
+
+// liveNotification is the NOTIFY payload fanning a click out to other instances
+type liveNotification struct {
+	Origin string    `json:"origin"` // Instance that recorded the click
+	URLID  int64     `json:"url_id"`
+	Click  LiveClick `json:"click"`
+}
+
+// LiveSubscription receives the live clicks of one URL
+type LiveSubscription struct {
+	urlID   int64
+	events  chan LiveClick
+	dropped atomic.Int64
+	hub     *liveHub
+}
+
+// Events returns the channel of live clicks. It is closed when the
+// subscription is closed or the service shuts down.
+func (s *LiveSubscription) Events() <-chan LiveClick {
+	return s.events
+}
+
+// Dropped returns how many clicks were discarded because the buffer was full
+func (s *LiveSubscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Close stops the subscription. It is safe to call more than once.
+func (s *LiveSubscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+// liveHub is the in-process pub/sub routing live clicks to subscribers by URL
+type liveHub struct {
+	mu     sync.Mutex
+	subs   map[int64]map[*LiveSubscription]struct{}
+	closed bool
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{subs: make(map[int64]map[*LiveSubscription]struct{})}
+}
+
+// subscribe registers a subscriber for urlID, or returns false once the hub is closed
+func (h *liveHub) subscribe(urlID int64) (*LiveSubscription, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return nil, false
+	}
+
+	sub := &LiveSubscription{urlID: urlID, events: make(chan LiveClick, liveBufferSize), hub: h}
+	if h.subs[urlID] == nil {
+		h.subs[urlID] = make(map[*LiveSubscription]struct{})
+	}
+	h.subs[urlID][sub] = struct{}{}
+	return sub, true
+}
+
+// unsubscribe removes a subscriber and closes its channel
+func (h *liveHub) unsubscribe(sub *LiveSubscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subs := h.subs[sub.urlID]
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(h.subs, sub.urlID)
+	}
+	close(sub.events)
+}
+
+// publish delivers a click to every subscriber of urlID without blocking
+func (h *liveHub) publish(urlID int64, click LiveClick) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subs[urlID] {
+		select {
+		case sub.events <- click:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// close ends every subscription and rejects new ones
+func (h *liveHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	for urlID, subs := range h.subs {
+		for sub := range subs {
+			close(sub.events)
+		}
+		delete(h.subs, urlID)
+	}
+}
+
+// newInstanceID returns a random identifier for this process, used to
+// recognize its own NOTIFY payloads
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// SubscribeLive subscribes to the clicks of a URL as they are recorded, on
+// this instance or, with fan-out enabled, on any instance
+func (s *service) SubscribeLive(ctx context.Context, shortCode string) (*LiveSubscription, error) {
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+
+	sub, ok := s.live.subscribe(url.ID)
+	if !ok {
+		return nil, ErrShuttingDown
+	}
+
+	log.Printf("[SHORTENER] Live subscriber added for %s", shortCode)
+	return sub, nil
+}
+
+// CloseLiveStreams ends every live subscription so long-lived streaming
+// connections can finish during shutdown
+func (s *service) CloseLiveStreams() {
+	s.live.close()
+}
+
+// publishLive hands a recorded click to local subscribers and, with fan-out
+// enabled, to the other instances
+func (s *service) publishLive(url *models.URL, click *models.ClickEvent) {
+	live := newLiveClick(url.ShortCode, click)
+	s.live.publish(url.ID, live)
+
+	if !s.config.LiveFanout {
+		return
+	}
+
+	payload, err := json.Marshal(liveNotification{Origin: s.instanceID, URLID: url.ID, Click: live})
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to encode live click: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), liveNotifyTimeout)
+	defer cancel()
+	if err := s.repo.NotifyClick(ctx, string(payload)); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to fan out live click: %v", err)
+	}
+}
+
+// handleLiveNotification publishes a click recorded by another instance
+func (s *service) handleLiveNotification(payload string) {
+	var n liveNotification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		log.Printf("[SHORTENER] WARNING: Ignoring malformed live click notification: %v", err)
+		return
+	}
+
+	// Our own clicks were already published locally
+	if n.Origin == s.instanceID {
+		return
+	}
+	s.live.publish(n.URLID, n.Click)
+}
+
+// liveListener receives clicks fanned out by other instances until shutdown,
+// reconnecting after failures
+func (s *service) liveListener() {
+	defer s.wg.Done()
+	log.Printf("[SHORTENER] Live listener started (instance %s)", s.instanceID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.shutdown
+		cancel()
+	}()
+
+	for {
+		err := s.repo.ListenClicks(ctx, s.handleLiveNotification)
+		if ctx.Err() != nil {
+			log.Printf("[SHORTENER] Live listener shutting down")
+			return
+		}
+		log.Printf("[SHORTENER] WARNING: Live listener failed, retrying in %s: %v", liveListenRetry, err)
+
+		select {
+		case <-ctx.Done():
+			log.Printf("[SHORTENER] Live listener shutting down")
+			return
+		case <-time.After(liveListenRetry):
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package shortener
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveHub_RoutesByURL(t *testing.T) {
+	hub := newLiveHub()
+	a, _ := hub.subscribe(1)
+	b, _ := hub.subscribe(2)
+	defer a.Close()
+	defer b.Close()
+
+	hub.publish(1, LiveClick{ShortCode: "one"})
+
+	select {
+	case click := <-a.Events():
+		if click.ShortCode != "one" {
+			t.Errorf("Received %s, expected one", click.ShortCode)
+		}
+	default:
+		t.Fatal("Subscriber of URL 1 received nothing")
+	}
+
+	select {
+	case click := <-b.Events():
+		t.Errorf("Subscriber of URL 2 received %+v", click)
+	default:
+	}
+}
+
+func TestLiveHub_BoundedBuffer(t *testing.T) {
+	hub := newLiveHub()
+	sub, _ := hub.subscribe(1)
+	defer sub.Close()
+
+	// Nobody reads, so clicks beyond the buffer are dropped instead of blocking
+	for i := 0; i < liveBufferSize+5; i++ {
+		hub.publish(1, LiveClick{})
+	}
+
+	if got := len(sub.Events()); got != liveBufferSize {
+		t.Errorf("Buffered %d clicks, expected %d", got, liveBufferSize)
+	}
+	if got := sub.Dropped(); got != 5 {
+		t.Errorf("Dropped() = %d, expected 5", got)
+	}
+}
+
+func TestLiveHub_Close(t *testing.T) {
+	hub := newLiveHub()
+	sub, _ := hub.subscribe(1)
+
+	hub.close()
+	if _, ok := <-sub.Events(); ok {
+		t.Error("Events() still open after hub close")
+	}
+
+	// Closing the subscription afterwards must not panic
+	sub.Close()
+	sub.Close()
+
+	if _, ok := hub.subscribe(1); ok {
+		t.Error("subscribe() succeeded on a closed hub")
+	}
+}
+
+func TestLiveNotifications(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewService(repo, DefaultConfig()).(*service)
+	ctx := context.Background()
+
+	url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CustomCode: "livetest"})
+	if err != nil {
+		t.Fatalf("Failed to create test URL: %v", err)
+	}
+
+	sub, err := svc.SubscribeLive(ctx, "livetest")
+	if err != nil {
+		t.Fatalf("SubscribeLive() unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	// A local click is published directly and fanned out with our origin
+	if err := svc.RecordClick(ctx, "livetest", &ClickContext{IP: "198.51.100.7", UserAgent: "Mozilla/5.0"}); err != nil {
+		t.Fatalf("RecordClick() unexpected error: %v", err)
+	}
+	if len(sub.Events()) != 1 {
+		t.Fatalf("Local click delivered %d times, expected 1", len(sub.Events()))
+	}
+	<-sub.Events()
+
+	if len(repo.notifications) != 1 {
+		t.Fatalf("Sent %d notifications, expected 1", len(repo.notifications))
+	}
+	var n liveNotification
+	if err := json.Unmarshal([]byte(repo.notifications[0]), &n); err != nil {
+		t.Fatalf("Notification is not valid JSON: %v", err)
+	}
+	if n.Origin != svc.instanceID || n.URLID != url.ID || n.Click.ShortCode != "livetest" {
+		t.Errorf("Notification = %+v", n)
+	}
+	if strings.Contains(repo.notifications[0], "198.51.100") {
+		t.Error("Notification leaks the client IP")
+	}
+
+	// Our own notification coming back through LISTEN is ignored
+	svc.handleLiveNotification(repo.notifications[0])
+	if len(sub.Events()) != 0 {
+		t.Error("Own notification was published twice")
+	}
+
+	// Another instance's click is published
+	n.Origin = "other-instance"
+	payload, _ := json.Marshal(n)
+	svc.handleLiveNotification(string(payload))
+	if len(sub.Events()) != 1 {
+		t.Error("Notification from another instance was not published")
+	}
+}
+
+func TestLiveClicksHandler(t *testing.T) {
+	router, svc := setupTestRouter(t)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/urls/testcampaign/live", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET live stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d, expected %d", resp.StatusCode, http.StatusOK)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, expected text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Reading stream: %v", err)
+			}
+			line = strings.TrimRight(line, "\n")
+			if line == "" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if first := readEvent(); len(first) != 1 || !strings.HasPrefix(first[0], "retry: ") {
+		t.Fatalf("First event = %q, expected retry", first)
+	}
+
+	if err := svc.RecordClick(ctx, "testcampaign", &ClickContext{UserAgent: "Mozilla/5.0", Referrer: "https://news.example"}); err != nil {
+		t.Fatalf("RecordClick() unexpected error: %v", err)
+	}
+
+	event := readEvent()
+	expected := []string{"id: 1", "event: click"}
+	if len(event) != 3 || event[0] != expected[0] || event[1] != expected[1] {
+		t.Fatalf("Click event = %q", event)
+	}
+
+	var click LiveClick
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(event[2], "data: ")), &click); err != nil {
+		t.Fatalf("Click data is not JSON: %v", err)
+	}
+	if click.ShortCode != "testcampaign" || click.Referrer == nil || *click.Referrer != "https://news.example" {
+		t.Errorf("Click = %+v", click)
+	}
+}
+
+func TestLiveClicksHandler_NotFound(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/api/urls/nonexistent/live", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Status = %d, expected %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,238 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"backend/internal/models"
+)
+
+const (
+	// Worker pool fetching link previews after creation
+	previewWorkers    = 2
+	previewBufferSize = 100
+
+	// Limits of preview overrides
+	maxPreviewTitleLength       = 300
+	maxPreviewDescriptionLength = 1000
+)
+
+// previewJob asks a preview worker to fetch the preview of a link
+type previewJob struct {
+	urlID     int64
+	shortCode string
+	target    string
+}
+
+// schedulePreview queues a preview fetch for url. When the queue is full the
+// fetch is skipped; the preview can still be refreshed on request.
+func (s *service) schedulePreview(url *models.URL) {
+	if s.previewChan == nil {
+		return
+	}
+
+	select {
+	case s.previewChan <- previewJob{urlID: url.ID, shortCode: url.ShortCode, target: url.TargetURL}:
+	default:
+		log.Printf("[SHORTENER] WARNING: Preview queue full, skipping preview of %s", url.ShortCode)
+	}
+}
+
+// previewWorker fetches queued previews until shutdown
+func (s *service) previewWorker(id int) {
+	defer s.wg.Done()
+
+	// Abandon in-flight fetches on shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.shutdown
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-s.shutdown:
+			log.Printf("[SHORTENER] Preview worker %d shutting down", id)
+			return
+		case job := <-s.previewChan:
+			if err := s.fetchPreview(ctx, job.urlID, job.shortCode, job.target); err != nil {
+				log.Printf("[SHORTENER] WARNING: Preview worker %d failed to store preview: %v", id, err)
+			}
+		}
+	}
+}
+
+// fetchPreview fetches and stores the preview of a link. A failed fetch is
+// recorded on the preview rather than returned.
+func (s *service) fetchPreview(ctx context.Context, urlID int64, shortCode, target string) error {
+	fetched, err := s.previews.Fetch(ctx, target)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to fetch preview of %s: %v", shortCode, err)
+		message := err.Error()
+		return s.repo.SavePreviewFetch(ctx, urlID, nil, time.Now().UTC(), &message)
+	}
+
+	log.Printf("[SHORTENER] Fetched preview of %s", shortCode)
+	return s.repo.SavePreviewFetch(ctx, urlID, fetched, time.Now().UTC(), nil)
+}
+
+// GetURLPreview returns the preview metadata of a link
+func (s *service) GetURLPreview(ctx context.Context, shortCode string) (*PreviewResponse, error) {
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+
+	return s.previewResponse(ctx, url)
+}
+
+// RefreshPreview fetches the preview of a link now
+func (s *service) RefreshPreview(ctx context.Context, shortCode string) (*PreviewResponse, error) {
+	log.Printf("[SHORTENER] Refreshing preview of: %s", shortCode)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+
+	if err := s.fetchPreview(ctx, url.ID, url.ShortCode, url.TargetURL); err != nil {
+		return nil, fmt.Errorf("failed to store preview: %w", err)
+	}
+	return s.previewResponse(ctx, url)
+}
+
+// SetPreviewOverride replaces the preview overrides of a link. Fields left
+// out or empty fall back to the fetched metadata.
+func (s *service) SetPreviewOverride(ctx context.Context, shortCode string, req *PreviewOverrideRequest) (*PreviewResponse, error) {
+	log.Printf("[SHORTENER] Setting preview override of: %s", shortCode)
+
+	override := models.LinkPreview{
+		Title:       nonEmpty(req.Title),
+		Description: nonEmpty(req.Description),
+		ImageURL:    nonEmpty(req.ImageURL),
+	}
+	if override.Title != nil && utf8.RuneCountInString(*override.Title) > maxPreviewTitleLength {
+		return nil, fmt.Errorf("%w: title exceeds %d characters", ErrInvalidRequest, maxPreviewTitleLength)
+	}
+	if override.Description != nil && utf8.RuneCountInString(*override.Description) > maxPreviewDescriptionLength {
+		return nil, fmt.Errorf("%w: description exceeds %d characters", ErrInvalidRequest, maxPreviewDescriptionLength)
+	}
+	if override.ImageURL != nil {
+		// Crawlers, not this service, fetch the image, so only its form is checked
+		if err := models.ValidateURL(*override.ImageURL); err != nil {
+			return nil, fmt.Errorf("%w: invalid image URL: %w", ErrInvalidRequest, err)
+		}
+	}
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+
+	before, err := s.repo.GetURLPreview(ctx, url.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preview: %w", err)
+	}
+	if err := s.repo.SetPreviewOverride(ctx, url.ID, &override); err != nil {
+		return nil, fmt.Errorf("failed to set preview override: %w", err)
+	}
+
+	changes := make(map[string]models.FieldChange)
+	diffPreviewField(changes, "preview_title", before.Override.Title, override.Title)
+	diffPreviewField(changes, "preview_description", before.Override.Description, override.Description)
+	diffPreviewField(changes, "preview_image_url", before.Override.ImageURL, override.ImageURL)
+	s.audit(ctx, models.AuditURLPreview, url, changes)
+
+	log.Printf("[SHORTENER] SUCCESS: Set preview override of %s", shortCode)
+	return s.previewResponse(ctx, url)
+}
+
+func (s *service) previewResponse(ctx context.Context, url *models.URL) (*PreviewResponse, error) {
+	preview, err := s.repo.GetURLPreview(ctx, url.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preview: %w", err)
+	}
+	return &PreviewResponse{ShortCode: url.ShortCode, Effective: preview.Effective(), URLPreview: preview}, nil
+}
+
+func diffPreviewField(changes map[string]models.FieldChange, name string, before, after *string) {
+	if (before == nil) != (after == nil) || (before != nil && *before != *after) {
+		changes[name] = models.FieldChange{Before: before, After: after}
+	}
+}
+
+func nonEmpty(s *string) *string {
+	if s == nil || *s == "" {
+		return nil
+	}
+	return s
+}
+
+// previewPage is served to link unfurlers in place of a redirect when a link
+// has preview overrides. Browsers that end up on it are sent on.
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.URL}}">
+{{- with .Title}}
+<meta property="og:title" content="{{.}}">
+<meta name="twitter:title" content="{{.}}">
+{{- end}}
+{{- with .Description}}
+<meta property="og:description" content="{{.}}">
+<meta name="twitter:description" content="{{.}}">
+<meta name="description" content="{{.}}">
+{{- end}}
+{{- with .Image}}
+<meta property="og:image" content="{{.}}">
+<meta name="twitter:image" content="{{.}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta http-equiv="refresh" content="0; url={{.URL}}">
+</head>
+<body><a href="{{.URL}}">{{.URL}}</a></body>
+</html>
+`))
+
+// writePreviewPage renders the preview page of a link
+func writePreviewPage(w http.ResponseWriter, url *models.URL, preview models.LinkPreview) {
+	data := struct {
+		URL                       string
+		Title, Description, Image string
+	}{URL: url.TargetURL}
+	if preview.Title != nil {
+		data.Title = *preview.Title
+	}
+	if preview.Description != nil {
+		data.Description = *preview.Description
+	}
+	if preview.ImageURL != nil {
+		data.Image = *preview.ImageURL
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := previewPage.Execute(w, data); err != nil {
+		log.Printf("[HANDLER] ERROR: Failed to render preview page: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend/internal/models"
+	"backend/internal/preview"
+	"backend/internal/ssrf"
+)
+
+// setupPreviewService creates a service fetching previews from a local
+// server that answers for news.test
+func setupPreviewService(t *testing.T) (Service, *MockRepository) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><title>Article</title><meta property="og:description" content="Fetched description"></head>`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	repo := NewMockRepository()
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{"news.test": {netip.MustParseAddr("93.184.216.34")}}
+	config.FetchPreviews = true
+	svc := NewService(repo, config)
+	t.Cleanup(func() { svc.Shutdown(context.Background()) })
+
+	// Route every fetch to the test server, set before any preview is queued
+	transport := &http.Transport{Proxy: func(r *http.Request) (*url.URL, error) { return url.Parse(server.URL) }}
+	svc.(*service).previews = preview.New(nil, preview.Config{Transport: transport})
+
+	return svc, repo
+}
+
+func TestPreviewFetchedAfterCreate(t *testing.T) {
+	svc, repo := setupPreviewService(t)
+	ctx := context.Background()
+
+	url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "http://news.test/article", CustomCode: "news"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+
+	// Fetched in the background
+	var fetched *models.URLPreview
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if fetched, _ = repo.GetURLPreview(ctx, url.ID); fetched.FetchedAt != nil {
+			break
+		}
+	}
+	if fetched.FetchedAt == nil {
+		t.Fatal("Preview was not fetched after creation")
+	}
+	if fetched.Fetched.Title == nil || *fetched.Fetched.Title != "Article" {
+		t.Errorf("Fetched preview = %+v, expected the page title", fetched.Fetched)
+	}
+
+	title := "Custom title"
+	resp, err := svc.SetPreviewOverride(ctx, "news", &PreviewOverrideRequest{Title: &title})
+	if err != nil {
+		t.Fatalf("SetPreviewOverride() unexpected error: %v", err)
+	}
+	if *resp.Effective.Title != title || *resp.Effective.Description != "Fetched description" {
+		t.Errorf("Effective preview = %+v, expected the override over the fetched description", resp.Effective)
+	}
+
+	last := repo.auditLog[len(repo.auditLog)-1]
+	if last.Action != models.AuditURLPreview || last.Changes["preview_title"].After == nil {
+		t.Errorf("Last audit entry = %+v, expected the preview title change", last)
+	}
+
+	// Refreshing keeps the override
+	resp, err = svc.RefreshPreview(ctx, "news")
+	if err != nil {
+		t.Fatalf("RefreshPreview() unexpected error: %v", err)
+	}
+	if *resp.Effective.Title != title || resp.FetchError != nil {
+		t.Errorf("RefreshPreview() = %+v, expected the override to survive", resp)
+	}
+}
+
+func TestSetPreviewOverrideValidation(t *testing.T) {
+	svc, _ := setupPreviewService(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "http://news.test/article", CustomCode: "news"}); err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+
+	badImage := "javascript:alert(1)"
+	longTitle := strings.Repeat("x", maxPreviewTitleLength+1)
+	for _, req := range []*PreviewOverrideRequest{{ImageURL: &badImage}, {Title: &longTitle}} {
+		if _, err := svc.SetPreviewOverride(ctx, "news", req); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("SetPreviewOverride(%+v) error = %v, expected ErrInvalidRequest", req, err)
+		}
+	}
+
+	if _, err := svc.SetPreviewOverride(ctx, "nonexistent", &PreviewOverrideRequest{}); err != ErrURLNotFound {
+		t.Errorf("SetPreviewOverride() of unknown code error = %v, expected ErrURLNotFound", err)
+	}
+}
+
+func TestPreviewServedToUnfurlers(t *testing.T) {
+	svc, _ := setupPreviewService(t)
+	ctx := context.Background()
+
+	for _, code := range []string{"plain", "custom"} {
+		if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "http://news.test/article", CustomCode: code}); err != nil {
+			t.Fatalf("CreateShortURL() unexpected error: %v", err)
+		}
+	}
+	title, image := `Launch "day" <3`, "https://cdn.example.com/launch.png"
+	if _, err := svc.SetPreviewOverride(ctx, "custom", &PreviewOverrideRequest{Title: &title, ImageURL: &image}); err != nil {
+		t.Fatalf("SetPreviewOverride() unexpected error: %v", err)
+	}
+
+	// Codes typed in another case resolve to the stored one
+	svc.(*service).config.IgnoreCodeCase = true
+
+	router := chi.NewRouter()
+	NewHandler(svc).RegisterRoutes(router)
+
+	const facebook = "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"
+	const browser = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+	tests := []struct {
+		name           string
+		path           string
+		userAgent      string
+		expectedStatus int
+		contains       string
+	}{
+		{"unfurler gets overrides", "/custom", facebook, http.StatusOK, `<meta property="og:title" content="Launch &#34;day&#34; &lt;3">`},
+		{"unfurler gets overrides of a code in another case", "/CUSTOM", facebook, http.StatusOK, `<meta property="og:title" content="Launch &#34;day&#34; &lt;3">`},
+		{"browser is redirected", "/custom", browser, http.StatusFound, ""},
+		{"no overrides redirects unfurlers", "/plain", facebook, http.StatusFound, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d", tt.name, rec.Code, tt.expectedStatus)
+		}
+		if tt.contains != "" && !strings.Contains(rec.Body.String(), tt.contains) {
+			t.Errorf("%s: body does not contain %s:\n%s", tt.name, tt.contains, rec.Body.String())
+		}
+	}
+
+	// Management endpoints
+	for _, tt := range []struct {
+		method, path, body string
+		expectedStatus     int
+	}{
+		{"GET", "/api/urls/custom/preview", "", http.StatusOK},
+		{"GET", "/api/urls/nonexistent/preview", "", http.StatusNotFound},
+		{"PUT", "/api/urls/custom/preview", `{"description": "New"}`, http.StatusOK},
+		{"PUT", "/api/urls/custom/preview", `{"image_url": "ftp://files/x.png"}`, http.StatusBadRequest},
+		{"PUT", "/api/urls/custom/preview", `not json`, http.StatusBadRequest},
+		{"POST", "/api/urls/custom/preview/refresh", "", http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s %s: status = %d, expected %d: %s", tt.method, tt.path, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"backend/internal/models"
+)
+
+const (
+	// Links probed at once during a link check
+	linkCheckConcurrency = 8
+
+	defaultBrokenLinksLimit = 100
+	maxBrokenLinksLimit     = 1000
+)
+
+// ProbeURL follows the redirect chain of a link's target now and stores the
+// outcome on the link
+func (s *service) ProbeURL(ctx context.Context, shortCode string) (*models.ProbeResult, error) {
+	log.Printf("[SHORTENER] Probing destination of: %s", shortCode)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+
+	result := s.prober.Probe(ctx, url.TargetURL)
+	if _, err := s.repo.RecordProbe(ctx, url.ID, result); err != nil {
+		return nil, fmt.Errorf("failed to record probe: %w", err)
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Probed %s: %s after %d hops", shortCode, result.Status, len(result.Hops))
+	return result, nil
+}
+
+// CheckLinks probes the destination of every redirecting link and stores
+// each outcome, for the broken-link report
+func (s *service) CheckLinks(ctx context.Context) (*LinkCheckResult, error) {
+	result := &LinkCheckResult{}
+
+	var afterID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		urls, err := s.repo.GetURLsToScan(ctx, afterID, rescanBatchSize)
+		if err != nil {
+			log.Printf("[SHORTENER] ERROR: Link check failed after %d URLs: %v", result.Checked, err)
+			return result, err
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, linkCheckConcurrency)
+		for _, url := range urls {
+			afterID = url.ID
+
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(url *models.URL) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				probed := s.prober.Probe(ctx, url.TargetURL)
+				if _, err := s.repo.RecordProbe(ctx, url.ID, probed); err != nil {
+					log.Printf("[SHORTENER] WARNING: Failed to record probe of %s: %v", url.ShortCode, err)
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				result.Checked++
+				if probed.Status != models.ProbeOK {
+					result.Broken++
+				}
+			}(url)
+		}
+		wg.Wait()
+
+		if len(urls) < rescanBatchSize {
+			log.Printf("[SHORTENER] Link check complete: %d checked, %d broken", result.Checked, result.Broken)
+			return result, nil
+		}
+	}
+}
+
+// GetBrokenLinks returns links whose last probe did not succeed, most
+// recently probed first
+func (s *service) GetBrokenLinks(ctx context.Context, limit int) ([]*models.URL, error) {
+	if limit <= 0 {
+		limit = defaultBrokenLinksLimit
+	}
+	if limit > maxBrokenLinksLimit {
+		limit = maxBrokenLinksLimit
+	}
+
+	urls, err := s.repo.GetBrokenURLs(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get broken links: %w", err)
+	}
+	if urls == nil {
+		urls = []*models.URL{}
+	}
+	return urls, nil
+}
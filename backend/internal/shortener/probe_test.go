@@ -0,0 +1,113 @@
+package shortener
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend/internal/models"
+	"backend/internal/probe"
+)
+
+// setupProbeService creates links to a local test server, probed without
+// the SSRF guard, which would refuse the loopback address
+func setupProbeService(t *testing.T) (Service, *MockRepository) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	repo := NewMockRepository()
+	svc := NewService(repo, DefaultConfig())
+	svc.(*service).prober = probe.New(nil, probe.Config{Transport: server.Client().Transport})
+
+	// Created directly: the service refuses loopback targets
+	for code, path := range map[string]string{"fine": "/ok", "moved": "/moved", "loop": "/loop"} {
+		if err := repo.CreateURL(context.Background(), &models.URL{ShortCode: code, TargetURL: server.URL + path, IsActive: true}); err != nil {
+			t.Fatalf("CreateURL(%s) unexpected error: %v", code, err)
+		}
+	}
+	return svc, repo
+}
+
+func TestProbeURL(t *testing.T) {
+	svc, repo := setupProbeService(t)
+	ctx := context.Background()
+
+	result, err := svc.ProbeURL(ctx, "moved")
+	if err != nil {
+		t.Fatalf("ProbeURL() unexpected error: %v", err)
+	}
+	if result.Status != models.ProbeOK || len(result.Hops) != 2 {
+		t.Errorf("ProbeURL() = %+v, expected ok after 2 hops", result)
+	}
+
+	url := repo.urls["moved"]
+	if url.ProbeStatus == nil || *url.ProbeStatus != models.ProbeOK || url.FinalURL == nil || *url.FinalURL != result.FinalURL {
+		t.Errorf("Stored probe = %v, %v, expected ok ending at %s", url.ProbeStatus, url.FinalURL, result.FinalURL)
+	}
+
+	if _, err := svc.ProbeURL(ctx, "nonexistent"); err != ErrURLNotFound {
+		t.Errorf("ProbeURL() of unknown code error = %v, expected ErrURLNotFound", err)
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	svc, _ := setupProbeService(t)
+	ctx := context.Background()
+
+	result, err := svc.CheckLinks(ctx)
+	if err != nil {
+		t.Fatalf("CheckLinks() unexpected error: %v", err)
+	}
+	if result.Checked != 3 || result.Broken != 1 {
+		t.Errorf("CheckLinks() = %+v, expected 3 checked, 1 broken", result)
+	}
+
+	broken, err := svc.GetBrokenLinks(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetBrokenLinks() unexpected error: %v", err)
+	}
+	if len(broken) != 1 || broken[0].ShortCode != "loop" || *broken[0].ProbeStatus != models.ProbeRedirectLoop {
+		t.Errorf("GetBrokenLinks() = %+v, expected the loop link", broken)
+	}
+}
+
+func TestProbeHandlers(t *testing.T) {
+	svc, _ := setupProbeService(t)
+
+	router := chi.NewRouter()
+	NewHandler(svc).RegisterRoutes(router)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"probe", "POST", "/api/urls/loop/probe", http.StatusOK},
+		{"probe unknown link", "POST", "/api/urls/nonexistent/probe", http.StatusNotFound},
+		{"report", "GET", "/api/admin/broken-links?limit=10", http.StatusOK},
+		{"bad limit", "GET", "/api/admin/broken-links?limit=-1", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d: %s", tt.name, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+}
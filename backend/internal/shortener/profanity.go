@@ -0,0 +1,46 @@
+package shortener
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed words/blocked.txt
+var blockedWordList string
+
+var blockedWords = parseWordList(blockedWordList)
+
+// Digits read as the letters they resemble. 1 stands for both i and l, so
+// codes are checked once per reading.
+var (
+	leetReplacer  = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g", "-", "", "_", "")
+	leetReplacerL = strings.NewReplacer("0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "9", "g", "-", "", "_", "")
+)
+
+// IsProfane reports whether a code spells an offensive word in any case,
+// with separators dropped and digits read as look-alike letters
+func IsProfane(code string) bool {
+	lower := strings.ToLower(code)
+	for _, reading := range []string{leetReplacer.Replace(lower), leetReplacerL.Replace(lower)} {
+		for _, word := range blockedWords {
+			if strings.Contains(reading, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseWordList returns the words of an embedded list, one per line, skipping
+// blank lines and # comments
+func parseWordList(list string) []string {
+	var words []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.ToLower(line))
+	}
+	return words
+}
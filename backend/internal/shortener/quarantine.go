@@ -0,0 +1,137 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+	"backend/internal/urlscan"
+)
+
+const (
+	// Links read per query during a re-scan
+	rescanBatchSize = 500
+
+	defaultQuarantineLimit = 100
+	maxQuarantineLimit     = 1000
+)
+
+// RescanURLs runs every redirecting link through the URL scanners and
+// quarantines those whose target is now blocked or resolves to an internal
+// address, emitting EventURLQuarantined for each. Links are checked against
+// the lists of this instance; hosts that fail to resolve are left alone.
+func (s *service) RescanURLs(ctx context.Context) (*RescanResult, error) {
+	result := &RescanResult{}
+
+	var afterID int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		urls, err := s.repo.GetURLsToScan(ctx, afterID, rescanBatchSize)
+		if err != nil {
+			log.Printf("[SHORTENER] ERROR: Re-scan failed after %d URLs: %v", result.Scanned, err)
+			return result, err
+		}
+
+		for _, url := range urls {
+			afterID = url.ID
+			result.Scanned++
+
+			if reason := s.rescanReason(ctx, url.TargetURL); reason != "" {
+				if s.quarantine(ctx, url, reason) {
+					result.Quarantined++
+				}
+			}
+		}
+
+		if len(urls) < rescanBatchSize {
+			log.Printf("[SHORTENER] Re-scan complete: %d scanned, %d quarantined", result.Scanned, result.Quarantined)
+			return result, nil
+		}
+	}
+}
+
+// rescanReason returns why a target should be quarantined, or "" if it
+// passes. Scanner failures and DNS lookup errors do not flag a link.
+func (s *service) rescanReason(ctx context.Context, target string) string {
+	var blocked *urlscan.BlockedError
+	if err := s.scanner.Scan(ctx, target); errors.As(err, &blocked) {
+		return blocked.Error()
+	}
+
+	err := s.guard.CheckURL(ctx, target)
+	if errors.Is(err, models.ErrSSRFDetected) && !errors.Is(err, ssrf.ErrUnresolvable) {
+		return err.Error()
+	}
+	return ""
+}
+
+// quarantine stops a flagged link from redirecting. It reports false if the
+// link changed state meanwhile or could not be updated.
+func (s *service) quarantine(ctx context.Context, url *models.URL, reason string) bool {
+	quarantined, err := s.repo.QuarantineURL(ctx, url.ID, reason)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to quarantine %s: %v", url.ShortCode, err)
+		return false
+	}
+
+	s.urlCache.Delete(url.ShortCode)
+	s.audit(ctx, models.AuditURLQuarantine, quarantined, diffURL(url, quarantined))
+	s.events.emit(Event{Type: EventURLQuarantined, URLID: quarantined.ID, ShortCode: quarantined.ShortCode})
+
+	log.Printf("[SHORTENER] WARNING: Quarantined %s: %s", url.ShortCode, reason)
+	return true
+}
+
+// GetQuarantinedURLs returns quarantined links, most recent first
+func (s *service) GetQuarantinedURLs(ctx context.Context, limit int) ([]*models.URL, error) {
+	if limit <= 0 {
+		limit = defaultQuarantineLimit
+	}
+	if limit > maxQuarantineLimit {
+		limit = maxQuarantineLimit
+	}
+
+	urls, err := s.repo.GetQuarantinedURLs(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantined URLs: %w", err)
+	}
+	if urls == nil {
+		urls = []*models.URL{}
+	}
+	return urls, nil
+}
+
+// ReleaseURL lifts a quarantine, for example after a false positive. A
+// target that still matches a blocking rule is quarantined again by the
+// next re-scan unless it is allowlisted.
+func (s *service) ReleaseURL(ctx context.Context, shortCode string) (*models.URL, error) {
+	log.Printf("[SHORTENER] Releasing URL from quarantine: %s", shortCode)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if !url.IsQuarantined() {
+		return nil, ErrURLNotQuarantined
+	}
+	before := *url
+
+	released, err := s.repo.ReleaseURL(ctx, shortCode)
+	if err != nil {
+		// Released concurrently
+		return nil, ErrURLNotQuarantined
+	}
+
+	s.urlCache.Delete(shortCode)
+	s.audit(ctx, models.AuditURLRelease, released, diffURL(&before, released))
+	s.events.emit(Event{Type: EventURLReleased, URLID: released.ID, ShortCode: shortCode})
+
+	log.Printf("[SHORTENER] SUCCESS: Released URL: %s", shortCode)
+	return released, nil
+}
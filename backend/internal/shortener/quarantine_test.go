@@ -0,0 +1,214 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+)
+
+// setupRescanService creates a service whose blocklist starts empty, with
+// links to good.test and turned-bad.test
+func setupRescanService(t *testing.T) (Service, *MockRepository, string) {
+	t.Helper()
+
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(blocklist, nil, 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	repo := NewMockRepository()
+	config := DefaultConfig()
+	config.URLBlocklists = []string{blocklist}
+	svc := NewService(repo, config)
+
+	for code, target := range map[string]string{"good": "https://good.test/", "turned": "https://turned-bad.test/page"} {
+		if _, err := svc.CreateShortURL(context.Background(), &CreateURLRequest{URL: target, CustomCode: code}); err != nil {
+			t.Fatalf("CreateShortURL(%s) unexpected error: %v", target, err)
+		}
+	}
+	return svc, repo, blocklist
+}
+
+// blockDomain adds a domain to the blocklist and reloads it
+func blockDomain(t *testing.T, svc Service, blocklist, domain string) {
+	t.Helper()
+
+	if err := os.WriteFile(blocklist, []byte(domain+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to update blocklist: %v", err)
+	}
+	if err := svc.ReloadURLScanners(); err != nil {
+		t.Fatalf("ReloadURLScanners() unexpected error: %v", err)
+	}
+}
+
+func TestRescanURLs(t *testing.T) {
+	svc, repo, blocklist := setupRescanService(t)
+	ctx := context.Background()
+
+	var events []string
+	svc.OnEvent(func(e Event) { events = append(events, e.Type+"/"+e.ShortCode) })
+
+	// Nothing is flagged until the lists change
+	result, err := svc.RescanURLs(ctx)
+	if err != nil {
+		t.Fatalf("RescanURLs() unexpected error: %v", err)
+	}
+	if result.Scanned != 2 || result.Quarantined != 0 {
+		t.Errorf("RescanURLs() = %+v, expected 2 scanned, none quarantined", result)
+	}
+
+	blockDomain(t, svc, blocklist, "turned-bad.test")
+
+	result, err = svc.RescanURLs(ctx)
+	if err != nil {
+		t.Fatalf("RescanURLs() unexpected error: %v", err)
+	}
+	if result.Scanned != 2 || result.Quarantined != 1 {
+		t.Errorf("RescanURLs() = %+v, expected 2 scanned, 1 quarantined", result)
+	}
+
+	// Quarantine is its own state: the link stays active but stops redirecting
+	if _, err := svc.GetURLForRedirect(ctx, "turned", nil); !errors.Is(err, ErrURLQuarantined) {
+		t.Errorf("GetURLForRedirect() error = %v, expected ErrURLQuarantined", err)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "good", nil); err != nil {
+		t.Errorf("GetURLForRedirect() of clean link unexpected error: %v", err)
+	}
+
+	quarantined, err := svc.GetQuarantinedURLs(ctx, 0)
+	if err != nil {
+		t.Fatalf("GetQuarantinedURLs() unexpected error: %v", err)
+	}
+	if len(quarantined) != 1 || quarantined[0].ShortCode != "turned" || !quarantined[0].IsActive {
+		t.Fatalf("GetQuarantinedURLs() = %+v, expected the active turned link", quarantined)
+	}
+	if reason := quarantined[0].QuarantineReason; reason == nil || !strings.Contains(*reason, "turned-bad.test") {
+		t.Errorf("QuarantineReason = %v, expected the matched rule", quarantined[0].QuarantineReason)
+	}
+
+	// Quarantined links are not scanned again
+	if result, _ := svc.RescanURLs(ctx); result.Scanned != 1 || result.Quarantined != 0 {
+		t.Errorf("Second RescanURLs() = %+v, expected 1 scanned, none quarantined", result)
+	}
+
+	if _, err := svc.ReleaseURL(ctx, "turned"); err != nil {
+		t.Fatalf("ReleaseURL() unexpected error: %v", err)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "turned", nil); err != nil {
+		t.Errorf("GetURLForRedirect() after release unexpected error: %v", err)
+	}
+	if _, err := svc.ReleaseURL(ctx, "turned"); err != ErrURLNotQuarantined {
+		t.Errorf("Second ReleaseURL() error = %v, expected ErrURLNotQuarantined", err)
+	}
+
+	expected := []string{"url.quarantined/turned", "url.released/turned"}
+	if len(events) != len(expected) || events[0] != expected[0] || events[1] != expected[1] {
+		t.Errorf("Events = %v, expected %v", events, expected)
+	}
+
+	var actions []string
+	for _, e := range repo.auditLog {
+		if e.ShortCode == "turned" {
+			actions = append(actions, e.Action)
+		}
+	}
+	if len(actions) != 3 || actions[1] != models.AuditURLQuarantine || actions[2] != models.AuditURLRelease {
+		t.Errorf("Audit actions = %v, expected create, quarantine, release", actions)
+	}
+}
+
+func TestQuarantineHandlers(t *testing.T) {
+	svc, _, blocklist := setupRescanService(t)
+	blockDomain(t, svc, blocklist, "turned-bad.test")
+	if _, err := svc.RescanURLs(context.Background()); err != nil {
+		t.Fatalf("RescanURLs() unexpected error: %v", err)
+	}
+
+	router := chi.NewRouter()
+	NewHandler(svc).RegisterRoutes(router)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"redirect quarantined link", "GET", "/turned", http.StatusForbidden},
+		{"list", "GET", "/api/admin/quarantine?limit=10", http.StatusOK},
+		{"bad limit", "GET", "/api/admin/quarantine?limit=abc", http.StatusBadRequest},
+		{"release clean link", "POST", "/api/admin/quarantine/good/release", http.StatusConflict},
+		{"release unknown link", "POST", "/api/admin/quarantine/nonexistent/release", http.StatusNotFound},
+		{"release", "POST", "/api/admin/quarantine/turned/release", http.StatusOK},
+		{"redirect released link", "GET", "/turned", http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d: %s", tt.name, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+}
+
+func TestRescanURLs_Rebinding(t *testing.T) {
+	resolver := ssrf.StaticResolver{
+		"rebind.test": {netip.MustParseAddr("93.184.216.34")},
+		"stable.test": {netip.MustParseAddr("93.184.216.35")},
+		"flaky.test":  {netip.MustParseAddr("93.184.216.36")},
+	}
+	config := DefaultConfig()
+	config.SSRFStrict = true
+	config.Resolver = resolver
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	// Strict mode rejects unresolvable hosts and numeric internal hosts
+	for _, target := range []string{"https://missing.test/", "http://2130706433/", "http://0x7f.1/", "http://[::ffff:a00:1]/"} {
+		if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: target}); !errors.Is(err, models.ErrSSRFDetected) {
+			t.Errorf("CreateShortURL(%s) error = %v, expected ErrSSRFDetected", target, err)
+		}
+	}
+
+	for code, target := range map[string]string{"rebind": "https://rebind.test/", "stable": "https://stable.test/", "flaky": "https://flaky.test/"} {
+		if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: target, CustomCode: code}); err != nil {
+			t.Fatalf("CreateShortURL(%s) unexpected error: %v", target, err)
+		}
+	}
+
+	// The host now points inside the network and DNS for another fails
+	resolver["rebind.test"] = []netip.Addr{netip.MustParseAddr("64:ff9b::a9fe:a9fe")}
+	delete(resolver, "flaky.test")
+
+	// Updates re-check the target even when it is unchanged
+	active := true
+	if _, err := svc.UpdateURL(ctx, "rebind", &UpdateURLRequest{IsActive: &active}); !errors.Is(err, models.ErrSSRFDetected) {
+		t.Errorf("UpdateURL() error = %v, expected ErrSSRFDetected", err)
+	}
+
+	result, err := svc.RescanURLs(ctx)
+	if err != nil {
+		t.Fatalf("RescanURLs() unexpected error: %v", err)
+	}
+	if result.Scanned != 3 || result.Quarantined != 1 {
+		t.Errorf("RescanURLs() = %+v, expected 3 scanned, 1 quarantined", result)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "rebind", nil); !errors.Is(err, ErrURLQuarantined) {
+		t.Errorf("GetURLForRedirect() error = %v, expected ErrURLQuarantined", err)
+	}
+	if _, err := svc.GetURLForRedirect(ctx, "flaky", nil); err != nil {
+		t.Errorf("GetURLForRedirect() of unresolvable link unexpected error: %v", err)
+	}
+}
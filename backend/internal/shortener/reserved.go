@@ -0,0 +1,303 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/internal/database"
+	"backend/internal/models"
+)
+
+const (
+	// How long the reservations of other instances may go unseen
+	reservedCodesRefresh = time.Minute
+
+	reservedCodesTimeout = 5 * time.Second
+)
+
+// reservation is a reserved code or compiled pattern
+type reservation struct {
+	*models.ReservedCode
+	regex *regexp.Regexp
+}
+
+// newReservation validates a reservation and compiles its pattern
+func newReservation(reserved *models.ReservedCode) (*reservation, error) {
+	if reserved.Kind == "" {
+		reserved.Kind = models.ReservationExact
+	}
+	if reserved.Code == "" || len(reserved.Code) > models.MaxCustomCodeLength*2 {
+		return nil, fmt.Errorf("%w: reserved code must be between 1 and %d characters", ErrInvalidRequest, models.MaxCustomCodeLength*2)
+	}
+
+	r := &reservation{ReservedCode: reserved}
+	switch reserved.Kind {
+	case models.ReservationExact:
+		if strings.ContainsAny(reserved.Code, "/?#% ") {
+			return nil, fmt.Errorf("%w: reserved code %q is not a path segment", ErrInvalidRequest, reserved.Code)
+		}
+	case models.ReservationGlob:
+		if _, err := path.Match(reserved.Code, ""); err != nil {
+			return nil, fmt.Errorf("%w: invalid glob %q: %v", ErrInvalidRequest, reserved.Code, err)
+		}
+	case models.ReservationRegex:
+		regex, err := regexp.Compile(`(?i)^(?:` + reserved.Code + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regex %q: %v", ErrInvalidRequest, reserved.Code, err)
+		}
+		r.regex = regex
+	default:
+		return nil, fmt.Errorf("%w: unknown reservation kind %q", ErrInvalidRequest, reserved.Kind)
+	}
+	return r, nil
+}
+
+// matches reports whether the reservation covers a lowercase code
+func (r *reservation) matches(code string) bool {
+	switch r.Kind {
+	case models.ReservationGlob:
+		matched, _ := path.Match(strings.ToLower(r.Code), code)
+		return matched
+	case models.ReservationRegex:
+		return r.regex.MatchString(code)
+	}
+	return strings.ToLower(r.Code) == code
+}
+
+// reservedSet is an immutable snapshot of every reservation: built-in codes,
+// registered routes and those stored in the database. Changes swap in a new
+// set, so checks never query the database or take a lock.
+type reservedSet struct {
+	exact    map[string]*reservation // By lowercase code
+	patterns []*reservation
+	all      []*models.ReservedCode
+	stored   []*models.ReservedCode
+	routes   []string
+	loadedAt time.Time
+}
+
+func newReservedSet(stored []*models.ReservedCode, routes []string, loadedAt time.Time) *reservedSet {
+	set := &reservedSet{
+		exact:    make(map[string]*reservation),
+		stored:   stored,
+		routes:   routes,
+		loadedAt: loadedAt,
+	}
+
+	for _, code := range models.DefaultReservedCodes {
+		set.add(&models.ReservedCode{Code: code, Kind: models.ReservationExact, Reason: "system", Source: models.ReservationSourceBuiltin})
+	}
+	for _, route := range routes {
+		set.add(&models.ReservedCode{Code: route, Kind: models.ReservationExact, Reason: "route", Source: models.ReservationSourceRoute})
+	}
+	for _, reserved := range stored {
+		set.add(reserved)
+	}
+	return set
+}
+
+// add compiles a reservation into the set. A stored reservation of a code
+// that is already reserved replaces the built-in one.
+func (set *reservedSet) add(reserved *models.ReservedCode) {
+	r, err := newReservation(reserved)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Ignoring reservation %q: %v", reserved.Code, err)
+		return
+	}
+
+	if r.Kind == models.ReservationExact {
+		key := strings.ToLower(r.Code)
+		if existing, ok := set.exact[key]; ok {
+			set.remove(existing.ReservedCode)
+		}
+		set.exact[key] = r
+	} else {
+		set.patterns = append(set.patterns, r)
+	}
+	set.all = append(set.all, reserved)
+}
+
+func (set *reservedSet) remove(reserved *models.ReservedCode) {
+	for i, existing := range set.all {
+		if existing == reserved {
+			set.all = append(set.all[:i:i], set.all[i+1:]...)
+			return
+		}
+	}
+}
+
+// match returns the reservation covering a code, or nil
+func (set *reservedSet) match(code string) *models.ReservedCode {
+	code = strings.ToLower(code)
+	if r, ok := set.exact[code]; ok {
+		return r.ReservedCode
+	}
+	for _, r := range set.patterns {
+		if r.matches(code) {
+			return r.ReservedCode
+		}
+	}
+	return nil
+}
+
+// find returns the reservation of this code, in any case, or of exactly this
+// pattern, or nil
+func (set *reservedSet) find(code string) *models.ReservedCode {
+	for _, reserved := range set.all {
+		if reserved.Code == code || (reserved.Kind == models.ReservationExact && strings.EqualFold(reserved.Code, code)) {
+			return reserved
+		}
+	}
+	return nil
+}
+
+// reservedCodes returns the current reservations, reloading them from the
+// database once they are older than reservedCodesRefresh. A failed reload
+// keeps the previous set; the schema trigger still rejects exact codes.
+func (s *service) reservedCodes(ctx context.Context) *reservedSet {
+	set := s.reserved.Load()
+	if time.Since(set.loadedAt) > reservedCodesRefresh && s.reservedLoad.TryLock() {
+		defer s.reservedLoad.Unlock()
+		if err := s.loadReservedCodes(ctx); err != nil {
+			log.Printf("[SHORTENER] WARNING: Failed to reload reserved codes: %v", err)
+		}
+		set = s.reserved.Load()
+	}
+	return set
+}
+
+// loadReservedCodes swaps in the reservations stored in the database. The
+// caller holds s.reservedLoad.
+func (s *service) loadReservedCodes(ctx context.Context) error {
+	stored, err := s.repo.ListReservedCodes(ctx)
+	if err != nil {
+		return err
+	}
+	s.reserved.Store(newReservedSet(stored, s.reserved.Load().routes, time.Now()))
+	return nil
+}
+
+// isReservedCode reports whether a code is reserved
+func (s *service) isReservedCode(ctx context.Context, code string) bool {
+	return s.reservedCodes(ctx).match(code) != nil
+}
+
+// ReserveRoutes reserves the first path segments of registered routes, which
+// would shadow links of the same code
+func (s *service) ReserveRoutes(routes []string) {
+	s.reservedLoad.Lock()
+	defer s.reservedLoad.Unlock()
+
+	set := s.reserved.Load()
+	s.reserved.Store(newReservedSet(set.stored, routes, set.loadedAt))
+	log.Printf("[SHORTENER] Reserved %d route codes: %s", len(routes), strings.Join(routes, ", "))
+}
+
+// GetReservedCodes returns every reservation: built-in, route and stored ones
+func (s *service) GetReservedCodes(ctx context.Context) ([]*models.ReservedCode, error) {
+	log.Printf("[SHORTENER] Listing reserved codes")
+
+	s.reservedLoad.Lock()
+	defer s.reservedLoad.Unlock()
+
+	if err := s.loadReservedCodes(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list reserved codes: %w", err)
+	}
+	return s.reserved.Load().all, nil
+}
+
+// ReserveCode reserves a code, or every code matching a glob or regex, so it
+// is never used for a link. Codes of existing links cannot be reserved.
+func (s *service) ReserveCode(ctx context.Context, req *ReserveCodeRequest) (*models.ReservedCode, error) {
+	log.Printf("[SHORTENER] Reserving code: %s (reason: %s)", req.Code, req.Reason)
+
+	if req.Reason == "" {
+		return nil, fmt.Errorf("%w: a reason is required", ErrInvalidRequest)
+	}
+	reserved := &models.ReservedCode{Code: req.Code, Kind: req.Kind, Reason: req.Reason, Description: req.Description}
+	if _, err := newReservation(reserved); err != nil {
+		return nil, err
+	}
+
+	s.reservedLoad.Lock()
+	defer s.reservedLoad.Unlock()
+
+	if existing := s.reserved.Load().find(reserved.Code); existing != nil {
+		return nil, fmt.Errorf("%w: %s (%s)", ErrAlreadyReserved, reserved.Code, existing.Source)
+	}
+	if reserved.Kind == models.ReservationExact {
+		if _, err := s.repo.GetURLByShortCode(ctx, reserved.Code); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrCustomCodeTaken, reserved.Code)
+		}
+	}
+
+	if err := s.repo.AddReservedCode(ctx, reserved); err != nil {
+		if errors.Is(err, database.ErrReservedCodeExists) {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyReserved, reserved.Code)
+		}
+		return nil, fmt.Errorf("failed to reserve code: %w", err)
+	}
+
+	s.recordAudit(ctx, &models.AuditEntry{
+		ShortCode: reserved.Code,
+		Action:    models.AuditReservedCodeAdd,
+		Changes: map[string]models.FieldChange{
+			"kind":        {After: reserved.Kind},
+			"reason":      {After: reserved.Reason},
+			"description": {After: reserved.Description},
+		},
+	})
+
+	if err := s.loadReservedCodes(ctx); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to reload reserved codes: %v", err)
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Reserved %s code: %s", reserved.Kind, reserved.Code)
+	return reserved, nil
+}
+
+// UnreserveCode removes a stored reservation. Built-in and route
+// reservations cannot be removed.
+func (s *service) UnreserveCode(ctx context.Context, code string) error {
+	log.Printf("[SHORTENER] Removing reserved code: %s", code)
+
+	s.reservedLoad.Lock()
+	defer s.reservedLoad.Unlock()
+
+	removed, err := s.repo.RemoveReservedCode(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to remove reserved code: %w", err)
+	}
+	if !removed {
+		if existing := s.reserved.Load().find(code); existing != nil && existing.Source != models.ReservationSourceAdmin {
+			return fmt.Errorf("%w: %s (%s)", ErrBuiltinReserved, code, existing.Source)
+		}
+		return ErrNotReserved
+	}
+
+	var before *models.ReservedCode
+	if before = s.reserved.Load().find(code); before == nil {
+		before = &models.ReservedCode{Code: code}
+	}
+	s.recordAudit(ctx, &models.AuditEntry{
+		ShortCode: code,
+		Action:    models.AuditReservedCodeRemove,
+		Changes: map[string]models.FieldChange{
+			"kind":   {Before: before.Kind},
+			"reason": {Before: before.Reason},
+		},
+	})
+
+	if err := s.loadReservedCodes(ctx); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to reload reserved codes: %v", err)
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Removed reserved code: %s", code)
+	return nil
+}
@@ -0,0 +1,194 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+	"backend/internal/ssrf"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestReservedSet_Match(t *testing.T) {
+	set := newReservedSet([]*models.ReservedCode{
+		{Code: "Brand", Kind: models.ReservationExact, Source: models.ReservationSourceAdmin, Reason: "trademark"},
+		{Code: "promo-*", Kind: models.ReservationGlob, Source: models.ReservationSourceAdmin, Reason: "campaigns"},
+		{Code: `v\d+`, Kind: models.ReservationRegex, Source: models.ReservationSourceAdmin, Reason: "versions"},
+		{Code: "[bad", Kind: models.ReservationGlob, Source: models.ReservationSourceAdmin, Reason: "ignored"},
+	}, []string{"health"}, time.Now())
+
+	tests := []struct {
+		code   string
+		source string // Empty if the code is free
+	}{
+		{"brand", models.ReservationSourceAdmin},
+		{"BRAND", models.ReservationSourceAdmin},
+		{"brands", ""},
+		{"promo-spring", models.ReservationSourceAdmin},
+		{"PROMO-x", models.ReservationSourceAdmin},
+		{"promo", ""},
+		{"v2", models.ReservationSourceAdmin},
+		{"V10", models.ReservationSourceAdmin},
+		{"v2beta", ""},
+		{"api", models.ReservationSourceBuiltin},
+		{"Admin", models.ReservationSourceBuiltin},
+		{"health", models.ReservationSourceRoute},
+		{"mylink", ""},
+	}
+
+	for _, tt := range tests {
+		reserved := set.match(tt.code)
+		switch {
+		case tt.source == "" && reserved != nil:
+			t.Errorf("match(%q) = %s reservation %q, expected none", tt.code, reserved.Source, reserved.Code)
+		case tt.source != "" && (reserved == nil || reserved.Source != tt.source):
+			t.Errorf("match(%q) = %+v, expected a %s reservation", tt.code, reserved, tt.source)
+		}
+	}
+
+	if len(set.all) != len(models.DefaultReservedCodes)+4 {
+		t.Errorf("Set has %d reservations, expected the invalid glob to be ignored", len(set.all))
+	}
+}
+
+func TestReserveCode(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CustomCode: "existing"}); err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  *ReserveCodeRequest
+		err  error
+	}{
+		{"exact code", &ReserveCodeRequest{Code: "brand", Reason: "trademark"}, nil},
+		{"glob", &ReserveCodeRequest{Code: "promo-*", Kind: models.ReservationGlob, Reason: "campaigns"}, nil},
+		{"regex", &ReserveCodeRequest{Code: `[0-9]+`, Kind: models.ReservationRegex, Reason: "numbers"}, nil},
+		{"duplicate in another case", &ReserveCodeRequest{Code: "BRAND", Reason: "trademark"}, ErrAlreadyReserved},
+		{"built-in code", &ReserveCodeRequest{Code: "api", Reason: "api"}, ErrAlreadyReserved},
+		{"code of a link", &ReserveCodeRequest{Code: "existing", Reason: "taken"}, ErrCustomCodeTaken},
+		{"missing reason", &ReserveCodeRequest{Code: "other"}, ErrInvalidRequest},
+		{"invalid glob", &ReserveCodeRequest{Code: "[a-", Kind: models.ReservationGlob, Reason: "bad"}, ErrInvalidRequest},
+		{"invalid regex", &ReserveCodeRequest{Code: "(", Kind: models.ReservationRegex, Reason: "bad"}, ErrInvalidRequest},
+		{"unknown kind", &ReserveCodeRequest{Code: "other", Kind: "prefix", Reason: "bad"}, ErrInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.ReserveCode(ctx, tt.req)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ReserveCode() error = %v, expected %v", err, tt.err)
+			}
+		})
+	}
+
+	// Reservations apply to custom codes at once
+	for _, code := range []string{"Brand", "promo-spring", "2024", "API"} {
+		if err := svc.ValidateCustomCode(ctx, code); !errors.Is(err, models.ErrReservedCode) {
+			t.Errorf("ValidateCustomCode(%q) error = %v, expected ErrReservedCode", code, err)
+		}
+	}
+	if err := svc.ValidateCustomCode(ctx, "promo"); err != nil {
+		t.Errorf("ValidateCustomCode(promo) unexpected error: %v", err)
+	}
+
+	reserved, err := svc.GetReservedCodes(ctx)
+	if err != nil || len(reserved) != len(models.DefaultReservedCodes)+3 {
+		t.Errorf("GetReservedCodes() = %d reservations (%v), expected the built-in codes and 3 more", len(reserved), err)
+	}
+}
+
+func TestUnreserveCode(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	repo := NewMockRepository()
+	svc := NewService(repo, config)
+	ctx := context.Background()
+
+	svc.ReserveRoutes([]string{"health"})
+	if _, err := svc.ReserveCode(ctx, &ReserveCodeRequest{Code: "promo-*", Kind: models.ReservationGlob, Reason: "campaigns"}); err != nil {
+		t.Fatalf("ReserveCode() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		code string
+		err  error
+	}{
+		{"promo-*", nil},
+		{"promo-*", ErrNotReserved},
+		{"api", ErrBuiltinReserved},
+		{"health", ErrBuiltinReserved},
+		{"unknown", ErrNotReserved},
+	}
+
+	for _, tt := range tests {
+		if err := svc.UnreserveCode(ctx, tt.code); !errors.Is(err, tt.err) {
+			t.Errorf("UnreserveCode(%q) error = %v, expected %v", tt.code, err, tt.err)
+		}
+	}
+
+	if err := svc.ValidateCustomCode(ctx, "promo-spring"); err != nil {
+		t.Errorf("ValidateCustomCode(promo-spring) after unreserving unexpected error: %v", err)
+	}
+
+	removed, _ := svc.GetAuditLog(ctx, &AuditRequest{Action: models.AuditReservedCodeRemove})
+	if len(removed.Entries) != 1 || removed.Entries[0].ShortCode != "promo-*" {
+		t.Errorf("Removed reservation entries = %+v", removed.Entries)
+	}
+}
+
+func TestGenerateUniqueCode_SkipsReserved(t *testing.T) {
+	config := DefaultConfig()
+	config.Resolver = ssrf.StaticResolver{}
+	config.CodeAlphabet = CodeAlphabetWords
+	config.MaxRetries = 10
+	svc := NewService(NewMockRepository(), config)
+	ctx := context.Background()
+
+	// Word codes end in a number from 10 to 99
+	if _, err := svc.ReserveCode(ctx, &ReserveCodeRequest{Code: "*-1?", Kind: models.ReservationGlob, Reason: "test"}); err != nil {
+		t.Fatalf("ReserveCode() unexpected error: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/words"})
+		if err != nil {
+			t.Fatalf("CreateShortURL() unexpected error: %v", err)
+		}
+		if url.ShortCode[len(url.ShortCode)-2] == '1' {
+			t.Errorf("CreateShortURL() = %q, expected a code outside the reserved pattern", url.ShortCode)
+		}
+	}
+}
+
+func TestHandler_ReserveRouteCodes(t *testing.T) {
+	svc := NewService(NewMockRepository(), DefaultConfig())
+	handler := NewHandler(svc)
+
+	r := chi.NewRouter()
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/", noop)
+	r.Get("/health", noop)
+	r.Get("/db-test", noop)
+	handler.RegisterRoutes(r)
+
+	if err := handler.ReserveRouteCodes(r); err != nil {
+		t.Fatalf("ReserveRouteCodes() unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, code := range []string{"health", "db-test", "api"} {
+		if err := svc.ValidateCustomCode(ctx, code); !errors.Is(err, models.ErrReservedCode) {
+			t.Errorf("ValidateCustomCode(%q) error = %v, expected ErrReservedCode", code, err)
+		}
+	}
+}
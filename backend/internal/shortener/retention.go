@@ -0,0 +1,158 @@
+package shortener
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"backend/internal/database"
+)
+
+// Monthly click_events partitions created ahead of the current month, so
+// clicks never land in the default partition under normal operation
+const clickPartitionsAhead = 2
+
+// Maintenance manages click_events partitions and purges raw click events
+// older than the retention period
+type Maintenance struct {
+	repo      database.URLRepository
+	retention time.Duration
+}
+
+// NewMaintenance creates a maintenance runner. A retention of 0 keeps raw
+// click events forever.
+func NewMaintenance(repo database.URLRepository, retention time.Duration) *Maintenance {
+	return &Maintenance{repo: repo, retention: retention}
+}
+
+// RunOnce creates upcoming partitions and purges expired raw click events
+func (m *Maintenance) RunOnce(ctx context.Context, now time.Time) error {
+	if err := m.ensurePartitions(ctx, now); err != nil {
+		return err
+	}
+	if m.retention <= 0 {
+		return nil
+	}
+	return m.purge(ctx, now)
+}
+
+// ensurePartitions creates the partitions for the current and upcoming months
+func (m *Maintenance) ensurePartitions(ctx context.Context, now time.Time) error {
+	month := startOfMonth(now)
+	for i := 0; i <= clickPartitionsAhead; i++ {
+		if _, err := m.repo.EnsureClickPartition(ctx, month.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purge drops partitions that are entirely expired and deletes the expired
+// rows left in the partially expired month
+func (m *Maintenance) purge(ctx context.Context, now time.Time) error {
+	cutoff, err := m.purgeCutoff(ctx, now)
+	if err != nil {
+		return err
+	}
+	if cutoff.IsZero() {
+		return nil
+	}
+
+	months, err := m.repo.ListClickPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, month := range months {
+		if month.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		if err := m.repo.DropClickPartition(ctx, month); err != nil {
+			return err
+		}
+	}
+
+	purged, err := m.repo.PurgeClickEventsBefore(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("[SHORTENER] Purged %d click events before %s", purged, cutoff.Format(time.RFC3339))
+	}
+
+	return m.recordHorizon(ctx, cutoff)
+}
+
+// purgeCutoff returns the instant before which raw click events may be
+// deleted. Raw events are only purged once both rollups have compacted them,
+// so a lagging rollup holds retention back rather than losing aggregates.
+// The zero time means nothing may be purged yet.
+func (m *Maintenance) purgeCutoff(ctx context.Context, now time.Time) (time.Time, error) {
+	cutoff := now.Add(-m.retention).UTC().Truncate(time.Hour)
+
+	for _, rollup := range []string{database.RollupHourly, database.RollupDaily} {
+		watermark, err := m.repo.GetRollupWatermark(ctx, rollup)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if watermark.IsZero() {
+			log.Printf("[SHORTENER] WARNING: Skipping click retention, %s rollup has not run yet", rollup)
+			return time.Time{}, nil
+		}
+		if watermark.Before(cutoff) {
+			log.Printf("[SHORTENER] WARNING: Click retention held back to %s by %s rollup",
+				watermark.Format(time.RFC3339), rollup)
+			cutoff = watermark.UTC()
+		}
+	}
+
+	return cutoff, nil
+}
+
+// recordHorizon stores the purge cutoff so backfills know where raw events
+// end. It never moves backwards, since purged events cannot return.
+func (m *Maintenance) recordHorizon(ctx context.Context, cutoff time.Time) error {
+	horizon, err := m.repo.GetRollupWatermark(ctx, database.RetentionWatermark)
+	if err != nil {
+		return err
+	}
+	if !cutoff.After(horizon) {
+		return nil
+	}
+	return m.repo.SetRollupWatermark(ctx, database.RetentionWatermark, cutoff)
+}
+
+// startOfMonth returns the first instant of the UTC month containing t
+func startOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// maintenanceWorker manages partitions and retention at startup and then
+// periodically until shutdown
+func (s *service) maintenanceWorker(interval time.Duration) {
+	defer s.wg.Done()
+	log.Printf("[SHORTENER] Maintenance worker started (every %s, retention %s)", interval, s.config.ClickRetention)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.runMaintenance(interval)
+
+		select {
+		case <-s.shutdown:
+			log.Printf("[SHORTENER] Maintenance worker shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runMaintenance runs one partition and retention pass, unless another
+// instance is running one. Partitions are created and dropped by DDL that is
+// not safe to run concurrently.
+func (s *service) runMaintenance(timeout time.Duration) bool {
+	return s.runExclusive("Maintenance", database.LockPartitionMaintenance, timeout, func(ctx context.Context) error {
+		return s.maintenance.RunOnce(ctx, time.Now())
+	})
+}
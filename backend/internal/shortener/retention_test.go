@@ -0,0 +1,154 @@
+package shortener
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/database"
+)
+
+func TestMaintenance_EnsuresPartitions(t *testing.T) {
+	repo := NewMockRepository()
+	now := time.Date(2025, 11, 20, 8, 0, 0, 0, time.UTC)
+
+	if err := NewMaintenance(repo, 0).RunOnce(context.Background(), now); err != nil {
+		t.Fatalf("RunOnce() unexpected error: %v", err)
+	}
+
+	expected := []time.Time{
+		time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if len(repo.partitions) != len(expected) {
+		t.Fatalf("Created %d partitions, expected %d", len(repo.partitions), len(expected))
+	}
+	for i, month := range expected {
+		if !repo.partitions[i].Equal(month) {
+			t.Errorf("Partition %d = %v, expected %v", i, repo.partitions[i], month)
+		}
+	}
+
+	if !repo.purgedBefore.IsZero() {
+		t.Errorf("RunOnce() without retention purged clicks before %v", repo.purgedBefore)
+	}
+}
+
+func TestMaintenance_Retention(t *testing.T) {
+	now := time.Date(2025, 11, 20, 8, 30, 0, 0, time.UTC)
+	retention := 90 * 24 * time.Hour
+	caughtUp := map[string]time.Time{
+		database.RollupHourly: time.Date(2025, 11, 20, 8, 0, 0, 0, time.UTC),
+		database.RollupDaily:  time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name            string
+		watermarks      map[string]time.Time
+		expectedCutoff  time.Time
+		expectedDropped []time.Time
+	}{
+		{
+			name:           "rollups caught up",
+			watermarks:     caughtUp,
+			expectedCutoff: time.Date(2025, 8, 22, 8, 0, 0, 0, time.UTC),
+			expectedDropped: []time.Time{
+				time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "lagging rollup holds retention back",
+			watermarks: map[string]time.Time{
+				database.RollupHourly: caughtUp[database.RollupHourly],
+				database.RollupDaily:  time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC),
+			},
+			expectedCutoff:  time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC),
+			expectedDropped: []time.Time{time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "rollup never ran",
+			watermarks: map[string]time.Time{
+				database.RollupDaily: caughtUp[database.RollupDaily],
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRepository()
+			for rollup, watermark := range tt.watermarks {
+				repo.watermarks[rollup] = watermark
+			}
+			for month := 6; month <= 8; month++ {
+				repo.partitions = append(repo.partitions, time.Date(2025, time.Month(month), 1, 0, 0, 0, 0, time.UTC))
+			}
+
+			if err := NewMaintenance(repo, retention).RunOnce(context.Background(), now); err != nil {
+				t.Fatalf("RunOnce() unexpected error: %v", err)
+			}
+
+			if !repo.purgedBefore.Equal(tt.expectedCutoff) {
+				t.Errorf("Purged clicks before %v, expected %v", repo.purgedBefore, tt.expectedCutoff)
+			}
+			if got := repo.watermarks[database.RetentionWatermark]; !got.Equal(tt.expectedCutoff) {
+				t.Errorf("Retention watermark = %v, expected %v", got, tt.expectedCutoff)
+			}
+
+			if len(repo.droppedPartitions) != len(tt.expectedDropped) {
+				t.Fatalf("Dropped %v, expected %v", repo.droppedPartitions, tt.expectedDropped)
+			}
+			for i, month := range tt.expectedDropped {
+				if !repo.droppedPartitions[i].Equal(month) {
+					t.Errorf("Dropped partition %d = %v, expected %v", i, repo.droppedPartitions[i], month)
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenance_RetentionWatermarkNeverMovesBack(t *testing.T) {
+	repo := NewMockRepository()
+	horizon := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	repo.watermarks[database.RollupHourly] = time.Date(2025, 11, 20, 8, 0, 0, 0, time.UTC)
+	repo.watermarks[database.RollupDaily] = time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC)
+	repo.watermarks[database.RetentionWatermark] = horizon
+
+	// A longer retention than before must not pretend purged clicks are back
+	now := time.Date(2025, 11, 20, 8, 30, 0, 0, time.UTC)
+	if err := NewMaintenance(repo, 90*24*time.Hour).RunOnce(context.Background(), now); err != nil {
+		t.Fatalf("RunOnce() unexpected error: %v", err)
+	}
+
+	if got := repo.watermarks[database.RetentionWatermark]; !got.Equal(horizon) {
+		t.Errorf("Retention watermark = %v, expected %v", got, horizon)
+	}
+}
+
+func TestRunMaintenance_Exclusive(t *testing.T) {
+	repo := NewMockRepository()
+	svc := &service{repo: repo, maintenance: NewMaintenance(repo, 0)}
+	ctx := context.Background()
+
+	// Another instance is running maintenance, so this pass does nothing
+	acquired, err := repo.WithAdvisoryLock(ctx, database.LockPartitionMaintenance, func(ctx context.Context) error {
+		if svc.runMaintenance(time.Minute) {
+			t.Error("runMaintenance() ran while another instance held the lock")
+		}
+		return nil
+	})
+	if !acquired || err != nil {
+		t.Fatalf("WithAdvisoryLock() = %v, %v", acquired, err)
+	}
+	if len(repo.partitions) != 0 {
+		t.Errorf("Concurrent pass created %d partitions, expected none", len(repo.partitions))
+	}
+
+	if !svc.runMaintenance(time.Minute) {
+		t.Fatal("runMaintenance() skipped after the lock was released")
+	}
+	if len(repo.partitions) != clickPartitionsAhead+1 {
+		t.Errorf("Created %d partitions, expected %d", len(repo.partitions), clickPartitionsAhead+1)
+	}
+}
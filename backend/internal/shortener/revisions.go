@@ -0,0 +1,79 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"backend/internal/models"
+)
+
+// GetURLRevisions returns the revision history of a link, newest first.
+// Each update stores a new revision; deactivation, expiry and deletion are
+// lifecycle changes and do not.
+func (s *service) GetURLRevisions(ctx context.Context, shortCode string) (*RevisionsResponse, error) {
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+
+	revisions, err := s.repo.GetURLRevisions(ctx, url.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revisions: %w", err)
+	}
+
+	return &RevisionsResponse{
+		ShortCode:       shortCode,
+		CurrentRevision: url.Revision,
+		Revisions:       revisions,
+	}, nil
+}
+
+// RestoreRevision rolls a link back to the target and options of an earlier
+// revision. The rollback is stored as a new revision, so it can itself be
+// undone and clicks after it are attributed apart from the original.
+func (s *service) RestoreRevision(ctx context.Context, shortCode string, revision int) (*models.URL, error) {
+	log.Printf("[SHORTENER] Restoring %s to revision %d", shortCode, revision)
+
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, ErrURLNotFound
+	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+	if revision == url.Revision {
+		return nil, fmt.Errorf("%w: revision %d is the current revision", ErrInvalidRequest, revision)
+	}
+
+	target, err := s.repo.GetURLRevision(ctx, url.ID, revision)
+	if err != nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	// The old target may have been blocked or moved to an internal address since
+	if err := s.checkTarget(ctx, target.TargetURL); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+	before := *url
+
+	url.TargetURL = target.TargetURL
+	url.IsActive = target.IsActive
+	url.ExpiresAt = target.ExpiresAt
+
+	if err := s.repo.UpdateURL(ctx, url); err != nil {
+		return nil, fmt.Errorf("failed to restore revision: %w", err)
+	}
+
+	s.urlCache.Delete(shortCode)
+
+	changes := diffURL(&before, url)
+	changes["revision"] = models.FieldChange{Before: before.Revision, After: url.Revision}
+	s.audit(ctx, models.AuditURLRollback, url, changes)
+	if url.TargetURL != before.TargetURL {
+		s.schedulePreview(url)
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Restored %s to revision %d as revision %d", shortCode, revision, url.Revision)
+	return url, nil
+}
@@ -0,0 +1,141 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/internal/models"
+)
+
+func TestRestoreRevision(t *testing.T) {
+	repo := NewMockRepository()
+	svc := NewService(repo, DefaultConfig())
+	ctx := context.Background()
+
+	if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/v1", CustomCode: "versioned"}); err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+	if err := svc.RecordClick(ctx, "versioned", &ClickContext{IP: "203.0.113.1"}); err != nil {
+		t.Fatalf("RecordClick() unexpected error: %v", err)
+	}
+
+	updated, err := svc.UpdateURL(ctx, "versioned", &UpdateURLRequest{TargetURL: "https://example.com/v2"})
+	if err != nil {
+		t.Fatalf("UpdateURL() unexpected error: %v", err)
+	}
+	if updated.Revision != 2 {
+		t.Errorf("Revision after update = %d, expected 2", updated.Revision)
+	}
+	for i := 0; i < 2; i++ {
+		if err := svc.RecordClick(ctx, "versioned", &ClickContext{IP: "203.0.113.1"}); err != nil {
+			t.Fatalf("RecordClick() unexpected error: %v", err)
+		}
+	}
+
+	// Rolling back stores the old target as a new revision
+	restored, err := svc.RestoreRevision(ctx, "versioned", 1)
+	if err != nil {
+		t.Fatalf("RestoreRevision() unexpected error: %v", err)
+	}
+	if restored.Revision != 3 || restored.TargetURL != "https://example.com/v1" {
+		t.Errorf("RestoreRevision() = revision %d, target %s; expected revision 3, target v1",
+			restored.Revision, restored.TargetURL)
+	}
+
+	history, err := svc.GetURLRevisions(ctx, "versioned")
+	if err != nil {
+		t.Fatalf("GetURLRevisions() unexpected error: %v", err)
+	}
+	if history.CurrentRevision != 3 || len(history.Revisions) != 3 || history.Revisions[0].Revision != 3 {
+		t.Errorf("GetURLRevisions() = %+v, expected revisions 3, 2, 1", history)
+	}
+
+	// Clicks stay with the revision that was live when they happened
+	analytics, err := svc.GetAnalytics(ctx, "versioned", &AnalyticsRequest{})
+	if err != nil {
+		t.Fatalf("GetAnalytics() unexpected error: %v", err)
+	}
+	expected := []models.RevisionStat{{Revision: 2, Clicks: 2}, {Revision: 1, Clicks: 1}}
+	if len(analytics.RevisionStats) != len(expected) {
+		t.Fatalf("RevisionStats = %+v, expected %+v", analytics.RevisionStats, expected)
+	}
+	for i := range expected {
+		if analytics.RevisionStats[i] != expected[i] {
+			t.Errorf("RevisionStats[%d] = %+v, expected %+v", i, analytics.RevisionStats[i], expected[i])
+		}
+	}
+
+	last := repo.auditLog[len(repo.auditLog)-1]
+	if last.Action != models.AuditURLRollback || last.Changes["revision"].After != 3 {
+		t.Errorf("Last audit entry = %+v, expected a rollback to revision 3", last)
+	}
+}
+
+func TestRestoreRevisionErrors(t *testing.T) {
+	svc := NewService(NewMockRepository(), DefaultConfig())
+	ctx := context.Background()
+
+	for _, code := range []string{"current", "removed"} {
+		if _, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com", CustomCode: code}); err != nil {
+			t.Fatalf("CreateShortURL() unexpected error: %v", err)
+		}
+		if _, err := svc.UpdateURL(ctx, code, &UpdateURLRequest{TargetURL: "https://example.org"}); err != nil {
+			t.Fatalf("UpdateURL() unexpected error: %v", err)
+		}
+	}
+	if _, err := svc.DeleteURL(ctx, "removed"); err != nil {
+		t.Fatalf("DeleteURL() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		shortCode string
+		revision  int
+		expected  error
+	}{
+		{"unknown link", "nonexistent", 1, ErrURLNotFound},
+		{"unknown revision", "current", 7, ErrRevisionNotFound},
+		{"current revision", "current", 2, ErrInvalidRequest},
+		{"deleted link", "removed", 1, ErrURLDeleted},
+	}
+
+	for _, tt := range tests {
+		if _, err := svc.RestoreRevision(ctx, tt.shortCode, tt.revision); !errors.Is(err, tt.expected) {
+			t.Errorf("%s: RestoreRevision() error = %v, expected %v", tt.name, err, tt.expected)
+		}
+	}
+}
+
+func TestRevisionHandlers(t *testing.T) {
+	router, svc := setupTestRouter(t)
+
+	if _, err := svc.UpdateURL(context.Background(), "testcampaign", &UpdateURLRequest{TargetURL: "https://example.org"}); err != nil {
+		t.Fatalf("UpdateURL() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"list", "GET", "/api/urls/testcampaign/revisions", http.StatusOK},
+		{"list unknown link", "GET", "/api/urls/nonexistent/revisions", http.StatusNotFound},
+		{"bad revision", "POST", "/api/urls/testcampaign/revisions/abc/restore", http.StatusBadRequest},
+		{"unknown revision", "POST", "/api/urls/testcampaign/revisions/9/restore", http.StatusNotFound},
+		{"restore", "POST", "/api/urls/testcampaign/revisions/1/restore", http.StatusOK},
+		{"restore current", "POST", "/api/urls/testcampaign/revisions/3/restore", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.expectedStatus {
+			t.Errorf("%s: status = %d, expected %d: %s", tt.name, rec.Code, tt.expectedStatus, rec.Body.String())
+		}
+	}
+}
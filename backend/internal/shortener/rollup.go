@@ -0,0 +1,194 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/internal/database"
+)
+
+const (
+	// Clicks are recorded asynchronously, so an hour is only compacted once
+	// it has been closed for this long
+	rollupLateness = 5 * time.Minute
+
+	// Work done per pass, so a long backlog is caught up over several passes
+	maxRollupHoursPerRun = 48
+	maxRollupDaysPerRun  = 7
+)
+
+// Rollup compacts raw click events into the hourly and daily rollup tables
+type Rollup struct {
+	repo database.URLRepository
+}
+
+// NewRollup creates a rollup compactor
+func NewRollup(repo database.URLRepository) *Rollup {
+	return &Rollup{repo: repo}
+}
+
+// RunOnce compacts closed hours and days past the current watermarks
+func (r *Rollup) RunOnce(ctx context.Context, now time.Time) error {
+	if err := r.advance(ctx, database.RollupHourly, now, maxRollupHoursPerRun); err != nil {
+		return err
+	}
+	return r.advance(ctx, database.RollupDaily, now, maxRollupDaysPerRun)
+}
+
+// advance compacts up to maxUnits closed units after the watermark of one rollup
+func (r *Rollup) advance(ctx context.Context, rollup string, now time.Time, maxUnits int) error {
+	unit := database.RollupUnit(rollup)
+	closed := now.Add(-rollupLateness).UTC().Truncate(unit)
+
+	watermark, err := r.startingWatermark(ctx, rollup, closed)
+	if err != nil {
+		return err
+	}
+
+	start := watermark
+	for n := 0; n < maxUnits && watermark.Before(closed); n++ {
+		if err := r.compact(ctx, rollup, watermark); err != nil {
+			return err
+		}
+		watermark = watermark.Add(unit)
+
+		if err := r.repo.SetRollupWatermark(ctx, rollup, watermark); err != nil {
+			return err
+		}
+	}
+
+	if watermark.After(start) {
+		log.Printf("[ROLLUP] SUCCESS: %s rollup advanced from %s to %s",
+			rollup, start.Format(time.RFC3339), watermark.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// startingWatermark returns the stored watermark, or for a rollup that has
+// never run, the start of the unit holding the oldest click
+func (r *Rollup) startingWatermark(ctx context.Context, rollup string, closed time.Time) (time.Time, error) {
+	watermark, err := r.repo.GetRollupWatermark(ctx, rollup)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !watermark.IsZero() {
+		return watermark.UTC(), nil
+	}
+
+	earliest, err := r.repo.GetEarliestClickTime(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if earliest.IsZero() {
+		// No clicks yet, so everything up to now is trivially compacted
+		return closed, r.repo.SetRollupWatermark(ctx, rollup, closed)
+	}
+
+	log.Printf("[ROLLUP] Starting %s rollup from earliest click at %s", rollup, earliest.Format(time.RFC3339))
+	return earliest.UTC().Truncate(database.RollupUnit(rollup)), nil
+}
+
+// compact rolls up the unit of a rollup starting at start
+func (r *Rollup) compact(ctx context.Context, rollup string, start time.Time) error {
+	var rows int64
+	var err error
+
+	switch rollup {
+	case database.RollupHourly:
+		rows, err = r.repo.RollupHour(ctx, start)
+	case database.RollupDaily:
+		rows, err = r.repo.RollupDay(ctx, start)
+	default:
+		return fmt.Errorf("unknown rollup: %s", rollup)
+	}
+
+	if err != nil {
+		log.Printf("[ROLLUP] ERROR: Failed to compact %s rollup at %s: %v", rollup, start.Format(time.RFC3339), err)
+		return err
+	}
+
+	log.Printf("[ROLLUP] Compacted %s rollup at %s (%d rows)", rollup, start.Format(time.RFC3339), rows)
+	return nil
+}
+
+// Backfill recomputes both rollups for every closed unit overlapping [from, to).
+// When the recomputed range reaches the current watermark, the watermark is
+// moved forward past it; it never moves backwards. Units that may have lost
+// raw events to retention are skipped, so their rollups are preserved.
+func (r *Rollup) Backfill(ctx context.Context, from, to, now time.Time) error {
+	horizon, err := r.repo.GetRollupWatermark(ctx, database.RetentionWatermark)
+	if err != nil {
+		return err
+	}
+
+	for _, rollup := range []string{database.RollupHourly, database.RollupDaily} {
+		unit := database.RollupUnit(rollup)
+		closed := now.Add(-rollupLateness).UTC().Truncate(unit)
+
+		start := from.UTC().Truncate(unit)
+		if start.Before(horizon) {
+			start = horizon.UTC().Truncate(unit)
+			if start.Before(horizon) {
+				start = start.Add(unit)
+			}
+			log.Printf("[ROLLUP] WARNING: Raw clicks before %s were purged, %s backfill starts at %s",
+				horizon.Format(time.RFC3339), rollup, start.Format(time.RFC3339))
+		}
+
+		end := to.UTC()
+		if end.After(closed) {
+			end = closed
+		}
+
+		log.Printf("[ROLLUP] Backfilling %s rollup from %s to %s", rollup, start.Format(time.RFC3339), end.Format(time.RFC3339))
+
+		var unitStart time.Time
+		for unitStart = start; unitStart.Before(end); unitStart = unitStart.Add(unit) {
+			if err := r.compact(ctx, rollup, unitStart); err != nil {
+				return err
+			}
+		}
+
+		watermark, err := r.startingWatermark(ctx, rollup, closed)
+		if err != nil {
+			return err
+		}
+		if !start.After(watermark) && unitStart.After(watermark) {
+			if err := r.repo.SetRollupWatermark(ctx, rollup, unitStart); err != nil {
+				return err
+			}
+			log.Printf("[ROLLUP] %s watermark moved to %s", rollup, unitStart.Format(time.RFC3339))
+		}
+	}
+
+	log.Printf("[ROLLUP] SUCCESS: Backfill complete")
+	return nil
+}
+
+// rollupWorker periodically compacts new click events until shutdown
+func (s *service) rollupWorker(interval time.Duration) {
+	defer s.wg.Done()
+	log.Printf("[SHORTENER] Rollup worker started (every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			log.Printf("[SHORTENER] Rollup worker shutting down")
+			return
+		case <-ticker.C:
+			s.runRollup(interval)
+		}
+	}
+}
+
+// runRollup runs one rollup pass, unless another instance is running one
+func (s *service) runRollup(timeout time.Duration) bool {
+	return s.runExclusive("Rollup", database.LockRollup, timeout, func(ctx context.Context) error {
+		return s.rollup.RunOnce(ctx, time.Now())
+	})
+}
@@ -0,0 +1,170 @@
+package shortener
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/database"
+)
+
+func TestRollup_RunOnce(t *testing.T) {
+	now := time.Date(2025, 9, 3, 10, 20, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		earliestClick  time.Time
+		watermarks     map[string]time.Time
+		expectedHours  int
+		expectedDays   int
+		expectedHourly time.Time
+		expectedDaily  time.Time
+	}{
+		{
+			name:           "no clicks yet",
+			expectedHourly: time.Date(2025, 9, 3, 10, 0, 0, 0, time.UTC),
+			expectedDaily:  time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "first run starts at earliest click",
+			earliestClick:  time.Date(2025, 9, 2, 22, 45, 0, 0, time.UTC),
+			expectedHours:  12, // 22:00 through 09:00
+			expectedDays:   1,  // 2025-09-02
+			expectedHourly: time.Date(2025, 9, 3, 10, 0, 0, 0, time.UTC),
+			expectedDaily:  time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "caught up",
+			watermarks: map[string]time.Time{
+				database.RollupHourly: time.Date(2025, 9, 3, 10, 0, 0, 0, time.UTC),
+				database.RollupDaily:  time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+			},
+			expectedHourly: time.Date(2025, 9, 3, 10, 0, 0, 0, time.UTC),
+			expectedDaily:  time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "long backlog is capped per pass",
+			watermarks: map[string]time.Time{
+				database.RollupHourly: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC),
+				database.RollupDaily:  time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC),
+			},
+			expectedHours:  maxRollupHoursPerRun,
+			expectedDays:   maxRollupDaysPerRun,
+			expectedHourly: time.Date(2025, 8, 3, 0, 0, 0, 0, time.UTC),
+			expectedDaily:  time.Date(2025, 8, 8, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRepository()
+			repo.earliestClick = tt.earliestClick
+			for rollup, watermark := range tt.watermarks {
+				repo.watermarks[rollup] = watermark
+			}
+
+			if err := NewRollup(repo).RunOnce(context.Background(), now); err != nil {
+				t.Fatalf("RunOnce() unexpected error: %v", err)
+			}
+
+			if len(repo.rolledHours) != tt.expectedHours {
+				t.Errorf("Rolled %d hours, expected %d", len(repo.rolledHours), tt.expectedHours)
+			}
+			if len(repo.rolledDays) != tt.expectedDays {
+				t.Errorf("Rolled %d days, expected %d", len(repo.rolledDays), tt.expectedDays)
+			}
+			if got := repo.watermarks[database.RollupHourly]; !got.Equal(tt.expectedHourly) {
+				t.Errorf("Hourly watermark = %v, expected %v", got, tt.expectedHourly)
+			}
+			if got := repo.watermarks[database.RollupDaily]; !got.Equal(tt.expectedDaily) {
+				t.Errorf("Daily watermark = %v, expected %v", got, tt.expectedDaily)
+			}
+		})
+	}
+}
+
+func TestRollup_RunOnceRespectsLateness(t *testing.T) {
+	repo := NewMockRepository()
+	repo.watermarks[database.RollupHourly] = time.Date(2025, 9, 3, 9, 0, 0, 0, time.UTC)
+	repo.watermarks[database.RollupDaily] = time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC)
+
+	// 10:02 is within the lateness window, so 09:00-10:00 is not closed yet
+	now := time.Date(2025, 9, 3, 10, 2, 0, 0, time.UTC)
+	if err := NewRollup(repo).RunOnce(context.Background(), now); err != nil {
+		t.Fatalf("RunOnce() unexpected error: %v", err)
+	}
+
+	if len(repo.rolledHours) != 0 {
+		t.Errorf("Rolled %v, expected nothing before lateness has passed", repo.rolledHours)
+	}
+}
+
+func TestRollup_Backfill(t *testing.T) {
+	now := time.Date(2025, 9, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		from          time.Time
+		to            time.Time
+		watermark     time.Time
+		horizon       time.Time
+		expectedDays  int
+		expectedDaily time.Time
+	}{
+		{
+			name:          "history behind the watermark keeps it",
+			from:          time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+			to:            time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+			watermark:     time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC),
+			expectedDays:  2,
+			expectedDaily: time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "range reaching the watermark advances it",
+			from:          time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC),
+			to:            now,
+			watermark:     time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC),
+			expectedDays:  5, // 5th through 9th; the 10th is not closed
+			expectedDaily: time.Date(2025, 9, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "gap before the watermark leaves it",
+			from:          time.Date(2025, 9, 9, 0, 0, 0, 0, time.UTC),
+			to:            now,
+			watermark:     time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC),
+			expectedDays:  1,
+			expectedDaily: time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "purged history is not recomputed",
+			from:          time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC),
+			to:            time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC),
+			watermark:     time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC),
+			horizon:       time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC),
+			expectedDays:  1, // The 1st lost raw events, only the 2nd is recomputed
+			expectedDaily: time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMockRepository()
+			repo.watermarks[database.RollupHourly] = tt.watermark
+			repo.watermarks[database.RollupDaily] = tt.watermark
+			if !tt.horizon.IsZero() {
+				repo.watermarks[database.RetentionWatermark] = tt.horizon
+			}
+
+			if err := NewRollup(repo).Backfill(context.Background(), tt.from, tt.to, now); err != nil {
+				t.Fatalf("Backfill() unexpected error: %v", err)
+			}
+
+			if len(repo.rolledDays) != tt.expectedDays {
+				t.Errorf("Rolled %d days, expected %d", len(repo.rolledDays), tt.expectedDays)
+			}
+			if got := repo.watermarks[database.RollupDaily]; !got.Equal(tt.expectedDaily) {
+				t.Errorf("Daily watermark = %v, expected %v", got, tt.expectedDaily)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package shortener
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/bits"
+	"strings"
+	"sync"
+
+	"backend/internal/database"
+)
+
+const (
+	// Code strategies of Config.CodeStrategy
+	CodeStrategyRandom   = "random"   // Random codes, looked up before use
+	CodeStrategySequence = "sequence" // Permuted database sequence ids, never repeating
+
+	// Longest sequence code whose space 62^n fits in 64-bit arithmetic; smaller
+	// alphabets fit as well
+	maxSequenceCodeLength = 10
+
+	defaultCodeBlockSize = 100
+	feistelRounds        = 8
+)
+
+var ErrCodeSpaceExhausted = errors.New("sequence exhausted the code space")
+
+// SequenceStrategy allocates codes from a database sequence. Each instance
+// leases ids in blocks and encodes them with a keyed permutation of the code
+// space, so codes never collide with each other yet do not reveal their
+// order: without the secret, one code says nothing about the next.
+type SequenceStrategy struct {
+	repo      database.URLRepository
+	perm      *codePermutation
+	blockSize int
+
+	mu  sync.Mutex
+	ids []int64 // Leased and unused
+}
+
+// NewSequenceStrategy creates a sequence strategy for codes of the given
+// alphabet and length. The secret, alphabet and length key the permutation
+// and must not change once codes were issued, or new codes may collide with
+// old ones. A secret is required, since without one anyone can decode codes
+// to their ids and enumerate the next ones.
+func NewSequenceStrategy(repo database.URLRepository, secret string, alphabet *Alphabet, length, blockSize int) (*SequenceStrategy, error) {
+	if secret == "" {
+		return nil, errors.New("sequence codes require a code secret")
+	}
+	perm, err := newCodePermutation(secret, alphabet, length)
+	if err != nil {
+		return nil, err
+	}
+	if blockSize <= 0 {
+		blockSize = defaultCodeBlockSize
+	}
+	return &SequenceStrategy{repo: repo, perm: perm, blockSize: blockSize}, nil
+}
+
+// NextCode returns the code of the next leased id, leasing a block when none
+// is left. Ids of codes spelling offensive words are skipped.
+func (s *SequenceStrategy) NextCode(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		code, err := s.next(ctx)
+		if err != nil || !IsProfane(code) {
+			return code, err
+		}
+	}
+}
+
+func (s *SequenceStrategy) next(ctx context.Context) (string, error) {
+	if len(s.ids) == 0 {
+		ids, err := s.repo.AllocateCodeIDs(ctx, s.blockSize)
+		if err != nil {
+			return "", err
+		}
+		if len(ids) == 0 {
+			return "", errors.New("no code ids allocated")
+		}
+		log.Printf("[SHORTENER] Leased %d code ids", len(ids))
+		s.ids = ids
+	}
+
+	id := s.ids[0]
+	s.ids = s.ids[1:]
+	if id < 0 {
+		return "", fmt.Errorf("invalid code id %d", id)
+	}
+	return s.perm.encode(uint64(id))
+}
+
+// Unique reports true: every id is leased once
+func (s *SequenceStrategy) Unique() bool {
+	return true
+}
+
+// codePermutation is a keyed bijection between ids in [0, base^length) and
+// codes of that length: a Feistel network over the smallest even bit width
+// covering the range, cycle-walked back into it
+type codePermutation struct {
+	chars    string
+	length   int
+	size     uint64 // base^length
+	halfBits uint
+	mask     uint64
+	key      []byte
+}
+
+func newCodePermutation(secret string, alphabet *Alphabet, length int) (*codePermutation, error) {
+	if alphabet.words {
+		return nil, fmt.Errorf("%s codes cannot be allocated from a sequence", alphabet.Name)
+	}
+	if length < minCodeLength || length > maxSequenceCodeLength {
+		return nil, fmt.Errorf("sequence code length must be between %d and %d characters", minCodeLength, maxSequenceCodeLength)
+	}
+
+	size := uint64(1)
+	for i := 0; i < length; i++ {
+		size *= uint64(alphabet.base())
+	}
+	width := uint(bits.Len64(size - 1))
+	if width%2 == 1 {
+		width++
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	return &codePermutation{
+		chars:    alphabet.Chars,
+		length:   length,
+		size:     size,
+		halfBits: width / 2,
+		mask:     1<<(width/2) - 1,
+		key:      key[:],
+	}, nil
+}
+
+// encode returns the code of id
+func (p *codePermutation) encode(id uint64) (string, error) {
+	if id >= p.size {
+		return "", ErrCodeSpaceExhausted
+	}
+
+	// Cycle walking: values outside the range are permuted again until one
+	// falls inside, which keeps the mapping a bijection of the range
+	x := p.feistel(id)
+	for x >= p.size {
+		x = p.feistel(x)
+	}
+
+	code := make([]byte, p.length)
+	for i := p.length - 1; i >= 0; i-- {
+		code[i] = p.chars[x%uint64(len(p.chars))]
+		x /= uint64(len(p.chars))
+	}
+	return string(code), nil
+}
+
+// decode returns the id of a code, or false if it is not a code of this
+// permutation's length
+func (p *codePermutation) decode(code string) (uint64, bool) {
+	if len(code) != p.length {
+		return 0, false
+	}
+
+	var x uint64
+	for i := 0; i < len(code); i++ {
+		digit := strings.IndexByte(p.chars, code[i])
+		if digit < 0 {
+			return 0, false
+		}
+		x = x*uint64(len(p.chars)) + uint64(digit)
+	}
+
+	x = p.feistelInverse(x)
+	for x >= p.size {
+		x = p.feistelInverse(x)
+	}
+	return x, true
+}
+
+func (p *codePermutation) feistel(x uint64) uint64 {
+	l, r := x>>p.halfBits, x&p.mask
+	for i := 0; i < feistelRounds; i++ {
+		l, r = r, l^p.round(i, r)
+	}
+	return l<<p.halfBits | r
+}
+
+func (p *codePermutation) feistelInverse(x uint64) uint64 {
+	l, r := x>>p.halfBits, x&p.mask
+	for i := feistelRounds - 1; i >= 0; i-- {
+		l, r = r^p.round(i, l), l
+	}
+	return l<<p.halfBits | r
+}
+
+// round is the keyed round function of the Feistel network
+func (p *codePermutation) round(i int, half uint64) uint64 {
+	var input [9]byte
+	input[0] = byte(i)
+	binary.BigEndian.PutUint64(input[1:], half)
+
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(input[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil)) & p.mask
+}
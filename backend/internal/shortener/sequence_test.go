@@ -0,0 +1,137 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"backend/internal/models"
+)
+
+func TestCodePermutation(t *testing.T) {
+	for length := minCodeLength; length <= maxSequenceCodeLength; length++ {
+		perm, err := newCodePermutation("secret", AlphabetBase62, length)
+		if err != nil {
+			t.Fatalf("newCodePermutation(%d) unexpected error: %v", length, err)
+		}
+
+		seen := make(map[string]bool)
+		for _, id := range append(sequentialIDs(2000), perm.size-2, perm.size-1) {
+			code, err := perm.encode(id)
+			if err != nil {
+				t.Fatalf("length %d: encode(%d) unexpected error: %v", length, id, err)
+			}
+			if len(code) != length || !IsValidCode(code) {
+				t.Errorf("length %d: encode(%d) = %q, expected a base62 code of that length", length, id, code)
+			}
+			if seen[code] {
+				t.Errorf("length %d: encode(%d) = %q repeats an earlier code", length, id, code)
+			}
+			seen[code] = true
+
+			if decoded, ok := perm.decode(code); !ok || decoded != id {
+				t.Errorf("length %d: decode(%q) = %d, %v, expected %d", length, code, decoded, ok, id)
+			}
+		}
+
+		if _, err := perm.encode(perm.size); !errors.Is(err, ErrCodeSpaceExhausted) {
+			t.Errorf("length %d: encode(size) error = %v, expected ErrCodeSpaceExhausted", length, err)
+		}
+	}
+
+	for _, length := range []int{minCodeLength - 1, maxSequenceCodeLength + 1} {
+		if _, err := newCodePermutation("secret", AlphabetBase62, length); err == nil {
+			t.Errorf("newCodePermutation(%d) expected an error", length)
+		}
+	}
+}
+
+func TestCodePermutation_Keyed(t *testing.T) {
+	a, _ := newCodePermutation("one secret", AlphabetBase62, 7)
+	b, _ := newCodePermutation("another secret", AlphabetBase62, 7)
+
+	// Consecutive ids give unrelated codes, which differ between secrets
+	same, adjacent := 0, 0
+	var previous string
+	for id := uint64(1); id <= 100; id++ {
+		codeA, _ := a.encode(id)
+		codeB, _ := b.encode(id)
+		if codeA == codeB {
+			same++
+		}
+		if previous != "" && codeA[:5] == previous[:5] {
+			adjacent++
+		}
+		previous = codeA
+	}
+	if same > 0 || adjacent > 0 {
+		t.Errorf("Codes look related: %d equal across secrets, %d sharing a prefix with the previous id", same, adjacent)
+	}
+}
+
+func TestNewSequenceStrategy_RequiresSecret(t *testing.T) {
+	if _, err := NewSequenceStrategy(NewMockRepository(), "", AlphabetBase62, 7, 100); err == nil {
+		t.Error("NewSequenceStrategy() without a secret expected an error")
+	}
+}
+
+func TestSequenceStrategy(t *testing.T) {
+	repo := NewMockRepository()
+	config := DefaultConfig()
+	config.CodeStrategy = CodeStrategySequence
+	config.CodeSecret = "test secret"
+	config.CodeBlockSize = 3
+	svc := NewService(repo, config)
+	ctx := context.Background()
+
+	sequence := svc.(*service).codes.(*SequenceStrategy)
+
+	// The code of the next id is taken by a reserved code, so it is skipped
+	reserved, _ := sequence.perm.encode(1)
+	repo.AddReservedCode(ctx, &models.ReservedCode{Code: reserved, Reason: "brand"})
+
+	owner := int64(1)
+	codes := make(map[string]bool)
+	for i := 0; i < 7; i++ {
+		url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/sequence", UserID: &owner})
+		if err != nil {
+			t.Fatalf("CreateShortURL() unexpected error: %v", err)
+		}
+		if codes[url.ShortCode] || url.ShortCode == reserved {
+			t.Errorf("CreateShortURL() reused code %s", url.ShortCode)
+		}
+		codes[url.ShortCode] = true
+
+		if id, ok := sequence.perm.decode(url.ShortCode); !ok || id < 2 || id > 8 {
+			t.Errorf("Code %s decodes to id %d, expected one of the leased ids", url.ShortCode, id)
+		}
+	}
+
+	// Reusing a link allocates no id, so the next new link gets the last
+	// leased one
+	yes := true
+	reused, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/sequence", UserID: &owner, Deduplicate: &yes})
+	if err != nil || !codes[reused.ShortCode] {
+		t.Fatalf("CreateShortURL() = %v, %v, expected an existing link", reused, err)
+	}
+	url, err := svc.CreateShortURL(ctx, &CreateURLRequest{URL: "https://example.com/sequence/other", UserID: &owner, Deduplicate: &yes})
+	if err != nil {
+		t.Fatalf("CreateShortURL() unexpected error: %v", err)
+	}
+	if id, _ := sequence.perm.decode(url.ShortCode); id != 9 {
+		t.Errorf("Code %s after a reused link decodes to id %d, expected 9", url.ShortCode, id)
+	}
+
+	// 9 ids in blocks of 3
+	if repo.allocations != 3 {
+		t.Errorf("Leased %d blocks, expected 3", repo.allocations)
+	}
+}
+
+func sequentialIDs(n int) []uint64 {
+	ids := make([]uint64, n)
+	for i := range ids {
+		ids[i] = uint64(i)
+	}
+	return ids
+}
@@ -0,0 +1,77 @@
+package shortener
+
+import (
+	"fmt"
+	"time"
+
+	"backend/internal/models"
+)
+
+// maxSeriesBuckets caps the number of points in a zero-filled time series
+const maxSeriesBuckets = 5000
+
+// truncateToBucket returns the start of the bucket containing t, computed in loc.
+// Weeks start on Monday to match PostgreSQL's date_trunc.
+func truncateToBucket(t time.Time, granularity string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	year, month, day := t.Date()
+
+	switch granularity {
+	case models.GranularityMinute:
+		return time.Date(year, month, day, t.Hour(), t.Minute(), 0, 0, loc)
+	case models.GranularityHour:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, loc)
+	case models.GranularityWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, loc)
+	case models.GranularityMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket following start. Calendar units
+// are added in local time so days and months stay aligned across DST changes.
+func nextBucket(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case models.GranularityMinute:
+		return start.Add(time.Minute)
+	case models.GranularityHour:
+		return start.Add(time.Hour)
+	case models.GranularityWeek:
+		return start.AddDate(0, 0, 7)
+	case models.GranularityMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// zeroFillSeries expands sparse buckets into a contiguous series covering
+// [from, to), with zero clicks for buckets that had no data
+func zeroFillSeries(buckets []models.TimeBucket, from, to time.Time, granularity string, loc *time.Location) ([]models.TimeBucket, error) {
+	clicks := make(map[int64]int64, len(buckets))
+	for _, b := range buckets {
+		clicks[b.Start.Unix()] += b.Clicks
+	}
+
+	series := []models.TimeBucket{}
+	for b := truncateToBucket(from, granularity, loc); b.Before(to); b = nextBucket(b, granularity) {
+		if len(series) == maxSeriesBuckets {
+			return nil, fmt.Errorf("%w: range needs more than %d %s buckets", ErrInvalidRequest, maxSeriesBuckets, granularity)
+		}
+		series = append(series, models.TimeBucket{Start: b, Clicks: clicks[b.Unix()]})
+	}
+	return series, nil
+}
+
+// percentChange returns the relative change from previous to current in
+// percent, or nil when there is no previous value to compare against
+func percentChange(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := float64(current-previous) / float64(previous) * 100
+	return &change
+}
@@ -0,0 +1,127 @@
+package shortener
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("Failed to load timezone %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestTruncateToBucket(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+	kolkata := mustLoadLocation(t, "Asia/Kolkata")
+
+	// Wednesday 2025-09-03 02:30 UTC is still Tuesday evening in New York
+	instant := time.Date(2025, 9, 3, 2, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		granularity string
+		loc         *time.Location
+		expected    time.Time
+	}{
+		{"minute", models.GranularityMinute, time.UTC, time.Date(2025, 9, 3, 2, 30, 0, 0, time.UTC)},
+		{"hour", models.GranularityHour, time.UTC, time.Date(2025, 9, 3, 2, 0, 0, 0, time.UTC)},
+		{"day utc", models.GranularityDay, time.UTC, time.Date(2025, 9, 3, 0, 0, 0, 0, time.UTC)},
+		{"day new york", models.GranularityDay, newYork, time.Date(2025, 9, 2, 0, 0, 0, 0, newYork)},
+		{"hour with half-hour offset", models.GranularityHour, kolkata, time.Date(2025, 9, 3, 8, 0, 0, 0, kolkata)},
+		{"week starts monday", models.GranularityWeek, time.UTC, time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"month", models.GranularityMonth, newYork, time.Date(2025, 9, 1, 0, 0, 0, 0, newYork)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateToBucket(instant, tt.granularity, tt.loc)
+			if !got.Equal(tt.expected) {
+				t.Errorf("truncateToBucket(%s) = %v, expected %v", tt.granularity, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestZeroFillSeries(t *testing.T) {
+	from := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 9, 5, 0, 0, 0, 0, time.UTC)
+
+	buckets := []models.TimeBucket{
+		{Start: time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC), Clicks: 7},
+		{Start: time.Date(2025, 9, 4, 0, 0, 0, 0, time.UTC), Clicks: 2},
+	}
+
+	series, err := zeroFillSeries(buckets, from, to, models.GranularityDay, time.UTC)
+	if err != nil {
+		t.Fatalf("zeroFillSeries() unexpected error: %v", err)
+	}
+
+	expected := []int64{0, 7, 0, 2}
+	if len(series) != len(expected) {
+		t.Fatalf("zeroFillSeries() returned %d buckets, expected %d", len(series), len(expected))
+	}
+	for i, clicks := range expected {
+		if series[i].Clicks != clicks {
+			t.Errorf("Bucket %d (%s) clicks = %d, expected %d", i, series[i].Start, series[i].Clicks, clicks)
+		}
+	}
+}
+
+func TestZeroFillSeries_DST(t *testing.T) {
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	// Clocks go back on 2025-11-02, so that day is 25 hours long
+	from := time.Date(2025, 11, 1, 0, 0, 0, 0, newYork)
+	to := time.Date(2025, 11, 4, 0, 0, 0, 0, newYork)
+
+	days, err := zeroFillSeries(nil, from, to, models.GranularityDay, newYork)
+	if err != nil {
+		t.Fatalf("zeroFillSeries() unexpected error: %v", err)
+	}
+	if len(days) != 3 {
+		t.Fatalf("zeroFillSeries() returned %d days, expected 3", len(days))
+	}
+	for _, day := range days {
+		if local := day.Start.In(newYork); local.Hour() != 0 {
+			t.Errorf("Day bucket %v does not start at local midnight", local)
+		}
+	}
+
+	hours, err := zeroFillSeries(nil, from, to, models.GranularityHour, newYork)
+	if err != nil {
+		t.Fatalf("zeroFillSeries() unexpected error: %v", err)
+	}
+	if len(hours) != 73 {
+		t.Errorf("zeroFillSeries() returned %d hours, expected 73", len(hours))
+	}
+}
+
+func TestZeroFillSeries_TooManyBuckets(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 30)
+
+	_, err := zeroFillSeries(nil, from, to, models.GranularityMinute, time.UTC)
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("zeroFillSeries() error = %v, expected %v", err, ErrInvalidRequest)
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	if got := percentChange(5, 0); got != nil {
+		t.Errorf("percentChange(5, 0) = %v, expected nil", *got)
+	}
+	if got := percentChange(15, 10); got == nil || *got != 50 {
+		t.Errorf("percentChange(15, 10) = %v, expected 50", got)
+	}
+	if got := percentChange(5, 10); got == nil || *got != -50 {
+		t.Errorf("percentChange(5, 10) = %v, expected -50", got)
+	}
+}
@@ -2,17 +2,31 @@ package shortener
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	neturl "net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"backend/internal/cache"
 	"backend/internal/database"
+	"backend/internal/geoip"
+	"backend/internal/hll"
 	"backend/internal/models"
+	"backend/internal/preview"
+	"backend/internal/probe"
+	"backend/internal/ssrf"
+	"backend/internal/urlscan"
+	"backend/internal/useragent"
 )
 
 // Service defines the interface for URL shortening operations
@@ -27,14 +41,56 @@ type Service interface {
 	GetURLInfo(ctx context.Context, shortCode string) (*models.URLInfoResponse, error)
 	UpdateURL(ctx context.Context, shortCode string, req *UpdateURLRequest) (*models.URL, error)
 	DeactivateURL(ctx context.Context, shortCode string) error
+	DeleteURL(ctx context.Context, shortCode string) (*DeletionResponse, error)
+	RestoreURL(ctx context.Context, shortCode string) (*models.URL, error)
+	GetURLRevisions(ctx context.Context, shortCode string) (*RevisionsResponse, error)
+	RestoreRevision(ctx context.Context, shortCode string, revision int) (*models.URL, error)
+	GetAuditLog(ctx context.Context, req *AuditRequest) (*AuditResponse, error)
 
 	// Analytics operations
 	RecordClick(ctx context.Context, shortCode string, clickCtx *ClickContext) error
-	GetAnalytics(ctx context.Context, shortCode string, days int) (*AnalyticsResponse, error)
+	GetAnalytics(ctx context.Context, shortCode string, req *AnalyticsRequest) (*AnalyticsResponse, error)
+	GetCampaignStats(ctx context.Context, req *CampaignRequest) (*CampaignResponse, error)
+	ExportClicks(ctx context.Context, req *ExportRequest) (*ClickExport, error)
+
+	// Privacy operations
+	EraseClicks(ctx context.Context, req *ErasureRequest) (*ErasureResponse, error)
+
+	// Live click stream
+	SubscribeLive(ctx context.Context, shortCode string) (*LiveSubscription, error)
+	CloseLiveStreams()
+
+	// Destination scanning
+	ReloadURLScanners() error
+	RescanURLs(ctx context.Context) (*RescanResult, error)
+	GetQuarantinedURLs(ctx context.Context, limit int) ([]*models.URL, error)
+	ReleaseURL(ctx context.Context, shortCode string) (*models.URL, error)
+
+	// Destination health checks
+	ProbeURL(ctx context.Context, shortCode string) (*models.ProbeResult, error)
+	CheckLinks(ctx context.Context) (*LinkCheckResult, error)
+	GetBrokenLinks(ctx context.Context, limit int) ([]*models.URL, error)
+
+	// Link previews
+	GetURLPreview(ctx context.Context, shortCode string) (*PreviewResponse, error)
+	RefreshPreview(ctx context.Context, shortCode string) (*PreviewResponse, error)
+	SetPreviewOverride(ctx context.Context, shortCode string, req *PreviewOverrideRequest) (*PreviewResponse, error)
 
 	// Utility operations
 	ValidateCustomCode(ctx context.Context, code string) error
 	GetRecentURLs(ctx context.Context, limit int) ([]*models.URL, error)
+	GetCodeStats() *CodeStats
+
+	// Reserved codes
+	ReserveCode(ctx context.Context, req *ReserveCodeRequest) (*models.ReservedCode, error)
+	UnreserveCode(ctx context.Context, code string) error
+	GetReservedCodes(ctx context.Context) ([]*models.ReservedCode, error)
+	ReserveRoutes(routes []string)
+
+	// Maintenance operations
+	ExpireURLs(ctx context.Context) (int, error)
+	PurgeDeletedURLs(ctx context.Context) (int, error)
+	OnEvent(handler EventHandler)
 
 	// Lifecycle operations
 	Shutdown(ctx context.Context) error
@@ -46,15 +102,52 @@ type clickJob struct {
 	clickCtx *ClickContext
 }
 
+// clickEnricher adds derived data to a click event from the raw click context.
+// Enrichers run before IP anonymization so they can see the full address.
+type clickEnricher func(click *models.ClickEvent, clickCtx *ClickContext)
+
 // service implements the Service interface
 type service struct {
-	repo      database.URLRepository
-	generator *Generator
-	config    *Config
+	repo     database.URLRepository
+	codes    CodeStrategy
+	alphabet *Alphabet // Of the configured codes
+	config   *Config
+
+	// Click enrichment pipeline
+	geo       geoip.Resolver
+	enrichers []clickEnricher
+
+	// Target URL blocklists, rules and internal address checks
+	scanner *urlscan.Chain
+	guard   *ssrf.Guard
+
+	// Follows target redirect chains for health checks
+	prober *probe.Prober
+
+	// Fetches link previews, in the background after creation
+	previews    *preview.Fetcher
+	previewChan chan previewJob
+
+	// Background compaction of click events into rollup tables
+	rollup *Rollup
+
+	// Background partition management and raw click retention
+	maintenance *Maintenance
+
+	// Live click pub/sub, fanned out across instances via LISTEN/NOTIFY
+	live       *liveHub
+	instanceID string
+
+	// Link lifecycle events
+	events eventBus
 
 	// URL cache for fast redirects
 	urlCache *cache.LRU[string, *models.URL]
 
+	// Reserved codes and patterns, swapped whole on changes
+	reserved     atomic.Pointer[reservedSet]
+	reservedLoad sync.Mutex // Serializes reloads and changes
+
 	// Worker pool for async click recording
 	clickChan chan clickJob
 	wg        sync.WaitGroup
@@ -69,6 +162,17 @@ const (
 	// Worker pool configuration
 	clickWorkers    = 4
 	clickBufferSize = 1000
+
+	// Analytics defaults
+	defaultAnalyticsDays = 30
+
+	// Expired URLs deactivated per sweep query
+	expirySweepBatchSize = 500
+
+	// Campaign analytics defaults
+	defaultCampaignDays  = 30
+	defaultCampaignLimit = 100
+	maxCampaignLimit     = 10000 // Large enough for CSV exports
 )
 
 // NewService creates a new shortener service
@@ -80,18 +184,92 @@ func NewService(repo database.URLRepository, config *Config) Service {
 		log.Printf("[SHORTENER] Using default configuration")
 	}
 
-	generator, err := NewGeneratorWithLength(config.DefaultCodeLength)
+	alphabet, err := AlphabetByName(config.CodeAlphabet)
 	if err != nil {
-		log.Fatalf("[SHORTENER] FATAL: Failed to create generator: %v", err)
+		log.Fatalf("[SHORTENER] FATAL: %v", err)
+	}
+	if config.IgnoreCodeCase && !alphabet.CaseInsensitive {
+		log.Fatalf("[SHORTENER] FATAL: Case-insensitive codes need a case-insensitive code alphabet, not %s", alphabet.Name)
+	}
+
+	var codes CodeStrategy
+	switch config.CodeStrategy {
+	case "", CodeStrategyRandom:
+		if alphabet.words {
+			codes = NewWordStrategy()
+			break
+		}
+		adaptive, err := NewAdaptiveStrategy(repo, alphabet, config.DefaultCodeLength, config.MaxCodeOccupancy)
+		if err != nil {
+			log.Fatalf("[SHORTENER] FATAL: Failed to create generator: %v", err)
+		}
+		codes = adaptive
+	case CodeStrategySequence:
+		sequence, err := NewSequenceStrategy(repo, config.CodeSecret, alphabet, config.DefaultCodeLength, config.CodeBlockSize)
+		if err != nil {
+			log.Fatalf("[SHORTENER] FATAL: Failed to create sequence code strategy: %v", err)
+		}
+		codes = sequence
+	default:
+		log.Fatalf("[SHORTENER] FATAL: Unknown code strategy %q", config.CodeStrategy)
+	}
+
+	if config.EnableAnalytics && config.VisitorHashSalt == "" {
+		log.Printf("[SHORTENER] WARNING: No visitor hash salt configured, unique visitor hashes are unsalted")
 	}
 
 	svc := &service{
-		repo:      repo,
-		generator: generator,
-		config:    config,
-		urlCache:  cache.NewLRU[string, *models.URL](urlCacheCapacity, urlCacheTTL),
-		clickChan: make(chan clickJob, clickBufferSize),
-		shutdown:  make(chan struct{}),
+		repo:       repo,
+		codes:      codes,
+		alphabet:   alphabet,
+		config:     config,
+		urlCache:   cache.NewLRU[string, *models.URL](urlCacheCapacity, urlCacheTTL),
+		clickChan:  make(chan clickJob, clickBufferSize),
+		shutdown:   make(chan struct{}),
+		live:       newLiveHub(),
+		instanceID: newInstanceID(),
+	}
+
+	// Set up target URL scanning; the built-in rules apply even if lists fail to load
+	svc.scanner = urlscan.New(urlscan.Config{
+		Blocklists:        config.URLBlocklists,
+		HashPrefixLists:   config.URLHashPrefixLists,
+		Allowlists:        config.URLAllowlists,
+		BlockedExtensions: config.BlockedExtensions,
+	})
+	if err := svc.scanner.Reload(); err != nil {
+		log.Printf("[SHORTENER] WARNING: URL lists not loaded, using built-in rules only: %v", err)
+	}
+	svc.guard = ssrf.NewGuard(config.Resolver, config.SSRFStrict)
+
+	// Load reserved codes; until they load, only the built-in ones apply
+	svc.reserved.Store(newReservedSet(nil, nil, time.Time{}))
+	reservedCtx, cancel := context.WithTimeout(context.Background(), reservedCodesTimeout)
+	svc.reservedLoad.Lock()
+	if err := svc.loadReservedCodes(reservedCtx); err != nil {
+		log.Printf("[SHORTENER] WARNING: Reserved codes not loaded, using built-in codes only: %v", err)
+	}
+	svc.reservedLoad.Unlock()
+	cancel()
+
+	// Links through this service count as nested shorteners too
+	shorteners := slices.Clone(probe.DefaultShorteners)
+	if base, err := neturl.Parse(config.BaseURL); err == nil && base.Hostname() != "" {
+		shorteners = append(shorteners, base.Hostname())
+	}
+	svc.prober = probe.New(svc.guard, probe.Config{Timeout: config.ProbeTimeout, Shorteners: shorteners})
+	svc.previews = preview.New(svc.guard, preview.Config{Timeout: config.ProbeTimeout})
+
+	// Set up click enrichment stages
+	svc.enrichers = append(svc.enrichers, svc.enrichUserAgent)
+	if config.GeoIPDatabasePath != "" {
+		resolver, err := geoip.Open(config.GeoIPDatabasePath)
+		if err != nil {
+			log.Printf("[SHORTENER] WARNING: GeoIP enrichment disabled: %v", err)
+		} else {
+			svc.geo = resolver
+			svc.enrichers = append(svc.enrichers, svc.enrichGeoIP)
+		}
 	}
 
 	// Start click workers
@@ -100,6 +278,48 @@ func NewService(repo database.URLRepository, config *Config) Service {
 		go svc.clickWorker(i)
 	}
 
+	// Start preview workers
+	if config.FetchPreviews {
+		svc.previewChan = make(chan previewJob, previewBufferSize)
+		for i := 0; i < previewWorkers; i++ {
+			svc.wg.Add(1)
+			go svc.previewWorker(i)
+		}
+	}
+
+	// Start rollup worker
+	if config.EnableAnalytics && config.RollupInterval > 0 {
+		svc.rollup = NewRollup(repo)
+		svc.wg.Add(1)
+		go svc.rollupWorker(config.RollupInterval)
+	}
+
+	// Adopt the stored code length before the first code is generated, and
+	// follow keyspace occupancy and other instances' changes afterwards
+	if _, ok := codes.(*AdaptiveStrategy); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), codeLengthRefreshTimeout)
+		svc.refreshCodeLength(ctx, false)
+		cancel()
+
+		if config.MaintenanceInterval > 0 {
+			svc.wg.Add(1)
+			go svc.codeLengthWorker(config.MaintenanceInterval)
+		}
+	}
+
+	// Start maintenance worker
+	if config.EnableAnalytics && config.MaintenanceInterval > 0 {
+		svc.maintenance = NewMaintenance(repo, config.ClickRetention)
+		svc.wg.Add(1)
+		go svc.maintenanceWorker(config.MaintenanceInterval)
+	}
+
+	// Start live click listener for clicks recorded by other instances
+	if config.EnableAnalytics && config.LiveFanout {
+		svc.wg.Add(1)
+		go svc.liveListener()
+	}
+
 	log.Printf("[SHORTENER] Service initialized - BaseURL: %s, CodeLength: %d, MaxRetries: %d, CacheSize: %d, Workers: %d",
 		config.BaseURL, config.DefaultCodeLength, config.MaxRetries, urlCacheCapacity, clickWorkers)
 
@@ -118,6 +338,17 @@ func DefaultConfig() *Config {
 		EnableAnalytics:     true,
 		AnonymizeIPs:        true,
 		RespectDNT:          true,
+		RollupInterval:      time.Minute,
+		ClickRetention:      90 * 24 * time.Hour,
+		MaintenanceInterval: time.Hour,
+		LiveFanout:          true,
+		DeletionGracePeriod: 30 * 24 * time.Hour,
+		BlockedExtensions:   urlscan.DefaultBlockedExtensions,
+		ProbeTimeout:        10 * time.Second,
+		CodeStrategy:        CodeStrategyRandom,
+		CodeBlockSize:       defaultCodeBlockSize,
+		CodeAlphabet:        CodeAlphabetBase62,
+		MaxCodeOccupancy:    defaultMaxCodeOccupancy,
 	}
 }
 
@@ -131,21 +362,35 @@ func (s *service) CreateShortURL(ctx context.Context, req *CreateURLRequest) (*m
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
-	normalizedURL, err := models.NormalizeURL(req.URL)
+	normalizedURL, err := s.normalizeURL(req.URL)
 	if err != nil {
 		log.Printf("[SHORTENER] ERROR: URL normalization failed: %v", err)
-		return nil, fmt.Errorf("failed to normalize URL: %w", err)
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	if err := s.checkTarget(ctx, normalizedURL); err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	codes, err := s.codeStrategy(req.CodeAlphabet)
+	if err != nil {
+		return nil, err
 	}
 
-	// Handle custom code if provided
+	// Links are only reused within the configured alphabet, since the
+	// stored link does not record the alphabet of its code
+	dedupe := codes == s.codes && s.shouldDeduplicate(req)
+
+	// Handle custom code if provided. A reused link needs no code, so a
+	// generated one is only allocated once no link is found.
 	var shortCode string
 	if req.CustomCode != "" {
 		shortCode, err = s.handleCustomCode(ctx, req.CustomCode)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		shortCode, err = s.generateUniqueCode(ctx)
+	} else if !dedupe {
+		shortCode, err = s.generateUniqueCode(ctx, codes)
 		if err != nil {
 			return nil, err
 		}
@@ -157,14 +402,41 @@ func (s *service) CreateShortURL(ctx context.Context, req *CreateURLRequest) (*m
 		TargetURL: normalizedURL,
 		IsActive:  true,
 		ExpiresAt: req.ExpiresAt,
+		OwnerID:   req.UserID,
 	}
 
-	// Save to database
-	if err := s.repo.CreateURL(ctx, url); err != nil {
-		log.Printf("[SHORTENER] ERROR: Failed to create URL in database: %v", err)
-		return nil, fmt.Errorf("failed to create URL: %w", err)
+	// Save to database. Generated codes that never repeat are not looked up
+	// first, so one taken by a custom, reserved or retired code is replaced.
+	for attempt := 1; ; attempt++ {
+		existing, created, err := s.saveURL(ctx, url, dedupe, codes)
+		if err == nil {
+			if !created {
+				log.Printf("[SHORTENER] SUCCESS: Reusing short URL ID=%d, ShortCode=%s for the same target", existing.ID, existing.ShortCode)
+				return existing, nil
+			}
+			break
+		}
+		if req.CustomCode != "" || !codes.Unique() || !errors.Is(err, database.ErrShortCodeExists) || attempt >= s.config.MaxRetries {
+			log.Printf("[SHORTENER] ERROR: Failed to create URL in database: %v", err)
+			return nil, fmt.Errorf("failed to create URL: %w", err)
+		}
+
+		log.Printf("[SHORTENER] WARNING: Generated code %s is taken, allocating another", url.ShortCode)
+		if dedupe {
+			continue
+		}
+		if url.ShortCode, err = s.generateUniqueCode(ctx, codes); err != nil {
+			return nil, err
+		}
 	}
 
+	// Only generated codes of the configured alphabet fill its keyspace
+	if adaptive, ok := s.codes.(*AdaptiveStrategy); ok && codes == s.codes && req.CustomCode == "" {
+		adaptive.Created(ctx, url.ShortCode)
+	}
+	s.audit(ctx, models.AuditURLCreate, url, diffURL(&models.URL{}, url))
+	s.schedulePreview(url)
+
 	log.Printf("[SHORTENER] SUCCESS: Created short URL ID=%d, ShortCode=%s", url.ID, url.ShortCode)
 	return url, nil
 }
@@ -173,25 +445,25 @@ func (s *service) CreateShortURL(ctx context.Context, req *CreateURLRequest) (*m
 func (s *service) GetURLForRedirect(ctx context.Context, shortCode string, clickCtx *ClickContext) (*models.URL, error) {
 	log.Printf("[SHORTENER] Getting URL for redirect: %s", shortCode)
 
-	// Check cache first
-	url, found := s.urlCache.Get(shortCode)
-	if !found {
-		// Cache miss - get from database
-		var err error
-		url, err = s.repo.GetURLByShortCode(ctx, shortCode)
-		if err != nil {
-			log.Printf("[SHORTENER] ERROR: URL not found: %s", shortCode)
-			return nil, ErrURLNotFound
-		}
-		// Store in cache for future requests
-		s.urlCache.Set(shortCode, url)
-		log.Printf("[SHORTENER] Cache miss for %s, fetched from DB", shortCode)
-	} else {
-		log.Printf("[SHORTENER] Cache hit for %s", shortCode)
+	url, err := s.lookupRedirectCode(ctx, shortCode)
+	if err != nil {
+		log.Printf("[SHORTENER] ERROR: URL not found: %s", shortCode)
+		return nil, ErrURLNotFound
 	}
+	shortCode = url.ShortCode
 
 	// Check if URL is accessible
 	if !url.IsAccessible() {
+		if url.IsDeleted() {
+			s.urlCache.Delete(shortCode)
+			log.Printf("[SHORTENER] ERROR: URL deleted: %s", shortCode)
+			return nil, ErrURLDeleted
+		}
+		if url.IsQuarantined() {
+			s.urlCache.Delete(shortCode)
+			log.Printf("[SHORTENER] ERROR: URL quarantined: %s", shortCode)
+			return nil, ErrURLQuarantined
+		}
 		if url.IsExpired() {
 			// Remove expired URL from cache
 			s.urlCache.Delete(shortCode)
@@ -233,7 +505,7 @@ func (s *service) GetURLInfo(ctx context.Context, shortCode string) (*models.URL
 		clickCount = 0
 	}
 
-	lastClicked, err := s.repo.GetLastClicked(ctx, url.ID)
+	lastClicked, err := s.repo.GetLastClicked(ctx, url.ID, false)
 	if err != nil {
 		log.Printf("[SHORTENER] WARNING: Failed to get last clicked: %v", err)
 	}
@@ -250,15 +522,19 @@ func (s *service) UpdateURL(ctx context.Context, shortCode string, req *UpdateUR
 	if err != nil {
 		return nil, ErrURLNotFound
 	}
+	if url.IsDeleted() {
+		return nil, ErrURLDeleted
+	}
+	before := *url
 
 	// Apply updates
 	if req.TargetURL != "" {
 		if err := models.ValidateURL(req.TargetURL); err != nil {
 			return nil, fmt.Errorf("invalid target URL: %w", err)
 		}
-		normalized, err := models.NormalizeURL(req.TargetURL)
+		normalized, err := s.normalizeURL(req.TargetURL)
 		if err != nil {
-			return nil, fmt.Errorf("failed to normalize URL: %w", err)
+			return nil, fmt.Errorf("invalid target URL: %w", err)
 		}
 		url.TargetURL = normalized
 	}
@@ -271,6 +547,11 @@ func (s *service) UpdateURL(ctx context.Context, shortCode string, req *UpdateUR
 		url.ExpiresAt = req.ExpiresAt
 	}
 
+	// Re-check the target even if unchanged: its host may resolve elsewhere by now
+	if err := s.checkTarget(ctx, url.TargetURL); err != nil {
+		return nil, fmt.Errorf("invalid target URL: %w", err)
+	}
+
 	// Save changes
 	if err := s.repo.UpdateURL(ctx, url); err != nil {
 		return nil, fmt.Errorf("failed to update URL: %w", err)
@@ -279,6 +560,11 @@ func (s *service) UpdateURL(ctx context.Context, shortCode string, req *UpdateUR
 	// Invalidate cache
 	s.urlCache.Delete(shortCode)
 
+	s.audit(ctx, models.AuditURLUpdate, url, diffURL(&before, url))
+	if url.TargetURL != before.TargetURL {
+		s.schedulePreview(url)
+	}
+
 	log.Printf("[SHORTENER] SUCCESS: Updated URL: %s", shortCode)
 	return url, nil
 }
@@ -287,6 +573,12 @@ func (s *service) UpdateURL(ctx context.Context, shortCode string, req *UpdateUR
 func (s *service) DeactivateURL(ctx context.Context, shortCode string) error {
 	log.Printf("[SHORTENER] Deactivating URL: %s", shortCode)
 
+	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		return ErrURLNotFound
+	}
+	before := *url
+
 	if err := s.repo.DeactivateURL(ctx, shortCode); err != nil {
 		return fmt.Errorf("failed to deactivate URL: %w", err)
 	}
@@ -294,6 +586,10 @@ func (s *service) DeactivateURL(ctx context.Context, shortCode string) error {
 	// Invalidate cache
 	s.urlCache.Delete(shortCode)
 
+	after := before
+	after.IsActive = false
+	s.audit(ctx, models.AuditURLDeactivate, &after, diffURL(&before, &after))
+
 	log.Printf("[SHORTENER] SUCCESS: Deactivated URL: %s", shortCode)
 	return nil
 }
@@ -307,90 +603,521 @@ func (s *service) RecordClick(ctx context.Context, shortCode string, clickCtx *C
 		return ErrURLNotFound
 	}
 
-	return s.recordClickAsync(ctx, url, clickCtx)
+	click, err := s.recordClickAsync(ctx, url, clickCtx)
+	if err != nil {
+		return err
+	}
+	if click != nil {
+		s.publishLive(url, click)
+	}
+	return nil
+}
+
+// ExpireURLs deactivates every expired URL that is still active, evicting
+// each from the cache and emitting EventURLExpired. It returns how many URLs
+// were expired.
+func (s *service) ExpireURLs(ctx context.Context) (int, error) {
+	var total int
+	for {
+		expired, err := s.repo.CleanupExpiredURLs(ctx, expirySweepBatchSize)
+		if err != nil {
+			log.Printf("[SHORTENER] ERROR: Expiry sweep failed after %d URLs: %v", total, err)
+			return total, err
+		}
+
+		for _, url := range expired {
+			s.urlCache.Delete(url.ShortCode)
+			s.audit(ctx, models.AuditURLExpire, url, map[string]models.FieldChange{
+				"is_active": {Before: true, After: false},
+			})
+			s.events.emit(Event{Type: EventURLExpired, URLID: url.ID, ShortCode: url.ShortCode})
+		}
+		total += len(expired)
+
+		if len(expired) < expirySweepBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// ReloadURLScanners re-reads the URL blocklists, hash prefix lists and
+// allowlists. On error the previous lists stay in effect.
+func (s *service) ReloadURLScanners() error {
+	log.Printf("[SHORTENER] Reloading URL scanners")
+	return s.scanner.Reload()
+}
+
+// saveURL stores a new link, or with dedupe returns the owner's existing
+// link to its target. It reports whether url was created. With dedupe the
+// code of a new link is generated from codes once no link is reused.
+func (s *service) saveURL(ctx context.Context, url *models.URL, dedupe bool, codes CodeStrategy) (*models.URL, bool, error) {
+	if dedupe {
+		return s.repo.CreateOrGetURL(ctx, url, func(ctx context.Context) (string, error) {
+			return s.generateUniqueCode(ctx, codes)
+		})
+	}
+	if err := s.repo.CreateURL(ctx, url); err != nil {
+		return nil, false, err
+	}
+	return url, true, nil
+}
+
+// shouldDeduplicate reports whether a create request reuses an existing link
+// to its target. A custom code always asks for a new link, and anonymous
+// requests do too, as they would otherwise share links with each other.
+func (s *service) shouldDeduplicate(req *CreateURLRequest) bool {
+	if req.CustomCode != "" || req.UserID == nil {
+		return false
+	}
+	if req.Deduplicate != nil {
+		return *req.Deduplicate
+	}
+	if req.UserID != nil {
+		if dedupe, ok := s.config.OwnerDeduplication[*req.UserID]; ok {
+			return dedupe
+		}
+	}
+	return s.config.DeduplicateTargets
+}
+
+// normalizeURL normalizes a target URL with the configured query options
+func (s *service) normalizeURL(rawURL string) (string, error) {
+	return models.NormalizeURLWith(rawURL, models.NormalizeOptions{
+		StripTrackingParams: s.config.StripTrackingParams,
+		SortQueryParams:     s.config.SortQueryParams,
+		StripFragments:      s.config.StripFragments,
+	})
+}
+
+// checkTarget runs a target URL through the URL scanners and checks that
+// its host does not resolve to an internal address
+func (s *service) checkTarget(ctx context.Context, target string) error {
+	if err := s.scanner.Scan(ctx, target); err != nil {
+		return err
+	}
+	if err := s.guard.CheckURL(ctx, target); err != nil {
+		log.Printf("[SHORTENER] ERROR: SSRF check failed: %v", err)
+		return err
+	}
+	return nil
 }
 
 // GetAnalytics retrieves analytics data for a URL
-func (s *service) GetAnalytics(ctx context.Context, shortCode string, days int) (*AnalyticsResponse, error) {
-	log.Printf("[SHORTENER] Getting analytics for: %s (last %d days)", shortCode, days)
+func (s *service) GetAnalytics(ctx context.Context, shortCode string, req *AnalyticsRequest) (*AnalyticsResponse, error) {
+	if req == nil {
+		req = &AnalyticsRequest{}
+	}
+
+	period, err := resolveAnalyticsPeriod(req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	includeBots := req.IncludeBots
+	log.Printf("[SHORTENER] Getting analytics for: %s (%s to %s, %s in %s, bots: %v)", shortCode,
+		period.from.Format(time.RFC3339), period.to.Format(time.RFC3339), period.granularity, period.loc, includeBots)
 
 	url, err := s.repo.GetURLByShortCode(ctx, shortCode)
 	if err != nil {
 		return nil, ErrURLNotFound
 	}
 
-	// Get basic click data
+	// Get basic click data (sharded counters only hold human clicks)
 	clickCount, _ := s.repo.GetClickCount(ctx, url.ID)
-	lastClicked, _ := s.repo.GetLastClicked(ctx, url.ID)
+	lastClicked, _ := s.repo.GetLastClicked(ctx, url.ID, includeBots)
+
+	botClicks, err := s.repo.GetBotClickCount(ctx, url.ID)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get bot click count: %v", err)
+	}
+	if includeBots {
+		clickCount += botClicks
+	}
 
-	// Calculate period
-	endTime := time.Now()
-	startTime := endTime.AddDate(0, 0, -days)
+	startTime, endTime := period.from, period.to
+	timezone := period.loc.String()
 
-	// Get detailed analytics
-	clicksByDay, err := s.repo.GetClicksByDay(ctx, url.ID, days)
+	// Daily series in the requested timezone, reused when day granularity was asked for
+	daySeries, err := s.getClickSeries(ctx, url.ID, startTime, endTime, models.GranularityDay, period.loc, includeBots)
 	if err != nil {
-		log.Printf("[SHORTENER] WARNING: Failed to get clicks by day: %v", err)
-		clicksByDay = []models.DayStat{} // Default to empty
+		return nil, err
 	}
-	
-	topReferrers, err := s.repo.GetTopReferrers(ctx, url.ID, days, 10)
+
+	timeSeries := daySeries
+	if period.granularity != models.GranularityDay {
+		timeSeries, err = s.getClickSeries(ctx, url.ID, startTime, endTime, period.granularity, period.loc, includeBots)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var periodClicks int64
+	for _, bucket := range daySeries {
+		periodClicks += bucket.Clicks
+	}
+
+	topReferrers, err := s.repo.GetTopReferrers(ctx, url.ID, startTime, endTime, 10, includeBots)
 	if err != nil {
 		log.Printf("[SHORTENER] WARNING: Failed to get top referrers: %v", err)
 		topReferrers = []models.ReferrerStat{} // Default to empty
 	}
 	
-	browserStats, err := s.repo.GetBrowserStats(ctx, url.ID, days, 10)
+	browserStats, err := s.repo.GetBrowserStats(ctx, url.ID, startTime, endTime, 10, includeBots)
 	if err != nil {
 		log.Printf("[SHORTENER] WARNING: Failed to get browser stats: %v", err)
 		browserStats = []models.BrowserStat{} // Default to empty
 	}
 
+	osStats, err := s.repo.GetOSStats(ctx, url.ID, startTime, endTime, 10, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get OS stats: %v", err)
+		osStats = []models.OSStat{} // Default to empty
+	}
+
+	deviceStats, err := s.repo.GetDeviceStats(ctx, url.ID, startTime, endTime, 10, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get device stats: %v", err)
+		deviceStats = []models.DeviceStat{} // Default to empty
+	}
+
+	topCountries, err := s.repo.GetTopCountries(ctx, url.ID, startTime, endTime, 10, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get top countries: %v", err)
+		topCountries = []models.CountryStat{} // Default to empty
+	}
+
+	topCities, err := s.repo.GetTopCities(ctx, url.ID, startTime, endTime, 10, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get top cities: %v", err)
+		topCities = []models.CityStat{} // Default to empty
+	}
+
+	revisionStats, err := s.repo.GetRevisionStats(ctx, url.ID, startTime, endTime, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get revision stats: %v", err)
+		revisionStats = []models.RevisionStat{} // Default to empty
+	}
+
+	// Estimate unique visitors from the daily HyperLogLog sketches.
+	// Sketches are kept per UTC day, so per-day uniques line up exactly only in UTC.
+	uniqueClicks, uniquesByDay := s.getUniqueVisitors(ctx, url.ID, url.CreatedAt, endTime, includeBots)
+	clicksByDay := make([]models.DayStat, 0, len(daySeries))
+	for i := len(daySeries) - 1; i >= 0; i-- {
+		date := daySeries[i].Start.Format("2006-01-02")
+		clicksByDay = append(clicksByDay, models.DayStat{
+			Date:         date,
+			Clicks:       daySeries[i].Clicks,
+			UniqueClicks: uniquesByDay[date],
+		})
+	}
+
+	comparison := s.comparePreviousPeriod(ctx, url.ID, startTime, endTime, periodClicks, includeBots)
+
 	// Create analytics response
 	analytics := &AnalyticsResponse{
-		ShortCode:    shortCode,
-		TargetURL:    url.TargetURL,
-		TotalClicks:  clickCount,
-		UniqueClicks: clickCount, // Simplified - in production, calculate unique IPs
-		LastClicked:  lastClicked,
-		CreatedAt:    url.CreatedAt,
-		PeriodStart:  startTime,
-		PeriodEnd:    endTime,
-		ClicksByDay:  clicksByDay,
-		TopReferrers: topReferrers,
-		TopCountries: []models.CountryStat{}, // Would require GeoIP lookup
-		BrowserStats: browserStats,
-	}
-
-	log.Printf("[SHORTENER] SUCCESS: Analytics retrieved - TotalClicks: %d", clickCount)
+		ShortCode:     shortCode,
+		TargetURL:     url.TargetURL,
+		TotalClicks:   clickCount,
+		UniqueClicks:  uniqueClicks,
+		BotClicks:     botClicks,
+		IncludesBots:  includeBots,
+		LastClicked:   lastClicked,
+		CreatedAt:     url.CreatedAt,
+		PeriodStart:   startTime,
+		PeriodEnd:     endTime,
+		PeriodClicks:  periodClicks,
+		Granularity:   period.granularity,
+		Timezone:      timezone,
+		TimeSeries:    timeSeries,
+		Comparison:    comparison,
+		ClicksByDay:   clicksByDay,
+		TopReferrers:  topReferrers,
+		TopCountries:  topCountries,
+		TopCities:     topCities,
+		BrowserStats:  browserStats,
+		OSStats:       osStats,
+		DeviceStats:   deviceStats,
+		RevisionStats: revisionStats,
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Analytics retrieved - TotalClicks: %d, UniqueClicks: %d, PeriodClicks: %d",
+		clickCount, uniqueClicks, periodClicks)
 	return analytics, nil
 }
 
+// analyticsPeriod is a validated analytics time range
+type analyticsPeriod struct {
+	from        time.Time
+	to          time.Time
+	granularity string
+	loc         *time.Location
+}
+
+// resolveAnalyticsPeriod applies defaults to an analytics request and validates it
+func resolveAnalyticsPeriod(req *AnalyticsRequest, now time.Time) (*analyticsPeriod, error) {
+	period := &analyticsPeriod{
+		from:        req.From,
+		to:          req.To,
+		granularity: req.Granularity,
+		loc:         time.UTC,
+	}
+
+	if req.Timezone != "" {
+		loc, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRequest, req.Timezone)
+		}
+		period.loc = loc
+	}
+
+	if period.granularity == "" {
+		period.granularity = models.GranularityDay
+	}
+	if !models.IsValidGranularity(period.granularity) {
+		return nil, fmt.Errorf("%w: unknown granularity %q", ErrInvalidRequest, period.granularity)
+	}
+
+	if period.to.IsZero() {
+		period.to = now
+	}
+	if period.from.IsZero() {
+		days := req.Days
+		if days <= 0 {
+			days = defaultAnalyticsDays
+		}
+		period.from = period.to.AddDate(0, 0, -days)
+	}
+	if !period.from.Before(period.to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+
+	return period, nil
+}
+
+// getClickSeries loads a click series and fills in the empty buckets
+func (s *service) getClickSeries(ctx context.Context, urlID int64, from, to time.Time, granularity string, loc *time.Location, includeBots bool) ([]models.TimeBucket, error) {
+	buckets, err := s.repo.GetClickSeries(ctx, urlID, from, to, granularity, loc.String(), includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get %s click series: %v", granularity, err)
+		buckets = nil // Zero-fill below
+	}
+
+	return zeroFillSeries(buckets, from, to, granularity, loc)
+}
+
+// comparePreviousPeriod compares clicks and unique visitors in [from, to) with
+// the period of equal length immediately before it
+func (s *service) comparePreviousPeriod(ctx context.Context, urlID int64, from, to time.Time, clicks int64, includeBots bool) *PeriodComparison {
+	prevFrom, prevTo := from.Add(-to.Sub(from)), from
+
+	prevClicks, err := s.repo.GetClickCountInRange(ctx, urlID, prevFrom, prevTo, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get previous period clicks: %v", err)
+		return nil
+	}
+
+	uniques, _ := s.getUniqueVisitors(ctx, urlID, from, to, includeBots)
+	prevUniques, _ := s.getUniqueVisitors(ctx, urlID, prevFrom, prevTo, includeBots)
+
+	return &PeriodComparison{
+		PreviousStart:        prevFrom,
+		PreviousEnd:          prevTo,
+		PreviousClicks:       prevClicks,
+		ClicksDelta:          clicks - prevClicks,
+		ClicksChange:         percentChange(clicks, prevClicks),
+		UniqueClicks:         uniques,
+		PreviousUniqueClicks: prevUniques,
+		UniqueClicksDelta:    uniques - prevUniques,
+		UniqueClicksChange:   percentChange(uniques, prevUniques),
+	}
+}
+
+// getUniqueVisitors merges the daily sketches between from and to, returning the
+// estimate for the whole range and the per-day estimates keyed by date
+func (s *service) getUniqueVisitors(ctx context.Context, urlID int64, from, to time.Time, includeBots bool) (int64, map[string]int64) {
+	byDay := make(map[string]int64)
+
+	sketches, err := s.repo.GetUniqueSketches(ctx, urlID, from, to, includeBots)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get unique sketches: %v", err)
+		return 0, byDay
+	}
+
+	total := hll.NewDefault()
+	for day, sketch := range sketches {
+		byDay[day] = int64(sketch.Estimate())
+		if err := total.Merge(sketch); err != nil {
+			log.Printf("[SHORTENER] WARNING: Failed to merge unique sketch for %s: %v", day, err)
+		}
+	}
+
+	return int64(total.Estimate()), byDay
+}
+
+// GetCampaignStats retrieves clicks grouped by UTM parameters, for one link or all links
+func (s *service) GetCampaignStats(ctx context.Context, req *CampaignRequest) (*CampaignResponse, error) {
+	if req == nil {
+		req = &CampaignRequest{}
+	}
+
+	query, err := s.buildCampaignQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[SHORTENER] Getting campaign stats (link: %q, group by %v, filters %v)", req.ShortCode, query.GroupBy, query.Filters)
+
+	if req.ShortCode != "" {
+		url, err := s.repo.GetURLByShortCode(ctx, req.ShortCode)
+		if err != nil {
+			return nil, ErrURLNotFound
+		}
+		query.URLID = url.ID
+	}
+
+	campaigns, err := s.repo.GetCampaignStats(ctx, query)
+	if err != nil {
+		log.Printf("[SHORTENER] ERROR: Failed to get campaign stats: %v", err)
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+
+	// A query with no grouping yields the grand total for the same filters
+	totalQuery := *query
+	totalQuery.GroupBy = nil
+	totalQuery.GroupByLink = false
+	totalQuery.Limit = 1
+
+	var totalClicks int64
+	if totals, err := s.repo.GetCampaignStats(ctx, &totalQuery); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to get campaign total: %v", err)
+	} else if len(totals) > 0 {
+		totalClicks = totals[0].Clicks
+	}
+
+	response := &CampaignResponse{
+		ShortCode:    req.ShortCode,
+		GroupBy:      query.GroupBy,
+		GroupByLink:  query.GroupByLink,
+		Filters:      query.Filters,
+		TotalClicks:  totalClicks,
+		IncludesBots: query.IncludeBots,
+		Campaigns:    campaigns,
+		PeriodStart:  query.From,
+		PeriodEnd:    query.To,
+	}
+
+	log.Printf("[SHORTENER] SUCCESS: Campaign stats retrieved - Rows: %d, TotalClicks: %d", len(campaigns), totalClicks)
+	return response, nil
+}
+
+// buildCampaignQuery validates a campaign request and fills in defaults. The
+// range must lie within the click retention window.
+func (s *service) buildCampaignQuery(req *CampaignRequest) (*database.CampaignQuery, error) {
+	query := &database.CampaignQuery{
+		From:        req.From,
+		To:          req.To,
+		GroupByLink: req.GroupByLink,
+		Filters:     make(map[string]string),
+		IncludeBots: req.IncludeBots,
+		Limit:       req.Limit,
+	}
+
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.AddDate(0, 0, -defaultCampaignDays)
+	}
+
+	// UTM dimensions are only kept in raw click events, so ranges reaching
+	// past their retention would silently lose clicks. A default start is
+	// moved up instead.
+	if s.config.ClickRetention > 0 {
+		oldest := time.Now().Add(-s.config.ClickRetention)
+		if query.From.Before(oldest) {
+			if !req.From.IsZero() || query.To.Before(oldest) {
+				return nil, fmt.Errorf("%w: campaign stats only cover the %d days of click retention", ErrInvalidRequest, int(s.config.ClickRetention.Hours()/24))
+			}
+			query.From = oldest
+		}
+	}
+	if !query.From.Before(query.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+
+	groupBy := req.GroupBy
+	if len(groupBy) == 0 {
+		groupBy = []string{models.UTMSource, models.UTMMedium, models.UTMCampaign}
+	}
+	seen := make(map[string]bool)
+	for _, name := range groupBy {
+		dim, ok := normalizeUTMDimension(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown UTM dimension %q", ErrInvalidRequest, name)
+		}
+		if !seen[dim] {
+			seen[dim] = true
+			query.GroupBy = append(query.GroupBy, dim)
+		}
+	}
+
+	for name, value := range req.Filters {
+		dim, ok := normalizeUTMDimension(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown UTM dimension %q", ErrInvalidRequest, name)
+		}
+		query.Filters[dim] = value
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = defaultCampaignLimit
+	}
+	if query.Limit > maxCampaignLimit {
+		query.Limit = maxCampaignLimit
+	}
+
+	return query, nil
+}
+
+// normalizeUTMDimension accepts "source" or "utm_source" style names
+func normalizeUTMDimension(name string) (string, bool) {
+	dim := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "utm_")
+	for _, known := range models.UTMDimensions {
+		if dim == known {
+			return dim, true
+		}
+	}
+	return "", false
+}
+
 // ValidateCustomCode validates a custom code for availability
 func (s *service) ValidateCustomCode(ctx context.Context, code string) error {
 	log.Printf("[SHORTENER] Validating custom code: %s", code)
+	code = s.customCode(code)
 
 	// Basic validation
 	if err := models.ValidateCustomCode(code); err != nil {
 		return err
 	}
 
-	// Check if reserved
-	isReserved, err := s.repo.IsReservedCode(ctx, code)
-	if err != nil {
-		return fmt.Errorf("failed to check reserved code: %w", err)
-	}
-
-	if isReserved {
+	// Check if reserved, by a code or a pattern
+	if s.isReservedCode(ctx, code) {
 		return models.ErrReservedCode
 	}
 
 	// Check if already taken
-	_, err = s.repo.GetURLByShortCode(ctx, code)
+	_, err := s.repo.GetURLByShortCode(ctx, code)
 	if err == nil {
 		return ErrCustomCodeTaken
 	}
 
+	// Codes of permanently deleted links are never reissued
+	retired, err := s.repo.IsCodeTombstoned(ctx, code)
+	if err != nil {
+		return fmt.Errorf("failed to check retired code: %w", err)
+	}
+	if retired {
+		return ErrCustomCodeRetired
+	}
+
 	return nil
 }
 
@@ -416,28 +1143,48 @@ func (s *service) handleCustomCode(ctx context.Context, customCode string) (stri
 		log.Printf("[SHORTENER] ERROR: Custom code validation failed: %v", err)
 		return "", err
 	}
+	customCode = s.customCode(customCode)
 
 	log.Printf("[SHORTENER] SUCCESS: Custom code validated: %s", customCode)
 	return customCode, nil
 }
 
 // generateUniqueCode generates a unique short code with collision handling
-func (s *service) generateUniqueCode(ctx context.Context) (string, error) {
+func (s *service) generateUniqueCode(ctx context.Context, codes CodeStrategy) (string, error) {
 	log.Printf("[SHORTENER] Generating unique code")
 
+	// Codes that never repeat only conflict with custom, reserved and retired
+	// codes, which the insert rejects, and with reserved patterns
+	if codes.Unique() {
+		for attempt := 0; attempt < s.config.MaxRetries; attempt++ {
+			code, err := codes.NextCode(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to generate code: %w", err)
+			}
+			// Pattern reservations are only known here, so skip past them
+			if s.isReservedCode(ctx, code) {
+				log.Printf("[SHORTENER] WARNING: Skipping reserved code: %s", code)
+				continue
+			}
+			log.Printf("[SHORTENER] SUCCESS: Allocated code: %s", code)
+			return code, nil
+		}
+		return "", fmt.Errorf("%w: every allocated code was reserved", ErrTooManyRetries)
+	}
+
 	var lastErr error
 	collisionCount := 0
 
 	for attempt := 0; attempt < s.config.MaxRetries; attempt++ {
 		// Generate code
-		code, err := s.generator.Generate()
+		code, err := codes.NextCode(ctx)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate code: %w", err)
 		}
 
-		// Check if code exists
+		// Check if code is reserved, exists, or existed and was retired
 		_, err = s.repo.GetURLByShortCode(ctx, code)
-		if err != nil {
+		if err != nil && !s.isCodeRetired(ctx, code) && !s.isReservedCode(ctx, code) {
 			// Code doesn't exist, we can use it
 			if collisionCount > 0 {
 				log.Printf("[SHORTENER] SUCCESS: Generated unique code after %d collisions: %s",
@@ -450,6 +1197,10 @@ func (s *service) generateUniqueCode(ctx context.Context) (string, error) {
 
 		// Collision detected
 		collisionCount++
+		adaptive, isAdaptive := codes.(*AdaptiveStrategy)
+		if isAdaptive {
+			adaptive.Collided()
+		}
 		lastErr = fmt.Errorf("collision detected for code: %s", code)
 		log.Printf("[SHORTENER] WARNING: Collision %d/%d for code: %s",
 			attempt+1, s.config.MaxRetries, code)
@@ -457,9 +1208,14 @@ func (s *service) generateUniqueCode(ctx context.Context) (string, error) {
 		// If too many collisions, increase code length
 		if collisionCount >= s.config.CollisionThreshold {
 			log.Printf("[SHORTENER] WARNING: High collision rate, increasing code length")
-			newGenerator, err := NewGeneratorWithLength(s.generator.GetCodeLength() + 1)
-			if err == nil {
-				s.generator = newGenerator
+			if isAdaptive {
+				adaptive.Grow(ctx, len(code))
+			} else if generator, ok := codes.(*Generator); ok {
+				// Generators of other alphabets grow for this request only
+				newGenerator, err := NewGeneratorWithAlphabet(generator.alphabet, generator.GetCodeLength()+1)
+				if err == nil {
+					codes = newGenerator
+				}
 			}
 		}
 	}
@@ -468,33 +1224,86 @@ func (s *service) generateUniqueCode(ctx context.Context) (string, error) {
 	return "", fmt.Errorf("%w: %v", ErrTooManyRetries, lastErr)
 }
 
-// recordClickAsync records a click event asynchronously
-func (s *service) recordClickAsync(ctx context.Context, url *models.URL, clickCtx *ClickContext) error {
+// isCodeRetired reports whether a code belonged to a purged link. Lookup
+// failures count as retired so a code is never reissued by mistake.
+func (s *service) isCodeRetired(ctx context.Context, code string) bool {
+	retired, err := s.repo.IsCodeTombstoned(ctx, code)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to check retired code %s: %v", code, err)
+		return true
+	}
+	return retired
+}
+
+// recordClickAsync records a click event asynchronously. It returns the
+// recorded event, or nil when analytics skipped the click.
+func (s *service) recordClickAsync(ctx context.Context, url *models.URL, clickCtx *ClickContext) (*models.ClickEvent, error) {
 	if !s.config.EnableAnalytics || clickCtx == nil {
-		return nil
+		return nil, nil
 	}
 
 	// Respect Do Not Track
 	if s.config.RespectDNT && clickCtx.DNTHeader {
 		log.Printf("[SHORTENER] Skipping analytics due to DNT header")
-		return nil
+		return nil, nil
 	}
 
 	// Parse click context
 	clickEvent := s.parseClickContext(url.ID, clickCtx)
+	revision := url.Revision
+	clickEvent.Revision = &revision
 
 	// Record in database
 	if err := s.repo.RecordClick(ctx, clickEvent); err != nil {
-		return fmt.Errorf("failed to record click: %w", err)
+		return nil, fmt.Errorf("failed to record click: %w", err)
 	}
 
-	// Update sharded counters
-	if err := s.repo.UpdateCounterShards(ctx, url.ID); err != nil {
-		log.Printf("[SHORTENER] WARNING: Failed to update counter shards: %v", err)
-		// Don't return error as main click recording succeeded
+	// Update sharded counters (human clicks only, bots are counted from events)
+	if !clickEvent.IsBot {
+		if err := s.repo.UpdateCounterShards(ctx, url.ID); err != nil {
+			log.Printf("[SHORTENER] WARNING: Failed to update counter shards: %v", err)
+			// Don't return error as main click recording succeeded
+		}
 	}
 
-	return nil
+	// Update unique visitor sketch
+	if err := s.recordUniqueVisitor(ctx, clickEvent); err != nil {
+		log.Printf("[SHORTENER] WARNING: Failed to record unique visitor: %v", err)
+	}
+
+	return clickEvent, nil
+}
+
+// recordUniqueVisitor adds the click's visitor hash to the daily HyperLogLog sketch
+func (s *service) recordUniqueVisitor(ctx context.Context, click *models.ClickEvent) error {
+	hash, ok := s.visitorHash(click)
+	if !ok {
+		return nil
+	}
+
+	sketch := hll.NewDefault()
+	sketch.Insert(hash)
+	return s.repo.MergeUniqueSketch(ctx, click.URLID, click.OccurredAt, click.IsBot, sketch)
+}
+
+// visitorHash derives a salted 64-bit visitor identifier from the
+// (already anonymized) IP and user agent of a click
+func (s *service) visitorHash(click *models.ClickEvent) (uint64, bool) {
+	if click.IP == nil && click.UserAgent == nil {
+		return 0, false
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.config.VisitorHashSalt))
+	if click.IP != nil {
+		mac.Write([]byte(*click.IP))
+	}
+	mac.Write([]byte{0})
+	if click.UserAgent != nil {
+		mac.Write([]byte(*click.UserAgent))
+	}
+
+	sum := mac.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), true
 }
 
 // parseClickContext parses HTTP request context into click event
@@ -504,6 +1313,12 @@ func (s *service) parseClickContext(urlID int64, clickCtx *ClickContext) *models
 	click := &models.ClickEvent{
 		URLID:      urlID,
 		OccurredAt: now,
+		IsBot:      clickCtx.IsBot,
+	}
+
+	// Run enrichment stages on the raw context (before IP anonymization)
+	for _, enrich := range s.enrichers {
+		enrich(click, clickCtx)
 	}
 
 	// Process IP address
@@ -564,6 +1379,48 @@ func (s *service) parseClickContext(urlID int64, clickCtx *ClickContext) *models
 	return click
 }
 
+// enrichUserAgent parses the user agent into browser, OS, device and bot flag
+func (s *service) enrichUserAgent(click *models.ClickEvent, clickCtx *ClickContext) {
+	if clickCtx.UserAgent == "" {
+		return
+	}
+
+	agent := useragent.Parse(clickCtx.UserAgent)
+	click.Browser = optionalString(agent.Browser)
+	click.BrowserVersion = optionalString(agent.BrowserVersion)
+	click.OS = optionalString(agent.OS)
+	click.DeviceType = optionalString(agent.DeviceType)
+	click.IsBot = click.IsBot || agent.IsBot
+}
+
+// enrichGeoIP resolves the client IP to country, region and city
+func (s *service) enrichGeoIP(click *models.ClickEvent, clickCtx *ClickContext) {
+	if clickCtx.IP == "" {
+		return
+	}
+
+	loc, err := s.geo.Lookup(clickCtx.IP)
+	if err != nil {
+		log.Printf("[SHORTENER] WARNING: GeoIP lookup failed: %v", err)
+		return
+	}
+	if loc == nil {
+		return
+	}
+
+	click.Country = optionalString(loc.CountryCode)
+	click.Region = optionalString(loc.Region)
+	click.City = optionalString(loc.City)
+}
+
+// optionalString returns nil for empty strings so they are stored as NULL
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
 // anonymizeIP anonymizes an IP address for privacy
 func (s *service) anonymizeIP(ipStr string) string {
 	ip := net.ParseIP(ipStr)
@@ -636,6 +1493,9 @@ func ParseClickContextFromRequest(r *http.Request) *ClickContext {
 	// Check DNT header
 	dnt := r.Header.Get("DNT") == "1" || r.Header.Get("Sec-GPC") == "1"
 
+	// Flag unfurlers, crawlers and speculative prefetches
+	isBot, botReason := detectBot(r)
+
 	return &ClickContext{
 		IP:          ip,
 		UserAgent:   r.Header.Get("User-Agent"),
@@ -643,10 +1503,36 @@ func ParseClickContextFromRequest(r *http.Request) *ClickContext {
 		UTMParams:   utmParams,
 		QueryParams: queryParams,
 		DNTHeader:   dnt,
+		IsBot:       isBot,
+		BotReason:   botReason,
 		Request:     r,
 	}
 }
 
+// detectBot reports whether a request was made by a bot rather than a person
+// clicking the link, along with the reason it was flagged
+func detectBot(r *http.Request) (bool, string) {
+	// Link unfurlers and scanners commonly probe with HEAD
+	if r.Method == http.MethodHead {
+		return true, "head_request"
+	}
+
+	// Browser prefetch/prerender and link previews announce themselves
+	for _, header := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(r.Header.Get(header))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "prerender") ||
+			strings.Contains(value, "preview") {
+			return true, "prefetch"
+		}
+	}
+
+	if useragent.IsBot(r.Header.Get("User-Agent")) {
+		return true, "user_agent"
+	}
+
+	return false, ""
+}
+
 // extractIPAddress extracts the real IP address from HTTP request
 func extractIPAddress(r *http.Request) string {
 	// Try X-Forwarded-For header first
@@ -686,19 +1572,42 @@ func (s *service) clickWorker(id int) {
 				return
 			}
 			ctx := context.Background()
-			if err := s.recordClickAsync(ctx, job.url, job.clickCtx); err != nil {
+			click, err := s.recordClickAsync(ctx, job.url, job.clickCtx)
+			if err != nil {
 				log.Printf("[SHORTENER] WARNING: Worker %d failed to record click: %v", id, err)
+				continue
+			}
+			if click != nil {
+				s.publishLive(job.url, click)
 			}
 		}
 	}
 }
 
+// runExclusive runs one pass of a periodic job under its advisory lock, so
+// only one instance runs it at a time. Passes are skipped while another
+// instance holds the lock.
+func (s *service) runExclusive(job string, lockKey int64, timeout time.Duration, run func(ctx context.Context) error) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	acquired, err := s.repo.WithAdvisoryLock(ctx, lockKey, run)
+	switch {
+	case err != nil:
+		log.Printf("[SHORTENER] WARNING: %s pass failed: %v", job, err)
+	case !acquired:
+		log.Printf("[SHORTENER] %s pass skipped, another instance holds its lock", job)
+	}
+	return acquired
+}
+
 // Shutdown gracefully shuts down the service, draining pending clicks
 func (s *service) Shutdown(ctx context.Context) error {
 	log.Printf("[SHORTENER] Shutting down service, draining %d pending clicks", len(s.clickChan))
 
 	// Signal workers to stop accepting new work
 	close(s.shutdown)
+	s.CloseLiveStreams()
 
 	// Drain remaining clicks with timeout
 	done := make(chan struct{})
@@ -711,7 +1620,7 @@ func (s *service) Shutdown(ctx context.Context) error {
 					close(done)
 					return
 				}
-				if err := s.recordClickAsync(ctx, job.url, job.clickCtx); err != nil {
+				if _, err := s.recordClickAsync(ctx, job.url, job.clickCtx); err != nil {
 					log.Printf("[SHORTENER] WARNING: Failed to record click during shutdown: %v", err)
 				}
 			default:
@@ -726,6 +1635,11 @@ func (s *service) Shutdown(ctx context.Context) error {
 
 	select {
 	case <-done:
+		if s.geo != nil {
+			if err := s.geo.Close(); err != nil {
+				log.Printf("[SHORTENER] WARNING: Failed to close GeoIP database: %v", err)
+			}
+		}
 		log.Printf("[SHORTENER] Service shutdown complete")
 		return nil
 	case <-ctx.Done():
@@ -6,19 +6,46 @@ import (
 	"time"
 	
 	"backend/internal/models"
+	"backend/internal/ssrf"
 )
 
 // Config holds configuration for the shortener service
 type Config struct {
-	MaxRetries          int           `json:"max_retries"`
-	BaseURL             string        `json:"base_url"`
-	DefaultCodeLength   int           `json:"default_code_length"`
-	MaxCustomCodeLength int           `json:"max_custom_code_length"`
-	CollisionThreshold  int           `json:"collision_threshold"`
-	ClickTimeout        time.Duration `json:"click_timeout"`
-	EnableAnalytics     bool          `json:"enable_analytics"`
-	AnonymizeIPs        bool          `json:"anonymize_ips"`
-	RespectDNT          bool          `json:"respect_dnt"`
+	MaxRetries          int            `json:"max_retries"`
+	BaseURL             string         `json:"base_url"`
+	DefaultCodeLength   int            `json:"default_code_length"`
+	MaxCustomCodeLength int            `json:"max_custom_code_length"`
+	CollisionThreshold  int            `json:"collision_threshold"`
+	ClickTimeout        time.Duration  `json:"click_timeout"`
+	EnableAnalytics     bool           `json:"enable_analytics"`
+	AnonymizeIPs        bool           `json:"anonymize_ips"`
+	RespectDNT          bool           `json:"respect_dnt"`
+	VisitorHashSalt     string         `json:"-"`                     // Secret salt for unique-visitor hashing
+	GeoIPDatabasePath   string         `json:"geoip_database_path"`   // Optional .mmdb file for click geolocation
+	RollupInterval      time.Duration  `json:"rollup_interval"`       // How often clicks are compacted into rollups; 0 disables
+	ClickRetention      time.Duration  `json:"click_retention"`       // How long raw click events are kept; 0 keeps them forever
+	MaintenanceInterval time.Duration  `json:"maintenance_interval"`  // How often partitions and retention are managed; 0 disables
+	LiveFanout          bool           `json:"live_fanout"`           // Share live clicks with other instances via Postgres NOTIFY
+	DeletionGracePeriod time.Duration  `json:"deletion_grace_period"` // How long a deleted link can be restored before it is purged
+	URLBlocklists       []string       `json:"url_blocklists"`        // Hosts-file or domain/URL lists of blocked targets
+	URLHashPrefixLists  []string       `json:"url_hash_prefix_lists"` // Safe Browsing-style SHA-256 hash prefix lists
+	URLAllowlists       []string       `json:"url_allowlists"`        // Domains exempt from every blocking rule
+	BlockedExtensions   []string       `json:"blocked_extensions"`    // File extensions of blocked target paths
+	SSRFStrict          bool           `json:"ssrf_strict"`           // Reject target hosts that do not resolve
+	Resolver            ssrf.Resolver  `json:"-"`                     // DNS resolver for target checks; nil uses the system resolver
+	ProbeTimeout        time.Duration  `json:"probe_timeout"`         // Per-request timeout of destination probes and preview fetches
+	FetchPreviews       bool           `json:"fetch_previews"`        // Fetch title, description and image of new targets
+	StripTrackingParams bool           `json:"strip_tracking_params"` // Drop utm_* and click ID parameters from targets
+	SortQueryParams     bool           `json:"sort_query_params"`     // Order target query parameters by name
+	StripFragments      bool           `json:"strip_fragments"`       // Drop fragments from targets, so they dedupe without them
+	DeduplicateTargets  bool           `json:"deduplicate_targets"`   // Default of CreateURLRequest.Deduplicate
+	OwnerDeduplication  map[int64]bool `json:"owner_deduplication"`   // Per-owner defaults overriding DeduplicateTargets
+	CodeStrategy        string         `json:"code_strategy"`         // How generated codes are chosen: random (default) or sequence
+	CodeSecret          string         `json:"-"`                     // Key of the sequence code permutation; must never change
+	CodeBlockSize       int            `json:"code_block_size"`       // Sequence ids leased per database round trip
+	CodeAlphabet        string         `json:"code_alphabet"`         // Codes generated: base62 (default), readable, crockford or words
+	IgnoreCodeCase      bool           `json:"ignore_code_case"`      // Resolve codes typed in any case; needs crockford or words
+	MaxCodeOccupancy    float64        `json:"max_code_occupancy"`    // Share of a length's keyspace in use before random codes grow longer
 }
 
 // Request types
@@ -26,7 +53,14 @@ type CreateURLRequest struct {
 	URL        string     `json:"url" validate:"required"`
 	CustomCode string     `json:"custom_code,omitempty"`
 	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
-	UserID     *int64     `json:"user_id,omitempty"` // For future multi-tenant support
+	UserID     *int64     `json:"user_id,omitempty"` // Owner of the link, scoping deduplication
+
+	// Return the owner's existing live link to the same target and expiry
+	// instead of creating one; unset uses the owner's default
+	Deduplicate *bool `json:"deduplicate,omitempty"`
+
+	// Alphabet of the generated code, overriding Config.CodeAlphabet
+	CodeAlphabet string `json:"code_alphabet,omitempty"`
 }
 
 type UpdateURLRequest struct {
@@ -35,6 +69,56 @@ type UpdateURLRequest struct {
 	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
+type AnalyticsRequest struct {
+	Days        int       `json:"days"`         // Range length when From is not set
+	From        time.Time `json:"from"`         // Inclusive
+	To          time.Time `json:"to"`           // Exclusive, defaults to now
+	Granularity string    `json:"granularity"`  // minute, hour, day (default), week or month
+	Timezone    string    `json:"timezone"`     // IANA name for bucket boundaries, defaults to UTC
+	IncludeBots bool      `json:"include_bots"` // Bots are excluded from counts by default
+}
+
+// CampaignRequest selects a UTM campaign breakdown, optionally drilled
+// down by fixing some dimensions through Filters
+type CampaignRequest struct {
+	ShortCode   string            `json:"short_code,omitempty"` // Empty for all links
+	From        time.Time         `json:"from"`                 // Defaults to 30 days before To; within click retention
+	To          time.Time         `json:"to"`                   // Defaults to now
+	GroupBy     []string          `json:"group_by"`             // Defaults to source, medium, campaign
+	GroupByLink bool              `json:"group_by_link"`
+	Filters     map[string]string `json:"filters,omitempty"`
+	IncludeBots bool              `json:"include_bots"`
+	Limit       int               `json:"limit"`
+}
+
+// ExportRequest selects raw click events to export
+type ExportRequest struct {
+	ShortCode   string    `json:"short_code,omitempty"` // Empty for all links
+	From        time.Time `json:"from"`                 // Zero exports from the oldest stored click
+	To          time.Time `json:"to"`                   // Defaults to now
+	Format      string    `json:"format"`               // csv (default), ndjson or parquet
+	IncludeBots bool      `json:"include_bots"`
+}
+
+// ErasureRequest identifies a data subject whose clicks must be erased.
+// When both fields are set, clicks must match both.
+type ErasureRequest struct {
+	IP          string `json:"ip,omitempty"`
+	UserAgent   string `json:"user_agent,omitempty"`
+	MatchPrefix bool   `json:"match_prefix,omitempty"` // Allow an IP alone to match its whole anonymization prefix
+}
+
+// AuditRequest selects audit log entries, newest first
+type AuditRequest struct {
+	ShortCode string    `json:"short_code,omitempty"` // Empty for all links
+	Action    string    `json:"action,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`     // Defaults to now
+	Before    int64     `json:"before"` // Cursor from a previous response's next_before
+	Limit     int       `json:"limit"`
+}
+
 // Context types
 type ClickContext struct {
 	IP          string            `json:"ip"`
@@ -43,6 +127,8 @@ type ClickContext struct {
 	UTMParams   map[string]string `json:"utm_params"`
 	QueryParams map[string]string `json:"query_params"`
 	DNTHeader   bool              `json:"dnt_header"`
+	IsBot       bool              `json:"is_bot"`
+	BotReason   string            `json:"bot_reason,omitempty"`
 	Request     *http.Request     `json:"-"` // Original request for advanced parsing
 }
 
@@ -51,24 +137,132 @@ type AnalyticsResponse struct {
 	ShortCode      string                  `json:"short_code"`
 	TargetURL      string                  `json:"target_url"`
 	TotalClicks    int64                   `json:"total_clicks"`
-	UniqueClicks   int64                   `json:"unique_clicks"` // Estimated
+	UniqueClicks   int64                   `json:"unique_clicks"` // HyperLogLog estimate
+	BotClicks      int64                   `json:"bot_clicks"`
+	IncludesBots   bool                    `json:"includes_bots"`
 	LastClicked    *time.Time              `json:"last_clicked"`
 	CreatedAt      time.Time               `json:"created_at"`
+	PeriodClicks   int64                   `json:"period_clicks"` // Clicks between PeriodStart and PeriodEnd
+	Granularity    string                  `json:"granularity"`
+	Timezone       string                  `json:"timezone"`
+	TimeSeries     []models.TimeBucket     `json:"time_series"` // Zero-filled, at Granularity
+	Comparison     *PeriodComparison       `json:"comparison,omitempty"`
 	ClicksByDay    []models.DayStat        `json:"clicks_by_day"`
 	TopReferrers   []models.ReferrerStat   `json:"top_referrers"`
 	TopCountries   []models.CountryStat    `json:"top_countries"`
+	TopCities      []models.CityStat       `json:"top_cities"`
 	BrowserStats   []models.BrowserStat    `json:"browser_stats"`
+	OSStats        []models.OSStat         `json:"os_stats"`
+	DeviceStats    []models.DeviceStat     `json:"device_stats"`
+	RevisionStats  []models.RevisionStat   `json:"revision_stats"` // Raw clicks only, by revision live at click time
 	PeriodStart    time.Time               `json:"period_start"`
 	PeriodEnd      time.Time               `json:"period_end"`
 }
 
+// DeletionResponse describes a deleted link awaiting permanent deletion
+type DeletionResponse struct {
+	ShortCode  string    `json:"short_code"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	PurgeAfter time.Time `json:"purge_after"` // Restorable until then
+}
+
+// RevisionsResponse lists the revisions of a link, newest first
+type RevisionsResponse struct {
+	ShortCode       string               `json:"short_code"`
+	CurrentRevision int                  `json:"current_revision"`
+	Revisions       []models.URLRevision `json:"revisions"`
+}
+
+// RescanResult reports the outcome of a destination re-scan
+type RescanResult struct {
+	Scanned     int `json:"scanned"`
+	Quarantined int `json:"quarantined"`
+}
+
+// LinkCheckResult reports the outcome of a broken-link check
+type LinkCheckResult struct {
+	Checked int `json:"checked"`
+	Broken  int `json:"broken"`
+}
+
+// PreviewOverrideRequest sets the preview of a link; fields left out or
+// empty use the metadata fetched from the destination
+type PreviewOverrideRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Description *string `json:"description,omitempty"`
+	ImageURL    *string `json:"image_url,omitempty"`
+}
+
+// PreviewResponse is the preview of a link: the effective values and the
+// fetched and override values they combine
+type PreviewResponse struct {
+	ShortCode string             `json:"short_code"`
+	Effective models.LinkPreview `json:"effective"`
+	*models.URLPreview
+}
+
+// AuditResponse is one page of audit entries
+type AuditResponse struct {
+	Entries    []models.AuditEntry `json:"entries"`
+	NextBefore *int64              `json:"next_before,omitempty"` // Set when more entries may follow
+}
+
+// ErasureResponse reports the outcome of a data-subject erasure
+type ErasureResponse struct {
+	ClicksErased  int64  `json:"clicks_erased"`
+	MatchedPrefix string `json:"matched_prefix,omitempty"` // Anonymized address matched, covering other visitors too
+}
+
+// PeriodComparison compares the requested period with the equally long period before it
+type PeriodComparison struct {
+	PreviousStart        time.Time `json:"previous_start"`
+	PreviousEnd          time.Time `json:"previous_end"`
+	PreviousClicks       int64     `json:"previous_clicks"`
+	ClicksDelta          int64     `json:"clicks_delta"`
+	ClicksChange         *float64  `json:"clicks_change_pct"` // nil when the previous period had no clicks
+	UniqueClicks         int64     `json:"unique_clicks"`
+	PreviousUniqueClicks int64     `json:"previous_unique_clicks"`
+	UniqueClicksDelta    int64     `json:"unique_clicks_delta"`
+	UniqueClicksChange   *float64  `json:"unique_clicks_change_pct"`
+}
+
+type CampaignResponse struct {
+	ShortCode    string                `json:"short_code,omitempty"`
+	GroupBy      []string              `json:"group_by"`
+	GroupByLink  bool                  `json:"group_by_link"`
+	Filters      map[string]string     `json:"filters,omitempty"`
+	TotalClicks  int64                 `json:"total_clicks"` // All matching clicks, not just the returned rows
+	IncludesBots bool                  `json:"includes_bots"`
+	Campaigns    []models.CampaignStat `json:"campaigns"`
+	PeriodStart  time.Time             `json:"period_start"`
+	PeriodEnd    time.Time             `json:"period_end"`
+}
+
+// ReserveCodeRequest reserves a code, or a glob or regex pattern of codes
+type ReserveCodeRequest struct {
+	Code        string `json:"code"`
+	Kind        string `json:"kind,omitempty"` // exact (default), glob or regex
+	Reason      string `json:"reason"`
+	Description string `json:"description,omitempty"`
+}
 
 // Service errors
 var (
-	ErrURLNotFound      = errors.New("URL not found")
-	ErrURLExpired       = errors.New("URL has expired")
-	ErrURLInactive      = errors.New("URL is inactive")
-	ErrTooManyRetries   = errors.New("too many collision retries")
-	ErrCustomCodeTaken  = errors.New("custom code already taken")
-	ErrInvalidRequest   = errors.New("invalid request")
+	ErrURLNotFound       = errors.New("URL not found")
+	ErrURLExpired        = errors.New("URL has expired")
+	ErrURLInactive       = errors.New("URL is inactive")
+	ErrTooManyRetries    = errors.New("too many collision retries")
+	ErrCustomCodeTaken   = errors.New("custom code already taken")
+	ErrInvalidRequest    = errors.New("invalid request")
+	ErrShuttingDown      = errors.New("service is shutting down")
+	ErrURLDeleted        = errors.New("URL has been deleted")
+	ErrURLNotDeleted     = errors.New("URL is not deleted")
+	ErrRestoreExpired    = errors.New("restore window has passed")
+	ErrCustomCodeRetired = errors.New("custom code belonged to a deleted link")
+	ErrRevisionNotFound  = errors.New("revision not found")
+	ErrURLQuarantined    = errors.New("URL target was flagged as malicious")
+	ErrURLNotQuarantined = errors.New("URL is not quarantined")
+	ErrNotReserved       = errors.New("code is not reserved")
+	ErrAlreadyReserved   = errors.New("code is already reserved")
+	ErrBuiltinReserved   = errors.New("built-in reservations cannot be removed")
 )
\ No newline at end of file
@@ -0,0 +1,86 @@
+package shortener
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	wordCodeMinNumber = 10 // Two-digit suffixes, so every code has the same shape
+	wordCodeMaxNumber = 99
+)
+
+var (
+	//go:embed words/adjectives.txt
+	adjectiveList string
+
+	//go:embed words/nouns.txt
+	nounList string
+
+	adjectives = parseWordList(adjectiveList)
+	nouns      = parseWordList(nounList)
+)
+
+// WordStrategy generates codes of an adjective, a noun and a number, like
+// brave-otter-42, which are easy to read aloud and type. Its space of about
+// 1.2 million codes is small, so codes are looked up before use.
+type WordStrategy struct{}
+
+// NewWordStrategy creates a word code strategy
+func NewWordStrategy() *WordStrategy {
+	return &WordStrategy{}
+}
+
+// NextCode returns a random word code, implementing CodeStrategy
+func (w *WordStrategy) NextCode(ctx context.Context) (string, error) {
+	for {
+		adjective, err := randomIndex(len(adjectives))
+		if err != nil {
+			return "", err
+		}
+		noun, err := randomIndex(len(nouns))
+		if err != nil {
+			return "", err
+		}
+		number, err := randomIndex(wordCodeMaxNumber - wordCodeMinNumber + 1)
+		if err != nil {
+			return "", err
+		}
+
+		code := fmt.Sprintf("%s-%s-%d", adjectives[adjective], nouns[noun], wordCodeMinNumber+number)
+		if !IsProfane(code) {
+			return code, nil
+		}
+	}
+}
+
+// Unique reports false: word codes can collide and are looked up before use
+func (w *WordStrategy) Unique() bool {
+	return false
+}
+
+// isWordCode checks if a lowercase string has the shape of a word code
+func isWordCode(code string) bool {
+	parts := strings.Split(code, "-")
+	if len(parts) != 3 || len(parts[2]) != 2 {
+		return false
+	}
+	for _, word := range parts[:2] {
+		if word == "" || strings.Trim(word, "abcdefghijklmnopqrstuvwxyz") != "" {
+			return false
+		}
+	}
+	return strings.Trim(parts[2], "0123456789") == "" && parts[2][0] != '0'
+}
+
+func randomIndex(n int) (int, error) {
+	value, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, ErrRandomGeneration
+	}
+	return int(value.Int64()), nil
+}
@@ -0,0 +1,159 @@
+package ssrf
+
+import (
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// blockedPrefixes are ranges a link must never point into: loopback,
+// private, link-local, shared, documentation, multicast and reserved space
+var blockedPrefixes = mustPrefixes(
+	// IPv4
+	"0.0.0.0/8",       // "This" network
+	"10.0.0.0/8",      // Private
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"127.0.0.0/8",     // Loopback
+	"169.254.0.0/16",  // Link-local, including cloud metadata services
+	"172.16.0.0/12",   // Private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1
+	"192.88.99.0/24",  // 6to4 relay anycast
+	"192.168.0.0/16",  // Private
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	// IPv6
+	"::/128",        // Unspecified
+	"::1/128",       // Loopback
+	"100::/64",      // Discard
+	"2001::/32",     // Teredo, tunnels to arbitrary IPv4
+	"2001:db8::/32", // Documentation
+	"fc00::/7",      // Unique local
+	"fe80::/10",     // Link-local
+	"fec0::/10",     // Site-local (deprecated)
+	"ff00::/8",      // Multicast
+)
+
+// Ranges embedding an IPv4 address, which is checked in turn
+var (
+	nat64Prefix      = netip.MustParsePrefix("64:ff9b::/96")   // Well-known NAT64 prefix
+	nat64LocalPrefix = netip.MustParsePrefix("64:ff9b:1::/48") // Local-use NAT64
+	sixToFourPrefix  = netip.MustParsePrefix("2002::/16")      // 6to4
+)
+
+func mustPrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, len(cidrs))
+	for i, cidr := range cidrs {
+		prefixes[i] = netip.MustParsePrefix(cidr)
+	}
+	return prefixes
+}
+
+// IsBlockedAddr reports whether addr is internal or otherwise not publicly
+// routable. IPv4 addresses embedded in IPv4-mapped, NAT64 and 6to4 IPv6
+// addresses are checked as IPv4.
+func IsBlockedAddr(addr netip.Addr) bool {
+	addr = addr.WithZone("")
+	if embedded, ok := embeddedIPv4(addr); ok {
+		addr = embedded
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedIPv4 extracts the IPv4 address carried by an IPv6 address
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	if addr.Is4In6() {
+		return addr.Unmap(), true
+	}
+	if !addr.Is6() {
+		return netip.Addr{}, false
+	}
+
+	b := addr.As16()
+	switch {
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case nat64LocalPrefix.Contains(addr):
+		// RFC 6052 /48 layout: the IPv4 address straddles the reserved u octet
+		return netip.AddrFrom4([4]byte{b[6], b[7], b[9], b[10]}), true
+	case sixToFourPrefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+	return netip.Addr{}, false
+}
+
+// ParseHost parses a URL host as an IP address the way browsers and
+// inet_aton do, so numeric forms like "2130706433", "0x7f.1" and
+// "0177.0.0.1" are recognized as 127.0.0.1. It reports false for names.
+func ParseHost(host string) (netip.Addr, bool) {
+	host = strings.TrimSuffix(host, ".")
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr, true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	// Each part but the last is one byte; the last fills the remaining bytes
+	var value uint64
+	for i, part := range parts {
+		n, ok := parseNumericPart(part)
+		if !ok {
+			return netip.Addr{}, false
+		}
+
+		if i < len(parts)-1 {
+			if n > 0xff {
+				return netip.Addr{}, false
+			}
+			value |= n << (8 * uint(3-i))
+			continue
+		}
+
+		remaining := uint(4 - i)
+		if n >= 1<<(8*remaining) {
+			return netip.Addr{}, false
+		}
+		value |= n
+	}
+
+	return netip.AddrFrom4([4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}), true
+}
+
+// parseNumericPart parses a decimal, 0x-prefixed hex or 0-prefixed octal number
+func parseNumericPart(part string) (uint64, bool) {
+	if part == "" {
+		return 0, false
+	}
+
+	base := 10
+	lower := strings.ToLower(part)
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		base, lower = 16, lower[2:]
+		if lower == "" {
+			return 0, true // "0x" alone is zero
+		}
+	case len(lower) > 1 && lower[0] == '0':
+		base, lower = 8, lower[1:]
+	}
+
+	n, err := strconv.ParseUint(lower, base, 32)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,147 @@
+// Package ssrf keeps links and server-side fetches away from internal
+// addresses. Hosts are resolved through an injectable Resolver and every
+// address is checked, and Guard.DialContext connects only to the
+// addresses it checked so a host cannot be rebound between check and use.
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"backend/internal/models"
+)
+
+// ErrUnresolvable is wrapped when a host has no addresses. Strict guards
+// reject such hosts; callers re-checking existing links may ignore it, as
+// lookups also fail during DNS outages.
+var ErrUnresolvable = errors.New("host does not resolve")
+
+// Resolver looks up the addresses of a host. *net.Resolver implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// StaticResolver resolves hosts from a fixed map, for tests
+type StaticResolver map[string][]netip.Addr
+
+// LookupNetIP returns the addresses of host, or a not-found DNS error
+func (r StaticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if addrs, ok := r[strings.ToLower(host)]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// Hostnames that always refer to the local machine or network
+var (
+	internalHosts    = []string{"localhost"}
+	internalSuffixes = []string{".local", ".internal", ".localhost", ".localdomain"}
+)
+
+// Guard checks hosts against internal address ranges
+type Guard struct {
+	resolver Resolver
+	strict   bool
+}
+
+// NewGuard creates a guard using resolver, or net.DefaultResolver when nil.
+// A strict guard rejects hosts that fail to resolve instead of allowing them.
+func NewGuard(resolver Resolver, strict bool) *Guard {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &Guard{resolver: resolver, strict: strict}
+}
+
+// CheckURL checks the host of rawURL
+func (g *Guard) CheckURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %w", err)
+	}
+	_, err = g.Check(ctx, parsed.Hostname())
+	return err
+}
+
+// Check resolves host and returns its addresses. The error wraps
+// models.ErrSSRFDetected if the host is internal or, for a strict guard,
+// does not resolve.
+func (g *Guard) Check(ctx context.Context, host string) ([]netip.Addr, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "" {
+		return nil, fmt.Errorf("%w: empty host", models.ErrSSRFDetected)
+	}
+
+	// Numeric hosts, including forms like 2130706433 or 0x7f.1, are not looked up
+	if addr, ok := ParseHost(host); ok {
+		if IsBlockedAddr(addr) {
+			return nil, fmt.Errorf("%w: %s is an internal address", models.ErrSSRFDetected, addr)
+		}
+		return []netip.Addr{addr}, nil
+	}
+
+	for _, internal := range internalHosts {
+		if host == internal {
+			return nil, fmt.Errorf("%w: %s is an internal host", models.ErrSSRFDetected, host)
+		}
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil, fmt.Errorf("%w: %s is an internal host", models.ErrSSRFDetected, host)
+		}
+	}
+
+	addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("no addresses")
+	}
+	if err != nil {
+		if !g.strict {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%w: %w: %s: %v", models.ErrSSRFDetected, ErrUnresolvable, host, err)
+	}
+
+	// A single internal address is enough: the client picks which one to use
+	for _, addr := range addrs {
+		if IsBlockedAddr(addr) {
+			return nil, fmt.Errorf("%w: %s resolves to internal address %s", models.ErrSSRFDetected, host, addr.Unmap())
+		}
+	}
+	return addrs, nil
+}
+
+// DialContext resolves and checks the host of address, then connects to one
+// of the checked addresses. Use it as the DialContext of an http.Transport
+// for server-side fetches, so redirects and DNS rebinding cannot reach
+// internal services. Unresolvable hosts are always rejected here.
+func (g *Guard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := g.Check(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%w: %w: %s", models.ErrSSRFDetected, ErrUnresolvable, host)
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr.Unmap().String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,147 @@
+package ssrf
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"backend/internal/models"
+)
+
+func TestParseHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string // Empty when host is a name
+	}{
+		{"127.0.0.1", "127.0.0.1"},
+		{"2130706433", "127.0.0.1"},
+		{"0x7f.1", "127.0.0.1"},
+		{"0x7F000001", "127.0.0.1"},
+		{"0177.0.0.1", "127.0.0.1"},
+		{"127.1", "127.0.0.1"},
+		{"10.1.256", "10.1.1.0"},
+		{"169.254.43518", "169.254.169.254"},
+		{"127.0.0.1.", "127.0.0.1"},
+		{"[::1]", "::1"},
+		{"::ffff:127.0.0.1", "::ffff:127.0.0.1"},
+		{"example.com", ""},
+		{"1.2.3.4.5", ""},
+		{"256.0.0.1", ""},
+		{"4294967296", ""},
+		{"08.0.0.1", ""},
+		{"0x", "0.0.0.0"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		addr, ok := ParseHost(tt.host)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("ParseHost(%q) = %s, expected a name", tt.host, addr)
+			}
+			continue
+		}
+		if !ok || addr.String() != tt.expected {
+			t.Errorf("ParseHost(%q) = %s, %t, expected %s", tt.host, addr, ok, tt.expected)
+		}
+	}
+}
+
+func TestIsBlockedAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		blocked bool
+	}{
+		{"93.184.216.34", false},
+		{"2606:2800:220:1:248:1893:25c8:1946", false},
+		{"127.0.0.1", true},
+		{"10.0.0.1", true},
+		{"172.16.5.4", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"::1", true},
+		{"::", true},
+		{"fd00::1", true},
+		{"fe80::1%eth0", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:93.184.216.34", false},
+		{"64:ff9b::a00:1", true},         // NAT64 of 10.0.0.1
+		{"64:ff9b::5db8:d822", false},    // NAT64 of 93.184.216.34
+		{"64:ff9b:1:7f00:0:100::", true}, // Local-use NAT64 of 127.0.0.1
+		{"2002:c0a8:101::1", true},       // 6to4 of 192.168.1.1
+		{"2002:5db8:d822::1", false},     // 6to4 of 93.184.216.34
+	}
+
+	for _, tt := range tests {
+		if got := IsBlockedAddr(netip.MustParseAddr(tt.addr)); got != tt.blocked {
+			t.Errorf("IsBlockedAddr(%s) = %t, expected %t", tt.addr, got, tt.blocked)
+		}
+	}
+}
+
+func TestGuardCheckURL(t *testing.T) {
+	resolver := StaticResolver{
+		"public.test":   {netip.MustParseAddr("93.184.216.34")},
+		"internal.test": {netip.MustParseAddr("10.0.0.5")},
+		"mixed.test":    {netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("::ffff:127.0.0.1")},
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		strict      bool
+		expectedErr bool
+		unresolved  bool
+	}{
+		{"public host", "https://public.test/", true, false, false},
+		{"internal address", "https://internal.test/", false, true, false},
+		{"any internal address", "https://mixed.test/", false, true, false},
+		{"decimal loopback", "http://2130706433/", false, true, false},
+		{"hex loopback", "http://0x7f.1/", false, true, false},
+		{"metadata service", "http://169.254.169.254/latest/meta-data/", false, true, false},
+		{"mapped loopback", "http://[::ffff:7f00:1]/", false, true, false},
+		{"localhost", "http://LOCALHOST./", false, true, false},
+		{"internal suffix", "http://db.internal/", false, true, false},
+		{"public address", "http://93.184.216.34/", true, false, false},
+		{"unresolvable lenient", "https://missing.test/", false, false, false},
+		{"unresolvable strict", "https://missing.test/", true, true, true},
+	}
+
+	for _, tt := range tests {
+		err := NewGuard(resolver, tt.strict).CheckURL(context.Background(), tt.url)
+
+		if !tt.expectedErr {
+			if err != nil {
+				t.Errorf("%s: CheckURL(%s) unexpected error: %v", tt.name, tt.url, err)
+			}
+			continue
+		}
+		if !errors.Is(err, models.ErrSSRFDetected) {
+			t.Errorf("%s: CheckURL(%s) error = %v, expected ErrSSRFDetected", tt.name, tt.url, err)
+		}
+		if errors.Is(err, ErrUnresolvable) != tt.unresolved {
+			t.Errorf("%s: CheckURL(%s) error = %v, unresolvable expected %t", tt.name, tt.url, err, tt.unresolved)
+		}
+	}
+}
+
+func TestGuardDialContext(t *testing.T) {
+	guard := NewGuard(StaticResolver{"rebound.test": {netip.MustParseAddr("127.0.0.1")}}, false)
+
+	// Dialing always needs vetted addresses, even for a lenient guard
+	for _, address := range []string{"rebound.test:80", "missing.test:443", "[::1]:8080"} {
+		conn, err := guard.DialContext(context.Background(), "tcp", address)
+		if err == nil {
+			conn.Close()
+			t.Errorf("DialContext(%s) expected an error", address)
+			continue
+		}
+		if !errors.Is(err, models.ErrSSRFDetected) {
+			t.Errorf("DialContext(%s) error = %v, expected ErrSSRFDetected", address, err)
+		}
+	}
+}
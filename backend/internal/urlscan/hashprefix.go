@@ -0,0 +1,117 @@
+package urlscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// Accepted prefix lengths in bytes, from Safe Browsing's shortest to a full hash
+const (
+	minHashPrefixLength = 4
+	maxHashPrefixLength = sha256.Size
+)
+
+// HashPrefixList blocks URLs whose Safe Browsing URL expressions have a
+// listed SHA-256 hash prefix. Lists are used offline, so a prefix match
+// blocks without a full-hash confirmation; use long prefixes or full hashes
+// to avoid collisions.
+type HashPrefixList struct {
+	name     string
+	prefixes map[string]struct{} // Raw prefix bytes
+	lengths  []int               // Distinct prefix lengths in the list
+}
+
+// LoadHashPrefixList reads one hex-encoded hash prefix per line
+func LoadHashPrefixList(path string) (*HashPrefixList, error) {
+	list := &HashPrefixList{
+		name:     "hash prefix list " + path,
+		prefixes: make(map[string]struct{}),
+	}
+	seen := make(map[int]bool)
+
+	var invalid int
+	err := readLines(path, func(line string) {
+		prefix, err := hex.DecodeString(line)
+		if err != nil || len(prefix) < minHashPrefixLength || len(prefix) > maxHashPrefixLength {
+			invalid++
+			return
+		}
+		list.prefixes[string(prefix)] = struct{}{}
+		if !seen[len(prefix)] {
+			seen[len(prefix)] = true
+			list.lengths = append(list.lengths, len(prefix))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hash prefix list: %w", err)
+	}
+	if invalid > 0 {
+		log.Printf("[URLSCAN] WARNING: Skipped %d invalid hash prefixes in %s", invalid, path)
+	}
+
+	log.Printf("[URLSCAN] Loaded %s: %d prefixes", list.name, len(list.prefixes))
+	return list, nil
+}
+
+func (l *HashPrefixList) Name() string { return l.name }
+
+func (l *HashPrefixList) Scan(ctx context.Context, u *url.URL) (Result, error) {
+	for _, expr := range urlExpressions(u) {
+		sum := sha256.Sum256([]byte(expr))
+		for _, n := range l.lengths {
+			if _, ok := l.prefixes[string(sum[:n])]; ok {
+				return Result{Verdict: Block, Rule: fmt.Sprintf("%s (%s)", expr, hex.EncodeToString(sum[:n]))}, nil
+			}
+		}
+	}
+	return Result{}, nil
+}
+
+// urlExpressions returns the host suffix / path prefix combinations Safe
+// Browsing hashes for a URL: up to five hosts times up to six paths
+func urlExpressions(u *url.URL) []string {
+	host := canonicalHost(u.Hostname())
+
+	urlPath := u.EscapedPath()
+	if urlPath == "" {
+		urlPath = "/"
+	}
+
+	var paths []string
+	if u.RawQuery != "" {
+		paths = append(paths, urlPath+"?"+u.RawQuery)
+	}
+	paths = append(paths, urlPath)
+
+	// The root and up to three more leading directories
+	var dirs []string
+	if trimmed := strings.Trim(urlPath, "/"); trimmed != "" {
+		dirs = strings.Split(trimmed, "/")
+		if !strings.HasSuffix(urlPath, "/") {
+			dirs = dirs[:len(dirs)-1] // The last segment is a file
+		}
+	}
+	prefix := "/"
+	for i := 0; i < 4; i++ {
+		if prefix != urlPath {
+			paths = append(paths, prefix)
+		}
+		if i >= len(dirs) {
+			break
+		}
+		prefix += dirs[i] + "/"
+	}
+
+	var expressions []string
+	for _, h := range hostSuffixes(host, true) {
+		for _, p := range paths {
+			expressions = append(expressions, h+p)
+		}
+	}
+	return expressions
+}
@@ -0,0 +1,184 @@
+package urlscan
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// DomainList blocks or allows hosts and URL prefixes read from a file
+type DomainList struct {
+	name     string
+	verdict  Verdict
+	domains  map[string]struct{}
+	prefixes []string // host + path prefixes, without scheme
+}
+
+// LoadBlocklist reads a blocklist. Each line is a hosts-file entry
+// ("0.0.0.0 ads.example"), a domain, or a URL prefix
+// ("https://example.com/phish/"). A domain also blocks its subdomains.
+func LoadBlocklist(path string) (*DomainList, error) {
+	return loadDomainList("blocklist", path, Block)
+}
+
+// LoadAllowlist reads an allowlist in the blocklist format. Allowed URLs
+// skip every later scanner.
+func LoadAllowlist(path string) (*DomainList, error) {
+	return loadDomainList("allowlist", path, Allow)
+}
+
+func loadDomainList(kind, path string, verdict Verdict) (*DomainList, error) {
+	list := &DomainList{
+		name:    fmt.Sprintf("%s %s", kind, path),
+		verdict: verdict,
+		domains: make(map[string]struct{}),
+	}
+
+	err := readLines(path, func(line string) {
+		fields := strings.Fields(line)
+
+		// Hosts-file entries start with the address the names resolve to
+		if len(fields) > 1 {
+			if _, err := netip.ParseAddr(fields[0]); err == nil {
+				for _, host := range fields[1:] {
+					list.addDomain(host)
+				}
+				return
+			}
+		}
+
+		for _, entry := range fields {
+			if strings.Contains(entry, "/") {
+				list.addPrefix(entry)
+			} else {
+				list.addDomain(entry)
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", kind, err)
+	}
+
+	log.Printf("[URLSCAN] Loaded %s: %d domains, %d URL prefixes", list.name, len(list.domains), len(list.prefixes))
+	return list, nil
+}
+
+// addDomain adds a host, skipping the loopback names hosts files define
+func (l *DomainList) addDomain(host string) {
+	host = canonicalHost(host)
+	if !strings.Contains(host, ".") || host == "localhost.localdomain" {
+		return
+	}
+	l.domains[host] = struct{}{}
+}
+
+func (l *DomainList) addPrefix(entry string) {
+	if !strings.Contains(entry, "://") {
+		entry = "http://" + entry
+	}
+	u, err := url.Parse(entry)
+	if err != nil || u.Host == "" {
+		return
+	}
+	l.prefixes = append(l.prefixes, canonicalHost(u.Hostname())+u.EscapedPath())
+}
+
+func (l *DomainList) Name() string { return l.name }
+
+func (l *DomainList) Scan(ctx context.Context, u *url.URL) (Result, error) {
+	host := canonicalHost(u.Hostname())
+
+	for _, domain := range hostSuffixes(host, false) {
+		if _, ok := l.domains[domain]; ok {
+			return Result{Verdict: l.verdict, Rule: domain}, nil
+		}
+	}
+
+	target := host + u.EscapedPath()
+	for _, prefix := range l.prefixes {
+		if strings.HasPrefix(target, prefix) {
+			return Result{Verdict: l.verdict, Rule: prefix}, nil
+		}
+	}
+
+	return Result{}, nil
+}
+
+// ExtensionRules blocks URLs whose path ends in one of a set of file extensions
+type ExtensionRules struct {
+	extensions map[string]struct{}
+}
+
+// NewExtensionRules creates rules for extensions given with or without the dot
+func NewExtensionRules(extensions []string) *ExtensionRules {
+	rules := &ExtensionRules{extensions: make(map[string]struct{}, len(extensions))}
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			rules.extensions[ext] = struct{}{}
+		}
+	}
+	return rules
+}
+
+func (r *ExtensionRules) Name() string { return "extension rules" }
+
+func (r *ExtensionRules) Scan(ctx context.Context, u *url.URL) (Result, error) {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if _, ok := r.extensions[ext]; ok && ext != "" {
+		return Result{Verdict: Block, Rule: "." + ext}, nil
+	}
+	return Result{}, nil
+}
+
+// canonicalHost lowercases a host and strips its trailing dot
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// hostSuffixes returns host followed by its parent domains, excluding the
+// top-level domain. IP addresses have no parents. With limit set, only the
+// last five components are used, as Safe Browsing does.
+func hostSuffixes(host string, limit bool) []string {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return []string{host}
+	}
+
+	suffixes := []string{host}
+	labels := strings.Split(host, ".")
+	start := 1
+	if limit && len(labels) > 5 {
+		start = len(labels) - 5
+	}
+	for i := start; i < len(labels)-1; i++ {
+		suffixes = append(suffixes, strings.Join(labels[i:], "."))
+	}
+	return suffixes
+}
+
+// readLines calls fn for every non-empty line of a file, with # comments removed
+func readLines(path string, fn func(line string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
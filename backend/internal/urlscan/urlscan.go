@@ -0,0 +1,189 @@
+package urlscan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"backend/internal/models"
+)
+
+// Verdict is the outcome of one scanner
+type Verdict int
+
+const (
+	NoMatch Verdict = iota // Defer to the next scanner
+	Allow                  // Trust the URL, skipping later scanners
+	Block                  // Reject the URL
+)
+
+// Result is a scanner's verdict and the rule that produced it
+type Result struct {
+	Verdict Verdict
+	Rule    string // The matching entry, empty for NoMatch
+}
+
+// URLScanner inspects a parsed, normalized target URL
+type URLScanner interface {
+	// Name identifies the scanner and its source in errors and logs
+	Name() string
+	Scan(ctx context.Context, u *url.URL) (Result, error)
+}
+
+// BlockedError reports the scanner and rule that rejected a URL. It wraps
+// models.ErrMaliciousURL.
+type BlockedError struct {
+	Scanner string
+	Rule    string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%v: %s matched %s", models.ErrMaliciousURL, e.Scanner, e.Rule)
+}
+
+func (e *BlockedError) Unwrap() error {
+	return models.ErrMaliciousURL
+}
+
+// Config lists the rule files and extension rules of a Chain
+type Config struct {
+	Blocklists        []string // Hosts-file or plain domain/URL lists
+	HashPrefixLists   []string // Hex SHA-256 hash prefixes of Safe Browsing URL expressions
+	Allowlists        []string // Domains exempt from every other rule
+	BlockedExtensions []string // File extensions of blocked paths, e.g. "exe"
+}
+
+// DefaultBlockedExtensions are executable and script file types
+var DefaultBlockedExtensions = []string{"exe", "bat", "cmd", "scr", "com", "pif", "vbs", "msi"}
+
+// Chain runs scanners in order until one allows or blocks the URL. It is
+// safe for concurrent use, and Reload swaps in freshly loaded lists
+// atomically.
+type Chain struct {
+	config   Config
+	scanners atomic.Pointer[[]URLScanner]
+	reloadMu sync.Mutex
+}
+
+// New creates a chain with the built-in rules of cfg. Its list files are
+// read by Reload.
+func New(cfg Config) *Chain {
+	c := &Chain{config: cfg}
+	builtin := c.builtinScanners()
+	c.scanners.Store(&builtin)
+	return c
+}
+
+// NewChain creates a chain of the given scanners, for callers that build
+// their own
+func NewChain(scanners ...URLScanner) *Chain {
+	c := &Chain{}
+	c.scanners.Store(&scanners)
+	return c
+}
+
+// builtinScanners returns the rules that need no files
+func (c *Chain) builtinScanners() []URLScanner {
+	scanners := []URLScanner{scriptInjection{}}
+	if len(c.config.BlockedExtensions) > 0 {
+		scanners = append(scanners, NewExtensionRules(c.config.BlockedExtensions))
+	}
+	return scanners
+}
+
+// Reload reads every list file of the chain's config. On any error the
+// current scanners are kept, so a bad edit never drops protection.
+func (c *Chain) Reload() error {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	var scanners []URLScanner
+	var errs []error
+
+	// Allowlists run first so they can exempt a URL from every later rule
+	for _, path := range c.config.Allowlists {
+		list, err := LoadAllowlist(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scanners = append(scanners, list)
+	}
+	scanners = append(scanners, c.builtinScanners()...)
+	for _, path := range c.config.Blocklists {
+		list, err := LoadBlocklist(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scanners = append(scanners, list)
+	}
+	for _, path := range c.config.HashPrefixLists {
+		list, err := LoadHashPrefixList(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		scanners = append(scanners, list)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		log.Printf("[URLSCAN] ERROR: Reload failed, keeping current rules: %v", err)
+		return err
+	}
+
+	c.scanners.Store(&scanners)
+	log.Printf("[URLSCAN] SUCCESS: Loaded %d scanners", len(scanners))
+	return nil
+}
+
+// Scan checks a normalized target URL against the chain. It returns a
+// *BlockedError when a scanner blocks it. A scanner that fails is skipped,
+// so an unavailable source never blocks every link.
+func (c *Chain) Scan(ctx context.Context, targetURL string) error {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidURL, err)
+	}
+
+	for _, scanner := range *c.scanners.Load() {
+		result, err := scanner.Scan(ctx, u)
+		if err != nil {
+			log.Printf("[URLSCAN] WARNING: Scanner %s failed, skipping: %v", scanner.Name(), err)
+			continue
+		}
+
+		switch result.Verdict {
+		case Allow:
+			return nil
+		case Block:
+			log.Printf("[URLSCAN] Blocked %s: %s matched %s", u.Host, scanner.Name(), result.Rule)
+			return &BlockedError{Scanner: scanner.Name(), Rule: result.Rule}
+		}
+	}
+	return nil
+}
+
+// scriptInjection blocks script URLs smuggled into an http(s) URL, for
+// example as a redirect parameter
+type scriptInjection struct{}
+
+func (scriptInjection) Name() string { return "script-injection" }
+
+func (scriptInjection) Scan(ctx context.Context, u *url.URL) (Result, error) {
+	raw := strings.ToLower(u.String())
+	if decoded, err := url.QueryUnescape(raw); err == nil {
+		raw = decoded
+	}
+	for _, scheme := range []string{"javascript:", "vbscript:", "data:"} {
+		if strings.Contains(raw, scheme) {
+			return Result{Verdict: Block, Rule: scheme}, nil
+		}
+	}
+	return Result{}, nil
+}
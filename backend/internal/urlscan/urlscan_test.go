@@ -0,0 +1,161 @@
+package urlscan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"backend/internal/models"
+)
+
+// writeHashList writes a list of the hash prefixes of expressions
+func writeHashList(t *testing.T, prefixLength int, expressions ...string) string {
+	t.Helper()
+
+	var content string
+	for _, expr := range expressions {
+		sum := sha256.Sum256([]byte(expr))
+		content += hex.EncodeToString(sum[:prefixLength]) + "\n"
+	}
+	content += "not-hex\n"
+
+	path := filepath.Join(t.TempDir(), "prefixes.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write hash list: %v", err)
+	}
+	return path
+}
+
+func TestChainScan(t *testing.T) {
+	chain := New(Config{
+		Blocklists:        []string{"testdata/hosts.txt"},
+		HashPrefixLists:   []string{writeHashList(t, 4, "hashed.test/evil/")},
+		Allowlists:        []string{"testdata/allow.txt"},
+		BlockedExtensions: DefaultBlockedExtensions,
+	})
+	if err := chain.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		scanner string // Empty when the URL is allowed
+		rule    string
+	}{
+		{"clean", "https://example.com/page", "", ""},
+		{"hosts entry", "https://malware.test/", "blocklist testdata/hosts.txt", "malware.test"},
+		{"subdomain of hosts entry", "https://cdn.tracker.test/x.js", "blocklist testdata/hosts.txt", "tracker.test"},
+		{"plain domain", "http://badsite.test/login", "blocklist testdata/hosts.txt", "badsite.test"},
+		{"loopback names ignored", "http://localhost.example.com/", "", ""},
+		{"URL prefix", "https://files.example/payloads/a.pdf", "blocklist testdata/hosts.txt", "files.example/payloads/"},
+		{"outside URL prefix", "https://files.example/docs/a.pdf", "", ""},
+		{"allowlisted subdomain", "https://safe.malware.test/setup.exe", "", ""},
+		{"hash prefix", "https://www.hashed.test/evil/page.html?id=1", "hash prefix list", "hashed.test/evil/"},
+		{"hash prefix other path", "https://hashed.test/good/", "", ""},
+		{"executable", "https://example.com/malware.EXE", "extension rules", ".exe"},
+		{"zip is allowed", "https://example.com/archive.zip", "", ""},
+		{"script injection", "https://example.com/?next=javascript:alert(1)", "script-injection", "javascript:"},
+	}
+
+	for _, tt := range tests {
+		err := chain.Scan(context.Background(), tt.url)
+
+		if tt.scanner == "" {
+			if err != nil {
+				t.Errorf("%s: Scan(%s) unexpected error: %v", tt.name, tt.url, err)
+			}
+			continue
+		}
+
+		var blocked *BlockedError
+		if !errors.As(err, &blocked) {
+			t.Errorf("%s: Scan(%s) error = %v, expected a BlockedError", tt.name, tt.url, err)
+			continue
+		}
+		if !errors.Is(err, models.ErrMaliciousURL) {
+			t.Errorf("%s: error does not wrap ErrMaliciousURL", tt.name)
+		}
+		if !strings.HasPrefix(blocked.Scanner, tt.scanner) {
+			t.Errorf("%s: scanner = %q, expected %q", tt.name, blocked.Scanner, tt.scanner)
+		}
+		if !strings.HasPrefix(blocked.Rule, tt.rule) {
+			t.Errorf("%s: rule = %q, expected %q", tt.name, blocked.Rule, tt.rule)
+		}
+	}
+}
+
+func TestChainReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("first.test\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write blocklist: %v", err)
+	}
+
+	chain := New(Config{Blocklists: []string{path}})
+
+	// Lists apply only once loaded
+	if err := chain.Scan(context.Background(), "https://first.test/"); err != nil {
+		t.Errorf("Scan() before Reload() unexpected error: %v", err)
+	}
+	if err := chain.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if err := chain.Scan(context.Background(), "https://first.test/"); err == nil {
+		t.Error("Scan() after Reload() expected first.test to be blocked")
+	}
+
+	if err := os.WriteFile(path, []byte("second.test\n"), 0o644); err != nil {
+		t.Fatalf("Failed to rewrite blocklist: %v", err)
+	}
+	if err := chain.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error: %v", err)
+	}
+	if err := chain.Scan(context.Background(), "https://first.test/"); err != nil {
+		t.Errorf("Scan() after edit unexpected error: %v", err)
+	}
+
+	// A failed reload keeps the previous lists
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Failed to remove blocklist: %v", err)
+	}
+	if err := chain.Reload(); err == nil {
+		t.Error("Reload() of a missing file expected an error")
+	}
+	if err := chain.Scan(context.Background(), "https://second.test/"); err == nil {
+		t.Error("Scan() after failed Reload() expected second.test to stay blocked")
+	}
+}
+
+func TestURLExpressions(t *testing.T) {
+	u, _ := url.Parse("http://a.b.c.d.e.f.g/1/2.html?param=1")
+
+	expected := []string{
+		"a.b.c.d.e.f.g/1/2.html?param=1",
+		"a.b.c.d.e.f.g/1/2.html",
+		"a.b.c.d.e.f.g/",
+		"a.b.c.d.e.f.g/1/",
+		"c.d.e.f.g/1/2.html?param=1",
+		"f.g/1/",
+	}
+	expressions := urlExpressions(u)
+	for _, expr := range expected {
+		if !slices.Contains(expressions, expr) {
+			t.Errorf("urlExpressions() is missing %s", expr)
+		}
+	}
+
+	// Five hosts (exact + four suffixes) times four paths
+	if len(expressions) != 20 {
+		t.Errorf("urlExpressions() returned %d expressions, expected 20: %v", len(expressions), expressions)
+	}
+	if slices.Contains(expressions, "g/") || slices.Contains(expressions, "b.c.d.e.f.g/") {
+		t.Errorf("urlExpressions() includes a host outside the last five components: %v", expressions)
+	}
+}
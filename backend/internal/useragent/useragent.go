@@ -0,0 +1,252 @@
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Device types
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// Unknown is used when a browser or OS cannot be identified
+const Unknown = "Other"
+
+// Agent is the parsed form of a User-Agent header
+type Agent struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os"`
+	DeviceType     string `json:"device_type"`
+	IsBot          bool   `json:"is_bot"`
+}
+
+// matcher maps a UA token to a product name. The version, if any,
+// is read from the characters following the token.
+type matcher struct {
+	token string
+	name  string
+}
+
+// Bot signatures, checked case-insensitively before anything else.
+// Link unfurlers and HTTP libraries are included since they never
+// represent a human click.
+var botMatchers = []matcher{
+	{"googlebot/", "Googlebot"},
+	{"bingbot/", "Bingbot"},
+	{"yandexbot/", "YandexBot"},
+	{"duckduckbot", "DuckDuckBot"},
+	{"baiduspider", "Baiduspider"},
+	{"applebot/", "Applebot"},
+	{"facebookexternalhit/", "Facebook"},
+	{"facebookcatalog/", "Facebook"},
+	{"meta-externalagent/", "Facebook"},
+	{"twitterbot/", "Twitterbot"},
+	{"slackbot-linkexpanding", "Slackbot"},
+	{"slack-imgproxy", "Slackbot"},
+	{"slackbot", "Slackbot"},
+	{"linkedinbot/", "LinkedInBot"},
+	{"discordbot/", "Discordbot"},
+	{"telegrambot", "TelegramBot"},
+	{"whatsapp/", "WhatsApp"},
+	{"skypeuripreview", "Skype"},
+	{"pinterestbot/", "Pinterestbot"},
+	{"redditbot/", "Redditbot"},
+	{"embedly", "Embedly"},
+	{"iframely/", "Iframely"},
+	{"headlesschrome/", "HeadlessChrome"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"python-requests/", "python-requests"},
+	{"python-urllib/", "Python-urllib"},
+	{"go-http-client/", "Go-http-client"},
+	{"okhttp/", "okhttp"},
+	{"axios/", "axios"},
+	{"node-fetch/", "node-fetch"},
+	{"java/", "Java"},
+	{"libwww-perl/", "libwww-perl"},
+}
+
+// Bots that fetch a page to render a link preview in a post or chat
+var unfurlers = map[string]bool{
+	"Facebook":     true,
+	"Twitterbot":   true,
+	"Slackbot":     true,
+	"LinkedInBot":  true,
+	"Discordbot":   true,
+	"TelegramBot":  true,
+	"WhatsApp":     true,
+	"Skype":        true,
+	"Pinterestbot": true,
+	"Redditbot":    true,
+	"Embedly":      true,
+	"Iframely":     true,
+}
+
+// Generic bot markers used when no specific signature matched, applied to
+// the lowercased header. "bot" only counts as a word or a product name
+// ending in it, and "preview" only as a link preview fetcher, so devices and
+// apps such as Cubot, Abbott or Safari Technology Preview are not flagged.
+var genericBotMarkers = regexp.MustCompile(`\bbot\b|[a-z]bot/|crawl|spider|slurp|scanner|monitor|(link|url|page)[ _-]?preview`)
+
+// Browser signatures in priority order. Chromium-based browsers all
+// advertise "Chrome/" and "Safari/", so the more specific tokens must
+// come first.
+var browserMatchers = []matcher{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"Edge/", "Edge"},
+	{"OPR/", "Opera"},
+	{"OPiOS/", "Opera"},
+	{"Opera/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"YaBrowser/", "Yandex Browser"},
+	{"Vivaldi/", "Vivaldi"},
+	{"UCBrowser/", "UC Browser"},
+	{"DuckDuckGo/", "DuckDuckGo"},
+	{"FxiOS/", "Firefox"},
+	{"Firefox/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chromium/", "Chromium"},
+	{"Chrome/", "Chrome"},
+	{"PostmanRuntime/", "Postman"},
+	{"MSIE ", "Internet Explorer"},
+	{"Trident/", "Internet Explorer"},
+}
+
+// Parse parses a User-Agent header into browser, OS and device information
+func Parse(ua string) Agent {
+	ua = strings.TrimSpace(ua)
+	if ua == "" {
+		return Agent{Browser: Unknown, OS: Unknown, DeviceType: DeviceOther}
+	}
+
+	agent := Agent{OS: parseOS(ua)}
+
+	if name, version, ok := parseBot(ua); ok {
+		agent.Browser = name
+		agent.BrowserVersion = version
+		agent.IsBot = true
+		agent.DeviceType = DeviceBot
+		return agent
+	}
+
+	agent.Browser, agent.BrowserVersion = parseBrowser(ua)
+	agent.DeviceType = parseDevice(ua, agent.OS)
+	return agent
+}
+
+// IsBot reports whether a User-Agent header belongs to a bot or HTTP library
+func IsBot(ua string) bool {
+	_, _, ok := parseBot(strings.TrimSpace(ua))
+	return ok
+}
+
+// IsUnfurler reports whether a User-Agent header belongs to a social network
+// or chat app fetching a link preview
+func IsUnfurler(ua string) bool {
+	name, _, ok := parseBot(strings.TrimSpace(ua))
+	return ok && unfurlers[name]
+}
+
+// parseBot matches bot signatures
+func parseBot(ua string) (string, string, bool) {
+	if ua == "" {
+		return "", "", false
+	}
+
+	lower := strings.ToLower(ua)
+	for _, m := range botMatchers {
+		if idx := strings.Index(lower, m.token); idx >= 0 {
+			return m.name, versionAfter(ua, idx+len(m.token)), true
+		}
+	}
+
+	if genericBotMarkers.MatchString(lower) {
+		return "Other Bot", "", true
+	}
+
+	return "", "", false
+}
+
+// parseBrowser identifies the browser and its version
+func parseBrowser(ua string) (string, string) {
+	for _, m := range browserMatchers {
+		if idx := strings.Index(ua, m.token); idx >= 0 {
+			version := versionAfter(ua, idx+len(m.token))
+			if m.token == "Trident/" {
+				// Trident/7.0 is IE 11; the real version is in "rv:"
+				if rv := strings.Index(ua, "rv:"); rv >= 0 {
+					version = versionAfter(ua, rv+3)
+				}
+			}
+			return m.name, version
+		}
+	}
+
+	// Safari only identifies itself through "Version/x Safari/y"
+	if strings.Contains(ua, "Safari/") && strings.Contains(ua, "AppleWebKit/") {
+		version := ""
+		if idx := strings.Index(ua, "Version/"); idx >= 0 {
+			version = versionAfter(ua, idx+len("Version/"))
+		}
+		return "Safari", version
+	}
+
+	return Unknown, ""
+}
+
+// parseOS identifies the operating system
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "Windows Phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "Windows"):
+		return "Windows"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return "iOS"
+	case strings.Contains(ua, "Android"):
+		return "Android"
+	case strings.Contains(ua, "CrOS"):
+		return "ChromeOS"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "Linux"), strings.Contains(ua, "X11"):
+		return "Linux"
+	default:
+		return Unknown
+	}
+}
+
+// parseDevice classifies the device from UA hints and the detected OS
+func parseDevice(ua, os string) string {
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"):
+		return DeviceTablet
+	case os == "Android" && !strings.Contains(ua, "Mobile"):
+		// Android tablets omit the "Mobile" token
+		return DeviceTablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"),
+		os == "Windows Phone", os == "Android":
+		return DeviceMobile
+	case os == "Windows", os == "macOS", os == "Linux", os == "ChromeOS":
+		return DeviceDesktop
+	default:
+		return DeviceOther
+	}
+}
+
+// versionAfter reads a dotted version number starting at offset
+func versionAfter(ua string, offset int) string {
+	end := offset
+	for end < len(ua) && (ua[end] == '.' || (ua[end] >= '0' && ua[end] <= '9')) {
+		end++
+	}
+	return strings.TrimRight(ua[offset:end], ".")
+}
@@ -0,0 +1,187 @@
+package useragent
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		ua       string
+		expected Agent
+	}{
+		{
+			name:     "chrome on windows",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36",
+			expected: Agent{Browser: "Chrome", BrowserVersion: "120.0.6099.109", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "edge is not chrome",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			expected: Agent{Browser: "Edge", BrowserVersion: "120.0.2210.91", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "legacy edge",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19582",
+			expected: Agent{Browser: "Edge", BrowserVersion: "18.19582", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "opera is not chrome",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			expected: Agent{Browser: "Opera", BrowserVersion: "105.0.0.0", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "firefox on linux",
+			ua:       "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expected: Agent{Browser: "Firefox", BrowserVersion: "121.0", OS: "Linux", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "safari on macos",
+			ua:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			expected: Agent{Browser: "Safari", BrowserVersion: "17.2", OS: "macOS", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "safari on iphone",
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			expected: Agent{Browser: "Safari", BrowserVersion: "17.2", OS: "iOS", DeviceType: DeviceMobile},
+		},
+		{
+			name:     "chrome on iphone",
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			expected: Agent{Browser: "Chrome", BrowserVersion: "120.0.6099.119", OS: "iOS", DeviceType: DeviceMobile},
+		},
+		{
+			name:     "safari on ipad",
+			ua:       "Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			expected: Agent{Browser: "Safari", BrowserVersion: "17.2", OS: "iOS", DeviceType: DeviceTablet},
+		},
+		{
+			name:     "chrome on android phone",
+			ua:       "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			expected: Agent{Browser: "Chrome", BrowserVersion: "120.0.6099.144", OS: "Android", DeviceType: DeviceMobile},
+		},
+		{
+			name:     "samsung internet on android tablet",
+			ua:       "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Safari/537.36",
+			expected: Agent{Browser: "Samsung Internet", BrowserVersion: "23.0", OS: "Android", DeviceType: DeviceTablet},
+		},
+		{
+			name:     "internet explorer 11",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			expected: Agent{Browser: "Internet Explorer", BrowserVersion: "11.0", OS: "Windows", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "chromeos",
+			ua:       "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expected: Agent{Browser: "Chrome", BrowserVersion: "120.0.0.0", OS: "ChromeOS", DeviceType: DeviceDesktop},
+		},
+		{
+			name:     "postman",
+			ua:       "PostmanRuntime/7.36.0",
+			expected: Agent{Browser: "Postman", BrowserVersion: "7.36.0", OS: Unknown, DeviceType: DeviceOther},
+		},
+		{
+			name:     "googlebot",
+			ua:       "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: Agent{Browser: "Googlebot", BrowserVersion: "2.1", OS: Unknown, DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "slack unfurler",
+			ua:       "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			expected: Agent{Browser: "Slackbot", OS: Unknown, DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "facebook unfurler",
+			ua:       "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			expected: Agent{Browser: "Facebook", BrowserVersion: "1.1", OS: Unknown, DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "curl",
+			ua:       "curl/8.4.0",
+			expected: Agent{Browser: "curl", BrowserVersion: "8.4.0", OS: Unknown, DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "headless chrome",
+			ua:       "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.6099.71 Safari/537.36",
+			expected: Agent{Browser: "HeadlessChrome", BrowserVersion: "120.0.6099.71", OS: "Linux", DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "generic crawler",
+			ua:       "Mozilla/5.0 (compatible; ExampleCrawler/1.0)",
+			expected: Agent{Browser: "Other Bot", OS: Unknown, DeviceType: DeviceBot, IsBot: true},
+		},
+		{
+			name:     "empty",
+			ua:       "",
+			expected: Agent{Browser: Unknown, OS: Unknown, DeviceType: DeviceOther},
+		},
+		{
+			name:     "unrecognized",
+			ua:       "Mozilla/5.0",
+			expected: Agent{Browser: Unknown, OS: Unknown, DeviceType: DeviceOther},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.ua)
+			if got != tt.expected {
+				t.Errorf("Parse(%q)\n got      %+v\n expected %+v", tt.ua, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		ua       string
+		expected bool
+	}{
+		{"Twitterbot/1.0", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"Go-http-client/1.1", true},
+		{"Mozilla/5.0 (compatible; ExampleBot/1.0)", true},
+		{"Mozilla/5.0 (compatible; bot; +https://example.com)", true},
+		{"LinkPreview/2.0", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", false},
+		{"Mozilla/5.0 (Linux; Android 10; CUBOT P50) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", false},
+		{"AbbottLibreLink/4.7 CFNetwork/1410.0.3 Darwin/22.6.0", false},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15 Safari Technology Preview", false},
+		{"Mozilla/5.0 (Linux; Android 14; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.0.0 Mobile Safari/537.36 PreviewBuild/3", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsBot(tt.ua); got != tt.expected {
+			t.Errorf("IsBot(%q) = %v, expected %v", tt.ua, got, tt.expected)
+		}
+	}
+}
+
+func TestIsUnfurler(t *testing.T) {
+	tests := []struct {
+		ua       string
+		expected bool
+	}{
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", false},
+		{"curl/8.4.0", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsUnfurler(tt.ua); got != tt.expected {
+			t.Errorf("IsUnfurler(%q) = %v, expected %v", tt.ua, got, tt.expected)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	ua := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91"
+	for i := 0; i < b.N; i++ {
+		Parse(ua)
+	}
+}